@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"chip8-go/chip8"
+)
+
+// capture saves screenshots and GIF recordings of a run. F8 takes a screenshot and F9 starts
+// and stops a recording, both named after the ROM. Its methods run on the chip goroutine.
+type capture struct {
+	chip    *chip8.Chip8
+	palette chip8.Palette
+	scale   int
+	fps     int
+
+	// Screenshots and recordings from the hotkeys are named after this path, numbered.
+	base string
+
+	// The recording in progress, if any, where it is saved and how many frames it lasts,
+	// 0 until stopped.
+	recorder *chip8.Recorder
+	path     string
+	limit    int
+}
+
+// newCapture returns a capture of chip, naming its files after rom.
+func newCapture(chip *chip8.Chip8, rom string, palette chip8.Palette, scale, fps int) *capture {
+
+	c := &capture{
+		chip:    chip,
+		palette: palette,
+		scale:   max(scale, 1),
+		fps:     fps,
+		base:    strings.TrimSuffix(rom, filepath.Ext(rom)),
+	}
+
+	chip.OnFrame = c.frame
+
+	return c
+
+}
+
+// record starts recording to path, for the given number of seconds or until stopped if 0.
+func (c *capture) record(path string, seconds float64) string {
+
+	c.recorder = chip8.NewRecorder(c.palette, c.scale, c.fps)
+	c.path = path
+	c.limit = int(seconds * 60)
+
+	return "recording to " + path
+
+}
+
+// stop ends the recording in progress and writes it.
+func (c *capture) stop() (string, error) {
+
+	recorder, path := c.recorder, c.path
+	c.recorder = nil
+
+	var b bytes.Buffer
+	if err := recorder.WriteGIF(&b); err != nil {
+		return "", fmt.Errorf("could not encode recording: %w", err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("recording of %.1fs saved to %s", float64(recorder.Frames())/60, path), nil
+
+}
+
+// stopAndLog stops the recording in progress, logging the outcome.
+func (c *capture) stopAndLog() {
+
+	msg, err := c.stop()
+
+	if logger := c.chip.Logger(); logger != nil {
+		if err != nil {
+			logger.Error(err.Error())
+		} else {
+			logger.Info(msg)
+		}
+	}
+
+}
+
+// toggleRecording starts or stops a recording and returns a message for the user.
+func (c *capture) toggleRecording() string {
+
+	if c.recorder != nil {
+		msg, err := c.stop()
+		if err != nil {
+			return err.Error()
+		}
+		return msg
+	}
+
+	return c.record(nextCaptureName(c.base, ".gif"), 0)
+
+}
+
+// screenshot saves the display as a PNG and returns a message for the user.
+func (c *capture) screenshot() string {
+
+	frame := c.chip.Display()
+	path := nextCaptureName(c.base, ".png")
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, frame.Image(c.palette, c.scale)); err != nil {
+		return err.Error()
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		return err.Error()
+	}
+
+	return "screenshot saved to " + path
+
+}
+
+// frame records a frame, stopping the recording once it lasted its limit.
+func (c *capture) frame(frame chip8.Frame) {
+
+	if c.recorder == nil {
+		return
+	}
+
+	c.recorder.Frame(frame)
+
+	if c.limit > 0 && c.recorder.Frames() >= c.limit {
+		c.stopAndLog()
+	}
+
+}
+
+// finish writes the recording in progress when the run ends.
+func (c *capture) finish() {
+	if c.recorder != nil {
+		c.stopAndLog()
+	}
+}
+
+// nextCaptureName returns the first free name base-1.ext, base-2.ext and so on.
+func nextCaptureName(base, ext string) string {
+
+	for n := 1; ; n++ {
+		path := fmt.Sprintf("%s-%d%s", base, n, ext)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+	}
+
+}
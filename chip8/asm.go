@@ -0,0 +1,515 @@
+package chip8
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Assemble compiles CHIP-8 assembly into a ROM to be loaded at DefaultLoadAddress.
+//
+// The source uses the mnemonics printed by DisassembleOpcode, one instruction per line:
+//
+//	start:  CLS
+//	        LD I, sprite      ; labels can be used wherever an address or byte is expected
+//	        DRW V0, V1, 5
+//	        JP start
+//	sprite: DB 0xF0, 0x90, 0xF0, 0x90, 0xF0
+//
+// Mnemonics and registers are case-insensitive, labels are not. Numbers are decimal, 0x hex
+// or 0b binary, and operands can add or subtract them, e.g. sprite+5. DB and DW emit bytes
+// and big-endian words. LD I takes the 4-byte XO-CHIP form F000 NNNN when written
+// LD I, LONG addr or when its address is above 0xFFF, and the 4-byte Megachip form
+// 01NN NNNN when its address is above 0xFFFF.
+func Assemble(src string) ([]byte, error) {
+
+	asm := &assembler{labels: map[string]int{}}
+
+	lines := strings.Split(src, "\n")
+
+	// The first pass only collects label addresses, the second encodes with all of them known.
+	for pass := 1; pass <= 2; pass++ {
+		asm.final = pass == 2
+		asm.rom = asm.rom[:0]
+
+		for n, line := range lines {
+			if err := asm.line(line); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+		}
+	}
+
+	return asm.rom, nil
+
+}
+
+// assembler holds the state of an Assemble pass.
+type assembler struct {
+	labels map[string]int
+	rom    []byte
+
+	// Set on the second pass, when undefined labels are an error.
+	final bool
+}
+
+// address is where the next byte is assembled.
+func (asm *assembler) address() int {
+	return DefaultLoadAddress + len(asm.rom)
+}
+
+// line assembles a single source line.
+func (asm *assembler) line(line string) error {
+
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.TrimSpace(line)
+
+	// Labels, any number of them, before the instruction.
+	for {
+		i := strings.IndexByte(line, ':')
+		if i < 0 || strings.ContainsAny(line[:i], " \t,") {
+			break
+		}
+
+		name := line[:i]
+		if !validLabel(name) {
+			return fmt.Errorf("invalid label %q", name)
+		}
+		if !asm.final {
+			if _, ok := asm.labels[name]; ok {
+				return fmt.Errorf("label %q defined twice", name)
+			}
+			asm.labels[name] = asm.address()
+		} else if asm.labels[name] != asm.address() {
+			// An earlier LD I to a label defined further down turned out to need 4 bytes.
+			return fmt.Errorf("label %q moved, write LD I, LONG for addresses above 0xFFF", name)
+		}
+
+		line = strings.TrimSpace(line[i+1:])
+	}
+
+	if line == "" {
+		return nil
+	}
+
+	mnemonic, rest := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		mnemonic, rest = line[:i], line[i+1:]
+	}
+	mnemonic = strings.ToUpper(mnemonic)
+
+	var args []string
+	if rest = strings.TrimSpace(rest); rest != "" {
+		for _, arg := range strings.Split(rest, ",") {
+			args = append(args, strings.TrimSpace(arg))
+		}
+	}
+
+	switch mnemonic {
+	case "DB":
+		return asm.data(args, 1)
+	case "DW":
+		return asm.data(args, 2)
+	}
+
+	opcode, long, err := asm.instruction(mnemonic, args)
+	if err != nil {
+		return err
+	}
+
+	asm.rom = append(asm.rom, byte(opcode>>8), byte(opcode))
+	// Only LD takes the Megachip 01NN NNNN form, SYS 0x1NN is 2 bytes.
+	if opcode == 0xF000 || mnemonic == "LD" && opcode&0xFF00 == 0x0100 {
+		asm.rom = append(asm.rom, byte(long>>8), byte(long))
+	}
+
+	return nil
+
+}
+
+// data emits every argument as a value of size bytes.
+func (asm *assembler) data(args []string, size int) error {
+
+	if len(args) == 0 {
+		return fmt.Errorf("no data")
+	}
+
+	for _, arg := range args {
+		value, err := asm.value(arg, 1<<(8*size)-1)
+		if err != nil {
+			return err
+		}
+		if size == 2 {
+			asm.rom = append(asm.rom, byte(value>>8))
+		}
+		asm.rom = append(asm.rom, byte(value))
+	}
+
+	return nil
+
+}
+
+// Opcodes of the instructions without operands.
+var asm_no_operands = map[string]uint16{
+	"CLS":   0x00E0,
+	"RET":   0x00EE,
+	"SCR":   0x00FB,
+	"SCL":   0x00FC,
+	"EXIT":  0x00FD,
+	"LOW":   0x00FE,
+	"HIGH":  0x00FF,
+	"AUDIO": 0xF002,
+
+	"MEGAOFF": 0x0010,
+	"MEGAON":  0x0011,
+	"STOPSND": 0x0700,
+}
+
+// Opcodes of the Megachip instructions taking a byte, or a nibble for DIGISND and BMODE.
+var asm_mega = map[string]uint16{
+	"LDPAL": 0x0200, "SPRW": 0x0300, "SPRH": 0x0400, "ALPHA": 0x0500, "DIGISND": 0x0600,
+	"BMODE": 0x0800, "CCOL": 0x0900,
+}
+
+// Last nibble of the 8XYN register to register instructions.
+var asm_alu = map[string]uint16{
+	"OR": 0x1, "AND": 0x2, "XOR": 0x3, "SUB": 0x5, "SHR": 0x6, "SUBN": 0x7, "SHL": 0xE,
+}
+
+// Last byte of the FXNN instructions written LD <special>, VX and LD VX, <special>.
+var (
+	asm_load_to = map[string]uint16{
+		"DT": 0x15, "ST": 0x18, "F": 0x29, "HF": 0x30, "B": 0x33, "[I]": 0x55, "R": 0x75,
+	}
+	asm_load_from = map[string]uint16{
+		"DT": 0x07, "K": 0x0A, "[I]": 0x65, "R": 0x85,
+	}
+)
+
+// instruction encodes a mnemonic and its operands. For F000 NNNN long is the address NNNN.
+func (asm *assembler) instruction(mnemonic string, args []string) (opcode uint16, long uint16, err error) {
+
+	if opcode, ok := asm_no_operands[mnemonic]; ok {
+		return opcode, 0, asm.arity(args, 0)
+	}
+
+	if opcode, ok := asm_mega[mnemonic]; ok {
+		if err := asm.arity(args, 1); err != nil {
+			return 0, 0, err
+		}
+		max := 0xFF
+		if mnemonic == "DIGISND" || mnemonic == "BMODE" {
+			max = 0xF
+		}
+		nn, err := asm.value(args[0], max)
+		return opcode | nn, 0, err
+	}
+
+	if nibble, ok := asm_alu[mnemonic]; ok {
+		// SHR VX and SHL VX shift VX in place.
+		if (mnemonic == "SHR" || mnemonic == "SHL") && len(args) == 1 {
+			args = append(args, args[0])
+		}
+		x, y, err := asm.registers(args)
+		return 0x8000 | x<<8 | y<<4 | nibble, 0, err
+	}
+
+	switch mnemonic {
+
+	case "SYS", "CALL":
+		if err := asm.arity(args, 1); err != nil {
+			return 0, 0, err
+		}
+		nnn, err := asm.value(args[0], 0xFFF)
+		return map[string]uint16{"SYS": 0x0000, "CALL": 0x2000}[mnemonic] | nnn, 0, err
+
+	case "JP":
+		if len(args) == 2 && strings.EqualFold(args[0], "V0") {
+			nnn, err := asm.value(args[1], 0xFFF)
+			return 0xB000 | nnn, 0, err
+		}
+		if err := asm.arity(args, 1); err != nil {
+			return 0, 0, err
+		}
+		nnn, err := asm.value(args[0], 0xFFF)
+		return 0x1000 | nnn, 0, err
+
+	case "SCD", "SCU", "SCRU", "PLANE":
+		if err := asm.arity(args, 1); err != nil {
+			return 0, 0, err
+		}
+		n, err := asm.value(args[0], 0xF)
+		switch mnemonic {
+		case "SCD":
+			return 0x00C0 | n, 0, err
+		case "SCU":
+			return 0x00D0 | n, 0, err
+		case "SCRU":
+			return 0x00B0 | n, 0, err
+		}
+		return 0xF001 | n<<8, 0, err
+
+	case "SE", "SNE":
+		if err := asm.arity(args, 2); err != nil {
+			return 0, 0, err
+		}
+		x, err := register(args[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		if y, err := register(args[1]); err == nil {
+			return map[string]uint16{"SE": 0x5000, "SNE": 0x9000}[mnemonic] | x<<8 | y<<4, 0, nil
+		}
+		nn, err := asm.value(args[1], 0xFF)
+		return map[string]uint16{"SE": 0x3000, "SNE": 0x4000}[mnemonic] | x<<8 | nn, 0, err
+
+	case "SAVE", "LOAD":
+		x, y, err := asm.registers(args)
+		return map[string]uint16{"SAVE": 0x5002, "LOAD": 0x5003}[mnemonic] | x<<8 | y<<4, 0, err
+
+	case "ADD":
+		if err := asm.arity(args, 2); err != nil {
+			return 0, 0, err
+		}
+		if strings.EqualFold(args[0], "I") {
+			x, err := register(args[1])
+			return 0xF01E | x<<8, 0, err
+		}
+		x, err := register(args[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		if y, err := register(args[1]); err == nil {
+			return 0x8004 | x<<8 | y<<4, 0, nil
+		}
+		nn, err := asm.value(args[1], 0xFF)
+		return 0x7000 | x<<8 | nn, 0, err
+
+	case "RND":
+		if err := asm.arity(args, 2); err != nil {
+			return 0, 0, err
+		}
+		x, err := register(args[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		nn, err := asm.value(args[1], 0xFF)
+		return 0xC000 | x<<8 | nn, 0, err
+
+	case "DRW":
+		if err := asm.arity(args, 3); err != nil {
+			return 0, 0, err
+		}
+		x, y, err := asm.registers(args[:2])
+		if err != nil {
+			return 0, 0, err
+		}
+		n, err := asm.value(args[2], 0xF)
+		return 0xD000 | x<<8 | y<<4 | n, 0, err
+
+	case "SKP", "SKNP", "PITCH":
+		if err := asm.arity(args, 1); err != nil {
+			return 0, 0, err
+		}
+		x, err := register(args[0])
+		return map[string]uint16{"SKP": 0xE09E, "SKNP": 0xE0A1, "PITCH": 0xF03A}[mnemonic] | x<<8, 0, err
+
+	case "LD":
+		return asm.load(args)
+	}
+
+	return 0, 0, fmt.Errorf("unknown instruction %s", mnemonic)
+
+}
+
+// load encodes the many forms of LD.
+func (asm *assembler) load(args []string) (opcode uint16, long uint16, err error) {
+
+	if err := asm.arity(args, 2); err != nil {
+		return 0, 0, err
+	}
+
+	dst, src := strings.ToUpper(args[0]), strings.ToUpper(args[1])
+
+	if dst == "I" {
+		// LONG is a prefix of the address, e.g. LD I, LONG sprite.
+		if rest, ok := strings.CutPrefix(src, "LONG "); ok {
+			nnnn, err := asm.value(strings.TrimSpace(args[1][len(args[1])-len(rest):]), 0xFFFF)
+			return 0xF000, nnnn, err
+		}
+
+		addr, err := asm.number(args[1], mega_memory_size-1)
+		if err != nil {
+			return 0, 0, err
+		}
+		if addr > 0xFFFF {
+			return 0x0100 | uint16(addr>>16), uint16(addr), nil
+		}
+		nnn := uint16(addr)
+		if nnn > 0xFFF {
+			return 0xF000, nnn, nil
+		}
+		return 0xA000 | nnn, 0, nil
+	}
+
+	if nn, ok := asm_load_to[dst]; ok {
+		x, err := register(args[1])
+		return 0xF000 | x<<8 | nn, 0, err
+	}
+
+	x, err := register(args[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if nn, ok := asm_load_from[src]; ok {
+		return 0xF000 | x<<8 | nn, 0, nil
+	}
+	if y, err := register(args[1]); err == nil {
+		return 0x8000 | x<<8 | y<<4, 0, nil
+	}
+
+	nn, err := asm.value(args[1], 0xFF)
+	return 0x6000 | x<<8 | nn, 0, err
+
+}
+
+// registers parses the two register operands of a VX, VY instruction.
+func (asm *assembler) registers(args []string) (x, y uint16, err error) {
+
+	if err := asm.arity(args, 2); err != nil {
+		return 0, 0, err
+	}
+	if x, err = register(args[0]); err != nil {
+		return 0, 0, err
+	}
+	y, err = register(args[1])
+
+	return x, y, err
+
+}
+
+func (asm *assembler) arity(args []string, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("want %d operands, got %d", n, len(args))
+	}
+	return nil
+}
+
+// register parses a register name V0 to VF.
+func register(arg string) (uint16, error) {
+
+	if len(arg) == 2 && (arg[0] == 'V' || arg[0] == 'v') {
+		if x, err := strconv.ParseUint(arg[1:], 16, 4); err == nil {
+			return uint16(x), nil
+		}
+	}
+
+	return 0, fmt.Errorf("%q is not a register", arg)
+
+}
+
+// value evaluates a sum of numbers and labels and checks it fits in max. A byte operand can
+// also be negative, down to -128, e.g. ADD V0, -1.
+func (asm *assembler) value(arg string, max int) (uint16, error) {
+
+	total, err := asm.number(arg, max)
+
+	return uint16(total) & uint16(max), err
+
+}
+
+// number is value for operands wider than 16 bits, e.g. a Megachip address.
+func (asm *assembler) number(arg string, max int) (int, error) {
+
+	expr := strings.ReplaceAll(arg, " ", "")
+	if expr == "" {
+		return 0, fmt.Errorf("missing operand")
+	}
+
+	total := 0
+
+	for expr != "" {
+		sign := 1
+		switch expr[0] {
+		case '-':
+			sign = -1
+			expr = expr[1:]
+		case '+':
+			expr = expr[1:]
+		}
+
+		end := strings.IndexAny(expr, "+-")
+		if end < 0 {
+			end = len(expr)
+		}
+		term := expr[:end]
+		expr = expr[end:]
+
+		n, err := asm.term(term)
+		if err != nil {
+			return 0, err
+		}
+		total += sign * n
+	}
+
+	if total > max || (max == 0xFF && total < -0x80) || (max != 0xFF && total < 0) {
+		return 0, fmt.Errorf("%s is out of range", arg)
+	}
+
+	return total, nil
+
+}
+
+// term evaluates a number or a label. Labels not yet defined on the first pass count as 0.
+func (asm *assembler) term(term string) (int, error) {
+
+	if term == "" {
+		return 0, fmt.Errorf("missing operand")
+	}
+
+	if term[0] >= '0' && term[0] <= '9' {
+		base, digits := 10, term
+		switch {
+		case strings.HasPrefix(term, "0x"), strings.HasPrefix(term, "0X"):
+			base, digits = 16, term[2:]
+		case strings.HasPrefix(term, "0b"), strings.HasPrefix(term, "0B"):
+			base, digits = 2, term[2:]
+		}
+
+		n, err := strconv.ParseInt(digits, base, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", term)
+		}
+		return int(n), nil
+	}
+
+	if addr, ok := asm.labels[term]; ok {
+		return addr, nil
+	}
+	if !asm.final && validLabel(term) {
+		return 0, nil
+	}
+
+	return 0, fmt.Errorf("undefined label %q", term)
+
+}
+
+// validLabel reports whether name can be used as a label: letters, digits and underscores,
+// not starting with a digit.
+func validLabel(name string) bool {
+
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		return false
+	}
+
+	for _, r := range name {
+		if !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+
+	return true
+
+}
@@ -0,0 +1,106 @@
+package chip8
+
+import "time"
+
+// Batcher adapts the number of instructions executed per frame so that a frame's work fits in
+// its time budget. When the host can't keep up, fewer instructions run per frame so the 60Hz
+// timers don't drift behind the CPU; when it catches up again, the batch grows back to Target.
+type Batcher struct {
+
+	// Target - number of instructions per frame at the nominal clock rate
+	Target int
+
+	// Budget - time available for executing one frame
+	Budget time.Duration
+
+	// Now - clock used to measure execution time, time.Now if nil
+	Now func() time.Time
+
+	size int
+}
+
+// NewBatcher returns a Batcher running target instructions per frame within the given budget.
+func NewBatcher(target int, budget time.Duration) *Batcher {
+	return &Batcher{Target: target, Budget: budget, size: target}
+}
+
+// Size returns the number of instructions to execute in the next frame.
+func (b *Batcher) Size() int {
+
+	if b.size <= 0 {
+		b.size = b.Target
+	}
+
+	return b.size
+
+}
+
+// Adjust updates the batch size from the time the last batch took to execute.
+func (b *Batcher) Adjust(elapsed time.Duration) {
+	b.adjust(b.Size(), elapsed)
+}
+
+// adjust updates the batch size from the time a batch of count instructions took.
+func (b *Batcher) adjust(count int, elapsed time.Duration) {
+
+	if elapsed <= 0 {
+		b.size = b.Target
+		return
+	}
+
+	// Scale the batch so it would have taken exactly the budget, without exceeding the target.
+	scaled := int(int64(count) * int64(b.Budget) / int64(elapsed))
+
+	b.size = max(1, min(b.Target, scaled))
+
+}
+
+func (b *Batcher) now() time.Time {
+
+	if b.Now != nil {
+		return b.Now()
+	}
+
+	return time.Now()
+
+}
+
+// SetBatcher makes Run execute the instructions of each 60Hz timer tick as a batch sized by b,
+// so that on a host too slow for the clock rate the batches shrink and the timers keep their
+// pace instead of the whole machine slowing down: the instructions left out are dropped. Set
+// b.Target to the instructions per tick, ClockHz / 60, and b.Budget to the share of a tick they
+// may take. With nil, the default, every instruction due runs. It is set before Run.
+func (chip *Chip8) SetBatcher(b *Batcher) {
+	chip.batcher = b
+}
+
+// RunBatch executes one frame worth of instructions as sized by the batcher, decrements
+// the timers once and adapts the batch size to the measured execution time.
+func (chip *Chip8) RunBatch(b *Batcher) error {
+
+	if err := chip.runBatch(b, b.Size()); err != nil {
+		return err
+	}
+
+	chip.DecrementTimers()
+
+	return nil
+
+}
+
+// runBatch executes count instructions and adapts the size of b to the time they took.
+func (chip *Chip8) runBatch(b *Batcher, count int) error {
+
+	start := b.now()
+
+	for i := 0; i < count; i++ {
+		if err := chip.Cycle(); err != nil {
+			return err
+		}
+	}
+
+	b.adjust(count, b.now().Sub(start))
+
+	return nil
+
+}
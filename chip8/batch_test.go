@@ -0,0 +1,99 @@
+package chip8
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunAdaptsBatches(t *testing.T) {
+
+	chip := New()
+	if err := chip.LoadROMBytes([]byte{0x70, 0x01, 0x12, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	chip.SetClockHz(600)
+
+	clock := NewManualClock(time.Time{})
+	chip.SetClock(clock)
+
+	// A slow host: every instruction takes a millisecond of the batcher's clock.
+	var host time.Time
+	cost := time.Millisecond
+	chip.OnStep = func(uint16, Instruction) { host = host.Add(cost) }
+
+	batcher := NewBatcher(10, 5*time.Millisecond)
+	batcher.Now = func() time.Time { return host }
+	chip.SetBatcher(batcher)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- chip.Run(ctx, nil) }()
+	clock.WaitTickers(1)
+
+	// The first tick runs the 10 instructions due in 10ms, the batch halves to fit in 5ms.
+	clock.Advance(timer_period)
+	if executed := chip.CycleCount(); executed != 10 {
+		t.Errorf("%d instructions in the first tick, want 10", executed)
+	}
+	if size := batcher.Size(); size != 5 {
+		t.Errorf("batch of %d after a slow tick, want 5", size)
+	}
+
+	// It stays there while the host is slow, the timers keep ticking at 60Hz.
+	chip.delay_timer = 10
+	clock.Advance(4 * timer_period)
+	if executed := chip.CycleCount(); executed != 30 {
+		t.Errorf("%d instructions after 5 ticks, want 30", executed)
+	}
+	if delay, _ := chip.Timers(); delay != 6 {
+		t.Errorf("delay timer at %d after 4 ticks, want 6", delay)
+	}
+
+	// Once the host is fast again, the batch grows back to the target.
+	cost = 0
+	clock.Advance(2 * timer_period)
+	if size := batcher.Size(); size != 10 {
+		t.Errorf("batch of %d on a fast host, want 10", size)
+	}
+	if executed := chip.CycleCount(); executed != 45 {
+		t.Errorf("%d instructions after 7 ticks, want 45", executed)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+}
+
+func TestRunBatch(t *testing.T) {
+
+	chip := New()
+	if err := chip.LoadROMBytes([]byte{0x70, 0x01, 0x12, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	chip.delay_timer = 2
+
+	var host time.Time
+	chip.OnStep = func(uint16, Instruction) { host = host.Add(2 * time.Millisecond) }
+
+	batcher := NewBatcher(8, 4*time.Millisecond)
+	batcher.Now = func() time.Time { return host }
+
+	if err := chip.RunBatch(batcher); err != nil {
+		t.Fatal(err)
+	}
+
+	if executed := chip.CycleCount(); executed != 8 {
+		t.Errorf("%d instructions in the batch, want 8", executed)
+	}
+	if delay, _ := chip.Timers(); delay != 1 {
+		t.Errorf("delay timer at %d after a batch, want 1", delay)
+	}
+	if size := batcher.Size(); size != 2 {
+		t.Errorf("batch of %d after 8 instructions in 16ms, want 2", size)
+	}
+
+}
@@ -0,0 +1,161 @@
+package chip8
+
+import "testing"
+
+// benchProgram is a program run by the benchmarks, with the heap allocations a run of cycles
+// instructions may make, which is none for everything on the hot path.
+type benchProgram struct {
+	name string
+
+	// source - the program, see Assemble
+	source string
+
+	platform Platform
+	cycles   int
+	allocs   float64
+}
+
+var bench_programs = []benchProgram{
+	{
+		// A single instruction, the cost of fetch, dispatch and execute.
+		name:   "Step",
+		source: "loop: ADD V0, 0x01\nJP loop\n",
+		cycles: 1,
+	},
+	{
+		// An 8x15 sprite drawn over itself at changing coordinates, so it collides and wraps.
+		name:   "DXYN",
+		source: "LD I, 0x000\nloop: DRW V0, V1, 15\nADD V0, 0x03\nADD V1, 0x05\nJP loop\n",
+		cycles: 1,
+	},
+	{
+		name:   "CLS",
+		source: "loop: CLS\nJP loop\n",
+		cycles: 1,
+	},
+	{
+		// A frame's worth of a game-like loop: it clears the display, draws the 16 digits of
+		// the font with BCD conversions in between, and reads and writes memory.
+		name: "ROMFrame",
+		source: `	LD V2, 0x00
+frame:	CLS
+	LD V0, 0x00
+	LD V1, 0x00
+digit:	LD F, V2
+	DRW V0, V1, 5
+	LD I, 0x300
+	LD B, V2
+	LD V3, [I]
+	LD [I], V3
+	CALL next
+	SE V2, 0x10
+	JP digit
+	LD V2, 0x00
+	JP frame
+next:	ADD V2, 0x01
+	ADD V0, 0x06
+	SNE V0, 0x3C
+	LD V0, 0x00
+	RET
+`,
+		cycles: cycles_per_frame * 60,
+	},
+	{
+		// A Megachip frame: a 16x16 sprite drawn and scrolled down, then 00E0 shows the frame.
+		// Showing it allocates the new front buffer, which frontends may keep while the next
+		// frame is drawn.
+		name: "MegaFrame",
+		source: `	MEGAON
+	SPRW 0x10
+	SPRH 0x10
+	LD I, 0x10000
+loop:	DRW V0, V1, 0
+	SCD 1
+	ADD V0, 0x01
+	CLS
+	JP loop
+`,
+		platform: PlatformMegaChip,
+		cycles:   5,
+		allocs:   1,
+	},
+}
+
+// benchChip returns a machine running the program of bench, warmed up so the decode cache and
+// the display are allocated.
+func benchChip(tb testing.TB, bench benchProgram) *Chip8 {
+
+	tb.Helper()
+
+	rom, err := Assemble(bench.source)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	chip := New()
+	chip.SetPlatform(bench.platform)
+	if err := chip.LoadROMBytes(rom); err != nil {
+		tb.Fatal(err)
+	}
+	if err := chip.RunCycles(bench.cycles); err != nil {
+		tb.Fatal(err)
+	}
+
+	return chip
+
+}
+
+// runBench runs the benchmark of the program called name.
+func runBench(b *testing.B, name string) {
+
+	for _, bench := range bench_programs {
+		if bench.name != name {
+			continue
+		}
+
+		chip := benchChip(b, bench)
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if err := chip.RunCycles(bench.cycles); err != nil {
+				b.Fatal(err)
+			}
+		}
+		return
+	}
+
+	b.Fatalf("no benchmark program %s", name)
+
+}
+
+func BenchmarkStep(b *testing.B)      { runBench(b, "Step") }
+func BenchmarkDXYN(b *testing.B)      { runBench(b, "DXYN") }
+func BenchmarkCLS(b *testing.B)       { runBench(b, "CLS") }
+func BenchmarkROMFrame(b *testing.B)  { runBench(b, "ROMFrame") }
+func BenchmarkMegaFrame(b *testing.B) { runBench(b, "MegaFrame") }
+
+func TestBenchAllocations(t *testing.T) {
+
+	for _, bench := range bench_programs {
+		t.Run(bench.name, func(t *testing.T) {
+
+			chip := benchChip(t, bench)
+
+			var err error
+			allocs := testing.AllocsPerRun(100, func() {
+				if err == nil {
+					err = chip.RunCycles(bench.cycles)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allocs > bench.allocs {
+				t.Errorf("%.1f allocations per run, want at most %.0f", allocs, bench.allocs)
+			}
+
+		})
+	}
+
+}
@@ -0,0 +1,90 @@
+package chip8
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A bundle file starts with this header, followed by the gzipped gob encoding of a bundle.
+var bundle_magic = []byte("C8BUNDLE")
+
+// Version 2 added the version of the save state after the bundle version: the state of a
+// bundle is a machineState, a bundle written with another state version is rejected.
+const bundle_version = 2
+
+// ErrInvalidBundle is returned when loading data that is not a supported bundle.
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+type bundle struct {
+	ROM   []byte
+	State machineState
+}
+
+// SaveBundle writes the loaded ROM together with the exact machine state and configuration,
+// so the scenario can be restored elsewhere with LoadBundle.
+func (chip *Chip8) SaveBundle(w io.Writer) error {
+
+	if _, err := w.Write(bundle_magic); err != nil {
+		return err
+	}
+
+	if _, err := w.Write([]byte{bundle_version, state_version}); err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+
+	if err := gob.NewEncoder(zw).Encode(bundle{ROM: chip.rom, State: chip.snapshot()}); err != nil {
+		return fmt.Errorf("could not encode bundle: %w", err)
+	}
+
+	return zw.Close()
+
+}
+
+// LoadBundle restores a ROM and machine state written by SaveBundle.
+// On error the machine is left unchanged.
+func (chip *Chip8) LoadBundle(r io.Reader) error {
+
+	header := make([]byte, len(bundle_magic)+2)
+
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+
+	if !bytes.Equal(header[:len(bundle_magic)], bundle_magic) {
+		return fmt.Errorf("%w: bad header", ErrInvalidBundle)
+	}
+
+	if header[len(bundle_magic)] != bundle_version {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidBundle, header[len(bundle_magic)])
+	}
+
+	if version := header[len(bundle_magic)+1]; version != state_version {
+		return fmt.Errorf("%w: unsupported state version %d", ErrInvalidBundle, version)
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	defer zr.Close()
+
+	var b bundle
+
+	if err := gob.NewDecoder(zr).Decode(&b); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+
+	if err := chip.restore(b.State); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	chip.rom = b.ROM
+
+	return nil
+
+}
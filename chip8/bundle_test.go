@@ -0,0 +1,105 @@
+package chip8
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"testing"
+)
+
+func TestSaveLoadBundle(t *testing.T) {
+
+	chip := New()
+	chip.SetSeed(7)
+	chip.SetPlatform(PlatformSuperChip)
+	chip.SetShiftQuirk(true)
+	if err := chip.LoadROMBytes(state_test_rom); err != nil {
+		t.Fatal(err)
+	}
+	runFrames(t, chip, 10)
+
+	var saved bytes.Buffer
+	if err := chip.SaveBundle(&saved); err != nil {
+		t.Fatal(err)
+	}
+	want := runFrames(t, chip, 20)
+
+	// A new machine gets the ROM, the state and the configuration of the bundle.
+	restored := New()
+	if err := restored.LoadBundle(bytes.NewReader(saved.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restored.rom, state_test_rom) {
+		t.Errorf("ROM of the bundle % X, want % X", restored.rom, state_test_rom)
+	}
+	if restored.Platform() != PlatformSuperChip || !restored.Quirks().Shift {
+		t.Errorf("configuration not restored: platform %v, quirks %+v", restored.Platform(), restored.Quirks())
+	}
+
+	got := runFrames(t, restored, 20)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("frame %d after loading differs:\n%s\nwant:\n%s", i, got[i].Text(), want[i].Text())
+		}
+	}
+
+}
+
+// encodeBundle writes b as SaveBundle would, with the given versions in the header.
+func encodeBundle(t *testing.T, b bundle, version, state byte) []byte {
+
+	t.Helper()
+
+	var buf bytes.Buffer
+	buf.Write(bundle_magic)
+	buf.Write([]byte{version, state})
+
+	zw := gzip.NewWriter(&buf)
+	if err := gob.NewEncoder(zw).Encode(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+
+}
+
+func TestLoadBundleRejectsInvalid(t *testing.T) {
+
+	chip := New()
+	if err := chip.LoadROMBytes(state_test_rom); err != nil {
+		t.Fatal(err)
+	}
+	runFrames(t, chip, 3)
+
+	corrupted := chip.snapshot()
+	corrupted.PC = 0xF000
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"older state version", encodeBundle(t, bundle{ROM: state_test_rom, State: chip.snapshot()}, bundle_version, state_version-1)},
+		{"older bundle version", encodeBundle(t, bundle{ROM: state_test_rom, State: chip.snapshot()}, 1, state_version)},
+		{"invalid state", encodeBundle(t, bundle{ROM: state_test_rom, State: corrupted}, bundle_version, state_version)},
+		{"truncated", encodeBundle(t, bundle{ROM: state_test_rom, State: chip.snapshot()}, bundle_version, state_version)[:20]},
+	}
+
+	for _, test := range tests {
+
+		loaded := New()
+		before := loaded.State()
+
+		err := loaded.LoadBundle(bytes.NewReader(test.data))
+		if !errors.Is(err, ErrInvalidBundle) {
+			t.Errorf("%s: got %v, want ErrInvalidBundle", test.name, err)
+		}
+		if loaded.State() != before || loaded.rom != nil {
+			t.Errorf("%s: the rejected bundle changed the machine", test.name)
+		}
+	}
+
+}
@@ -0,0 +1,84 @@
+package chip8
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Cheat freezes a byte of memory to a value, e.g. to keep a lives counter full.
+type Cheat struct {
+	Addr  int
+	Value byte
+}
+
+// ParseCheats parses a comma separated list of ADDR=VALUE cheats in hex, e.g. "2F0=03,2F1=FF".
+func ParseCheats(s string) ([]Cheat, error) {
+
+	var cheats []Cheat
+
+	for _, field := range strings.Split(s, ",") {
+
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		addr, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid cheat %q, want ADDR=VALUE", field)
+		}
+
+		a, err := strconv.ParseUint(strings.TrimPrefix(addr, "0x"), 16, 24)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cheat address %q", addr)
+		}
+		v, err := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cheat value %q", value)
+		}
+
+		cheats = append(cheats, Cheat{Addr: int(a), Value: byte(v)})
+	}
+
+	return cheats, nil
+
+}
+
+// ApplyCheats freezes the bytes of the cheats with the OnRead and OnWrite hooks: instructions
+// read the value of the cheat, and writing the byte stores that value. Hooks set before are
+// still called for the other bytes.
+func (chip *Chip8) ApplyCheats(cheats []Cheat) {
+
+	if len(cheats) == 0 {
+		return
+	}
+
+	frozen := make(map[int]byte, len(cheats))
+	for _, c := range cheats {
+		frozen[c.Addr%chip.memorySize()] = c.Value
+	}
+
+	on_read, on_write := chip.OnRead, chip.OnWrite
+
+	chip.OnRead = func(addr int, value byte) byte {
+		if v, ok := frozen[addr]; ok {
+			return v
+		}
+		if on_read != nil {
+			return on_read(addr, value)
+		}
+		return value
+	}
+
+	chip.OnWrite = func(addr int, old, value byte) byte {
+		if v, ok := frozen[addr]; ok {
+			return v
+		}
+		if on_write != nil {
+			return on_write(addr, old, value)
+		}
+		return value
+	}
+
+}
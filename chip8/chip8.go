@@ -0,0 +1,874 @@
+// Package chip8 implements a CHIP-8 interpreter that frontends can drive:
+// load a ROM, step or run the CPU, feed key presses and read back the display.
+package chip8
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+// ErrStackUnderflow is returned when 00EE is executed with an empty stack.
+var ErrStackUnderflow = errors.New("stack underflow: return with empty stack")
+
+// ErrStackOverflow is returned when 2NNN is executed with a full stack.
+var ErrStackOverflow = errors.New("stack overflow: call with full stack")
+
+// ErrROMTooLarge is returned when a ROM does not fit into memory.
+var ErrROMTooLarge = errors.New("ROM too big to fit into memory")
+
+// ErrMemoryOutOfRange is returned when an instruction would access memory past its end.
+var ErrMemoryOutOfRange = errors.New("memory access out of range")
+
+// ErrInvalidOpcode is wrapped by the InvalidOpcodeError returned for opcodes that are not
+// part of the instruction set.
+var ErrInvalidOpcode = errors.New("invalid opcode")
+
+// InvalidOpcodeError reports an opcode that is not part of the instruction set and its address.
+type InvalidOpcodeError struct {
+	Opcode uint16
+	PC     uint16
+}
+
+func (e *InvalidOpcodeError) Error() string {
+	return fmt.Sprintf("%v 0x%04X at 0x%03X", ErrInvalidOpcode, e.Opcode, e.PC)
+}
+
+func (e *InvalidOpcodeError) Unwrap() error {
+	return ErrInvalidOpcode
+}
+
+// DefaultLoadAddress is where ROMs are loaded and execution starts, after the interpreter area.
+const DefaultLoadAddress = 0x200
+
+// Display size in CHIP-8 pixels.
+const (
+	DisplayWidth  = 64
+	DisplayHeight = 32
+)
+
+// Display size in SUPER-CHIP high resolution mode.
+const (
+	HiResWidth  = 128
+	HiResHeight = 64
+)
+
+// RandomSource provides the random numbers used by CXNN. *rand.Rand implements it.
+type RandomSource interface {
+	Uint32() uint32
+}
+
+// SequenceSource is a RandomSource returning scripted values in order, starting over after
+// the last one. An empty sequence always returns 0.
+type SequenceSource struct {
+	Values []uint32
+	next   int
+}
+
+func (s *SequenceSource) Uint32() uint32 {
+
+	if len(s.Values) == 0 {
+		return 0
+	}
+
+	value := s.Values[s.next%len(s.Values)]
+	s.next++
+
+	return value
+
+}
+
+type Chip8 struct {
+
+	// Registers - 16 1-byte registers called V0 to VF
+	registers [16]byte
+
+	//Program Counter (PC) - points to current instruction in memory
+	program_counter uint16
+
+	//Index Register (I) - points to locations in memory
+	index_register uint32
+
+	// Stack - to call and return from subroutines
+	stack [16]uint16
+
+	// Stack Pointer (SP) - points to the next free slot in the stack
+	stack_pointer uint8
+
+	// Delay timer -  is decremented at a rate of 60 Hz (60 times per second) until it reaches 0
+	delay_timer uint8
+
+	// Sound timer - functions like the delay timer, but which also gives off a beeping sound as long as it’s not 0
+	sound_timer uint8
+
+	// OnSound - optional callback invoked when the beep starts (true) or stops (false)
+	OnSound func(playing bool)
+
+	// Whether the beep was last reported as playing
+	sound_playing bool
+
+	// OnUnknownOpcode - optional callback invoked with an opcode that is not part of the instruction set
+	// and its address, before execution skips over it. Without it such opcodes fail with an
+	// InvalidOpcodeError
+	OnUnknownOpcode func(opcode uint16, pc uint16)
+
+	// OnTrace - optional callback invoked after every executed instruction with its trace entry
+	OnTrace func(entry TraceEntry)
+
+	// OnFrame - optional callback invoked by RunWith once per frame with the display,
+	// e.g. to record it
+	OnFrame func(frame Frame)
+
+	// OnKeys - optional callback invoked by RunWith once per frame with the keys polled from
+	// its input, none without one, returning the keys the machine gets, e.g. merged with those
+	// of a netplay client
+	OnKeys func(keys [16]bool) [16]bool
+
+	// Hooks for cheats, bots and test drivers, see ApplyCheats. Each is optional and runs on
+	// the goroutine executing the program, where it may change the machine.
+
+	// OnStep - invoked after every executed instruction with its address
+	OnStep func(pc uint16, in Instruction)
+
+	// OnDraw - invoked after every sprite drawn by DXYN, with its coordinates and whether it
+	// collided
+	OnDraw func(x, y int, collision bool)
+
+	// OnKeyWait - invoked when FX0A starts waiting for a key, e.g. for a bot to press one
+	OnKeyWait func()
+
+	// OnRead - intercepts the reads of memory by instructions, returning the value read
+	OnRead func(addr int, value byte) byte
+
+	// OnWrite - intercepts the writes to memory by instructions, returning the value stored,
+	// old to leave the byte unchanged
+	OnWrite func(addr int, old, value byte) byte
+
+	// Logger - where diagnostics are reported, nil when logging is off
+	logger *slog.Logger
+
+	// Called on every data access to memory, with the byte before and after it, while the
+	// Debugger watches memory
+	on_memory func(addr int, write bool, old, value byte)
+
+	// Instruction counts, while profiling
+	profile *profile
+
+	// Replay being recorded or played back by Run, see RecordReplay and StartReplay
+	recording *replayRecorder
+	replay    *replayPlayer
+
+	// Time carried over between timer ticks that did not add up to a full 60Hz period
+	timer_elapsed time.Duration
+
+	// Memory - 4kB of RAM, 64kB with XO-CHIP
+	// CHIP-8’s index register and program counter can only address 12 bits
+	memory [xo_memory_size]byte
+
+	// Megachip memory - 16MB replacing memory while the platform is Megachip, see mem
+	mega_memory []byte
+
+	// ROM - copy of the loaded program, the address it was loaded at, where its execution
+	// starts and the file it was read from, empty when loaded from memory
+	rom          []byte
+	load_address uint16
+	entry_point  uint16
+	rom_path     string
+
+	// Where the next ROMs loaded are put and start, see SetLoadAddress
+	next_load_address uint16
+	next_entry_point  uint16
+
+	// Paused - Run keeps calling its frame callback but executes nothing and stops the timers
+	paused bool
+
+	// How fast Run executes the program, see SetSpeed
+	speed Speed
+
+	//Display - 64 x 32 pixels, monochromatic, packed one bit per pixel
+	// In low resolution only the top-left DisplayWidth x DisplayHeight pixels are used.
+	// XO-CHIP has a second plane.
+	display [2]Plane
+
+	// Set when the display changed since the last ConsumeFrame.
+	display_dirty bool
+
+	// Planes - the display planes drawn to, selected by the XO-CHIP FN01
+	planes uint8
+
+	// High resolution - SUPER-CHIP 128x64 mode, enabled by 00FF
+	hires bool
+
+	// Platform - the extensions to the instruction set that are enabled
+	platform Platform
+
+	// Megachip - the 256x192 color display and the rest of the Megachip state, nil on the
+	// other platforms
+	mega *megaChip
+
+	// RPL user flags - SUPER-CHIP storage for FX75/FX85
+	rpl [16]byte
+
+	// Audio pattern - XO-CHIP 1-bit samples loaded by F002, played back at a rate set by FX3A
+	pattern [16]byte
+	pitch   byte
+
+	//Keypad -  16 keys, true while held down
+	keypad [16]bool
+
+	// Keymap - translates physical keys for PressKey and ReleaseKey
+	keymap Keymap
+
+	// Single key mode - only the lowest held key is seen as pressed, for ROMs that assume single-key input
+	single_key bool
+
+	// Key wait - the key FX0A saw pressed, stored in V[X] once it is released (COSMAC VIP)
+	key_wait    byte
+	key_waiting bool
+
+	// Awaiting key - FX0A is waiting for a key to be pressed, see RunState
+	awaiting_key bool
+
+	// Vertical blank - set by the 60Hz timer tick, cleared by a DXYN waiting for it
+	vblank bool
+
+	// Shift quirk - 8XY6/8XYE shift V[Y] into V[X] (COSMAC VIP) instead of shifting V[X] in place
+	shift_quirk bool
+
+	// Index increment quirk - FX55/FX65 leave I pointing after the last register (COSMAC VIP)
+	index_increment_quirk bool
+
+	// Index overflow quirk - FX1E sets V[F] when I goes past 0x0FFF
+	index_overflow_quirk bool
+
+	// VF reset quirk - 8XY1/8XY2/8XY3 clear V[F] (COSMAC VIP)
+	vf_reset_quirk bool
+
+	// Random source used by CXNN. The default source is recreated from its seed and the
+	// number of values drawn when a save state is loaded; one set by SetRNG is not saved.
+	rng         RandomSource
+	rng_seed    int64
+	rng_draws   uint64
+	rng_default bool
+
+	// Jump quirk - BNNN is read as BXNN, jumping to XNN + V[X] (SUPER-CHIP)
+	jump_quirk bool
+
+	// Wrap quirk - DXYN pixels past the right or bottom edge wrap around instead of being clipped
+	wrap_quirk bool
+
+	// Vertical wrap - DXYN sprite rows past the bottom edge wrap to the top, columns are clipped
+	vertical_wrap bool
+
+	// Display wait quirk - DXYN waits for the vertical blank of the next 60Hz frame (COSMAC VIP)
+	display_wait bool
+
+	// Memory policy - what an access past the end of memory does, and whether writes to the
+	// interpreter area below 0x200 fail
+	memory_policy  MemoryPolicy
+	protect_memory bool
+
+	// Halt detection - a jump to itself fails with ErrHaltLoop, see SetHaltDetection
+	detect_halt bool
+
+	// Strict mode - quirk-dependent opcodes fail unless the quirk was configured explicitly
+	strict     bool
+	quirks_set map[string]bool
+
+	// Instruction fetched by Fetch and not executed yet, and its handler
+	fetched         Instruction
+	fetched_handler handler
+	has_fetched     bool
+
+	// Decode cache - the instructions decoded so far indexed by address, see decodeAt.
+	// Writes to memory drop the entries they overlap.
+	decoded []decodedOp
+
+	// Number of instructions executed
+	cycle_count uint64
+
+	// Trace - ring buffer of the last executed instructions, nil when tracing is off
+	trace      []TraceEntry
+	trace_next int
+	trace_full bool
+
+	// Clock rate - instructions per second, set explicitly or recommended for the loaded ROM
+	clock_hz       int
+	clock_override bool
+
+	// Clock - time source pacing Run, SystemClock when nil
+	clock Clock
+
+	// Frame pacing - calls per second of the frame callback of Run, DefaultFrameRate when 0,
+	// and the 60Hz frames it may skip in a row to keep up, see SetFrameRate and SetFrameSkip
+	frame_rate int
+	frame_skip int
+
+	// Batcher - adapts the instructions Run executes per timer tick to the host, see SetBatcher
+	batcher *Batcher
+
+	// Mirror - optional channel the driver publishes state snapshots to
+	mirror chan<- MirrorState
+
+	// Netplay - the host whose display Run shows instead of running the program, see
+	// FollowNetplay
+	netplay *NetplayClient
+}
+
+// Fontset - to represent sprites
+var fontset = [80]byte{
+	0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+	0x20, 0x60, 0x20, 0x20, 0x70, // 1
+	0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+	0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+	0x90, 0x90, 0xF0, 0x10, 0x10, // 4
+	0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+	0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+	0xF0, 0x10, 0x20, 0x40, 0x40, // 7
+	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+	0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+	0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+	0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
+	0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+	0xE0, 0x90, 0x90, 0x90, 0xE0, // D
+	0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+}
+
+// New returns a machine with the fontset loaded, ready to load a ROM.
+func New() *Chip8 {
+	chip := new(Chip8)
+
+	chip.program_counter = DefaultLoadAddress
+	chip.load_address = DefaultLoadAddress
+	chip.entry_point = DefaultLoadAddress
+	chip.next_load_address = DefaultLoadAddress
+	chip.next_entry_point = DefaultLoadAddress
+	chip.frame_skip = DefaultFrameSkip
+
+	chip.keymap = DefaultKeymap()
+
+	chip.planes = 1
+	chip.pitch = default_pitch
+	chip.vblank = true
+	chip.display_dirty = true
+
+	// CXNN uses a time-seeded source unless replaced with SetRNG.
+	chip.SetSeed(time.Now().UnixNano())
+
+	// FX55/FX65 increment I by default, like the original interpreter.
+	chip.index_increment_quirk = true
+
+	// Load Fontset
+	chip.loadFont()
+
+	return chip
+
+}
+
+// loadFont copies the fontset to the start of memory, followed by the large font.
+func (chip *Chip8) loadFont() {
+	memory := chip.mem()
+	for i := 0; i < 80; i++ {
+		memory[i] = fontset[i]
+	}
+	copy(memory[big_font_address:], big_fontset[:])
+}
+
+// Reset reinitializes the machine to its power-on state without reallocating it: registers,
+// stack, timers, display and keypad are cleared and execution restarts at the entry point. Memory is
+// cleared too, then the fontset and the loaded ROM are restored, so the same game restarts
+// even if it modified itself. Quirks and other settings are kept.
+func (chip *Chip8) Reset() {
+
+	chip.registers = [16]byte{}
+	chip.program_counter = chip.entry_point
+	chip.index_register = 0
+	chip.stack = [16]uint16{}
+	chip.stack_pointer = 0
+	chip.delay_timer = 0
+	chip.sound_timer = 0
+	chip.timer_elapsed = 0
+	chip.keypad = [16]bool{}
+	chip.key_waiting = false
+	chip.awaiting_key = false
+	chip.vblank = true
+	chip.hires = false
+	chip.planes = 1
+	chip.pattern = [16]byte{}
+	chip.pitch = default_pitch
+	chip.has_fetched = false
+	chip.cycle_count = 0
+	chip.Clear()
+
+	if chip.mega != nil {
+		chip.mega = newMegaChip()
+	}
+
+	clear(chip.mem())
+	chip.loadFont()
+	copy(chip.mem()[chip.load_address:], chip.rom)
+	chip.invalidateAllDecoded()
+
+	// Stop a beep that was playing.
+	if chip.sound_playing {
+		chip.sound_playing = false
+		if chip.OnSound != nil {
+			chip.OnSound(false)
+		}
+	}
+
+	chip.log(slog.LevelDebug, "reset")
+
+}
+
+// LoadROM receives a path to a ROM and tries to load it into memory.
+// It returns an error if the file could not be read or does not fit into memory.
+func (chip *Chip8) LoadROM(path string) error {
+
+	// Read contents of file, Octo source is assembled
+
+	data, err := ReadROMFile(path)
+
+	if err != nil {
+		return fmt.Errorf("could not read ROM: %w", err)
+	}
+
+	return chip.LoadNamedROM(path, data)
+
+}
+
+// LoadNamedROM loads a ROM the caller read from path itself, e.g. fetched over HTTP, as LoadROM
+// would: the recommended speed for its file name applies to an unknown dump and ReloadROM reads
+// path again.
+func (chip *Chip8) LoadNamedROM(path string, data []byte) error {
+
+	if err := chip.LoadROMBytes(data); err != nil {
+		return err
+	}
+
+	chip.applyRecommendedClock(data, path)
+	chip.rom_path = path
+
+	chip.log(slog.LevelDebug, "ROM file loaded", "path", path, "clock_hz", chip.ClockHz())
+
+	return nil
+
+}
+
+// LoadROMFromReader loads a ROM read from r, e.g. a file of an embed.FS or zip archive, or an
+// HTTP response body, reading no more than fits into memory. It returns an error if r fails
+// or the ROM does not fit.
+func (chip *Chip8) LoadROMFromReader(r io.Reader) error {
+
+	max_size := max(chip.memorySize()-int(chip.next_load_address), 0)
+
+	data, err := io.ReadAll(io.LimitReader(r, int64(max_size)+1))
+	if err != nil {
+		return fmt.Errorf("could not read ROM: %w", err)
+	}
+	if len(data) > max_size {
+		return fmt.Errorf("%w: more than %d bytes at 0x%03X", ErrROMTooLarge, max_size, chip.next_load_address)
+	}
+
+	return chip.LoadROMBytes(data)
+
+}
+
+// LoadROMBytes loads a ROM from memory, e.g. one embedded with go:embed or built inline in a test.
+// The recommended speed of a known dump applies, see RecommendedClockHz. It returns an error if
+// the ROM does not fit into memory.
+func (chip *Chip8) LoadROMBytes(data []byte) error {
+	return chip.loadROM(data, chip.next_load_address, chip.next_entry_point)
+}
+
+// SetLoadAddress sets where the ROMs loaded from then on are put and where their execution
+// starts, by LoadROM, LoadROMBytes and the others but LoadROMAt: e.g. 0x600 for ETI-660
+// programs, or an entry point after data for hybrid ROMs. Both are DefaultLoadAddress by
+// default. It returns an error if either address is past the end of memory.
+func (chip *Chip8) SetLoadAddress(addr, entry uint16) error {
+
+	for _, a := range []uint16{addr, entry} {
+		if int(a)+2 > chip.memorySize() {
+			return fmt.Errorf("%w: load address 0x%03X", ErrMemoryOutOfRange, a)
+		}
+	}
+
+	chip.next_load_address, chip.next_entry_point = addr, entry
+
+	return nil
+
+}
+
+// LoadAddress returns the address the ROM was loaded at and the address its execution starts at.
+func (chip *Chip8) LoadAddress() (addr, entry uint16) {
+	return chip.load_address, chip.entry_point
+}
+
+// LoadROMAt loads a ROM at the given address and starts execution there,
+// e.g. at 0x600 for ETI-660 programs. It returns an error if the ROM does not fit into memory.
+func (chip *Chip8) LoadROMAt(data []byte, addr uint16) error {
+	return chip.loadROM(data, addr, addr)
+}
+
+// loadROM loads a ROM at addr, starting execution at entry.
+func (chip *Chip8) loadROM(data []byte, addr, entry uint16) error {
+
+	//First, check if the ROM is too big to load.
+	if (int(addr) + len(data)) > chip.memorySize() {
+		return fmt.Errorf("%w: %d bytes at 0x%03X", ErrROMTooLarge, len(data), addr)
+	}
+
+	//If it's not, load it into memory.
+	copy(chip.mem()[addr:], data)
+	chip.invalidateAllDecoded()
+
+	// Keep a copy, the caller may reuse its slice.
+	chip.rom = append([]byte(nil), data...)
+	chip.load_address = addr
+	chip.entry_point = entry
+	chip.rom_path = ""
+	chip.program_counter = entry
+	chip.applyRecommendedClock(data, "")
+
+	chip.log(slog.LevelDebug, "ROM loaded", "bytes", len(data), hexAttr("address", addr))
+
+	return nil
+
+}
+
+// Executes one cycle: fetches the instruction at the program counter and executes it.
+// It returns an error if the instruction could not be executed,
+// in which case the machine state is left unchanged.
+
+func (chip *Chip8) Cycle() error {
+
+	if _, err := chip.Fetch(); err != nil {
+		return err
+	}
+
+	return chip.Execute()
+
+}
+
+// Fetch reads and decodes the instruction at the program counter without executing it
+// or advancing the program counter. The next call to Execute performs this instruction.
+// It returns an error if the program counter points past the last full opcode in memory.
+
+func (chip *Chip8) Fetch() (Instruction, error) {
+
+	pc := int(chip.program_counter)
+
+	if err := chip.checkMemory(pc, 2, false); err != nil {
+		return Instruction{}, fmt.Errorf("%w: fetch at PC 0x%03X", err, chip.program_counter)
+	}
+
+	// The opcode has 2 bytes, but our memory has 1 byte values, to address this:
+	//		First, add 8 zeroes to the right of the byte in memory where the program counter points to.
+	//		Then, make a bitwise_or operation to add the next byte in memory to those zeroes.
+
+	// Fetches bypass peek, they are not data accesses for watchpoints. Each address is only
+	// decoded once, until it is written to.
+	op := chip.decodeAt(pc % chip.memorySize())
+
+	chip.fetched, chip.fetched_handler = op.in, op.handler
+	chip.has_fetched = true
+
+	return chip.fetched, nil
+
+}
+
+// Execute performs the last fetched instruction and advances the program counter.
+// If no instruction was fetched since the last Execute, it fetches one first.
+
+func (chip *Chip8) Execute() error {
+
+	if !chip.has_fetched {
+		if _, err := chip.Fetch(); err != nil {
+			return err
+		}
+	}
+	chip.has_fetched = false
+
+	pc := chip.program_counter
+
+	tracing := chip.tracing()
+	var before TraceRegisters
+	if tracing {
+		before = chip.traceRegisters()
+	}
+
+	if err := chip.fetched_handler(chip, chip.fetched); err != nil {
+		return err
+	}
+
+	chip.cycle_count++
+	if chip.profile != nil {
+		chip.profile.addresses[pc]++
+		chip.profile.opcodes[chip.fetched.Opcode]++
+		chip.profile.total++
+	}
+	if chip.recording != nil {
+		chip.recording.cycles++
+	}
+	if tracing {
+		chip.recordTrace(TraceEntry{PC: pc, Opcode: chip.fetched.Opcode, Before: before, After: chip.traceRegisters()})
+	}
+	if chip.OnStep != nil {
+		chip.OnStep(pc, chip.fetched)
+	}
+
+	return nil
+
+}
+
+// unknownOpcode handles an opcode that is not part of the instruction set. With an
+// OnUnknownOpcode hook it is reported and skipped so execution can continue, otherwise an
+// InvalidOpcodeError is returned with the program counter left on it.
+func (chip *Chip8) unknownOpcode(in Instruction) error {
+
+	if chip.OnUnknownOpcode == nil {
+		return &InvalidOpcodeError{Opcode: in.Opcode, PC: chip.program_counter}
+	}
+
+	chip.log(slog.LevelWarn, "invalid opcode skipped", hexAttr("opcode", in.Opcode), hexAttr("pc", chip.program_counter))
+
+	chip.OnUnknownOpcode(in.Opcode, chip.program_counter)
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// Pixel reports whether the display pixel at (x, y) is on. Coordinates outside the display are off.
+func (chip *Chip8) Pixel(x, y int) bool {
+
+	if chip.MegaMode() {
+		frame := chip.Display()
+		return frame.At(x, y)
+	}
+
+	width, height := chip.Resolution()
+
+	if x < 0 || y < 0 || x >= width || y >= height {
+		return false
+	}
+
+	return planePixel(&chip.display, x, y) != 0
+
+}
+
+// Resolution returns the size of the display in the current mode: 64x32, 128x64 in
+// SUPER-CHIP high resolution mode or 256x192 in Megachip mode.
+func (chip *Chip8) Resolution() (width, height int) {
+
+	if chip.MegaMode() {
+		return MegaWidth, MegaHeight
+	}
+	if chip.hires {
+		return HiResWidth, HiResHeight
+	}
+
+	return DisplayWidth, DisplayHeight
+
+}
+
+// Display returns a copy of the display.
+func (chip *Chip8) Display() Frame {
+
+	if chip.MegaMode() {
+		return Frame{Width: MegaWidth, Height: MegaHeight, Mega: chip.mega.megaFrame()}
+	}
+
+	width, height := chip.Resolution()
+
+	return Frame{Width: width, Height: height, Planes: chip.display}
+
+}
+
+// DisplayDirty reports whether the display changed since the last ConsumeFrame. Clearing and
+// scrolling count as changes even when no pixel was on. A new machine starts dirty.
+func (chip *Chip8) DisplayDirty() bool {
+	return chip.display_dirty
+}
+
+// ConsumeFrame returns a copy of the display and whether it changed since the last call, then
+// marks it clean. Frontends use it to skip the frames where nothing was drawn.
+func (chip *Chip8) ConsumeFrame() (Frame, bool) {
+
+	dirty := chip.display_dirty
+	chip.display_dirty = false
+
+	return chip.Display(), dirty
+
+}
+
+// Clear turns off every pixel of the display, on every plane.
+func (chip *Chip8) Clear() {
+	chip.display = [2]Plane{}
+	chip.display_dirty = true
+}
+
+// clearPlanes turns off the pixels of the given planes.
+func (chip *Chip8) clearPlanes(planes uint8) {
+
+	for p := range chip.display {
+		if planes&(1<<p) != 0 {
+			chip.display[p] = Plane{}
+		}
+	}
+
+	chip.display_dirty = true
+
+}
+
+// Timers returns the current values of the delay and sound timers.
+func (chip *Chip8) Timers() (delay, sound uint8) {
+	return chip.delay_timer, chip.sound_timer
+}
+
+// DelayTimerMillis returns the approximate time left on the delay timer in milliseconds,
+// based on its 60Hz decrement rate.
+func (chip *Chip8) DelayTimerMillis() int {
+	return timerMillis(chip.delay_timer)
+}
+
+// SoundTimerMillis returns the approximate time left on the sound timer in milliseconds,
+// based on its 60Hz decrement rate.
+func (chip *Chip8) SoundTimerMillis() int {
+	return timerMillis(chip.sound_timer)
+}
+
+func timerMillis(value uint8) int {
+	return int(value) * 1000 / 60
+}
+
+// SetRNG replaces the random source used by CXNN, e.g. with a scripted source for tests.
+// Save states do not include its position, use SetSeed for reproducible runs.
+func (chip *Chip8) SetRNG(rng RandomSource) {
+	chip.rng = rng
+	chip.rng_default = false
+}
+
+// SetSeed restarts the default random source used by CXNN from seed, for reproducible runs.
+func (chip *Chip8) SetSeed(seed int64) {
+	chip.rng = rand.New(rand.NewSource(seed))
+	chip.rng_seed = seed
+	chip.rng_draws = 0
+	chip.rng_default = true
+
+	chip.log(slog.LevelDebug, "random source seeded", "seed", seed)
+}
+
+// SetShiftQuirk selects the source of the 8XY6 and 8XYE shifts. When enabled, V[Y] is shifted
+// and stored in V[X], as on the original COSMAC VIP. When disabled (the default), V[X] is shifted
+// in place and V[Y] is ignored, as on CHIP-48 and SUPER-CHIP.
+func (chip *Chip8) SetShiftQuirk(enabled bool) {
+	chip.shift_quirk = enabled
+	chip.configureQuirk("shift")
+}
+
+// SetIndexIncrementQuirk selects whether FX55 and FX65 increment I by X+1 after storing or
+// loading the registers. Enabled by default, as on the original COSMAC VIP; SUPER-CHIP leaves I unchanged.
+func (chip *Chip8) SetIndexIncrementQuirk(enabled bool) {
+	chip.index_increment_quirk = enabled
+	chip.configureQuirk("index_increment")
+}
+
+// SetIndexOverflowQuirk selects whether FX1E sets V[F] to 1 when I goes past 0x0FFF,
+// and to 0 otherwise. Disabled by default, leaving V[F] untouched.
+func (chip *Chip8) SetIndexOverflowQuirk(enabled bool) {
+	chip.index_overflow_quirk = enabled
+	chip.configureQuirk("index_overflow")
+}
+
+// SetVFResetQuirk selects whether the logic instructions 8XY1, 8XY2 and 8XY3 reset V[F] to 0,
+// a side effect of the original COSMAC VIP interpreter. Disabled by default, leaving V[F] untouched.
+func (chip *Chip8) SetVFResetQuirk(enabled bool) {
+	chip.vf_reset_quirk = enabled
+	chip.configureQuirk("vf_reset")
+}
+
+// SetJumpQuirk selects how BNNN computes its target. When enabled, it is read as BXNN and jumps
+// to XNN + V[X], as on CHIP-48 and SUPER-CHIP. When disabled (the default), it jumps to NNN + V[0].
+func (chip *Chip8) SetJumpQuirk(enabled bool) {
+	chip.jump_quirk = enabled
+	chip.configureQuirk("jump")
+}
+
+// SetWrapQuirk selects how DXYN handles sprites crossing the edges of the screen. The starting
+// position always wraps around. When enabled, pixels past the right or bottom edge also wrap around
+// to the opposite side, as in Octo with its clip quirk off, so ROMs written in Octo render the
+// same. When disabled (the default), they are clipped, as on the original interpreter and in Octo
+// with its clip quirk on.
+func (chip *Chip8) SetWrapQuirk(enabled bool) {
+	chip.wrap_quirk = enabled
+	chip.configureQuirk("wrap")
+}
+
+// SetVerticalWrap makes DXYN wrap only the sprite rows that run past the bottom edge of the
+// screen around to the top row, while pixels past the right edge are still clipped. When
+// disabled (the default), rows past the bottom edge are dropped. Octo never wraps one edge
+// alone, its rule is the wrap quirk, see SetWrapQuirk.
+func (chip *Chip8) SetVerticalWrap(enabled bool) {
+	chip.vertical_wrap = enabled
+	chip.configureQuirk("vertical_wrap")
+}
+
+// SetDisplayWaitQuirk selects whether DXYN waits for the vertical blank, as on the COSMAC VIP:
+// a sprite is only drawn at the start of a 60Hz frame, so at most one per frame, until then the
+// instruction is retried. This throttles games tuned for the VIP to their intended speed.
+// Disabled by default, drawing immediately.
+func (chip *Chip8) SetDisplayWaitQuirk(enabled bool) {
+	chip.display_wait = enabled
+	chip.configureQuirk("display_wait")
+}
+
+// SetKey marks a key (0x0 to 0xF) as held down or released. Keys out of range are ignored.
+func (chip *Chip8) SetKey(k byte, down bool) {
+	if down {
+		chip.KeyDown(k)
+	} else {
+		chip.KeyUp(k)
+	}
+}
+
+// KeyDown marks a key (0x0 to 0xF) as held down. Keys out of range are ignored.
+func (chip *Chip8) KeyDown(k byte) {
+	if int(k) < len(chip.keypad) {
+		chip.keypad[k] = true
+	}
+}
+
+// KeyUp marks a key (0x0 to 0xF) as released. Keys out of range are ignored.
+func (chip *Chip8) KeyUp(k byte) {
+	if int(k) < len(chip.keypad) {
+		chip.keypad[k] = false
+	}
+}
+
+// SetSingleKey selects whether several keys can be held at once (the default) or only one.
+// In single key mode only the lowest held key is reported as pressed.
+func (chip *Chip8) SetSingleKey(enabled bool) {
+	chip.single_key = enabled
+}
+
+// keyPressed reports whether the given key is held, taking single key mode into account.
+func (chip *Chip8) keyPressed(key byte) bool {
+
+	if !chip.single_key {
+		return chip.keypad[key]
+	}
+
+	for k, held := range chip.keypad {
+		if held {
+			return byte(k) == key
+		}
+	}
+
+	return false
+
+}
@@ -0,0 +1,180 @@
+package chip8
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFetchExecute(t *testing.T) {
+
+	chip := New()
+	// V3 = 0x10, V3 += 0x25
+	if err := chip.LoadROMBytes([]byte{0x63, 0x10, 0x73, 0x25}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chip.Cycle(); err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := chip.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The instruction is decoded, nothing else happened yet.
+	if in.Opcode != 0x7325 || in.Op != 0x7 || in.X != 3 || in.NN != 0x25 {
+		t.Errorf("fetched %+v, want 7325", in)
+	}
+	if chip.program_counter != 0x202 || chip.registers[3] != 0x10 || chip.CycleCount() != 1 {
+		t.Errorf("fetch changed the machine: PC %04X, V3 %02X, %d cycles", chip.program_counter, chip.registers[3], chip.CycleCount())
+	}
+
+	// Fetching again gives the same instruction.
+	if again, err := chip.Fetch(); err != nil || again != in {
+		t.Errorf("second fetch gave %+v, %v", again, err)
+	}
+
+	if err := chip.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if chip.program_counter != 0x204 || chip.registers[3] != 0x35 || chip.CycleCount() != 2 {
+		t.Errorf("after execute: PC %04X, V3 %02X, %d cycles, want 0204, 35, 2", chip.program_counter, chip.registers[3], chip.CycleCount())
+	}
+
+}
+
+func TestExecuteFetchesFirst(t *testing.T) {
+
+	chip := New()
+	if err := chip.LoadROMBytes([]byte{0x6A, 0x42}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chip.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if chip.registers[0xA] != 0x42 || chip.program_counter != 0x202 {
+		t.Errorf("VA %02X, PC %04X after executing without a fetch, want 42 and 0202", chip.registers[0xA], chip.program_counter)
+	}
+
+}
+
+func TestFetchPastMemory(t *testing.T) {
+
+	chip := New()
+	chip.program_counter = uint16(chip.memorySize() - 1)
+
+	if _, err := chip.Fetch(); err == nil {
+		t.Error("fetching the last byte of memory succeeded")
+	}
+
+}
+
+func TestResetMatchesNew(t *testing.T) {
+
+	// Changes the registers, timers, memory, stack and display, then loops in a subroutine.
+	rom := []byte{
+		0x60, 0x05, // LD V0, 5
+		0xF0, 0x15, // LD DT, V0
+		0xF0, 0x18, // LD ST, V0
+		0xA3, 0x00, // LD I, 0x300
+		0xF0, 0x55, // LD [I], V0
+		0xD0, 0x15, // DRW V0, V1, 5
+		0x22, 0x0E, // CALL 0x20E
+		0x12, 0x0E, // JP 0x20E
+	}
+
+	for _, platform := range []Platform{PlatformChip8, PlatformXOChip, PlatformMegaChip} {
+
+		chip := New()
+		chip.SetPlatform(platform)
+		chip.SetSeed(1)
+		if err := chip.LoadROMBytes(rom); err != nil {
+			t.Fatal(err)
+		}
+		if err := chip.RunCycles(10); err != nil {
+			t.Fatal(err)
+		}
+		chip.KeyDown(0x3)
+		chip.TickTimers(timer_period * 3 / 2)
+
+		chip.Reset()
+
+		fresh := New()
+		fresh.SetPlatform(platform)
+		fresh.SetSeed(1)
+		if err := fresh.LoadROMBytes(rom); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(chip.snapshot(), fresh.snapshot()) {
+			t.Errorf("%v: reset state differs from a new machine:\n%+v\nwant\n%+v", platform, chip.State(), fresh.State())
+		}
+		if chip.Display() != fresh.Display() {
+			t.Errorf("%v: reset display differs from a new machine", platform)
+		}
+	}
+
+}
+
+func TestLoadROMAtETI660(t *testing.T) {
+
+	// LD V0, 0x42; JP 0x602
+	rom := []byte{0x60, 0x42, 0x16, 0x02}
+
+	chip := New()
+	if err := chip.LoadROMAt(rom, 0x600); err != nil {
+		t.Fatal(err)
+	}
+	if addr, entry := chip.LoadAddress(); addr != 0x600 || entry != 0x600 || chip.program_counter != 0x600 {
+		t.Fatalf("loaded at %03X, entry %03X, PC %03X, want 600", addr, entry, chip.program_counter)
+	}
+	if memory := chip.mem(); memory[0x600] != 0x60 || memory[0x603] != 0x02 || memory[DefaultLoadAddress] != 0 {
+		t.Fatal("the ROM is not at 0x600 alone")
+	}
+
+	if err := chip.RunCycles(3); err != nil {
+		t.Fatal(err)
+	}
+	if chip.registers[0] != 0x42 || chip.program_counter != 0x602 {
+		t.Errorf("V0 %02X, PC %03X, want 42 and 602", chip.registers[0], chip.program_counter)
+	}
+
+	// A reset restarts at the load address.
+	chip.Reset()
+	if chip.program_counter != 0x600 || chip.mem()[0x600] != 0x60 {
+		t.Errorf("reset PC %03X, want 600 with the ROM reloaded", chip.program_counter)
+	}
+
+	// The room left after 0x600 bounds the ROM.
+	if err := chip.LoadROMAt(make([]byte, chip.memorySize()-0x600+1), 0x600); !errors.Is(err, ErrROMTooLarge) {
+		t.Errorf("loading past the end of memory: %v, want ErrROMTooLarge", err)
+	}
+
+}
+
+func TestSetLoadAddress(t *testing.T) {
+
+	chip := New()
+	if err := chip.SetLoadAddress(0x600, 0x602); err != nil {
+		t.Fatal(err)
+	}
+	if err := chip.LoadROMBytes([]byte{0x00, 0x00, 0x60, 0x42}); err != nil {
+		t.Fatal(err)
+	}
+	if addr, entry := chip.LoadAddress(); addr != 0x600 || entry != 0x602 {
+		t.Fatalf("loaded at %03X, entry %03X, want 600 and 602", addr, entry)
+	}
+	if err := chip.Cycle(); err != nil {
+		t.Fatal(err)
+	}
+	if chip.registers[0] != 0x42 {
+		t.Errorf("V0 %02X, want 42 from the instruction at the entry point", chip.registers[0])
+	}
+
+	if err := chip.SetLoadAddress(0x1000, 0x1000); !errors.Is(err, ErrMemoryOutOfRange) {
+		t.Errorf("load address past memory: %v, want ErrMemoryOutOfRange", err)
+	}
+
+}
@@ -0,0 +1,107 @@
+package chip8
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultClockHz is the instruction rate used for ROMs without a recommended speed.
+const DefaultClockHz = 700
+
+// Recommended instruction rates for known ROM dumps, keyed by the hex SHA-1 of the ROM like
+// the entries of a library database, so that they apply whatever the file is called and to ROMs
+// loaded from memory.
+var recommended_clock = map[string]int{
+	// IBM Logo, the 132-byte dump in testdata
+	"1ba58656810b67fd131eb9af3e3987863bf26c90": 700,
+}
+
+// Recommended instruction rates for popular ROMs by normalized title, for the files loaded by
+// name whose dump is not in recommended_clock.
+var recommended_clock_title = map[string]int{
+	"pong":           500,
+	"pong2":          500,
+	"tetris":         500,
+	"brix":           500,
+	"breakout":       500,
+	"blinky":         1000,
+	"space invaders": 500,
+	"tank":           500,
+	"ufo":            500,
+	"missile":        500,
+	"wall":           500,
+	"blitz":          500,
+	"connect4":       500,
+	"tictac":         500,
+	"hidden":         500,
+	"tron":           1000,
+}
+
+// ClockHz returns the instruction rate the ROM should run at, in instructions per second.
+func (chip *Chip8) ClockHz() int {
+
+	if chip.clock_hz <= 0 {
+		return DefaultClockHz
+	}
+
+	return chip.clock_hz
+
+}
+
+// SetClockHz sets the instruction rate explicitly. Once set, loading a ROM
+// no longer applies its recommended speed.
+func (chip *Chip8) SetClockHz(hz int) {
+	chip.clock_hz = hz
+	chip.clock_override = true
+}
+
+// applyRecommendedClock sets the clock rate recommended for rom, loaded from the file name, if
+// any, unless the rate was set explicitly.
+func (chip *Chip8) applyRecommendedClock(rom []byte, name string) {
+
+	if chip.clock_override {
+		return
+	}
+
+	chip.clock_hz = RecommendedClockHz(rom, name)
+
+}
+
+// RecommendedClockHz returns the recommended instruction rate for a ROM: the rate of its dump if
+// it is known, else the rate for the title of its file name, if not empty, else DefaultClockHz.
+func RecommendedClockHz(rom []byte, name string) int {
+
+	sum := sha1.Sum(rom)
+	if hz, ok := recommended_clock[hex.EncodeToString(sum[:])]; ok {
+		return hz
+	}
+
+	if name != "" {
+		if hz, ok := recommended_clock_title[romTitle(name)]; ok {
+			return hz
+		}
+	}
+
+	return DefaultClockHz
+
+}
+
+// romTitle normalizes a ROM path into a title: "./roms/Space Invaders [David Winter].ch8"
+// becomes "space invaders".
+func romTitle(path string) string {
+
+	title := filepath.Base(path)
+	title = strings.TrimSuffix(title, filepath.Ext(title))
+
+	// Drop the author and version annotations commonly found in ROM file names.
+	if i := strings.IndexAny(title, "[("); i >= 0 {
+		title = title[:i]
+	}
+
+	title = strings.ReplaceAll(title, "_", " ")
+
+	return strings.ToLower(strings.TrimSpace(title))
+
+}
@@ -0,0 +1,63 @@
+package chip8
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRecommendedClock(t *testing.T) {
+
+	rom, err := os.ReadFile("testdata/ibm_logo.ch8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A known dump gets its rate whatever its name: the IBM logo named Pong is not run at the
+	// rate of Pong.
+	if hz := RecommendedClockHz(rom, ""); hz != 700 {
+		t.Errorf("IBM logo without a name: %d Hz, want 700", hz)
+	}
+	if hz := RecommendedClockHz(rom, "roms/Pong.ch8"); hz != 700 {
+		t.Errorf("IBM logo named Pong: %d Hz, want 700", hz)
+	}
+
+	// An unknown dump falls back to its title, then to the default.
+	other := []byte{0x60, 0x02, 0x12, 0x00}
+	if hz := RecommendedClockHz(other, "roms/Pong [Paul Vervalin, 1990].ch8"); hz != 500 {
+		t.Errorf("unknown dump named Pong: %d Hz, want 500", hz)
+	}
+	if hz := RecommendedClockHz(other, "roms/mine.ch8"); hz != DefaultClockHz {
+		t.Errorf("unknown ROM: %d Hz, want %d", hz, DefaultClockHz)
+	}
+
+	// Loading applies the rate, by hash before the title of the file.
+	chip := New()
+	if err := chip.LoadROMBytes(other); err != nil {
+		t.Fatal(err)
+	}
+	if hz := chip.ClockHz(); hz != DefaultClockHz {
+		t.Errorf("after LoadROMBytes of an unknown ROM: %d Hz, want %d", hz, DefaultClockHz)
+	}
+	if err := chip.LoadNamedROM("roms/pong.ch8", other); err != nil {
+		t.Fatal(err)
+	}
+	if hz := chip.ClockHz(); hz != 500 {
+		t.Errorf("after loading an unknown dump named pong.ch8: %d Hz, want 500", hz)
+	}
+	if err := chip.LoadNamedROM("roms/pong.ch8", rom); err != nil {
+		t.Fatal(err)
+	}
+	if hz := chip.ClockHz(); hz != 700 {
+		t.Errorf("after loading the IBM logo named pong.ch8: %d Hz, want 700", hz)
+	}
+
+	// A rate set explicitly wins.
+	chip.SetClockHz(900)
+	if err := chip.LoadROMBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	if hz := chip.ClockHz(); hz != 900 {
+		t.Errorf("after SetClockHz(900): %d Hz", hz)
+	}
+
+}
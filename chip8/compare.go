@@ -0,0 +1,162 @@
+package chip8
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// Divergence describes how two machines differ, see Compare.
+type Divergence struct {
+	// Cycle - instructions each machine executed when the divergence was found
+	Cycle uint64
+
+	// Registers - the registers, timers and stack entries that differ, e.g. "V3 05 != 07"
+	Registers []string
+
+	// Pixels - the display pixels that differ, of the area both displays have
+	Pixels []image.Point
+}
+
+// Diverged reports whether the machines differ at all.
+func (d Divergence) Diverged() bool {
+	return len(d.Registers) > 0 || len(d.Pixels) > 0
+}
+
+// String summarizes the divergence on one line, e.g. "cycle 120: PC 0204 != 0206, 3 pixels".
+func (d Divergence) String() string {
+
+	if !d.Diverged() {
+		return fmt.Sprintf("cycle %d: no divergence", d.Cycle)
+	}
+
+	parts := append([]string(nil), d.Registers...)
+	if len(d.Pixels) > 0 {
+		parts = append(parts, fmt.Sprintf("%d pixels", len(d.Pixels)))
+	}
+
+	return fmt.Sprintf("cycle %d: %s", d.Cycle, strings.Join(parts, ", "))
+
+}
+
+// Compare returns the differences between the registers, timers, stacks and displays of two
+// machines. Only the displays that are shown are compared, the Megachip back buffer is not.
+func Compare(a, b *Chip8) Divergence {
+
+	d := Divergence{Cycle: a.cycle_count, Registers: compareRegisters(a, b)}
+
+	fa, fb := a.Display(), b.Display()
+	if fa.Width != fb.Width || fa.Height != fb.Height {
+		d.Registers = append(d.Registers, fmt.Sprintf("display %dx%d != %dx%d", fa.Width, fa.Height, fb.Width, fb.Height))
+	}
+	d.Pixels = DiffFrames(fa, fb)
+
+	return d
+
+}
+
+// compareRegisters lists the registers, timers and stack entries that differ.
+func compareRegisters(a, b *Chip8) []string {
+
+	var differences []string
+
+	differ := func(name, format string, x, y any) {
+		if x != y {
+			differences = append(differences, fmt.Sprintf("%s "+format+" != "+format, name, x, y))
+		}
+	}
+
+	for r := range a.registers {
+		if a.registers[r] != b.registers[r] {
+			differ(fmt.Sprintf("V%X", r), "%02X", a.registers[r], b.registers[r])
+		}
+	}
+	differ("I", "%04X", a.index_register, b.index_register)
+	differ("PC", "%04X", a.program_counter, b.program_counter)
+	differ("SP", "%d", a.stack_pointer, b.stack_pointer)
+	for i := range min(a.stack_pointer, b.stack_pointer) {
+		if a.stack[i] != b.stack[i] {
+			differ(fmt.Sprintf("stack[%d]", i), "%04X", a.stack[i], b.stack[i])
+		}
+	}
+	differ("DT", "%d", a.delay_timer, b.delay_timer)
+	differ("ST", "%d", a.sound_timer, b.sound_timer)
+
+	return differences
+
+}
+
+// DiffFrames returns the pixels of the area both frames have where their colors differ.
+func DiffFrames(a, b Frame) []image.Point {
+
+	var pixels []image.Point
+
+	for y := range min(a.Height, b.Height) {
+		for x := range min(a.Width, b.Width) {
+			if a.Color(x, y, DefaultPalette) != b.Color(x, y, DefaultPalette) {
+				pixels = append(pixels, image.Point{x, y})
+			}
+		}
+	}
+
+	return pixels
+
+}
+
+// Lockstep runs two machines instruction by instruction, e.g. a ROM against another build of
+// it or with other quirks, and finds where they diverge. Both get the same keys and their
+// timers tick together. Nothing is shared between the machines: each has its own random
+// source, which should have the same seed, see SetSeed.
+type Lockstep struct {
+	A, B *Chip8
+
+	// First - the first divergence found, nil while the machines agree
+	First *Divergence
+}
+
+// NewLockstep returns a lockstep run of two machines.
+func NewLockstep(a, b *Chip8) *Lockstep {
+	return &Lockstep{A: a, B: b}
+}
+
+// SetKeys holds the keys of keys on both machines and releases the others.
+func (l *Lockstep) SetKeys(keys [16]bool) {
+	l.A.keypad = keys
+	l.B.keypad = keys
+}
+
+// Frame runs a 60Hz frame: the instructions of a frame at the clock rate of A on each machine,
+// comparing their registers after each instruction, then their timers. It returns the
+// divergence at the end of the frame, displays included. The first divergence found is kept
+// in First. An error of either machine ends the frame, and is prefixed with "A: " or "B: ".
+func (l *Lockstep) Frame() (Divergence, error) {
+
+	cycles := max(l.A.ClockHz()/60, 1)
+
+	for i := 0; i < cycles; i++ {
+
+		if err := l.A.Cycle(); err != nil {
+			return Compare(l.A, l.B), fmt.Errorf("A: %w", err)
+		}
+		if err := l.B.Cycle(); err != nil {
+			return Compare(l.A, l.B), fmt.Errorf("B: %w", err)
+		}
+
+		if l.First == nil {
+			if registers := compareRegisters(l.A, l.B); len(registers) > 0 {
+				l.First = &Divergence{Cycle: l.A.cycle_count, Registers: registers, Pixels: DiffFrames(l.A.Display(), l.B.Display())}
+			}
+		}
+	}
+
+	l.A.DecrementTimers()
+	l.B.DecrementTimers()
+
+	d := Compare(l.A, l.B)
+	if l.First == nil && d.Diverged() {
+		l.First = &d
+	}
+
+	return d, nil
+
+}
@@ -0,0 +1,779 @@
+package chip8
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestVectors runs the opcode conformance vectors of testdata/vectors, and fails when an
+// instruction has no vector, so a new opcode comes with its own.
+func TestVectors(t *testing.T) {
+
+	files, err := filepath.Glob("testdata/vectors/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test vectors in testdata/vectors")
+	}
+
+	covered := map[string]bool{}
+
+	for _, file := range files {
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var vectors []testVector
+		if err := json.Unmarshal(data, &vectors); err != nil {
+			t.Fatalf("%s: could not parse test vectors: %v", file, err)
+		}
+
+		for _, vector := range vectors {
+
+			opcode, err := strconv.ParseUint(vector.Opcode, 16, 16)
+			if err != nil {
+				t.Fatalf("%s: %s: invalid opcode %q", file, vector.Name, vector.Opcode)
+			}
+			for _, pattern := range instruction_patterns {
+				if matchPattern(pattern, uint16(opcode)) {
+					covered[pattern] = true
+				}
+			}
+
+			t.Run(filepath.Base(file)+"/"+vector.Name, func(t *testing.T) {
+
+				mismatches, err := vector.run()
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, mismatch := range mismatches {
+					t.Error(mismatch)
+				}
+
+			})
+		}
+	}
+
+	for _, pattern := range instruction_patterns {
+		if !covered[pattern] {
+			t.Errorf("no vector covers %s", pattern)
+		}
+	}
+
+}
+
+// testVector describes a single opcode conformance case: the machine is set up with
+// the initial state, the opcode is executed once and the result is compared against
+// the expected state. Only the fields present in the expected state are checked.
+type testVector struct {
+	Name     string      `json:"name"`
+	Opcode   string      `json:"opcode"`
+	Initial  vectorState `json:"initial"`
+	Expected vectorState `json:"expected"`
+}
+
+// vectorState is a partial machine state. Registers are keyed by their hex index ("0" to "F")
+// and memory by address ("0x300" or "768").
+type vectorState struct {
+	V          map[string]uint8 `json:"v"`
+	I          *uint32          `json:"i"`
+	PC         *uint16          `json:"pc"`
+	SP         *uint8           `json:"sp"`
+	Stack      []uint16         `json:"stack"`
+	DelayTimer *uint8           `json:"dt"`
+	SoundTimer *uint8           `json:"st"`
+	Memory     map[string]uint8 `json:"memory"`
+
+	// Display holds the top rows of the screen, '#' for a pixel on and '.' for off.
+	// With XO-CHIP, '2' is a pixel on in the second plane only and '3' in both.
+	// Rows may be shorter than the screen, only the given pixels are set or checked.
+	Display []string `json:"display"`
+
+	// Keys are the keypad keys held down, only meaningful in the initial state.
+	Keys []uint8 `json:"keys"`
+
+	// Planes are the XO-CHIP planes drawn to, 1 by default.
+	Planes *uint8 `json:"planes"`
+
+	// Pattern and Pitch are the XO-CHIP audio pattern and its pitch.
+	Pattern []uint8 `json:"pattern"`
+	Pitch   *uint8  `json:"pitch"`
+
+	// HiRes is the SUPER-CHIP 128x64 mode.
+	HiRes *bool `json:"hires"`
+
+	// RPL holds the first SUPER-CHIP user flags.
+	RPL []uint8 `json:"rpl"`
+
+	// Platform selects the instruction set by name, see ParsePlatform. Only meaningful in the initial state.
+	Platform string `json:"platform"`
+
+	// Mega is the Megachip 256x192 color mode, the fields below are its state and need the
+	// megachip platform.
+	Mega *bool `json:"mega"`
+
+	// Back holds the top rows of the Megachip back buffer as palette indices, two hex digits per
+	// pixel. Rows may be shorter than the screen, only the given pixels are set or checked.
+	Back []string `json:"back"`
+
+	// Colors and Front are pixels of the Megachip back and front buffers keyed by "x,y", as
+	// RRGGBB hex colors.
+	Colors map[string]string `json:"colors"`
+	Front  map[string]string `json:"front"`
+
+	// Palette holds Megachip colors keyed by their hex index, as AARRGGBB hex colors.
+	Palette map[string]string `json:"palette"`
+
+	// SpriteWidth, SpriteHeight, Alpha, Blend and Collision are set by the Megachip 03NN, 04NN,
+	// 05NN, 080N and 09NN.
+	SpriteWidth  *uint8 `json:"sprite_width"`
+	SpriteHeight *uint8 `json:"sprite_height"`
+	Alpha        *uint8 `json:"alpha"`
+	Blend        *uint8 `json:"blend"`
+	Collision    *uint8 `json:"collision"`
+
+	// Sample is the Megachip digitised sound started by 060N. Only meaningful in the expected state.
+	Sample *vectorSample `json:"sample"`
+
+	// WaitKey is the key FX0A is waiting to be released, -1 if it is not waiting.
+	WaitKey *int `json:"wait_key"`
+
+	// State is the RunState after the opcode, e.g. "waiting for key". Only meaningful in the
+	// expected state.
+	State string `json:"state"`
+
+	// VBlank is set when a 60Hz frame began since the last DXYN waiting for it, see the
+	// display_wait quirk. It is set on a fresh machine.
+	VBlank *bool `json:"vblank"`
+
+	// Dirty is whether the instruction changed the display, see DisplayDirty. Only meaningful
+	// in the expected state.
+	Dirty *bool `json:"dirty"`
+
+	// SingleKey enables single key mode, only meaningful in the initial state.
+	SingleKey bool `json:"single_key"`
+
+	// Quirks are set explicitly by name, only meaningful in the initial state.
+	Quirks map[string]bool `json:"quirks"`
+
+	// MemoryPolicy selects the memory policy by name, see ParseMemoryPolicy, and ProtectMemory
+	// the protection of the interpreter area. Only meaningful in the initial state.
+	MemoryPolicy  string `json:"memory_policy"`
+	ProtectMemory bool   `json:"protect_memory"`
+
+	// Strict enables strict mode, only meaningful in the initial state.
+	Strict bool `json:"strict"`
+
+	// HaltDetection enables halt detection, see SetHaltDetection. Only meaningful in the
+	// initial state.
+	HaltDetection bool `json:"halt_detection"`
+
+	// SkipInvalid sets an OnUnknownOpcode hook, so unknown opcodes are skipped instead of
+	// failing. Only meaningful in the initial state.
+	SkipInvalid bool `json:"skip_invalid"`
+
+	// Seed seeds the random source, only meaningful in the initial state.
+	Seed int64 `json:"seed"`
+
+	// Random scripts the values of the random source, overriding Seed. Only meaningful in
+	// the initial state.
+	Random []uint32 `json:"random"`
+
+	// Log lists the messages logged by the instruction, each preceded by its level, e.g.
+	// "WARN invalid opcode skipped". Only meaningful in the expected state.
+	Log []string `json:"log"`
+
+	// Error is the expected error message, only meaningful in the expected state.
+	Error string `json:"error"`
+}
+
+// vectorSample is the digitised sound of a vectorState: where its samples are in memory, how
+// many there are, their rate and whether they loop and are playing.
+type vectorSample struct {
+	Address int  `json:"address"`
+	Length  int  `json:"length"`
+	Rate    int  `json:"rate"`
+	Loop    bool `json:"loop"`
+	Playing bool `json:"playing"`
+}
+
+// vectorMismatch reports a field that did not match its expected value.
+type vectorMismatch struct {
+	Vector string
+	Field  string
+	Want   string
+	Got    string
+}
+
+func (m vectorMismatch) String() string {
+	return fmt.Sprintf("%s: %s = %s, want %s", m.Vector, m.Field, m.Got, m.Want)
+}
+
+// run executes the vector and compares the resulting state.
+func (vector testVector) run() ([]vectorMismatch, error) {
+
+	opcode, err := strconv.ParseUint(vector.Opcode, 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid opcode %q", vector.Opcode)
+	}
+
+	chip := New()
+
+	if err := vector.Initial.apply(chip); err != nil {
+		return nil, err
+	}
+
+	chip.mem()[chip.program_counter] = byte(opcode >> 8)
+	chip.mem()[chip.program_counter+1] = byte(opcode)
+
+	var logged []string
+	chip.SetLogger(slog.New(vectorLog{&logged}))
+
+	// Only the changes made by the instruction count.
+	chip.display_dirty = false
+
+	cycle_err := chip.Cycle()
+
+	var mismatches []vectorMismatch
+
+	check := func(field string, want, got int) {
+		if want != got {
+			mismatches = append(mismatches, vectorMismatch{vector.Name, field, fmt.Sprintf("0x%X", want), fmt.Sprintf("0x%X", got)})
+		}
+	}
+
+	expected := vector.Expected
+
+	for name, value := range expected.V {
+		reg, err := parseRegister(name)
+		if err != nil {
+			return nil, err
+		}
+		check("V"+name, int(value), int(chip.registers[reg]))
+	}
+
+	if expected.I != nil {
+		check("I", int(*expected.I), int(chip.index_register))
+	}
+	if expected.PC != nil {
+		check("PC", int(*expected.PC), int(chip.program_counter))
+	}
+	if expected.SP != nil {
+		check("SP", int(*expected.SP), int(chip.stack_pointer))
+	}
+	for i, value := range expected.Stack {
+		if i >= len(chip.stack) {
+			return nil, errors.New("stack too deep")
+		}
+		check(fmt.Sprintf("stack[%d]", i), int(value), int(chip.stack[i]))
+	}
+
+	if expected.Planes != nil {
+		check("planes", int(*expected.Planes), int(chip.planes))
+	}
+	if expected.Pitch != nil {
+		check("pitch", int(*expected.Pitch), int(chip.pitch))
+	}
+	for i, value := range expected.Pattern {
+		if i >= len(chip.pattern) {
+			return nil, errors.New("pattern too long")
+		}
+		check(fmt.Sprintf("pattern[%d]", i), int(value), int(chip.pattern[i]))
+	}
+
+	if expected.HiRes != nil && *expected.HiRes != chip.hires {
+		mismatches = append(mismatches, vectorMismatch{vector.Name, "hires", strconv.FormatBool(*expected.HiRes), strconv.FormatBool(chip.hires)})
+	}
+	for i, value := range expected.RPL {
+		if i >= len(chip.rpl) {
+			return nil, errors.New("too many rpl flags")
+		}
+		check(fmt.Sprintf("rpl[%d]", i), int(value), int(chip.rpl[i]))
+	}
+
+	if expected.WaitKey != nil {
+		got := -1
+		if chip.key_waiting {
+			got = int(chip.key_wait)
+		}
+		if got != *expected.WaitKey {
+			mismatches = append(mismatches, vectorMismatch{vector.Name, "wait_key", strconv.Itoa(*expected.WaitKey), strconv.Itoa(got)})
+		}
+	}
+
+	if expected.State != "" && expected.State != chip.RunState().String() {
+		mismatches = append(mismatches, vectorMismatch{vector.Name, "state", expected.State, chip.RunState().String()})
+	}
+
+	if expected.VBlank != nil && *expected.VBlank != chip.vblank {
+		mismatches = append(mismatches, vectorMismatch{vector.Name, "vblank", strconv.FormatBool(*expected.VBlank), strconv.FormatBool(chip.vblank)})
+	}
+
+	if expected.Dirty != nil && *expected.Dirty != chip.display_dirty {
+		mismatches = append(mismatches, vectorMismatch{vector.Name, "dirty", strconv.FormatBool(*expected.Dirty), strconv.FormatBool(chip.display_dirty)})
+	}
+
+	if expected.DelayTimer != nil {
+		check("DT", int(*expected.DelayTimer), int(chip.delay_timer))
+	}
+	if expected.SoundTimer != nil {
+		check("ST", int(*expected.SoundTimer), int(chip.sound_timer))
+	}
+
+	for y, row := range expected.Display {
+		if y >= HiResHeight || len(row) > HiResWidth {
+			return nil, errors.New("display out of range")
+		}
+		got := make([]byte, len(row))
+		for x := range row {
+			got[x] = pixel_chars[planePixel(&chip.display, x, y)]
+		}
+		if string(got) != row {
+			mismatches = append(mismatches, vectorMismatch{vector.Name, fmt.Sprintf("display[%d]", y), row, string(got)})
+		}
+	}
+
+	for name, value := range expected.Memory {
+		addr, err := chip.parseAddress(name)
+		if err != nil {
+			return nil, err
+		}
+		check("memory["+name+"]", int(value), int(chip.mem()[addr]))
+	}
+
+	if expected.usesMega() {
+		result, err := expected.checkMega(vector.Name, chip)
+		if err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, result...)
+	}
+
+	if expected.Log != nil {
+		if want, got := strings.Join(expected.Log, "; "), strings.Join(logged, "; "); want != got {
+			mismatches = append(mismatches, vectorMismatch{vector.Name, "log", strconv.Quote(want), strconv.Quote(got)})
+		}
+	}
+
+	got_err := ""
+	if cycle_err != nil {
+		got_err = cycle_err.Error()
+	}
+	if got_err != expected.Error {
+		mismatches = append(mismatches, vectorMismatch{vector.Name, "error", strconv.Quote(expected.Error), strconv.Quote(got_err)})
+	}
+
+	return mismatches, nil
+
+}
+
+// vectorLog is a slog handler collecting the messages logged while a vector runs.
+type vectorLog struct {
+	messages *[]string
+}
+
+func (vectorLog) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h vectorLog) Handle(_ context.Context, r slog.Record) error {
+	*h.messages = append(*h.messages, r.Level.String()+" "+r.Message)
+	return nil
+}
+
+func (h vectorLog) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h vectorLog) WithGroup(string) slog.Handler      { return h }
+
+// apply copies the fields present in the state onto the machine.
+func (state vectorState) apply(chip *Chip8) error {
+
+	// The platform comes first, Megachip has more memory.
+	if state.Platform != "" {
+		platform, err := ParsePlatform(state.Platform)
+		if err != nil {
+			return err
+		}
+		chip.SetPlatform(platform)
+	}
+
+	for name, value := range state.V {
+		reg, err := parseRegister(name)
+		if err != nil {
+			return err
+		}
+		chip.registers[reg] = value
+	}
+
+	if state.I != nil {
+		chip.index_register = *state.I
+	}
+	if state.PC != nil {
+		chip.program_counter = *state.PC
+	}
+	if state.SP != nil {
+		chip.stack_pointer = *state.SP
+	}
+	if len(state.Stack) > len(chip.stack) {
+		return errors.New("stack too deep")
+	}
+	copy(chip.stack[:], state.Stack)
+
+	if state.DelayTimer != nil {
+		chip.delay_timer = *state.DelayTimer
+	}
+	if state.SoundTimer != nil {
+		chip.sound_timer = *state.SoundTimer
+	}
+
+	for name, value := range state.Memory {
+		addr, err := chip.parseAddress(name)
+		if err != nil {
+			return err
+		}
+		chip.mem()[addr] = value
+	}
+
+	for y, row := range state.Display {
+		if y >= HiResHeight || len(row) > HiResWidth {
+			return errors.New("display out of range")
+		}
+		for x, pixel := range row {
+			planes := strings.IndexRune(pixel_chars, pixel)
+			if planes < 0 {
+				return fmt.Errorf("invalid pixel %q", pixel)
+			}
+			setPlanePixel(&chip.display, x, y, uint8(planes))
+		}
+	}
+
+	for _, key := range state.Keys {
+		if key > 0xF {
+			return fmt.Errorf("invalid key %d", key)
+		}
+		chip.KeyDown(key)
+	}
+
+	if state.usesMega() {
+		if err := state.applyMega(chip); err != nil {
+			return err
+		}
+	}
+
+	if state.HiRes != nil {
+		chip.hires = *state.HiRes
+	}
+	if state.Planes != nil {
+		chip.planes = *state.Planes & 3
+	}
+	if state.Pitch != nil {
+		chip.pitch = *state.Pitch
+	}
+	if len(state.Pattern) > len(chip.pattern) {
+		return errors.New("pattern too long")
+	}
+	copy(chip.pattern[:], state.Pattern)
+	if len(state.RPL) > len(chip.rpl) {
+		return errors.New("too many rpl flags")
+	}
+	copy(chip.rpl[:], state.RPL)
+
+	if state.WaitKey != nil && *state.WaitKey >= 0 {
+		if *state.WaitKey > 0xF {
+			return fmt.Errorf("invalid wait key %d", *state.WaitKey)
+		}
+		chip.key_wait = byte(*state.WaitKey)
+		chip.key_waiting = true
+	}
+
+	if state.VBlank != nil {
+		chip.vblank = *state.VBlank
+	}
+
+	if state.MemoryPolicy != "" {
+		policy, err := ParseMemoryPolicy(state.MemoryPolicy)
+		if err != nil {
+			return err
+		}
+		chip.SetMemoryPolicy(policy)
+	}
+	chip.SetMemoryProtection(state.ProtectMemory)
+
+	chip.SetSingleKey(state.SingleKey)
+	chip.SetStrict(state.Strict)
+	chip.SetHaltDetection(state.HaltDetection)
+	if state.SkipInvalid {
+		chip.OnUnknownOpcode = func(opcode uint16, pc uint16) {}
+	}
+	if state.Random != nil {
+		chip.SetRNG(&SequenceSource{Values: state.Random})
+	} else {
+		chip.SetSeed(state.Seed)
+	}
+
+	for quirk, enabled := range state.Quirks {
+		switch quirk {
+		case "shift":
+			chip.SetShiftQuirk(enabled)
+		case "index_increment":
+			chip.SetIndexIncrementQuirk(enabled)
+		case "index_overflow":
+			chip.SetIndexOverflowQuirk(enabled)
+		case "vf_reset":
+			chip.SetVFResetQuirk(enabled)
+		case "jump":
+			chip.SetJumpQuirk(enabled)
+		case "wrap":
+			chip.SetWrapQuirk(enabled)
+		case "vertical_wrap":
+			chip.SetVerticalWrap(enabled)
+		case "display_wait":
+			chip.SetDisplayWaitQuirk(enabled)
+		default:
+			return fmt.Errorf("unknown quirk %q", quirk)
+		}
+	}
+
+	if int(chip.program_counter)+1 >= chip.memorySize() {
+		return errors.New("program counter out of range")
+	}
+
+	return nil
+
+}
+
+func parseRegister(name string) (int, error) {
+
+	reg, err := strconv.ParseUint(name, 16, 8)
+	if err != nil || reg > 0xF {
+		return 0, fmt.Errorf("invalid register %q", name)
+	}
+
+	return int(reg), nil
+
+}
+
+// parseAddress parses a memory address, which must be within the memory of the platform.
+func (chip *Chip8) parseAddress(name string) (int, error) {
+
+	addr, err := strconv.ParseUint(name, 0, 32)
+	if err != nil || addr >= uint64(len(chip.mem())) {
+		return 0, fmt.Errorf("invalid address %q", name)
+	}
+
+	return int(addr), nil
+
+}
+
+// usesMega reports whether the state has any Megachip field.
+func (state vectorState) usesMega() bool {
+	return state.Mega != nil || state.Back != nil || state.Colors != nil || state.Front != nil ||
+		state.Palette != nil || state.SpriteWidth != nil || state.SpriteHeight != nil ||
+		state.Alpha != nil || state.Blend != nil || state.Collision != nil || state.Sample != nil
+}
+
+// applyMega copies the Megachip fields present in the state onto the machine.
+func (state vectorState) applyMega(chip *Chip8) error {
+
+	m := chip.mega
+	if m == nil {
+		return errors.New("megachip state without the megachip platform")
+	}
+
+	if state.Mega != nil {
+		m.On = *state.Mega
+	}
+
+	for y, row := range state.Back {
+		if y >= MegaHeight || len(row) > 2*MegaWidth || len(row)%2 != 0 {
+			return errors.New("back buffer out of range")
+		}
+		for x := 0; 2*x < len(row); x++ {
+			index, err := strconv.ParseUint(row[2*x:2*x+2], 16, 8)
+			if err != nil {
+				return fmt.Errorf("invalid palette index %q", row[2*x:2*x+2])
+			}
+			m.Indices[y*MegaWidth+x] = byte(index)
+		}
+	}
+
+	for name, value := range state.Colors {
+		p, err := parsePixel(name)
+		if err != nil {
+			return err
+		}
+		if m.Back[p], err = parseColor(value, false); err != nil {
+			return err
+		}
+	}
+	if state.Front != nil {
+		front := *m.megaFrame()
+		for name, value := range state.Front {
+			p, err := parsePixel(name)
+			if err != nil {
+				return err
+			}
+			if front.Pixels[p], err = parseColor(value, false); err != nil {
+				return err
+			}
+		}
+		m.Front = &front
+	}
+
+	for name, value := range state.Palette {
+		index, err := strconv.ParseUint(name, 16, 8)
+		if err != nil {
+			return fmt.Errorf("invalid palette index %q", name)
+		}
+		if m.Palette[index], err = parseColor(value, true); err != nil {
+			return err
+		}
+	}
+
+	for _, field := range []struct {
+		value *uint8
+		dst   *byte
+	}{
+		{state.SpriteWidth, &m.SpriteWidth},
+		{state.SpriteHeight, &m.SpriteHeight},
+		{state.Alpha, &m.Alpha},
+		{state.Blend, &m.Blend},
+		{state.Collision, &m.Collision},
+	} {
+		if field.value != nil {
+			*field.dst = *field.value
+		}
+	}
+
+	return nil
+
+}
+
+// checkMega compares the Megachip fields present in the expected state.
+func (state vectorState) checkMega(vector string, chip *Chip8) ([]vectorMismatch, error) {
+
+	m := chip.mega
+	if m == nil {
+		return nil, errors.New("megachip state without the megachip platform")
+	}
+
+	var mismatches []vectorMismatch
+
+	mismatch := func(field, want, got string) {
+		if want != got {
+			mismatches = append(mismatches, vectorMismatch{vector, field, want, got})
+		}
+	}
+
+	if state.Mega != nil {
+		mismatch("mega", strconv.FormatBool(*state.Mega), strconv.FormatBool(m.On))
+	}
+
+	for y, row := range state.Back {
+		if y >= MegaHeight || len(row) > 2*MegaWidth {
+			return nil, errors.New("back buffer out of range")
+		}
+		var got strings.Builder
+		for x := 0; 2*x < len(row); x++ {
+			fmt.Fprintf(&got, "%02X", m.Indices[y*MegaWidth+x])
+		}
+		mismatch(fmt.Sprintf("back[%d]", y), strings.ToUpper(row), got.String())
+	}
+
+	for name, value := range state.Colors {
+		p, err := parsePixel(name)
+		if err != nil {
+			return nil, err
+		}
+		c := m.Back[p]
+		mismatch("colors["+name+"]", strings.ToUpper(value), fmt.Sprintf("%02X%02X%02X", c.R, c.G, c.B))
+	}
+	for name, value := range state.Front {
+		p, err := parsePixel(name)
+		if err != nil {
+			return nil, err
+		}
+		c := m.megaFrame().Pixels[p]
+		mismatch("front["+name+"]", strings.ToUpper(value), fmt.Sprintf("%02X%02X%02X", c.R, c.G, c.B))
+	}
+
+	for name, value := range state.Palette {
+		index, err := strconv.ParseUint(name, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid palette index %q", name)
+		}
+		c := m.Palette[index]
+		mismatch("palette["+name+"]", strings.ToUpper(value), fmt.Sprintf("%02X%02X%02X%02X", c.A, c.R, c.G, c.B))
+	}
+
+	for _, field := range []struct {
+		name  string
+		value *uint8
+		got   byte
+	}{
+		{"sprite_width", state.SpriteWidth, m.SpriteWidth},
+		{"sprite_height", state.SpriteHeight, m.SpriteHeight},
+		{"alpha", state.Alpha, m.Alpha},
+		{"blend", state.Blend, m.Blend},
+		{"collision", state.Collision, m.Collision},
+	} {
+		if field.value != nil {
+			mismatch(field.name, fmt.Sprintf("0x%X", *field.value), fmt.Sprintf("0x%X", field.got))
+		}
+	}
+
+	if state.Sample != nil {
+		s := m.Sample
+		got := vectorSample{Address: s.Address, Length: s.Length, Rate: s.Rate, Loop: s.Loop, Playing: s.Playing}
+		mismatch("sample", fmt.Sprintf("%+v", *state.Sample), fmt.Sprintf("%+v", got))
+	}
+
+	return mismatches, nil
+
+}
+
+// parsePixel parses the "x,y" of a Megachip pixel into its index in a buffer.
+func parsePixel(name string) (int, error) {
+
+	x, y, ok := strings.Cut(name, ",")
+	px, err_x := strconv.Atoi(strings.TrimSpace(x))
+	py, err_y := strconv.Atoi(strings.TrimSpace(y))
+
+	if !ok || err_x != nil || err_y != nil || px < 0 || py < 0 || px >= MegaWidth || py >= MegaHeight {
+		return 0, fmt.Errorf("invalid pixel %q", name)
+	}
+
+	return py*MegaWidth + px, nil
+
+}
+
+// parseColor parses an RRGGBB hex color, or AARRGGBB when alpha is set. Colors without alpha are opaque.
+func parseColor(s string, alpha bool) (color.RGBA, error) {
+
+	digits := 6
+	if alpha {
+		digits = 8
+	}
+
+	value, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || len(s) != digits {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+
+	c := color.RGBA{R: byte(value >> 16), G: byte(value >> 8), B: byte(value), A: 0xFF}
+	if alpha {
+		c.A = byte(value >> 24)
+	}
+
+	return c, nil
+
+}
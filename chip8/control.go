@@ -0,0 +1,118 @@
+package chip8
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Pause stops Run from executing instructions and ticking the timers until Resume. The frame
+// callback is still called, so a frontend keeps polling its input and can resume.
+func (chip *Chip8) Pause() {
+
+	chip.paused = true
+
+	// The timers stop, so does the beep. The next tick after Resume restarts it.
+	if chip.sound_playing {
+		chip.sound_playing = false
+		if chip.OnSound != nil {
+			chip.OnSound(false)
+		}
+	}
+
+	chip.log(slog.LevelInfo, "paused", hexAttr("pc", chip.program_counter))
+
+}
+
+// Resume continues a paused run where it stopped.
+func (chip *Chip8) Resume() {
+	chip.paused = false
+	chip.log(slog.LevelInfo, "resumed")
+}
+
+// Paused reports whether the machine is paused.
+func (chip *Chip8) Paused() bool {
+	return chip.paused
+}
+
+// ReloadROM reads the ROM file loaded by LoadROM again and restarts it with Reset, picking up
+// changes made to the file since, e.g. by the assembler or to Octo source. A ROM loaded from memory is simply
+// restarted. If the file can't be read or no longer fits, the machine is left unchanged.
+func (chip *Chip8) ReloadROM() error {
+
+	if chip.rom_path != "" {
+		data, err := ReadROMFile(chip.rom_path)
+		if err != nil {
+			return fmt.Errorf("could not read ROM: %w", err)
+		}
+
+		if int(chip.load_address)+len(data) > chip.memorySize() {
+			return fmt.Errorf("%w: %d bytes at 0x%03X", ErrROMTooLarge, len(data), chip.load_address)
+		}
+
+		chip.rom = data
+	}
+
+	chip.Reset()
+	chip.log(slog.LevelInfo, "ROM reloaded", "path", chip.rom_path, "bytes", len(chip.rom))
+
+	return nil
+
+}
+
+// Speed selects how fast Run executes a program.
+type Speed int
+
+const (
+	// SpeedNormal runs at the clock rate, the timers at 60Hz.
+	SpeedNormal Speed = iota
+
+	// SpeedTurbo executes instructions as fast as the host allows, the timers still at 60Hz,
+	// to get through slow parts of a game.
+	SpeedTurbo
+
+	// SpeedSlow runs the whole machine, timers included, slow_motion_factor times slower,
+	// to follow timing-sensitive action.
+	SpeedSlow
+)
+
+// How many times slower SpeedSlow runs.
+const slow_motion_factor = 4
+
+// String returns "normal", "turbo" or "slow motion".
+func (s Speed) String() string {
+
+	switch s {
+	case SpeedNormal:
+		return "normal"
+	case SpeedTurbo:
+		return "turbo"
+	case SpeedSlow:
+		return "slow motion"
+	}
+
+	return fmt.Sprintf("Speed(%d)", int(s))
+
+}
+
+// SetSpeed selects how fast Run executes the program, normal by default. Unlike the clock
+// rate it is not part of the machine state.
+func (chip *Chip8) SetSpeed(s Speed) {
+	chip.speed = s
+	chip.log(slog.LevelInfo, "speed changed", "speed", s.String())
+}
+
+// Speed returns how fast Run executes the program.
+func (chip *Chip8) Speed() Speed {
+	return chip.speed
+}
+
+// instructionRate returns the instructions Run executes per second, slowed down in slow motion.
+func (chip *Chip8) instructionRate() int {
+
+	if chip.speed == SpeedSlow {
+		return max(chip.ClockHz()/slow_motion_factor, 1)
+	}
+
+	return chip.ClockHz()
+
+}
@@ -0,0 +1,83 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"chip8-go/chip8/decode"
+)
+
+// ErrHaltLoop is returned, with halt detection on, when the program jumps to itself: nothing
+// gets it out of that loop but a reset.
+var ErrHaltLoop = errors.New("program halted in a jump to itself")
+
+// SetHaltDetection selects whether a jump to itself, 1NNN with NNN its own address, fails with
+// ErrHaltLoop instead of looping forever. Off by default, test ROMs end on such a loop to
+// leave their results on the display.
+func (chip *Chip8) SetHaltDetection(enabled bool) {
+	chip.detect_halt = enabled
+}
+
+// CrashReport is the post-mortem of a machine whose program failed, see NewCrashReport.
+type CrashReport struct {
+	// Err - why the program failed
+	Err error
+
+	// PC and Opcode - the faulting instruction, its opcode 0 when PC is past the end of memory
+	PC     uint16
+	Opcode uint16
+
+	// State - the registers, timers and stack, see StateReport
+	State string
+
+	// Trace - the last instructions executed before the failure, oldest first, while tracing
+	Trace []TraceEntry
+}
+
+// NewCrashReport returns the post-mortem of chip after its program failed with err. The failing
+// instruction is the one at PC: instructions failing do not advance it.
+func (chip *Chip8) NewCrashReport(err error) CrashReport {
+	return CrashReport{
+		Err:    err,
+		PC:     chip.program_counter,
+		Opcode: chip.opcodeAt(chip.program_counter),
+		State:  chip.StateReport(),
+		Trace:  chip.Trace(),
+	}
+}
+
+// Format returns the report as text, with the last n instructions of the trace, all of them
+// if n is 0 or less:
+//
+//	CHIP-8 program failed: stack underflow: return with empty stack
+//	At 0x0204: 00EE RET
+//	V0=00 V1=00 ...
+//	Last 2 instructions:
+//	...
+func (r CrashReport) Format(n int) string {
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "CHIP-8 program failed: %v\n", r.Err)
+	fmt.Fprintf(&b, "At 0x%04X: %04X %s\n", r.PC, r.Opcode, decode.Decode(r.Opcode))
+	b.WriteString(r.State)
+
+	trace := r.Trace
+	if n > 0 && len(trace) > n {
+		trace = trace[len(trace)-n:]
+	}
+	if len(trace) > 0 {
+		fmt.Fprintf(&b, "Last %d instructions:\n", len(trace))
+		for _, entry := range trace {
+			fmt.Fprintln(&b, entry)
+		}
+	}
+
+	return b.String()
+
+}
+
+func (r CrashReport) String() string {
+	return r.Format(0)
+}
@@ -0,0 +1,167 @@
+package chip8
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// CRTEffects are the effects a CRT draws the display with. The zero value draws it like
+// DrawFrameLetterboxed.
+type CRTEffects struct {
+	// Decay is the share of its brightness a pixel turned off keeps each frame, like the
+	// phosphor of a CRT, from 0 (off at once) to below 1. It hides the flicker of games that
+	// erase and redraw their sprites.
+	Decay float64
+
+	// Scanlines darkens the bottom of every row of pixels.
+	Scanlines bool
+
+	// Curvature bulges the display like the glass of a CRT.
+	Curvature bool
+}
+
+// Enabled reports whether any effect is on.
+func (e CRTEffects) Enabled() bool {
+	return e.Decay > 0 || e.Scanlines || e.Curvature
+}
+
+// Brightness kept by the darkened part of a scanline.
+const scanline_brightness = 0.6
+
+// Distortion of the corners of a curved display: how far past the edge they would be drawn,
+// as a share of the display size.
+const crt_curvature = 0.08
+
+// CRT draws frames with CRTEffects, between the display of the chip and the window of a
+// frontend. It keeps the color each pixel is shown in to fade it out over several frames.
+type CRT struct {
+	effects CRTEffects
+	palette Palette
+
+	width, height int
+
+	// Color shown per pixel, indexed [y][x], as floats so that fading is smooth.
+	shown [MegaHeight][MegaWidth][3]float32
+
+	fading bool
+}
+
+// NewCRT returns a CRT drawing frames in the palette colors.
+func NewCRT(effects CRTEffects, palette Palette) *CRT {
+	return &CRT{effects: effects, palette: palette}
+}
+
+// Update moves the CRT on by a frame, showing frame. Call it once per 60Hz frame, also while
+// the frame does not change as long as Fading reports true.
+func (crt *CRT) Update(frame Frame) {
+
+	// Nothing fades across a change of resolution.
+	decay := float32(crt.effects.Decay)
+	if frame.Width != crt.width || frame.Height != crt.height {
+		decay = 0
+		crt.width, crt.height = frame.Width, frame.Height
+	}
+
+	crt.fading = false
+
+	for y := 0; y < frame.Height; y++ {
+		for x := 0; x < frame.Width; x++ {
+
+			pixel := frame.Pixel(x, y)
+			c := frame.Color(x, y, crt.palette)
+			target := [3]float32{float32(c.R), float32(c.G), float32(c.B)}
+
+			shown := &crt.shown[y][x]
+
+			// Pixels light up at once, only turning off takes time.
+			if pixel != 0 || decay == 0 {
+				*shown = target
+				continue
+			}
+
+			for i := range shown {
+				shown[i] = target[i] + (shown[i]-target[i])*decay
+				if math.Abs(float64(shown[i]-target[i])) < 1 {
+					shown[i] = target[i]
+				}
+			}
+
+			if *shown != target {
+				crt.fading = true
+			}
+		}
+	}
+
+}
+
+// Fading reports whether pixels are still fading out, so the frame must be updated and
+// drawn again even if it did not change.
+func (crt *CRT) Fading() bool {
+	return crt.fading
+}
+
+// Draw renders the frame of the last Update into dst, scaled and letterboxed like
+// DrawFrameLetterboxed, with the effects. It returns the viewport used.
+func (crt *CRT) Draw(dst *image.RGBA) Viewport {
+
+	bounds := dst.Bounds()
+	vp := FitFrameViewport(bounds.Dx(), bounds.Dy(), max(crt.width, 1), max(crt.height, 1))
+
+	// The darkened bottom of a row of pixels, a third of it, needs pixels at least 2 tall.
+	scanline := 0
+	if crt.effects.Scanlines && vp.Scale > 1 {
+		scanline = max(vp.Scale/3, 1)
+	}
+
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+
+			// Position relative to the viewport.
+			x := px - bounds.Min.X - vp.X
+			y := py - bounds.Min.Y - vp.Y
+
+			if crt.effects.Curvature {
+				x, y = curve(x, y, vp.Width, vp.Height)
+			}
+
+			if x < 0 || y < 0 || x >= vp.Width || y >= vp.Height {
+				dst.SetRGBA(px, py, crt.palette.Border)
+				continue
+			}
+
+			shown := crt.shown[y/vp.Scale][x/vp.Scale]
+
+			brightness := float32(1)
+			if y%vp.Scale >= vp.Scale-scanline {
+				brightness = scanline_brightness
+			}
+
+			dst.SetRGBA(px, py, color.RGBA{
+				uint8(shown[0] * brightness),
+				uint8(shown[1] * brightness),
+				uint8(shown[2] * brightness),
+				0xFF,
+			})
+		}
+	}
+
+	return vp
+
+}
+
+// curve returns the point of a width x height display shown at (x, y) when it bulges out:
+// points move away from the center the further they are from it, so the edges bend and the
+// corners fall outside the display.
+func curve(x, y, width, height int) (int, int) {
+
+	// From -1 to 1 across the display.
+	u := 2*(float64(x)+0.5)/float64(width) - 1
+	v := 2*(float64(y)+0.5)/float64(height) - 1
+
+	u *= 1 + crt_curvature*v*v
+	v *= 1 + crt_curvature*u*u
+
+	return int(math.Floor((u + 1) * float64(width) / 2)), int(math.Floor((v + 1) * float64(height) / 2))
+
+}
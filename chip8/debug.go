@@ -0,0 +1,90 @@
+package chip8
+
+import "fmt"
+
+// CPUState is a read-only snapshot of the CPU registers.
+type CPUState struct {
+	PC         uint16
+	I          uint32
+	V          [16]byte
+	SP         uint8
+	Stack      [16]uint16
+	DelayTimer uint8
+	SoundTimer uint8
+}
+
+// Step executes a single instruction, like Cycle, and returns the state it left the machine
+// in: StateWaitingForKey or StateWaitingForRelease while FX0A waits, until the keys given with
+// SetKey press and release one. Failures are returned as errors: an InvalidOpcodeError,
+// ErrStackOverflow, ErrStackUnderflow, ErrMemoryOutOfRange or a QuirkError in strict mode,
+// leaving the program counter on the failing instruction.
+func (chip *Chip8) Step() (RunState, error) {
+
+	err := chip.Cycle()
+
+	return chip.RunState(), err
+
+}
+
+// State returns a copy of the CPU registers, safe to keep after the machine moves on.
+func (chip *Chip8) State() CPUState {
+	return CPUState{
+		PC:         chip.program_counter,
+		I:          chip.index_register,
+		V:          chip.registers,
+		SP:         chip.stack_pointer,
+		Stack:      chip.stack,
+		DelayTimer: chip.delay_timer,
+		SoundTimer: chip.sound_timer,
+	}
+}
+
+// Memory returns a copy of n bytes of memory starting at addr, cut short at the end of memory.
+func (chip *Chip8) Memory(addr uint16, n int) []byte {
+
+	end := min(int(addr)+n, chip.memorySize())
+	if int(addr) >= end {
+		return nil
+	}
+
+	return append([]byte(nil), chip.mem()[addr:end]...)
+
+}
+
+// SetState overwrites the CPU registers, e.g. from a debugger. Any fetched but not executed
+// instruction is discarded. A stack pointer past the end of the stack is rejected.
+func (chip *Chip8) SetState(state CPUState) error {
+
+	if int(state.SP) > len(chip.stack) {
+		return fmt.Errorf("invalid stack pointer %d, the stack holds %d addresses", state.SP, len(chip.stack))
+	}
+
+	chip.program_counter = state.PC
+	chip.index_register = state.I
+	chip.registers = state.V
+	chip.stack_pointer = state.SP
+	chip.stack = state.Stack
+	chip.delay_timer = state.DelayTimer
+	chip.sound_timer = state.SoundTimer
+	chip.has_fetched = false
+
+	return nil
+
+}
+
+// SetMemory copies data into memory starting at addr, cut short at the end of memory, and
+// returns the number of bytes written. Unlike WriteMemory it ignores the memory protection,
+// it is meant for debuggers patching a program.
+func (chip *Chip8) SetMemory(addr uint16, data []byte) int {
+
+	end := min(int(addr)+len(data), chip.memorySize())
+	if int(addr) >= end {
+		return 0
+	}
+
+	chip.has_fetched = false
+	chip.invalidateDecoded(int(addr), end-int(addr))
+
+	return copy(chip.mem()[addr:end], data)
+
+}
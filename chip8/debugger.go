@@ -0,0 +1,223 @@
+package chip8
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"chip8-go/chip8/decode"
+)
+
+// Debugger runs a chip one instruction at a time or up to a breakpoint or watchpoint. Timers
+// advance with the instructions at the chip's clock rate, so a stepped program sees the same
+// timer values as a running one.
+type Debugger struct {
+	chip        *Chip8
+	breakpoints map[uint16]bool
+	watchpoints []Watchpoint
+
+	// Accesses of the last step that triggered a watchpoint
+	hits []WatchHit
+
+	// Addresses written by the program since the last ClearWrites
+	written map[uint16]bool
+
+	// Memory hook of the chip while stepping, see onMemory, and the PC of the instruction
+	// it steps
+	on_memory func(addr int, write bool, old, value byte)
+	pc        uint16
+}
+
+// NewDebugger returns a debugger for chip, without breakpoints.
+func NewDebugger(chip *Chip8) *Debugger {
+
+	d := &Debugger{chip: chip, breakpoints: map[uint16]bool{}, written: map[uint16]bool{}}
+	d.on_memory = d.onMemory
+
+	return d
+
+}
+
+// SetBreakpoint makes Continue stop before executing the instruction at addr.
+func (d *Debugger) SetBreakpoint(addr uint16) {
+	d.breakpoints[addr] = true
+}
+
+// ClearBreakpoint removes the breakpoint at addr, if any.
+func (d *Debugger) ClearBreakpoint(addr uint16) {
+	delete(d.breakpoints, addr)
+}
+
+// Breakpoints returns the breakpoint addresses in increasing order.
+func (d *Debugger) Breakpoints() []uint16 {
+
+	addrs := make([]uint16, 0, len(d.breakpoints))
+	for addr := range d.breakpoints {
+		addrs = append(addrs, addr)
+	}
+	slices.Sort(addrs)
+
+	return addrs
+
+}
+
+// AddWatchpoint makes Continue stop after an instruction accessing what w watches.
+// Adding a watchpoint twice has no effect.
+func (d *Debugger) AddWatchpoint(w Watchpoint) {
+	if !slices.Contains(d.watchpoints, w) {
+		d.watchpoints = append(d.watchpoints, w)
+	}
+}
+
+// RemoveWatchpoint removes a watchpoint added by AddWatchpoint, if any.
+func (d *Debugger) RemoveWatchpoint(w Watchpoint) {
+	d.watchpoints = slices.DeleteFunc(d.watchpoints, func(other Watchpoint) bool { return other == w })
+}
+
+// Watchpoints returns the watchpoints in the order they were added.
+func (d *Debugger) Watchpoints() []Watchpoint {
+	return slices.Clone(d.watchpoints)
+}
+
+// WatchHits returns the accesses of the last instruction executed that triggered a watchpoint.
+func (d *Debugger) WatchHits() []WatchHit {
+	return slices.Clone(d.hits)
+}
+
+// Step executes a single instruction and advances the timers by one clock period.
+func (d *Debugger) Step() error {
+
+	d.hits = d.hits[:0]
+
+	d.pc = d.chip.program_counter
+	d.chip.on_memory = d.on_memory
+	defer func() { d.chip.on_memory = nil }()
+
+	if len(d.watchpoints) == 0 {
+		if err := d.chip.Cycle(); err != nil {
+			return err
+		}
+	} else if err := d.watchedCycle(); err != nil {
+		return err
+	}
+
+	d.chip.TickTimers(time.Second / time.Duration(d.chip.ClockHz()))
+
+	return nil
+
+}
+
+// Continue executes instructions until the next one is at a breakpoint, one triggered a
+// watchpoint, ctx is done or an instruction fails. It always executes at least one instruction,
+// so continuing from a breakpoint moves on. It reports whether it stopped at a breakpoint or a
+// watchpoint, WatchHits tells which.
+func (d *Debugger) Continue(ctx context.Context) (bool, error) {
+
+	for i := 0; ; i++ {
+
+		// Checking ctx on every instruction would dominate the loop.
+		if i%1024 == 0 && ctx.Err() != nil {
+			return false, nil
+		}
+
+		if err := d.Step(); err != nil {
+			return false, err
+		}
+
+		if len(d.hits) > 0 || d.breakpoints[d.chip.program_counter] {
+			return true, nil
+		}
+	}
+
+}
+
+// Status describes the machine before the next instruction: the registers, timers and
+// stack of StateReport followed by the decoded instruction at PC, and whether FX0A waits.
+func (d *Debugger) Status() string {
+
+	pc := d.chip.program_counter
+
+	status := d.chip.StateReport() + fmt.Sprintf("Next: %04X %04X %s\n", pc, d.chip.opcodeAt(pc), decode.Decode(d.chip.opcodeAt(pc)))
+	if state := d.chip.RunState(); state != StateRunning {
+		status += "State: " + state.String() + "\n"
+	}
+
+	return status
+
+}
+
+// onMemory records the memory accesses of a step: the writes, and the accesses that trigger
+// a watchpoint.
+func (d *Debugger) onMemory(addr int, write bool, old, value byte) {
+
+	if write {
+		d.written[uint16(addr)] = true
+	}
+
+	for _, w := range d.watchpoints {
+		if w.Register == "" && addr >= int(w.Start) && addr <= int(w.End) && w.Access&accessOf(write) != 0 {
+			d.hits = append(d.hits, WatchHit{Watch: w, PC: d.pc, Addr: uint16(addr), Write: write, Old: uint32(old), New: uint32(value)})
+		}
+	}
+
+}
+
+// Written reports whether the program wrote to addr since the last ClearWrites, e.g. to
+// highlight the memory the last steps changed.
+func (d *Debugger) Written(addr uint16) bool {
+	return d.written[addr]
+}
+
+// ClearWrites forgets the writes reported by Written.
+func (d *Debugger) ClearWrites() {
+	clear(d.written)
+}
+
+// watchedCycle executes an instruction, recording the register accesses that trigger a
+// watchpoint. Step records the memory accesses.
+func (d *Debugger) watchedCycle() error {
+
+	chip := d.chip
+
+	in, err := chip.Fetch()
+	if err != nil {
+		return err
+	}
+
+	pc := chip.program_counter
+	read := chip.registersRead(in)
+	before := chip.registerValues()
+
+	// Reads are reported even if the instruction fails, they happened before.
+	for _, w := range d.watchpoints {
+		if r := w.index(); r >= 0 && w.Access&WatchRead != 0 && read&(1<<r) != 0 {
+			d.hits = append(d.hits, WatchHit{Watch: w, PC: pc, Old: before[r], New: before[r]})
+		}
+	}
+
+	if err := chip.Execute(); err != nil {
+		return err
+	}
+
+	after := chip.registerValues()
+	for _, w := range d.watchpoints {
+		if r := w.index(); r >= 0 && w.Access&WatchWrite != 0 && after[r] != before[r] {
+			d.hits = append(d.hits, WatchHit{Watch: w, PC: pc, Write: true, Old: before[r], New: after[r]})
+		}
+	}
+
+	return nil
+
+}
+
+// accessOf returns WatchWrite for a write and WatchRead for a read.
+func accessOf(write bool) WatchAccess {
+
+	if write {
+		return WatchWrite
+	}
+
+	return WatchRead
+
+}
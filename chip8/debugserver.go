@@ -0,0 +1,505 @@
+package chip8
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+)
+
+// DebugServer lets external tools, e.g. an editor, debug a chip over a network connection.
+// The protocol is JSON-RPC 2.0, one message per line: the client calls the methods below and
+// the server answers each call, and sends a "halted" notification whenever a continued
+// program stops. The machine only runs when told to, stepping or continuing.
+//
+//	getRegisters                      the registers, see DebugRegisters
+//	setRegisters   {"v": [...], ...}  overwrite the registers given, returns all of them
+//	readMemory     {"addr", "length"} bytes from addr, as {"data": "hex"}
+//	writeMemory    {"addr", "data"}   write hex bytes at addr, ignoring the memory protection
+//	setBreakpoint  {"addr"}           stop before the instruction at addr
+//	clearBreakpoint {"addr"}          remove a breakpoint
+//	getBreakpoints                    the breakpoint addresses
+//	setWatchpoint  {"register"} or {"addr", "end"}, and {"access": "r", "w" or "rw"}
+//	                                  stop after an access to a register or memory range
+//	clearWatchpoint                   remove a watchpoint, with the same params
+//	getWatchpoints                    the watchpoints, as their params
+//	step           {"count"}          execute count instructions, 1 by default, returns the registers
+//	continue                          run until a breakpoint or pause, then notify "halted"
+//	pause                             stop a continued program
+//	reset                             restart the program from power-on
+//	getDisplay                        the display as text, see Frame.Text
+//
+// A "halted" notification has the reason it stopped, "breakpoint", "watchpoint" with the
+// accesses that triggered it, "paused", "exited" or "error" with the error, and the program
+// counter.
+type DebugServer struct {
+	debugger *Debugger
+	chip     *Chip8
+
+	// Guards running and cancel, set while a continued program runs in the background.
+	mu      sync.Mutex
+	running chan struct{}
+	cancel  context.CancelFunc
+}
+
+// NewDebugServer returns a debug server for chip, without breakpoints.
+func NewDebugServer(chip *Chip8) *DebugServer {
+	return &DebugServer{debugger: NewDebugger(chip), chip: chip}
+}
+
+// JSON-RPC error codes.
+const (
+	rpc_parse_error      = -32700
+	rpc_invalid_request  = -32600
+	rpc_method_not_found = -32601
+	rpc_invalid_params   = -32602
+	rpc_failed           = -32000
+)
+
+// rpcRequest is a call, or a notification when it has no ID.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// rpcMessage is a response, with a result or an error, or a notification sent by the server.
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  any             `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// DebugRegisters are the registers as exchanged with debug clients.
+type DebugRegisters struct {
+	PC    uint16     `json:"pc"`
+	I     uint32     `json:"i"`
+	V     [16]uint8  `json:"v"`
+	SP    uint8      `json:"sp"`
+	Stack [16]uint16 `json:"stack"`
+	DT    uint8      `json:"dt"`
+	ST    uint8      `json:"st"`
+
+	// State - "running", "waiting for key" or "waiting for key release", see RunState
+	State string `json:"state"`
+}
+
+// debugHalt is the parameters of a "halted" notification.
+type debugHalt struct {
+	Reason string          `json:"reason"`
+	PC     uint16          `json:"pc"`
+	Error  string          `json:"error,omitempty"`
+	Hits   []debugWatchHit `json:"hits,omitempty"`
+}
+
+// debugWatch is a watchpoint as exchanged with debug clients, a register or a memory range.
+type debugWatch struct {
+	Register string  `json:"register,omitempty"`
+	Addr     *uint16 `json:"addr,omitempty"`
+	End      *uint16 `json:"end,omitempty"`
+	Access   string  `json:"access"`
+}
+
+// Access names of debugWatch, as parsed by ParseWatchAccess.
+var debug_access_names = map[WatchAccess]string{WatchRead: "r", WatchWrite: "w", WatchReadWrite: "rw"}
+
+func newDebugWatch(w Watchpoint) debugWatch {
+
+	watch := debugWatch{Register: w.Register, Access: debug_access_names[w.Access]}
+	if w.Register == "" {
+		watch.Addr, watch.End = &w.Start, &w.End
+	}
+
+	return watch
+
+}
+
+// debugWatchHit is an access that triggered a watchpoint, see WatchHit.
+type debugWatchHit struct {
+	Watch debugWatch `json:"watch"`
+	PC    uint16     `json:"pc"`
+	Addr  uint16     `json:"addr"`
+	Write bool       `json:"write"`
+	Old   uint32     `json:"old"`
+	New   uint32     `json:"new"`
+}
+
+// Serve accepts connections on l, serving them one at a time, until l is closed or ctx is done.
+func (s *DebugServer) Serve(ctx context.Context, l net.Listener) error {
+
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		s.chip.log(slog.LevelInfo, "debug client connected", "addr", conn.RemoteAddr().String())
+		s.ServeConn(ctx, conn)
+		s.chip.log(slog.LevelInfo, "debug client disconnected", "addr", conn.RemoteAddr().String())
+	}
+
+}
+
+// ServeConn serves a single client until it disconnects or ctx is done. A program it
+// continued is paused when it leaves.
+func (s *DebugServer) ServeConn(ctx context.Context, conn io.ReadWriteCloser) {
+
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	// Responses and notifications can be sent at the same time.
+	var write_mu sync.Mutex
+	send := func(msg rpcMessage) {
+		msg.JSONRPC = "2.0"
+		data, _ := json.Marshal(msg)
+		write_mu.Lock()
+		defer write_mu.Unlock()
+		conn.Write(append(data, '\n'))
+	}
+
+	defer s.pause()
+
+	in := bufio.NewScanner(conn)
+	in.Buffer(nil, 1<<20)
+
+	for in.Scan() {
+
+		var req rpcRequest
+		if err := json.Unmarshal(in.Bytes(), &req); err != nil {
+			send(rpcMessage{ID: json.RawMessage("null"), Error: &rpcError{rpc_parse_error, err.Error()}})
+			continue
+		}
+
+		result, err := s.call(req, send)
+
+		// Notifications get no response.
+		if req.ID == nil {
+			continue
+		}
+
+		var rpc_err *rpcError
+		switch {
+		case errors.As(err, &rpc_err):
+			send(rpcMessage{ID: req.ID, Error: rpc_err})
+		case err != nil:
+			send(rpcMessage{ID: req.ID, Error: &rpcError{rpc_failed, err.Error()}})
+		default:
+			// A result is required, null included.
+			if result == nil {
+				result = json.RawMessage("null")
+			}
+			send(rpcMessage{ID: req.ID, Result: result})
+		}
+	}
+
+}
+
+// call runs a method and returns its result.
+func (s *DebugServer) call(req rpcRequest, send func(rpcMessage)) (any, error) {
+
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return nil, &rpcError{rpc_invalid_request, "not a JSON-RPC 2.0 request"}
+	}
+
+	var params struct {
+		Addr   *uint16 `json:"addr"`
+		Length int     `json:"length"`
+		Data   string  `json:"data"`
+		Count  int     `json:"count"`
+
+		End      *uint16 `json:"end"`
+		Register string  `json:"register"`
+		Access   string  `json:"access"`
+
+		PC    *uint16     `json:"pc"`
+		I     *uint32     `json:"i"`
+		V     *[16]uint8  `json:"v"`
+		SP    *uint8      `json:"sp"`
+		Stack *[16]uint16 `json:"stack"`
+		DT    *uint8      `json:"dt"`
+		ST    *uint8      `json:"st"`
+	}
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{rpc_invalid_params, err.Error()}
+		}
+	}
+
+	addr := func() (uint16, error) {
+		if params.Addr == nil {
+			return 0, &rpcError{rpc_invalid_params, "missing addr"}
+		}
+		return *params.Addr, nil
+	}
+
+	// Only pause may reach a program running in the background.
+	if req.Method == "pause" {
+		s.pause()
+		return nil, nil
+	}
+	if s.isRunning() {
+		return nil, errors.New("the program is running, pause it first")
+	}
+
+	chip := s.chip
+
+	switch req.Method {
+
+	case "getRegisters":
+		return s.registers(), nil
+
+	case "setRegisters":
+		state := chip.State()
+		if params.PC != nil {
+			state.PC = *params.PC
+		}
+		if params.I != nil {
+			state.I = *params.I
+		}
+		if params.V != nil {
+			state.V = *params.V
+		}
+		if params.SP != nil {
+			state.SP = *params.SP
+		}
+		if params.Stack != nil {
+			state.Stack = *params.Stack
+		}
+		if params.DT != nil {
+			state.DelayTimer = *params.DT
+		}
+		if params.ST != nil {
+			state.SoundTimer = *params.ST
+		}
+		if err := chip.SetState(state); err != nil {
+			return nil, &rpcError{rpc_invalid_params, err.Error()}
+		}
+		return s.registers(), nil
+
+	case "readMemory":
+		a, err := addr()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"data": hex.EncodeToString(chip.Memory(a, max(params.Length, 1)))}, nil
+
+	case "writeMemory":
+		a, err := addr()
+		if err != nil {
+			return nil, err
+		}
+		data, err := hex.DecodeString(params.Data)
+		if err != nil {
+			return nil, &rpcError{rpc_invalid_params, "data is not hexadecimal"}
+		}
+		return map[string]int{"written": chip.SetMemory(a, data)}, nil
+
+	case "setBreakpoint", "clearBreakpoint":
+		a, err := addr()
+		if err != nil {
+			return nil, err
+		}
+		if req.Method == "setBreakpoint" {
+			s.debugger.SetBreakpoint(a)
+		} else {
+			s.debugger.ClearBreakpoint(a)
+		}
+		return nil, nil
+
+	case "getBreakpoints":
+		return s.debugger.Breakpoints(), nil
+
+	case "setWatchpoint", "clearWatchpoint":
+		w := Watchpoint{Register: strings.ToUpper(params.Register)}
+		if w.Register == "" {
+			a, err := addr()
+			if err != nil {
+				return nil, err
+			}
+			w.Start, w.End = a, a
+			if params.End != nil {
+				w.End = *params.End
+			}
+		} else if w.index() < 0 {
+			return nil, &rpcError{rpc_invalid_params, fmt.Sprintf("unknown register %q", params.Register)}
+		}
+		w.Access = WatchWrite
+		if params.Access != "" {
+			access, err := ParseWatchAccess(params.Access)
+			if err != nil {
+				return nil, &rpcError{rpc_invalid_params, err.Error()}
+			}
+			w.Access = access
+		}
+		if req.Method == "setWatchpoint" {
+			s.debugger.AddWatchpoint(w)
+		} else {
+			s.debugger.RemoveWatchpoint(w)
+		}
+		return nil, nil
+
+	case "getWatchpoints":
+		watches := []debugWatch{}
+		for _, w := range s.debugger.Watchpoints() {
+			watches = append(watches, newDebugWatch(w))
+		}
+		return watches, nil
+
+	case "step":
+		for i := 0; i < max(params.Count, 1); i++ {
+			if err := s.debugger.Step(); err != nil {
+				return nil, err
+			}
+		}
+		return s.registers(), nil
+
+	case "continue":
+		s.start(send)
+		return nil, nil
+
+	case "reset":
+		chip.Reset()
+		return s.registers(), nil
+
+	case "getDisplay":
+		frame := chip.Display()
+		return map[string]any{"width": frame.Width, "height": frame.Height, "text": frame.Text()}, nil
+
+	}
+
+	return nil, &rpcError{rpc_method_not_found, fmt.Sprintf("unknown method %q", req.Method)}
+
+}
+
+// registers returns the registers of the chip.
+func (s *DebugServer) registers() DebugRegisters {
+	return s.chip.debugRegisters()
+}
+
+// debugRegisters returns the registers as exchanged with debug and telemetry clients.
+func (chip *Chip8) debugRegisters() DebugRegisters {
+
+	state := chip.State()
+
+	return DebugRegisters{
+		PC:    state.PC,
+		I:     state.I,
+		V:     state.V,
+		SP:    state.SP,
+		Stack: state.Stack,
+		DT:    state.DelayTimer,
+		ST:    state.SoundTimer,
+		State: chip.RunState().String(),
+	}
+
+}
+
+// start continues the program in the background, sending a "halted" notification once it stops.
+func (s *DebugServer) start(send func(rpcMessage)) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	s.mu.Lock()
+	s.running, s.cancel = done, cancel
+	s.mu.Unlock()
+
+	go func() {
+
+		defer close(done)
+		defer cancel()
+
+		hit, err := s.debugger.Continue(ctx)
+
+		halt := debugHalt{Reason: "paused", PC: s.chip.State().PC}
+		switch {
+		case errors.Is(err, ErrExited):
+			halt.Reason = "exited"
+		case err != nil:
+			halt.Reason, halt.Error = "error", err.Error()
+		case len(s.debugger.WatchHits()) > 0:
+			halt.Reason = "watchpoint"
+			for _, hit := range s.debugger.WatchHits() {
+				halt.Hits = append(halt.Hits, debugWatchHit{
+					Watch: newDebugWatch(hit.Watch),
+					PC:    hit.PC,
+					Addr:  hit.Addr,
+					Write: hit.Write,
+					Old:   hit.Old,
+					New:   hit.New,
+				})
+			}
+		case hit:
+			halt.Reason = "breakpoint"
+		}
+
+		s.mu.Lock()
+		s.running, s.cancel = nil, nil
+		s.mu.Unlock()
+
+		send(rpcMessage{Method: "halted", Params: halt})
+
+	}()
+
+}
+
+// pause stops a program running in the background and waits until it stopped.
+func (s *DebugServer) pause() {
+
+	s.mu.Lock()
+	done, cancel := s.running, s.cancel
+	s.mu.Unlock()
+
+	if done == nil {
+		return
+	}
+
+	cancel()
+	<-done
+
+}
+
+// isRunning reports whether a program runs in the background.
+func (s *DebugServer) isRunning() bool {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.running != nil
+
+}
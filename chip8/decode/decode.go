@@ -0,0 +1,62 @@
+// Package decode turns CHIP-8 opcodes into instructions with their operand fields extracted.
+// The interpreter, the disassembler, the tracer and the debugger all decode through it.
+package decode
+
+import "strings"
+
+// Instruction is a decoded opcode with all its possible operand fields extracted.
+// Which fields are meaningful depends on the opcode.
+type Instruction struct {
+	Opcode uint16
+
+	// Op - first nibble, selecting the instruction group
+	Op int
+
+	// X - second nibble, a register index
+	X int
+
+	// Y - third nibble, a register index
+	Y int
+
+	// N - lowest nibble
+	N int
+
+	// NN - lowest byte
+	NN int
+
+	// NNN - lowest 12 bits, an address
+	NNN int
+
+	// Mnemonic - the instruction name without operands, e.g. "LD" or "DRW", or "DW" for an
+	// unknown opcode
+	Mnemonic string
+}
+
+// Decode extracts the operand fields and the mnemonic of an opcode.
+func Decode(opcode uint16) Instruction {
+
+	val := int(opcode)
+
+	in := Instruction{
+		Opcode: opcode,
+		Op:     GetNibbles(val, 12, 0xF000),
+		X:      GetNibbles(val, 8, 0x0F00),
+		Y:      GetNibbles(val, 4, 0x00F0),
+		N:      GetNibbles(val, 0, 0x000F),
+		NN:     GetNibbles(val, 0, 0x00FF),
+		NNN:    GetNibbles(val, 0, 0x0FFF),
+	}
+
+	in.Mnemonic, _, _ = strings.Cut(in.String(), " ")
+
+	return in
+
+}
+
+//Extract nibbles from opcode.
+
+func GetNibbles(val int, bits int, binary_and int) int {
+
+	return ((val & binary_and) >> bits)
+
+}
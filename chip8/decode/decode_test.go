@@ -0,0 +1,73 @@
+package decode
+
+import "testing"
+
+func TestDecodeFields(t *testing.T) {
+
+	in := Decode(0xD12F)
+
+	want := Instruction{Opcode: 0xD12F, Op: 0xD, X: 0x1, Y: 0x2, N: 0xF, NN: 0x2F, NNN: 0x12F, Mnemonic: "DRW"}
+	if in != want {
+		t.Errorf("Decode(D12F) = %+v, want %+v", in, want)
+	}
+
+	if got := GetNibbles(0xABCD, 8, 0x0F00); got != 0xB {
+		t.Errorf("second nibble of ABCD: %X, want B", got)
+	}
+
+}
+
+func TestDecodeText(t *testing.T) {
+
+	tests := []struct {
+		opcode   uint16
+		text     string
+		mnemonic string
+	}{
+		{0x00E0, "CLS", "CLS"},
+		{0x00EE, "RET", "RET"},
+		{0x00C4, "SCD 4", "SCD"},
+		{0x00FF, "HIGH", "HIGH"},
+		{0x0123, "LD I, HUGE 0x23", "LD"},
+		{0x0ABC, "SYS 0xABC", "SYS"},
+		{0x12A8, "JP 0x2A8", "JP"},
+		{0x22A8, "CALL 0x2A8", "CALL"},
+		{0x3A1F, "SE VA, 0x1F", "SE"},
+		{0x4A1F, "SNE VA, 0x1F", "SNE"},
+		{0x5120, "SE V1, V2", "SE"},
+		{0x5122, "SAVE V1, V2", "SAVE"},
+		{0x5121, "DW 0x5121", "DW"},
+		{0x631F, "LD V3, 0x1F", "LD"},
+		{0x7105, "ADD V1, 0x05", "ADD"},
+		{0x8AB4, "ADD VA, VB", "ADD"},
+		{0x8AB6, "SHR VA, VB", "SHR"},
+		{0x8ABE, "SHL VA, VB", "SHL"},
+		{0x8AB8, "DW 0x8AB8", "DW"},
+		{0x9AB0, "SNE VA, VB", "SNE"},
+		{0x9AB1, "DW 0x9AB1", "DW"},
+		{0xA22A, "LD I, 0x22A", "LD"},
+		{0xB300, "JP V0, 0x300", "JP"},
+		{0xC3FF, "RND V3, 0xFF", "RND"},
+		{0xD01F, "DRW V0, V1, 15", "DRW"},
+		{0xE29E, "SKP V2", "SKP"},
+		{0xE2A1, "SKNP V2", "SKNP"},
+		{0xE2A2, "DW 0xE2A2", "DW"},
+		{0xF000, "LD I, LONG", "LD"},
+		{0xF201, "PLANE 2", "PLANE"},
+		{0xF30A, "LD V3, K", "LD"},
+		{0xF333, "LD B, V3", "LD"},
+		{0xFF55, "LD [I], VF", "LD"},
+		{0xF3FF, "DW 0xF3FF", "DW"},
+	}
+
+	for _, test := range tests {
+		in := Decode(test.opcode)
+		if got := in.String(); got != test.text {
+			t.Errorf("%04X: %q, want %q", test.opcode, got, test.text)
+		}
+		if in.Mnemonic != test.mnemonic {
+			t.Errorf("%04X: mnemonic %q, want %q", test.opcode, in.Mnemonic, test.mnemonic)
+		}
+	}
+
+}
@@ -0,0 +1,153 @@
+package decode
+
+import "fmt"
+
+// String returns the instruction in assembly syntax, e.g. "CALL 0x2A8", "LD V3, 0x1F" or
+// "DRW V0, V1, 5". Unknown opcodes are rendered as a DW data directive.
+func (in Instruction) String() string {
+
+	opcode := in.Opcode
+
+	switch in.Op {
+
+	case 0:
+		switch opcode {
+		case 0x00E0:
+			return "CLS"
+		case 0x00EE:
+			return "RET"
+		case 0x00FB:
+			return "SCR"
+		case 0x00FC:
+			return "SCL"
+		case 0x00FD:
+			return "EXIT"
+		case 0x00FE:
+			return "LOW"
+		case 0x00FF:
+			return "HIGH"
+		case 0x0010:
+			return "MEGAOFF"
+		case 0x0011:
+			return "MEGAON"
+		case 0x0700:
+			return "STOPSND"
+		}
+		switch opcode & 0xFFF0 {
+		case 0x00B0:
+			return fmt.Sprintf("SCRU %d", in.N)
+		case 0x00C0:
+			return fmt.Sprintf("SCD %d", in.N)
+		case 0x00D0:
+			return fmt.Sprintf("SCU %d", in.N)
+		case 0x0600:
+			return fmt.Sprintf("DIGISND %d", in.N)
+		case 0x0800:
+			return fmt.Sprintf("BMODE %d", in.N)
+		}
+		mnemonic := map[uint16]string{
+			0x0100: "LD I, HUGE", 0x0200: "LDPAL", 0x0300: "SPRW", 0x0400: "SPRH", 0x0500: "ALPHA", 0x0900: "CCOL",
+		}[opcode&0xFF00]
+		if mnemonic != "" {
+			return fmt.Sprintf("%s 0x%02X", mnemonic, in.NN)
+		}
+		return fmt.Sprintf("SYS 0x%03X", in.NNN)
+
+	case 1:
+		return fmt.Sprintf("JP 0x%03X", in.NNN)
+
+	case 2:
+		return fmt.Sprintf("CALL 0x%03X", in.NNN)
+
+	case 3:
+		return fmt.Sprintf("SE V%X, 0x%02X", in.X, in.NN)
+
+	case 4:
+		return fmt.Sprintf("SNE V%X, 0x%02X", in.X, in.NN)
+
+	case 5:
+		switch in.N {
+		case 0:
+			return fmt.Sprintf("SE V%X, V%X", in.X, in.Y)
+		case 2:
+			return fmt.Sprintf("SAVE V%X, V%X", in.X, in.Y)
+		case 3:
+			return fmt.Sprintf("LOAD V%X, V%X", in.X, in.Y)
+		}
+
+	case 6:
+		return fmt.Sprintf("LD V%X, 0x%02X", in.X, in.NN)
+
+	case 7:
+		return fmt.Sprintf("ADD V%X, 0x%02X", in.X, in.NN)
+
+	case 8:
+		mnemonic := map[int]string{
+			0x0: "LD", 0x1: "OR", 0x2: "AND", 0x3: "XOR", 0x4: "ADD",
+			0x5: "SUB", 0x6: "SHR", 0x7: "SUBN", 0xE: "SHL",
+		}[in.N]
+
+		if mnemonic != "" {
+			return fmt.Sprintf("%s V%X, V%X", mnemonic, in.X, in.Y)
+		}
+
+	case 9:
+		if in.N == 0 {
+			return fmt.Sprintf("SNE V%X, V%X", in.X, in.Y)
+		}
+
+	case 10:
+		return fmt.Sprintf("LD I, 0x%03X", in.NNN)
+
+	case 11:
+		return fmt.Sprintf("JP V0, 0x%03X", in.NNN)
+
+	case 12:
+		return fmt.Sprintf("RND V%X, 0x%02X", in.X, in.NN)
+
+	case 13:
+		return fmt.Sprintf("DRW V%X, V%X, %d", in.X, in.Y, in.N)
+
+	case 14:
+		switch in.NN {
+		case 0x9E:
+			return fmt.Sprintf("SKP V%X", in.X)
+		case 0xA1:
+			return fmt.Sprintf("SKNP V%X", in.X)
+		}
+
+	case 15:
+		switch opcode {
+		case 0xF000:
+			return "LD I, LONG"
+		case 0xF002:
+			return "AUDIO"
+		}
+		if in.NN == 0x01 {
+			return fmt.Sprintf("PLANE %d", in.X)
+		}
+
+		format := map[int]string{
+			0x07: "LD V%X, DT",
+			0x0A: "LD V%X, K",
+			0x15: "LD DT, V%X",
+			0x18: "LD ST, V%X",
+			0x1E: "ADD I, V%X",
+			0x29: "LD F, V%X",
+			0x30: "LD HF, V%X",
+			0x33: "LD B, V%X",
+			0x3A: "PITCH V%X",
+			0x55: "LD [I], V%X",
+			0x65: "LD V%X, [I]",
+			0x75: "LD R, V%X",
+			0x85: "LD V%X, R",
+		}[in.NN]
+
+		if format != "" {
+			return fmt.Sprintf(format, in.X)
+		}
+	}
+
+	return fmt.Sprintf("DW 0x%04X", opcode)
+
+}
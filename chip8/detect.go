@@ -0,0 +1,144 @@
+package chip8
+
+import (
+	"fmt"
+	"strings"
+
+	"chip8-go/chip8/decode"
+)
+
+// Platform is a member of the CHIP-8 family a ROM targets.
+type Platform int
+
+const (
+	PlatformChip8 Platform = iota
+	PlatformSuperChip
+	PlatformXOChip
+	PlatformMegaChip
+)
+
+func (p Platform) String() string {
+	switch p {
+	case PlatformSuperChip:
+		return "SUPER-CHIP"
+	case PlatformXOChip:
+		return "XO-CHIP"
+	case PlatformMegaChip:
+		return "MEGA-CHIP"
+	default:
+		return "CHIP-8"
+	}
+}
+
+// ParsePlatform returns the platform named "chip8", "schip", "xochip" or "megachip".
+func ParsePlatform(name string) (Platform, error) {
+
+	switch strings.ToLower(name) {
+	case "chip8", "chip-8":
+		return PlatformChip8, nil
+	case "schip", "superchip", "super-chip":
+		return PlatformSuperChip, nil
+	case "xochip", "xo-chip":
+		return PlatformXOChip, nil
+	case "megachip", "mega-chip", "megachip8":
+		return PlatformMegaChip, nil
+	}
+
+	return PlatformChip8, fmt.Errorf("unknown platform %q, want chip8, schip, xochip or megachip", name)
+
+}
+
+// SetPlatform selects the instruction set: PlatformChip8 (the default) treats SUPER-CHIP and
+// XO-CHIP opcodes as unknown, PlatformSuperChip enables the SUPER-CHIP 1.1 instructions and
+// PlatformXOChip the XO-CHIP ones on top of them, with 64kB of memory. PlatformMegaChip enables
+// the Megachip instructions on top of SUPER-CHIP instead, with 16MB of memory and a 256x192
+// color display. Select it before loading a ROM larger than 4kB.
+func (chip *Chip8) SetPlatform(p Platform) {
+	chip.setPlatform(p)
+}
+
+// Platform returns the instruction set in use.
+func (chip *Chip8) Platform() Platform {
+	return chip.platform
+}
+
+// DetectPlatform statically scans the loaded ROM for opcodes only defined by SUPER-CHIP,
+// XO-CHIP or Megachip and reports the most likely platform. Since code and data can't be told
+// apart without running the ROM, this is a heuristic: data bytes may look like extended opcodes.
+func (chip *Chip8) DetectPlatform() Platform {
+	return DetectROMPlatform(chip.rom)
+}
+
+// DetectROMPlatform is DetectPlatform for a ROM that is not loaded yet, e.g. to select
+// XO-CHIP and its 64kB of memory before loading a ROM that does not fit in 4kB.
+func DetectROMPlatform(rom []byte) Platform {
+
+	platform := PlatformChip8
+
+	for i := 0; i+1 < len(rom); i += 2 {
+
+		opcode := int(rom[i])<<8 | int(rom[i+1])
+
+		switch p := detectOpcode(opcode); p {
+		case PlatformXOChip, PlatformMegaChip:
+			// XO-CHIP and Megachip are supersets of SUPER-CHIP, nothing more specific to find.
+			return p
+		case PlatformSuperChip:
+			platform = PlatformSuperChip
+		}
+	}
+
+	return platform
+
+}
+
+// detectOpcode returns the platform an opcode was introduced by.
+func detectOpcode(opcode int) Platform {
+
+	in := decode.Decode(uint16(opcode))
+
+	switch in.Op {
+
+	case 0:
+		switch {
+		//0011 - Megachip mode on
+		case opcode == 0x0011:
+			return PlatformMegaChip
+		//00DN - scroll up
+		case opcode&0xFFF0 == 0x00D0:
+			return PlatformXOChip
+		//00CN - scroll down
+		case opcode&0xFFF0 == 0x00C0:
+			return PlatformSuperChip
+		//00FB, 00FC, 00FD, 00FE, 00FF - scroll, exit and resolution
+		case opcode >= 0x00FB && opcode <= 0x00FF:
+			return PlatformSuperChip
+		}
+
+	case 5:
+		//5XY2, 5XY3 - save and load register ranges
+		switch in.N {
+		case 2, 3:
+			return PlatformXOChip
+		}
+
+	case 13:
+		//DXY0 - 16x16 sprite
+		if in.N == 0 {
+			return PlatformSuperChip
+		}
+
+	case 15:
+		switch in.NN {
+		//F000 NNNN - long index, FN01 - plane select, F002 - audio pattern, FX3A - pitch
+		case 0x00, 0x01, 0x02, 0x3A:
+			return PlatformXOChip
+		//FX30 - large font, FX75, FX85 - RPL flags
+		case 0x30, 0x75, 0x85:
+			return PlatformSuperChip
+		}
+	}
+
+	return PlatformChip8
+
+}
@@ -0,0 +1,39 @@
+package chip8
+
+import "testing"
+
+func TestDetectPlatform(t *testing.T) {
+
+	tests := []struct {
+		name string
+		rom  []byte
+		want Platform
+	}{
+		{"plain CHIP-8", []byte{0x00, 0xE0, 0x60, 0x05, 0xD0, 0x15, 0x12, 0x06}, PlatformChip8},
+		{"00FF hires", []byte{0x00, 0xFF, 0x60, 0x05, 0x12, 0x04}, PlatformSuperChip},
+		{"DXY0 16x16 sprite", []byte{0xA3, 0x00, 0xD0, 0x10, 0x12, 0x04}, PlatformSuperChip},
+		{"FX30 large font", []byte{0x60, 0x01, 0xF0, 0x30, 0x12, 0x04}, PlatformSuperChip},
+		{"00CN scroll down", []byte{0x00, 0xC4, 0x12, 0x02}, PlatformSuperChip},
+		{"FX75 flags", []byte{0xF3, 0x75, 0x12, 0x02}, PlatformSuperChip},
+		{"FN01 plane select", []byte{0x00, 0xFF, 0xF3, 0x01, 0x12, 0x04}, PlatformXOChip},
+		{"F000 long index", []byte{0xF0, 0x00, 0x12, 0x34, 0x12, 0x04}, PlatformXOChip},
+		{"5XY2 register range", []byte{0x50, 0x32, 0x12, 0x02}, PlatformXOChip},
+		{"0011 Megachip mode", []byte{0x00, 0x11, 0x12, 0x02}, PlatformMegaChip},
+	}
+
+	for _, test := range tests {
+
+		if got := DetectROMPlatform(test.rom); got != test.want {
+			t.Errorf("%s: detected %v, want %v", test.name, got, test.want)
+		}
+
+		chip := New()
+		if err := chip.LoadROMBytes(test.rom); err != nil {
+			t.Fatal(err)
+		}
+		if got := chip.DetectPlatform(); got != test.want {
+			t.Errorf("%s: DetectPlatform of the loaded ROM is %v, want %v", test.name, got, test.want)
+		}
+	}
+
+}
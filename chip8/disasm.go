@@ -0,0 +1,262 @@
+package chip8
+
+import (
+	"fmt"
+
+	"chip8-go/chip8/decode"
+)
+
+// Disassemble decodes every 2-byte instruction of a ROM into its mnemonic.
+// A trailing odd byte is rendered as a DB data directive.
+func Disassemble(rom []byte) []string {
+
+	lines := make([]string, 0, (len(rom)+1)/2)
+
+	for i := 0; i+1 < len(rom); i += 2 {
+		lines = append(lines, DisassembleOpcode(uint16(rom[i])<<8|uint16(rom[i+1])))
+	}
+
+	if len(rom)%2 == 1 {
+		lines = append(lines, fmt.Sprintf("DB 0x%02X", rom[len(rom)-1]))
+	}
+
+	return lines
+
+}
+
+// DisassembleOpcode returns the mnemonic of a single opcode, e.g. "CALL 0x2A8", "LD V3, 0x1F"
+// or "DRW V0, V1, 5". Unknown opcodes are rendered as a DW data directive.
+func DisassembleOpcode(opcode uint16) string {
+	return decode.Decode(opcode).String()
+}
+
+// DisasmLine is one line of a ROM listing: an instruction, or data bytes the disassembler
+// did not reach as code.
+type DisasmLine struct {
+	Address uint16
+
+	// Raw - the bytes of the instruction or data
+	Raw []byte
+
+	// Text - the mnemonic and operands, or a DB directive for data
+	Text string
+
+	// Label - set when a jump, call or LD I targets this address
+	Label string
+
+	// Comment - a note on the instruction, e.g. where a jump leads
+	Comment string
+
+	Data bool
+}
+
+// String formats the line as address, raw bytes, label, mnemonic and comment.
+func (line DisasmLine) String() string {
+
+	raw := ""
+	for _, b := range line.Raw {
+		raw += fmt.Sprintf("%02X", b)
+	}
+
+	text := fmt.Sprintf("%04X  %-8s  %-6s %s", line.Address, raw, line.Label, line.Text)
+	if line.Comment != "" {
+		text = fmt.Sprintf("%-44s ; %s", text, line.Comment)
+	}
+
+	return text
+
+}
+
+// Longest run of data bytes put on a single DB line.
+const disasm_data_width = 4
+
+// DisassembleROM produces an annotated listing of a ROM loaded at DefaultLoadAddress.
+//
+// Without follow, every 2 bytes are decoded as an instruction, like Disassemble. With
+// follow, the disassembler traces the reachable code from the entry point through jumps,
+// calls and both branches of skips, and everything it did not reach is listed as data.
+// The targets of BNNN jumps depend on V0 and can't be followed.
+//
+// F000 NNNN and the Megachip 01NN NNNN are always listed as a single 4-byte instruction.
+func DisassembleROM(rom []byte, follow bool) []DisasmLine {
+	return DisassembleROMAt(rom, DefaultLoadAddress, DefaultLoadAddress, follow)
+}
+
+// DisassembleROMAt is DisassembleROM for a ROM loaded at addr whose execution starts at entry,
+// see SetLoadAddress.
+func DisassembleROMAt(rom []byte, addr, entry uint16, follow bool) []DisasmLine {
+
+	base := int(addr)
+
+	code := make([]bool, len(rom))
+	labels := map[int]string{}
+
+	size := func(offset int) int {
+		if offset+3 < len(rom) && (rom[offset] == 0xF0 && rom[offset+1] == 0x00 || rom[offset] == 0x01) {
+			return 4
+		}
+		return 2
+	}
+
+	if follow {
+		traceCode(rom, int(entry)-base, base, code, size)
+	} else {
+		for i := 0; i+1 < len(rom); i += size(i) {
+			code[i] = true
+		}
+	}
+
+	// Label the targets of jumps, calls and LD I that are code or data in the ROM.
+	for i := 0; i+1 < len(rom); i++ {
+		if !code[i] {
+			continue
+		}
+		in := decode.Decode(uint16(rom[i])<<8 | uint16(rom[i+1]))
+		target := in.NNN - base
+
+		switch in.Op {
+		case 1, 2:
+			if target >= 0 && target < len(rom) {
+				labels[target] = fmt.Sprintf("L%03X:", in.NNN)
+			}
+		case 10:
+			if target >= 0 && target < len(rom) && labels[target] == "" {
+				labels[target] = fmt.Sprintf("D%03X:", in.NNN)
+			}
+		}
+	}
+
+	var lines []DisasmLine
+
+	for i := 0; i < len(rom); {
+
+		line := DisasmLine{Address: uint16(base + i), Label: labels[i]}
+
+		if code[i] && i+1 < len(rom) {
+			n := size(i)
+			opcode := uint16(rom[i])<<8 | uint16(rom[i+1])
+
+			line.Raw = rom[i : i+n]
+			line.Text = DisassembleOpcode(opcode)
+			line.Comment = disasmComment(opcode, base, len(rom))
+
+			switch {
+			case n == 4 && rom[i] == 0x01:
+				line.Text = fmt.Sprintf("LD I, 0x%06X", int(rom[i+1])<<16|int(rom[i+2])<<8|int(rom[i+3]))
+			case n == 4:
+				line.Text = fmt.Sprintf("LD I, 0x%04X", uint16(rom[i+2])<<8|uint16(rom[i+3]))
+			}
+
+			lines = append(lines, line)
+			i += n
+			continue
+		}
+
+		// Data runs up to the next instruction or label.
+		end := i + 1
+		for end < len(rom) && end-i < disasm_data_width && !code[end] && labels[end] == "" {
+			end++
+		}
+
+		line.Raw = rom[i:end]
+		line.Data = true
+		line.Text = "DB"
+		for j, b := range line.Raw {
+			if j > 0 {
+				line.Text += ","
+			}
+			line.Text += fmt.Sprintf(" 0x%02X", b)
+		}
+		line.Comment = disasmSprite(line.Raw)
+
+		lines = append(lines, line)
+		i = end
+	}
+
+	return lines
+
+}
+
+// traceCode marks the ROM offsets reached from the entry point, at offset entry of a ROM loaded
+// at base, as the start of an instruction.
+func traceCode(rom []byte, entry, base int, code []bool, size func(offset int) int) {
+
+	pending := []int{entry}
+
+	for len(pending) > 0 {
+
+		i := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		for i >= 0 && i+1 < len(rom) && !code[i] {
+
+			code[i] = true
+			in := decode.Decode(uint16(rom[i])<<8 | uint16(rom[i+1]))
+			next := i + size(i)
+
+			switch {
+
+			// Unconditional jump, return, exit, and the unfollowable BNNN end the run.
+			case in.Op == 1:
+				pending = append(pending, in.NNN-base)
+				next = -1
+			case in.Opcode == 0x00EE, in.Opcode == 0x00FD, in.Op == 11:
+				next = -1
+
+			case in.Op == 2:
+				pending = append(pending, in.NNN-base)
+
+			// Skips continue after the next instruction too.
+			case in.Op == 3, in.Op == 4, in.Op == 5 && in.N == 0, in.Op == 9 && in.N == 0,
+				in.Op == 14 && (in.NN == 0x9E || in.NN == 0xA1):
+				if next+1 < len(rom) {
+					pending = append(pending, next+size(next))
+				}
+			}
+
+			i = next
+		}
+	}
+
+}
+
+// disasmComment explains where a jump or call leads when it is outside the ROM loaded at base.
+func disasmComment(opcode uint16, base, romSize int) string {
+
+	in := decode.Decode(opcode)
+
+	switch in.Op {
+	case 1, 2:
+		if in.NNN < base || in.NNN >= base+romSize {
+			return "target outside the ROM"
+		}
+	case 11:
+		return "target depends on V0"
+	}
+
+	return ""
+
+}
+
+// disasmSprite draws data bytes as sprite rows, e.g. "#..##..#", which makes fonts and
+// graphics easy to spot in a listing.
+func disasmSprite(data []byte) string {
+
+	rows := ""
+
+	for j, b := range data {
+		if j > 0 {
+			rows += " "
+		}
+		for bit := 7; bit >= 0; bit-- {
+			if b>>bit&1 == 1 {
+				rows += "#"
+			} else {
+				rows += "."
+			}
+		}
+	}
+
+	return rows
+
+}
@@ -0,0 +1,176 @@
+package chip8
+
+import "image/color"
+
+// Words of a display row, one bit per pixel.
+const plane_words = HiResWidth / 64
+
+// Plane is a display plane packed one bit per pixel, indexed [y][word]. Each row is HiResWidth
+// bits, the leftmost pixel in the highest bit of the first word. Frontends can blit the words
+// directly, At reads a single pixel.
+type Plane [HiResHeight][plane_words]uint64
+
+// At reports whether the pixel at (x, y) is on. Coordinates outside the plane are off.
+func (p *Plane) At(x, y int) bool {
+
+	if x < 0 || y < 0 || x >= HiResWidth || y >= HiResHeight {
+		return false
+	}
+
+	return p[y][x/64]&pixelBit(x) != 0
+
+}
+
+// pixelBit returns the bit of column x in its word of a row.
+func pixelBit(x int) uint64 {
+	return 1 << (63 - x%64)
+}
+
+// Frame is a copy of the display, as returned by Display and passed to Display.Draw.
+// Only its top-left Width x Height pixels are used, the rest is always off. Frames can be
+// compared with ==.
+type Frame struct {
+	Width  int
+	Height int
+
+	// Planes holds the first plane, the only one of CHIP-8 and SUPER-CHIP, and the second
+	// XO-CHIP plane.
+	Planes [2]Plane
+
+	// Mega holds the colors of the display in Megachip mode, which leaves Planes off.
+	Mega *MegaFrame
+}
+
+// At reports whether the pixel at (x, y) is on in any plane. Coordinates outside the frame are off.
+func (f *Frame) At(x, y int) bool {
+	return f.Pixel(x, y) != 0
+}
+
+// Pixel returns the planes the pixel at (x, y) is on in: 1 for the first plane, 2 for the
+// second XO-CHIP plane and 3 for both. Coordinates outside the frame are off.
+func (f *Frame) Pixel(x, y int) uint8 {
+
+	if x < 0 || y < 0 || x >= f.Width || y >= f.Height {
+		return 0
+	}
+
+	// A Megachip pixel is on in the first plane unless it is black.
+	if f.Mega != nil {
+		if c := f.Mega.Pixels[y*MegaWidth+x]; c.R|c.G|c.B != 0 {
+			return 1
+		}
+		return 0
+	}
+
+	return planePixel(&f.Planes, x, y)
+
+}
+
+// Color returns the color of the pixel at (x, y) in the palette, or its own color in Megachip
+// mode. Coordinates outside the frame are the background.
+func (f *Frame) Color(x, y int, palette Palette) color.RGBA {
+
+	if f.Mega != nil && x >= 0 && y >= 0 && x < f.Width && y < f.Height {
+		return f.Mega.Pixels[y*MegaWidth+x]
+	}
+
+	switch f.Pixel(x, y) {
+	case 0:
+		return palette.Background
+	case 1:
+		return palette.Foreground
+	case 2:
+		return palette.Plane2
+	}
+
+	return palette.Overlap
+
+}
+
+// planePixel returns the planes the pixel at (x, y) is on in, within the display size.
+func planePixel(planes *[2]Plane, x, y int) uint8 {
+
+	bit := pixelBit(x)
+
+	var pixel uint8
+	for p := range planes {
+		if planes[p][y][x/64]&bit != 0 {
+			pixel |= 1 << p
+		}
+	}
+
+	return pixel
+
+}
+
+// setPlanePixel sets the pixel at (x, y) on in the planes of pixel, and off in the others.
+func setPlanePixel(planes *[2]Plane, x, y int, pixel uint8) {
+
+	bit := pixelBit(x)
+
+	for p := range planes {
+		if pixel&(1<<p) != 0 {
+			planes[p][y][x/64] |= bit
+		} else {
+			planes[p][y][x/64] &^= bit
+		}
+	}
+
+}
+
+// spriteRow places a sprite row of width bits, the leftmost pixel in bit width-1, at column x
+// of a display row of line_width pixels. Pixels past the right edge are clipped, or wrap around
+// to the left when wrap is set.
+func spriteRow(sprite uint64, width, x, line_width int, wrap bool) [plane_words]uint64 {
+
+	// Align the sprite on the left edge of the row, then shift it right.
+	hi, lo := sprite<<(64-width), uint64(0)
+	hi, lo = shiftRight128(hi, lo, x)
+
+	// The pixels that went past the edge are in the rest of the words.
+	var past uint64
+	if line_width == 64 {
+		past, lo = lo, 0
+	} else {
+		past, _ = shiftLeft128(sprite<<(64-width), 0, line_width-x)
+	}
+
+	if wrap {
+		hi |= past
+	}
+
+	return [plane_words]uint64{hi, lo}
+
+}
+
+// shiftRight128 shifts the 128-bit value hi:lo right by n bits.
+func shiftRight128(hi, lo uint64, n int) (uint64, uint64) {
+
+	switch {
+	case n >= 128:
+		return 0, 0
+	case n >= 64:
+		return 0, hi >> (n - 64)
+	case n == 0:
+		return hi, lo
+	}
+
+	return hi >> n, lo>>n | hi<<(64-n)
+
+}
+
+// shiftLeft128 shifts the 128-bit value hi:lo left by n bits.
+func shiftLeft128(hi, lo uint64, n int) (uint64, uint64) {
+
+	switch {
+	case n >= 128:
+		return 0, 0
+	case n >= 64:
+		return lo << (n - 64), 0
+	case n == 0:
+		return hi, lo
+	}
+
+	return hi<<n | lo>>(64-n), lo << n
+
+}
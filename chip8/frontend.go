@@ -0,0 +1,93 @@
+package chip8
+
+import "context"
+
+// Display is the output side of a frontend, driven by RunWith.
+type Display interface {
+	// Draw shows the display. It is called on the first frame and whenever the display
+	// changed since the previous call, including a change of resolution.
+	Draw(frame Frame) error
+
+	// Beep starts (true) or stops (false) the tone played while the sound timer is non-zero.
+	Beep(on bool)
+}
+
+// Animated is implemented by displays whose picture keeps changing after the frame did,
+// such as one fading pixels out with a CRT: RunWith draws every frame while Animating
+// reports true.
+type Animated interface {
+	Animating() bool
+}
+
+// Input is the keypad side of a frontend, driven by RunWith.
+type Input interface {
+	// PollKeys returns which of the 16 keys are held down. It is called once per frame.
+	PollKeys() [16]bool
+}
+
+// RunWith runs the chip like Run, connecting it to a frontend: every frame the keypad is read
+// from input and the display is sent to display if it changed. Either may be nil.
+// It returns when ctx is done, an instruction fails or Draw returns an error.
+func (chip *Chip8) RunWith(ctx context.Context, display Display, input Input) error {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if display != nil {
+		on_sound := chip.OnSound
+		chip.OnSound = func(playing bool) {
+			display.Beep(playing)
+			if on_sound != nil {
+				on_sound(playing)
+			}
+		}
+		defer func() { chip.OnSound = on_sound }()
+	}
+
+	var frame uint64
+	var draw_err error
+
+	err := chip.Run(ctx, func() {
+
+		// Keys are still polled while a replay plays, for the hotkeys, but the replay holds its own.
+		if input != nil || chip.OnKeys != nil {
+			var keys [16]bool
+			if input != nil {
+				keys = input.PollKeys()
+			}
+			if chip.OnKeys != nil {
+				keys = chip.OnKeys(keys)
+			}
+			if chip.replay == nil {
+				chip.keypad = keys
+			}
+		}
+
+		if display != nil {
+			current, changed := chip.ConsumeFrame()
+			if animated, ok := display.(Animated); ok && animated.Animating() {
+				changed = true
+			}
+			if frame == 0 || changed {
+				if err := display.Draw(current); err != nil {
+					draw_err = err
+					cancel()
+					return
+				}
+			}
+		}
+
+		if chip.OnFrame != nil {
+			chip.OnFrame(chip.Display())
+		}
+
+		chip.PublishState(frame)
+		frame++
+	})
+
+	if draw_err != nil {
+		return draw_err
+	}
+	return err
+
+}
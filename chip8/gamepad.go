@@ -0,0 +1,144 @@
+package chip8
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GamepadButton is a button of a gamepad with the standard layout of an Xbox controller.
+// The left stick counts as the d-pad.
+type GamepadButton uint8
+
+const (
+	GamepadUp GamepadButton = iota
+	GamepadDown
+	GamepadLeft
+	GamepadRight
+	GamepadA
+	GamepadB
+	GamepadX
+	GamepadY
+	GamepadLeftShoulder
+	GamepadRightShoulder
+	GamepadBack
+	GamepadStart
+
+	// GamepadButtons is the number of buttons.
+	GamepadButtons
+)
+
+// Names of the buttons in a gamepad map, indexed by GamepadButton.
+var gamepad_button_names = [GamepadButtons]string{
+	"up", "down", "left", "right", "a", "b", "x", "y", "lb", "rb", "back", "start",
+}
+
+// String returns the name of the button in a gamepad map, e.g. "up" or "lb".
+func (b GamepadButton) String() string {
+
+	if b < GamepadButtons {
+		return gamepad_button_names[b]
+	}
+
+	return fmt.Sprintf("GamepadButton(%d)", uint8(b))
+
+}
+
+// How far a stick must be pushed, from 0 to 1, to press a direction of the d-pad.
+const gamepad_stick_threshold = 0.5
+
+// GamepadState holds which buttons of a gamepad are held down, indexed by GamepadButton.
+type GamepadState [GamepadButtons]bool
+
+// PushStick holds down the d-pad directions a stick is pushed to, from its position: x from
+// -1 (left) to 1 (right), y from -1 (up) to 1 (down).
+func (s *GamepadState) PushStick(x, y float64) {
+
+	s[GamepadUp] = s[GamepadUp] || y < -gamepad_stick_threshold
+	s[GamepadDown] = s[GamepadDown] || y > gamepad_stick_threshold
+	s[GamepadLeft] = s[GamepadLeft] || x < -gamepad_stick_threshold
+	s[GamepadRight] = s[GamepadRight] || x > gamepad_stick_threshold
+
+}
+
+// GamepadMap translates gamepad buttons to CHIP-8 keys (0x0 to 0xF). Unmapped buttons do nothing.
+type GamepadMap map[GamepadButton]byte
+
+// DefaultGamepadMap maps the d-pad onto the 5, 7, 8 and 9 keys, the W, A, S and D of the
+// keyboard that most games steer with, and the face buttons onto 6, 4, 1 and C.
+func DefaultGamepadMap() GamepadMap {
+	return GamepadMap{
+		GamepadUp: 0x5, GamepadDown: 0x8, GamepadLeft: 0x7, GamepadRight: 0x9,
+		GamepadA: 0x6, GamepadB: 0x4, GamepadX: 0x1, GamepadY: 0xC,
+	}
+}
+
+// ParseGamepadMap parses a gamepad map written as comma separated button=key pairs, the key
+// in hexadecimal, e.g. "up=1,down=4,a=6". The buttons are up, down, left, right, a, b, x, y,
+// lb, rb, back and start.
+func ParseGamepadMap(s string) (GamepadMap, error) {
+
+	m := GamepadMap{}
+
+	for _, pair := range strings.Split(s, ",") {
+
+		name, key, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("gamepad map %q: want button=key pairs, got %q", s, pair)
+		}
+
+		button, ok := parseGamepadButton(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("gamepad map %q: unknown button %q, want one of %s", s, name, strings.Join(gamepad_button_names[:], ", "))
+		}
+		if _, ok := m[button]; ok {
+			return nil, fmt.Errorf("gamepad map %q: %s is mapped twice", s, button)
+		}
+
+		value, err := strconv.ParseUint(strings.TrimSpace(key), 16, 4)
+		if err != nil {
+			return nil, fmt.Errorf("gamepad map %q: invalid key %q for %s, want 0 to F", s, key, button)
+		}
+
+		m[button] = byte(value)
+	}
+
+	return m, nil
+
+}
+
+// parseGamepadButton returns the button of a name in a gamepad map, case-insensitively.
+func parseGamepadButton(name string) (GamepadButton, bool) {
+
+	for b, n := range gamepad_button_names {
+		if strings.EqualFold(n, name) {
+			return GamepadButton(b), true
+		}
+	}
+
+	return 0, false
+
+}
+
+// String writes the map like ParseGamepadMap reads it, in button order.
+func (m GamepadMap) String() string {
+
+	var pairs []string
+	for b := GamepadButton(0); b < GamepadButtons; b++ {
+		if key, ok := m[b]; ok {
+			pairs = append(pairs, fmt.Sprintf("%s=%X", b, key))
+		}
+	}
+
+	return strings.Join(pairs, ",")
+
+}
+
+// Press sets the CHIP-8 keys mapped to the buttons held down in keys, leaving the others as they are.
+func (m GamepadMap) Press(keys *[16]bool, held GamepadState) {
+	for b, down := range held {
+		if key, ok := m[GamepadButton(b)]; ok && down {
+			keys[key] = true
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RunCycles executes instructions without any frontend, advancing the timers by one clock
+// period per instruction so that a run only depends on the ROM, the configuration and the
+// random seed. It stops early, without error, when the program exits with 00FD.
+func (chip *Chip8) RunCycles(cycles int) error {
+
+	period := time.Second / time.Duration(chip.ClockHz())
+
+	for i := 0; i < cycles; i++ {
+		if err := chip.Cycle(); err != nil {
+			if errors.Is(err, ErrExited) {
+				return nil
+			}
+			return fmt.Errorf("cycle %d: %w", i, err)
+		}
+		chip.TickTimers(period)
+	}
+
+	return nil
+
+}
+
+// Characters of the display rows of a conformance vector, indexed by the planes a pixel is on in.
+const pixel_chars = ".#23"
+
+// Text renders the frame as one line per row, with the characters of conformance vectors:
+// '.' for a pixel off, '#' for on, and '2' and '3' for the second XO-CHIP plane and both.
+func (f *Frame) Text() string {
+
+	var b strings.Builder
+
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			b.WriteByte(pixel_chars[f.Pixel(x, y)])
+		}
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+
+}
+
+// DiffFrameText compares two renderings of Frame.Text and describes the rows that differ.
+// Line endings and trailing blank lines are ignored. It returns nil when both match.
+func DiffFrameText(want, got string) []string {
+
+	split := func(text string) []string {
+		text = strings.TrimRight(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+		if text == "" {
+			return nil
+		}
+		return strings.Split(text, "\n")
+	}
+
+	want_rows, got_rows := split(want), split(got)
+
+	var diff []string
+
+	if len(want_rows) != len(got_rows) {
+		diff = append(diff, fmt.Sprintf("%d rows, want %d", len(got_rows), len(want_rows)))
+	}
+
+	for y := 0; y < min(len(want_rows), len(got_rows)); y++ {
+		if want_rows[y] != got_rows[y] {
+			diff = append(diff, fmt.Sprintf("row %d:\n  got  %s\n  want %s", y, got_rows[y], want_rows[y]))
+		}
+	}
+
+	return diff
+
+}
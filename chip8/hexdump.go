@@ -0,0 +1,124 @@
+package chip8
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MemoryRegion is a named range of the address space, from Start up to End excluded.
+type MemoryRegion struct {
+	Name       string
+	Start, End int
+}
+
+// MemoryRegions returns how the address space is laid out: the fonts and the rest of the
+// interpreter area, the loaded program and the free memory around it.
+func (chip *Chip8) MemoryRegions() []MemoryRegion {
+
+	program_end := int(chip.load_address) + len(chip.rom)
+
+	regions := []MemoryRegion{
+		{"font", 0, len(fontset)},
+		{"large font", big_font_address, big_font_address + len(big_fontset)},
+		{"interpreter area", big_font_address + len(big_fontset), interpreter_area_end},
+		{"free", interpreter_area_end, int(chip.load_address)},
+		{fmt.Sprintf("program, %d bytes", len(chip.rom)), int(chip.load_address), program_end},
+		{"free", program_end, chip.memorySize()},
+	}
+
+	// Without a program, its region is empty, and so is the free memory before a program
+	// loaded at DefaultLoadAddress.
+	return slices.DeleteFunc(regions, func(r MemoryRegion) bool { return r.Start >= r.End })
+
+}
+
+// HexDumpOptions configures HexDump.
+type HexDumpOptions struct {
+	// Squeeze - runs of lines identical to the one before are listed as a single "*"
+	Squeeze bool
+
+	// Marked - bytes to highlight, e.g. recently written ones, shown with a * before them
+	Marked func(addr int) bool
+}
+
+// Bytes per line of a hex dump.
+const hexdump_width = 16
+
+// HexDump lists n bytes of memory from addr, cut short at the end of memory, 16 per line: the
+// address, the bytes in hex and as ASCII, and the regions of MemoryRegions starting on the line.
+//
+//	0200  00 E0 A2 2A 60 0C 61 08  D0 1F 70 09 A2 39 D0 1F  ...*`.a...p..9..  program, 132 bytes
+func (chip *Chip8) HexDump(addr, n int, opts HexDumpOptions) []string {
+
+	memory := chip.mem()
+	end := min(addr+n, len(memory), chip.memorySize())
+	regions := chip.MemoryRegions()
+
+	var lines []string
+	var previous []byte
+	squeezed := false
+
+	for line := max(addr, 0); line < end; line += hexdump_width {
+
+		data := memory[line:min(line+hexdump_width, end)]
+
+		var notes []string
+		for _, region := range regions {
+			if region.Start >= line && region.Start < line+len(data) {
+				notes = append(notes, region.Name)
+			}
+		}
+
+		marked := false
+		if opts.Marked != nil {
+			for i := range data {
+				marked = marked || opts.Marked(line+i)
+			}
+		}
+
+		if opts.Squeeze && len(notes) == 0 && !marked && string(data) == string(previous) {
+			if !squeezed {
+				lines = append(lines, "*")
+				squeezed = true
+			}
+			continue
+		}
+		previous, squeezed = data, false
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "%04X ", line)
+
+		for i := 0; i < hexdump_width; i++ {
+			if i == hexdump_width/2 {
+				b.WriteByte(' ')
+			}
+			switch {
+			case i >= len(data):
+				b.WriteString("   ")
+			case opts.Marked != nil && opts.Marked(line+i):
+				fmt.Fprintf(&b, "*%02X", data[i])
+			default:
+				fmt.Fprintf(&b, " %02X", data[i])
+			}
+		}
+
+		b.WriteString("  ")
+		for _, c := range data {
+			if c < 0x20 || c > 0x7E {
+				c = '.'
+			}
+			b.WriteByte(c)
+		}
+
+		if len(notes) > 0 {
+			b.WriteString(strings.Repeat(" ", hexdump_width-len(data)+2))
+			b.WriteString(strings.Join(notes, ", "))
+		}
+
+		lines = append(lines, strings.TrimRight(b.String(), " "))
+	}
+
+	return lines
+
+}
@@ -0,0 +1,49 @@
+package chip8
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// RenderImage returns the display as a monochrome image where each pixel that is on
+// is a scale x scale block of white on black, or in color in Megachip mode. A scale below 1
+// is treated as 1.
+func (chip *Chip8) RenderImage(scale int) image.Image {
+
+	scale = max(scale, 1)
+
+	width, height := chip.Resolution()
+
+	if chip.MegaMode() {
+		img := image.NewRGBA(image.Rect(0, 0, width*scale, height*scale))
+		DrawFrameLetterboxed(img, chip.Display(), DefaultPalette)
+		return img
+	}
+
+	img := image.NewGray(image.Rect(0, 0, width*scale, height*scale))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+
+			if !chip.Pixel(x, y) {
+				continue
+			}
+
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetGray(x*scale+dx, y*scale+dy, color.Gray{0xFF})
+				}
+			}
+		}
+	}
+
+	return img
+
+}
+
+// WritePNG encodes the display rendered at the given scale as a PNG.
+func (chip *Chip8) WritePNG(w io.Writer, scale int) error {
+	return png.Encode(w, chip.RenderImage(scale))
+}
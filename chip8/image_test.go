@@ -0,0 +1,53 @@
+package chip8
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// SHA-256 of the pixels of the IBM logo rendered at scale 2, one byte per pixel.
+const ibm_logo_image_sha256 = "7e870c07e382cfe077b68a67d1bd28655c45b8716174c82360eafeeabfb14dca"
+
+func TestRenderImageGolden(t *testing.T) {
+
+	chip := New()
+	if err := chip.LoadROM("testdata/ibm_logo.ch8"); err != nil {
+		t.Fatal(err)
+	}
+	if err := chip.RunCycles(100); err != nil {
+		t.Fatal(err)
+	}
+
+	img, ok := chip.RenderImage(2).(*image.Gray)
+	if !ok {
+		t.Fatalf("rendered a %T, want an *image.Gray", chip.RenderImage(2))
+	}
+	if size := img.Bounds().Size(); size != image.Pt(2*DisplayWidth, 2*DisplayHeight) {
+		t.Fatalf("image is %v, want %dx%d", size, 2*DisplayWidth, 2*DisplayHeight)
+	}
+
+	sum := sha256.Sum256(img.Pix)
+	if got := hex.EncodeToString(sum[:]); got != ibm_logo_image_sha256 {
+		frame := chip.Display()
+		t.Errorf("image hash %s, want %s, display:\n%s", got, ibm_logo_image_sha256, frame.Text())
+	}
+
+	// The PNG decodes to the same pixels.
+	var buf bytes.Buffer
+	if err := chip.WritePNG(&buf, 2); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gray, ok := decoded.(*image.Gray)
+	if !ok || !bytes.Equal(gray.Pix, img.Pix) {
+		t.Error("the PNG differs from the rendered image")
+	}
+
+}
@@ -0,0 +1,38 @@
+package chip8
+
+import (
+	"strconv"
+
+	"chip8-go/chip8/decode"
+)
+
+// Instruction is a decoded opcode, see decode.Decode. Handlers receive the instruction they
+// execute.
+type Instruction = decode.Instruction
+
+// Every instruction of the supported platforms, as written in the opcode comments. A hex digit
+// must match the opcode, a letter matches any nibble.
+var instruction_patterns = []string{
+	"0010", "0011", "00BN", "01NN", "02NN", "03NN", "04NN", "05NN", "060N", "0700", "080N", "09NN",
+	"00CN", "00DN", "00E0", "00EE", "00FB", "00FC", "00FD", "00FE", "00FF",
+	"1NNN", "2NNN", "3XNN", "4XNN", "5XY0", "5XY2", "5XY3", "6XNN", "7XNN",
+	"8XY0", "8XY1", "8XY2", "8XY3", "8XY4", "8XY5", "8XY6", "8XY7", "8XYE",
+	"9XY0", "ANNN", "BNNN", "CXNN", "DXYN", "EX9E", "EXA1",
+	"F000", "FN01", "F002", "FX07", "FX0A", "FX15", "FX18", "FX1E", "FX29",
+	"FX30", "FX33", "FX3A", "FX55", "FX65", "FX75", "FX85",
+}
+
+// matchPattern reports whether opcode is an instance of an instruction pattern like "8XY4".
+func matchPattern(pattern string, opcode uint16) bool {
+
+	for i := 0; i < 4; i++ {
+		nibble := int(opcode>>(12-4*i)) & 0xF
+		digit, err := strconv.ParseUint(pattern[i:i+1], 16, 8)
+		if err == nil && int(digit) != nibble {
+			return false
+		}
+	}
+
+	return true
+
+}
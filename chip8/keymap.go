@@ -0,0 +1,74 @@
+package chip8
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// Keymap translates physical keys to CHIP-8 keys (0x0 to 0xF).
+// Letters are matched case-insensitively and should be stored lower case.
+type Keymap map[rune]byte
+
+// DefaultKeymap returns the conventional layout mapping the left block of a QWERTY keyboard
+// onto the COSMAC VIP hex keypad:
+//
+//	1 2 3 4        1 2 3 C
+//	Q W E R   ->   4 5 6 D
+//	A S D F        7 8 9 E
+//	Z X C V        A 0 B F
+func DefaultKeymap() Keymap {
+	return Keymap{
+		'1': 0x1, '2': 0x2, '3': 0x3, '4': 0xC,
+		'q': 0x4, 'w': 0x5, 'e': 0x6, 'r': 0xD,
+		'a': 0x7, 's': 0x8, 'd': 0x9, 'f': 0xE,
+		'z': 0xA, 'x': 0x0, 'c': 0xB, 'v': 0xF,
+	}
+}
+
+// ParseKeymap builds a keymap from a layout of 16 physical keys, listed in the order of the
+// CHIP-8 keys they map to, 0x0 to 0xF. DefaultKeymap is the layout "x123qweasdzc4rfv".
+func ParseKeymap(layout string) (Keymap, error) {
+
+	keys := []rune(layout)
+	if len(keys) != 16 {
+		return nil, fmt.Errorf("keymap %q: need 16 keys, got %d", layout, len(keys))
+	}
+
+	k := Keymap{}
+
+	for chip8_key, physical := range keys {
+		physical = unicode.ToLower(physical)
+		if _, ok := k[physical]; ok {
+			return nil, fmt.Errorf("keymap %q: %q is mapped twice", layout, physical)
+		}
+		k[physical] = byte(chip8_key)
+	}
+
+	return k, nil
+
+}
+
+// Lookup returns the CHIP-8 key mapped to a physical key.
+func (k Keymap) Lookup(physical rune) (byte, bool) {
+	key, ok := k[unicode.ToLower(physical)]
+	return key, ok
+}
+
+// SetKeymap replaces the keymap used by PressKey and ReleaseKey.
+func (chip *Chip8) SetKeymap(k Keymap) {
+	chip.keymap = k
+}
+
+// PressKey holds down the CHIP-8 key mapped to a physical key. Unmapped keys are ignored.
+func (chip *Chip8) PressKey(physical rune) {
+	if key, ok := chip.keymap.Lookup(physical); ok {
+		chip.KeyDown(key)
+	}
+}
+
+// ReleaseKey releases the CHIP-8 key mapped to a physical key. Unmapped keys are ignored.
+func (chip *Chip8) ReleaseKey(physical rune) {
+	if key, ok := chip.keymap.Lookup(physical); ok {
+		chip.KeyUp(key)
+	}
+}
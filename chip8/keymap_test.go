@@ -0,0 +1,78 @@
+package chip8
+
+import "testing"
+
+func TestDefaultKeymap(t *testing.T) {
+
+	k := DefaultKeymap()
+
+	tests := []struct {
+		physical rune
+		want     byte
+	}{
+		{'1', 0x1}, {'4', 0xC}, {'q', 0x4}, {'Q', 0x4}, {'w', 0x5}, {'r', 0xD},
+		{'a', 0x7}, {'f', 0xE}, {'x', 0x0}, {'z', 0xA}, {'V', 0xF},
+	}
+
+	for _, test := range tests {
+		if got, ok := k.Lookup(test.physical); !ok || got != test.want {
+			t.Errorf("%q maps to %X (%v), want %X", test.physical, got, ok, test.want)
+		}
+	}
+
+	if _, ok := k.Lookup('p'); ok {
+		t.Error("'p' is mapped")
+	}
+
+	// The layout of ParseKeymap documented for DefaultKeymap is the same.
+	parsed, err := ParseKeymap("x123qweasdzc4rfv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != len(k) {
+		t.Fatalf("parsed %d keys, want %d", len(parsed), len(k))
+	}
+	for physical, key := range k {
+		if parsed[physical] != key {
+			t.Errorf("%q: parsed %X, want %X", physical, parsed[physical], key)
+		}
+	}
+
+}
+
+func TestPressKey(t *testing.T) {
+
+	chip := New()
+
+	chip.PressKey('Q')
+	if !chip.keypad[0x4] {
+		t.Fatal("Q does not hold key 4")
+	}
+	for k, held := range chip.keypad {
+		if held && k != 0x4 {
+			t.Errorf("key %X held by Q", k)
+		}
+	}
+
+	chip.ReleaseKey('q')
+	if chip.keypad[0x4] {
+		t.Error("releasing q leaves key 4 held")
+	}
+
+	// Unmapped keys change nothing.
+	chip.PressKey('p')
+	if chip.keypad != [16]bool{} {
+		t.Error("an unmapped key holds a key")
+	}
+
+}
+
+func TestParseKeymapRejects(t *testing.T) {
+
+	for _, layout := range []string{"", "x123qweasdzc4rf", "x123qweasdzc4rfvv", "x123qweasdzc4rfX"} {
+		if _, err := ParseKeymap(layout); err == nil {
+			t.Errorf("layout %q accepted", layout)
+		}
+	}
+
+}
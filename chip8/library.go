@@ -0,0 +1,147 @@
+package chip8
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LibraryDatabase is the name of the optional database file of a ROM directory, a JSON object
+// mapping ROM file names, or the SHA-1 of the ROM in hexadecimal, to their ROMInfo.
+const LibraryDatabase = "roms.json"
+
+// File extensions ScanROMs lists as ROMs, Octo source included.
+var rom_extensions = []string{".ch8", ".c8", ".sc8", ".xo8", ".8o"}
+
+// ROMInfo describes a ROM of a library: where it is and how it is meant to be run. Besides the
+// database of the directory, a ROM may have a sidecar file of the same name with a .json
+// extension, whose fields take precedence.
+type ROMInfo struct {
+	Path string `json:"-"`
+
+	Title       string `json:"title"`
+	Author      string `json:"author,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Settings to run the ROM with, as accepted by ParsePlatform, QuirksPreset and
+	// ParseKeymap, and the address it loads at, e.g. "0x600". Empty or 0 for the defaults.
+	Platform    string `json:"platform,omitempty"`
+	Quirks      string `json:"quirks,omitempty"`
+	Speed       int    `json:"speed,omitempty"`
+	Keys        string `json:"keys,omitempty"`
+	LoadAddress string `json:"load_address,omitempty"`
+}
+
+// ScanROMs lists the ROMs in dir and its subdirectories, sorted by title. ROMs without
+// metadata are titled after their file name and get the recommended speed of RecommendedClockHz.
+func ScanROMs(dir string) ([]ROMInfo, error) {
+
+	database := map[string]ROMInfo{}
+	if err := readJSON(filepath.Join(dir, LibraryDatabase), &database); err != nil {
+		return nil, err
+	}
+
+	var roms []ROMInfo
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isROMFile(path) {
+			return nil
+		}
+
+		info, err := romInfo(path, database)
+		if err != nil {
+			return err
+		}
+		roms = append(roms, info)
+
+		return nil
+
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(roms, func(i, j int) bool {
+		return strings.ToLower(roms[i].Title) < strings.ToLower(roms[j].Title)
+	})
+
+	return roms, nil
+
+}
+
+// romInfo merges the metadata of the ROM at path from the database and its sidecar file.
+func romInfo(path string, database map[string]ROMInfo) (ROMInfo, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ROMInfo{}, err
+	}
+
+	info, ok := database[filepath.Base(path)]
+	if !ok {
+		sum := sha1.Sum(data)
+		info = database[hex.EncodeToString(sum[:])]
+	}
+
+	sidecar := strings.TrimSuffix(path, filepath.Ext(path)) + ".json"
+	if err := readJSON(sidecar, &info); err != nil {
+		return ROMInfo{}, err
+	}
+
+	info.Path = path
+
+	if info.Title == "" {
+		name := filepath.Base(path)
+		info.Title = strings.TrimSpace(strings.TrimSuffix(name, filepath.Ext(name)))
+	}
+	if info.Speed == 0 {
+		info.Speed = RecommendedClockHz(data, path)
+	}
+
+	return info, nil
+
+}
+
+// readJSON decodes the JSON file at path into v. A missing file leaves v unchanged.
+func readJSON(path string, v any) error {
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+
+}
+
+func isROMFile(path string) bool {
+
+	ext := strings.ToLower(filepath.Ext(path))
+
+	for _, e := range rom_extensions {
+		if ext == e {
+			return true
+		}
+	}
+
+	return false
+
+}
@@ -0,0 +1,31 @@
+package chip8
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// SetLogger sets where the machine reports what happens to it: loading, resets, pauses and save
+// states at the debug and info levels, skipped invalid opcodes as warnings. Failures are returned
+// as errors and left to the caller to log. A nil logger, the default, turns logging off.
+func (chip *Chip8) SetLogger(logger *slog.Logger) {
+	chip.logger = logger
+}
+
+// Logger returns the logger set with SetLogger, nil if there is none.
+func (chip *Chip8) Logger() *slog.Logger {
+	return chip.logger
+}
+
+// log reports an event to the logger, if any.
+func (chip *Chip8) log(level slog.Level, msg string, args ...any) {
+	if chip.logger != nil {
+		chip.logger.Log(context.Background(), level, msg, args...)
+	}
+}
+
+// hexAttr is an attribute holding an address or opcode, formatted in hexadecimal.
+func hexAttr(key string, value uint16) slog.Attr {
+	return slog.String(key, fmt.Sprintf("0x%03X", value))
+}
@@ -0,0 +1,594 @@
+package chip8
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// Display size in Megachip mode.
+const (
+	MegaWidth  = 256
+	MegaHeight = 192
+)
+
+// Megachip addresses 16MB of memory with its 24-bit I.
+const mega_memory_size = 0x1000000
+
+// Sprite pixels drawn from the fontset in Megachip mode are opaque white, with this palette index
+// for the collision check of later sprites.
+const mega_font_index = 0xFF
+
+// Megachip blend modes selected by 080N.
+const (
+	mega_blend_normal = iota
+	mega_blend_25
+	mega_blend_50
+	mega_blend_75
+	mega_blend_add
+	mega_blend_multiply
+)
+
+// Opacity of the blend modes that mix a sprite with the pixels under it, out of 255.
+var mega_blend_alpha = [...]int{mega_blend_normal: 255, mega_blend_25: 64, mega_blend_50: 128, mega_blend_75: 192}
+
+// MegaFrame is the display in Megachip mode, 256x192 pixels of 24-bit color indexed
+// [y*MegaWidth+x]. A frame is never changed once shown, frames with the same pointer are equal.
+type MegaFrame struct {
+	Pixels [MegaWidth * MegaHeight]color.RGBA
+}
+
+// megaChip is the state of the Megachip extensions. Sprites are drawn to the back buffer, which
+// 00E0 shows and clears. Its fields are exported for save states.
+type megaChip struct {
+	// On is set by 0011 and cleared by 0010. Outside Megachip mode the display is the
+	// SUPER-CHIP one.
+	On bool
+
+	// Back buffer - the color and palette index of each pixel, indexed [y*MegaWidth+x]
+	Back    [MegaWidth * MegaHeight]color.RGBA
+	Indices [MegaWidth * MegaHeight]byte
+
+	// Front buffer - the frame shown, nil until Megachip mode is turned on
+	Front *MegaFrame
+
+	// Palette - the colors loaded by 02NN, index 0 is transparent
+	Palette [256]color.RGBA
+
+	// Sprite size, set by 03NN and 04NN, 0 for 256
+	SpriteWidth  byte
+	SpriteHeight byte
+
+	// Screen alpha, set by 05NN, applied to the back buffer when it is shown
+	Alpha byte
+
+	// Blend mode, set by 080N
+	Blend byte
+
+	// Collision color, set by 09NN: V[F] is set when a sprite draws over this palette index
+	Collision byte
+
+	// Digitised sound started by 060N
+	Sample megaSample
+}
+
+// megaSample is a digitised sound played by 060N: 8-bit unsigned samples in memory.
+type megaSample struct {
+	Address  int
+	Length   int
+	Rate     int
+	Loop     bool
+	Playing  bool
+	Position float64
+}
+
+// newMegaChip returns the Megachip state after a reset: mode off, opaque and normal blending.
+func newMegaChip() *megaChip {
+
+	m := &megaChip{Alpha: 0xFF}
+	fillBlack(m.Back[:])
+
+	return m
+
+}
+
+// fillBlack sets pixels to opaque black.
+func fillBlack(pixels []color.RGBA) {
+	for i := range pixels {
+		pixels[i] = color.RGBA{A: 0xFF}
+	}
+}
+
+// clone returns a copy of the state, sharing the front buffer that is never changed.
+func (m *megaChip) clone() *megaChip {
+
+	if m == nil {
+		return nil
+	}
+
+	copied := *m
+
+	return &copied
+
+}
+
+// megaChip reports whether Megachip instructions are enabled. When they are not, they are
+// unknown opcodes.
+func (chip *Chip8) megaChip() bool {
+	return chip.platform == PlatformMegaChip
+}
+
+// MegaMode reports whether the Megachip 256x192 color mode is on.
+func (chip *Chip8) MegaMode() bool {
+	return chip.mega != nil && chip.mega.On
+}
+
+// mem returns the memory of the platform: 16MB with Megachip, 64kB otherwise.
+func (chip *Chip8) mem() []byte {
+
+	if chip.mega_memory != nil {
+		return chip.mega_memory
+	}
+
+	return chip.memory[:]
+
+}
+
+// addIndex adds n to I, which wraps around at 16 bits, or 24 bits with Megachip.
+func (chip *Chip8) addIndex(n int) {
+
+	mask := uint32(0xFFFF)
+	if chip.megaChip() {
+		mask = mega_memory_size - 1
+	}
+
+	chip.index_register = (chip.index_register + uint32(n)) & mask
+
+}
+
+// setPlatform switches the platform, moving memory to and from the 16MB of Megachip.
+func (chip *Chip8) setPlatform(p Platform) {
+
+	switch {
+	case p == PlatformMegaChip && chip.mega_memory == nil:
+		chip.mega_memory = make([]byte, mega_memory_size)
+		copy(chip.mega_memory, chip.memory[:])
+		chip.mega = newMegaChip()
+	case p != PlatformMegaChip && chip.mega_memory != nil:
+		copy(chip.memory[:], chip.mega_memory)
+		chip.mega_memory = nil
+		chip.mega = nil
+		chip.display_dirty = true
+	}
+
+	chip.platform = p
+
+}
+
+// DigitisedSound returns the Megachip sound started by 060N: a copy of its 8-bit unsigned
+// samples, played at rate samples per second, over and over if loop is set. ok is false while
+// none is playing.
+func (chip *Chip8) DigitisedSound() (samples []byte, rate int, loop bool, ok bool) {
+
+	if chip.mega == nil || !chip.mega.Sample.Playing {
+		return nil, 0, false, false
+	}
+
+	s := chip.mega.Sample
+
+	return append([]byte(nil), chip.mem()[s.Address:s.Address+s.Length]...), s.Rate, s.Loop, true
+
+}
+
+// tickSample moves the digitised sound on by a 60Hz frame, stopping it at its end unless it loops.
+func (chip *Chip8) tickSample() {
+
+	if chip.mega == nil || !chip.mega.Sample.Playing {
+		return
+	}
+
+	s := &chip.mega.Sample
+	s.Position += float64(s.Rate) / 60
+
+	if s.Position >= float64(s.Length) {
+		if s.Loop && s.Length > 0 {
+			for s.Position >= float64(s.Length) {
+				s.Position -= float64(s.Length)
+			}
+		} else {
+			s.Playing = false
+		}
+	}
+
+}
+
+// megaFrame returns the front buffer, or a black one before the first 00E0.
+func (m *megaChip) megaFrame() *MegaFrame {
+
+	if m.Front == nil {
+		m.Front = &MegaFrame{}
+		fillBlack(m.Front.Pixels[:])
+	}
+
+	return m.Front
+
+}
+
+// 0010 - Turn Megachip mode off, back to the SUPER-CHIP display
+func (chip *Chip8) op0010(in Instruction) error {
+
+	if !chip.megaChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.mega.On = false
+	chip.Clear()
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 0011 - Turn Megachip mode on, with a 256x192 color display
+func (chip *Chip8) op0011(in Instruction) error {
+
+	if !chip.megaChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.mega.On = true
+	chip.mega.megaFrame()
+	chip.display_dirty = true
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 01NN NNNN - Set I = NNNNNN, the 24-bit address in NN and the next 2 bytes
+func (chip *Chip8) op01NN(in Instruction) error {
+
+	if !chip.megaChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	addr := int(chip.program_counter) + 2
+
+	if err := chip.checkMemory(addr, 2, false); err != nil {
+		return fmt.Errorf("%w: fetch at PC 0x%04X", err, addr)
+	}
+
+	chip.index_register = uint32(in.NN)<<16 | uint32(chip.peek(addr))<<8 | uint32(chip.peek(addr+1))
+	chip.program_counter += 4
+
+	return nil
+
+}
+
+// 02NN - Load NN colors of the palette from memory starting at location I, 4 bytes each in
+// ARGB order, into palette indices 1 to NN
+func (chip *Chip8) op02NN(in Instruction) error {
+
+	if !chip.megaChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	if err := chip.checkMemory(int(chip.index_register), 4*in.NN, false); err != nil {
+		return err
+	}
+
+	for i := range in.NN {
+		argb := int(chip.index_register) + 4*i
+		chip.mega.Palette[i+1] = color.RGBA{
+			R: chip.peek(argb + 1),
+			G: chip.peek(argb + 2),
+			B: chip.peek(argb + 3),
+			A: chip.peek(argb),
+		}
+	}
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 03NN - Set the sprite width to NN pixels, 0 for 256
+func (chip *Chip8) op03NN(in Instruction) error {
+
+	if !chip.megaChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.mega.SpriteWidth = byte(in.NN)
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 04NN - Set the sprite height to NN pixels, 0 for 256
+func (chip *Chip8) op04NN(in Instruction) error {
+
+	if !chip.megaChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.mega.SpriteHeight = byte(in.NN)
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 05NN - Set the screen alpha to NN, from 0 (black) to 0xFF (opaque)
+func (chip *Chip8) op05NN(in Instruction) error {
+
+	if !chip.megaChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.mega.Alpha = byte(in.NN)
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 060N - Play the digitised sound at location I, once if N != 0 and over and over if N = 0
+func (chip *Chip8) op060N(in Instruction) error {
+
+	if in.NN > 0xF || !chip.megaChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	// The sound starts with its rate in 2 bytes and its length in 3, then a byte of padding.
+	header := int(chip.index_register)
+
+	if err := chip.checkMemory(header, 6, false); err != nil {
+		return fmt.Errorf("%w: sound at I 0x%06X", err, chip.index_register)
+	}
+
+	rate := int(chip.peek(header))<<8 | int(chip.peek(header+1))
+	length := int(chip.peek(header+2))<<16 | int(chip.peek(header+3))<<8 | int(chip.peek(header+4))
+
+	if header+6+length > len(chip.mem()) {
+		return fmt.Errorf("%w: sound at I 0x%06X", ErrMemoryOutOfRange, chip.index_register)
+	}
+
+	chip.mega.Sample = megaSample{
+		Address: header + 6,
+		Length:  length,
+		Rate:    rate,
+		Loop:    in.N == 0,
+		Playing: true,
+	}
+
+	// A new sound restarts the beep even if one is playing.
+	chip.sound_playing = true
+	if chip.OnSound != nil {
+		chip.OnSound(true)
+	}
+
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 0700 - Stop the digitised sound
+func (chip *Chip8) op0700(in Instruction) error {
+
+	if !chip.megaChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.mega.Sample.Playing = false
+	chip.updateSound()
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 080N - Set the blend mode: 0 normal, 1 25%, 2 50%, 3 75%, 4 additive, 5 multiply
+func (chip *Chip8) op080N(in Instruction) error {
+
+	if in.NN > mega_blend_multiply || !chip.megaChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.mega.Blend = byte(in.N)
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 09NN - Set the collision color: DXYN sets V[F] when it draws over palette index NN
+func (chip *Chip8) op09NN(in Instruction) error {
+
+	if !chip.megaChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.mega.Collision = byte(in.NN)
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 00BN - Scroll the display up N pixels
+func (chip *Chip8) op00BN(in Instruction) error {
+
+	if !chip.megaChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.scroll(0, -in.N)
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// showMega is 00E0 in Megachip mode: the back buffer is shown, faded by the screen alpha, and
+// cleared for the next frame.
+func (chip *Chip8) showMega() {
+
+	m := chip.mega
+	front := &MegaFrame{}
+
+	for i, c := range m.Back {
+		front.Pixels[i] = color.RGBA{
+			R: byte(int(c.R) * int(m.Alpha) / 0xFF),
+			G: byte(int(c.G) * int(m.Alpha) / 0xFF),
+			B: byte(int(c.B) * int(m.Alpha) / 0xFF),
+			A: 0xFF,
+		}
+	}
+
+	m.Front = front
+	fillBlack(m.Back[:])
+	clear(m.Indices[:])
+
+	chip.display_dirty = true
+
+}
+
+// scroll moves the back buffer by dx pixels right and dy pixels down. The uncovered area
+// is cleared. Rows are moved in place, starting from the side they move to.
+func (m *megaChip) scroll(dx, dy int) {
+
+	dx = max(min(dx, MegaWidth), -MegaWidth)
+
+	for i := range MegaHeight {
+
+		y := i
+		if dy > 0 {
+			y = MegaHeight - 1 - i
+		}
+
+		back := m.Back[y*MegaWidth : (y+1)*MegaWidth]
+		indices := m.Indices[y*MegaWidth : (y+1)*MegaWidth]
+
+		sy := y - dy
+		if sy < 0 || sy >= MegaHeight {
+			fillBlack(back)
+			clear(indices)
+			continue
+		}
+
+		copy(back, m.Back[sy*MegaWidth:(sy+1)*MegaWidth])
+		copy(indices, m.Indices[sy*MegaWidth:(sy+1)*MegaWidth])
+
+		if dx > 0 {
+			copy(back[dx:], back)
+			copy(indices[dx:], indices)
+			fillBlack(back[:dx])
+			clear(indices[:dx])
+		} else if dx < 0 {
+			copy(back, back[-dx:])
+			copy(indices, indices[-dx:])
+			fillBlack(back[MegaWidth+dx:])
+			clear(indices[MegaWidth+dx:])
+		}
+	}
+
+}
+
+// drawMega is DXYN in Megachip mode: a sprite of palette indices, one byte per pixel, is
+// blended into the back buffer at (V[X], V[Y]). Index 0 is transparent and pixels past the
+// edges are clipped. V[F] is set if a pixel is drawn over the collision color.
+// Sprites of the fontset, below 0x200, are drawn in 1 bit per pixel like in the other modes.
+func (chip *Chip8) drawMega(in Instruction) error {
+
+	m := chip.mega
+
+	x := int(chip.registers[in.X])
+	y := int(chip.registers[in.Y])
+
+	chip.registers[15] = 0
+
+	addr := int(chip.index_register)
+
+	if addr < interpreter_area_end {
+		if err := chip.checkMemory(addr, in.N, false); err != nil {
+			return fmt.Errorf("%w: sprite at I 0x%03X", err, chip.index_register)
+		}
+		for row := range in.N {
+			bits := chip.peek(addr + row)
+			for col := range 8 {
+				if bits&(0x80>>col) != 0 {
+					chip.plotMega(x+col, y+row, mega_font_index, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF})
+				}
+			}
+		}
+		chip.program_counter += 2
+		return nil
+	}
+
+	width, height := int(m.SpriteWidth), int(m.SpriteHeight)
+	if width == 0 {
+		width = 256
+	}
+	if height == 0 {
+		height = 256
+	}
+
+	if err := chip.checkMemory(addr, width*height, false); err != nil {
+		return fmt.Errorf("%w: sprite at I 0x%06X", err, chip.index_register)
+	}
+
+	for row := range height {
+		for col := range width {
+			if index := chip.peek(addr + row*width + col); index != 0 {
+				chip.plotMega(x+col, y+row, index, m.Palette[index])
+			}
+		}
+	}
+
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// plotMega blends a sprite pixel of the given palette index and color into the back buffer.
+func (chip *Chip8) plotMega(x, y int, index byte, c color.RGBA) {
+
+	if x >= MegaWidth || y >= MegaHeight {
+		return
+	}
+
+	m := chip.mega
+	p := y*MegaWidth + x
+
+	if m.Indices[p] == m.Collision {
+		chip.registers[15] = 1
+	}
+
+	m.Back[p] = blend(m.Back[p], c, m.Blend)
+	m.Indices[p] = index
+
+}
+
+// blend returns the color of a pixel dst with a sprite pixel src drawn over it in a blend mode.
+// The alpha of src weighs it against dst, on top of the opacity of the mode.
+func blend(dst, src color.RGBA, mode byte) color.RGBA {
+
+	alpha := int(src.A)
+
+	mix := func(d, s byte) byte {
+		switch mode {
+		case mega_blend_add:
+			return byte(min(int(d)+int(s)*alpha/0xFF, 0xFF))
+		case mega_blend_multiply:
+			return byte(int(d) * (0xFF*0xFF - alpha*(0xFF-int(s))) / (0xFF * 0xFF))
+		}
+		a := alpha * mega_blend_alpha[mode] / 0xFF
+		return byte((int(s)*a + int(d)*(0xFF-a)) / 0xFF)
+	}
+
+	return color.RGBA{R: mix(dst.R, src.R), G: mix(dst.G, src.G), B: mix(dst.B, src.B), A: 0xFF}
+
+}
@@ -0,0 +1,147 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MemoryPolicy selects what an instruction accessing memory past its end does.
+type MemoryPolicy int
+
+const (
+	// MemoryStrict fails the instruction with ErrMemoryOutOfRange, the default.
+	MemoryStrict MemoryPolicy = iota
+
+	// MemoryWrap wraps the address around to the start of memory, like the address lines
+	// of the original hardware.
+	MemoryWrap
+)
+
+func (p MemoryPolicy) String() string {
+	if p == MemoryWrap {
+		return "wrap"
+	}
+	return "strict"
+}
+
+// ParseMemoryPolicy returns the memory policy named "strict" or "wrap".
+func ParseMemoryPolicy(name string) (MemoryPolicy, error) {
+
+	switch strings.ToLower(name) {
+	case "strict":
+		return MemoryStrict, nil
+	case "wrap":
+		return MemoryWrap, nil
+	}
+
+	return MemoryStrict, fmt.Errorf("unknown memory policy %q, want strict or wrap", name)
+
+}
+
+// The interpreter lived below this address on the COSMAC VIP, where programs are loaded.
+// The fontsets are kept there too.
+const interpreter_area_end = DefaultLoadAddress
+
+// ErrProtectedMemory is returned when an instruction writes to the interpreter area while it
+// is protected, see SetMemoryProtection.
+var ErrProtectedMemory = errors.New("write to protected memory")
+
+// SetMemoryPolicy selects what an instruction accessing memory past its end does.
+func (chip *Chip8) SetMemoryPolicy(p MemoryPolicy) {
+	chip.memory_policy = p
+}
+
+// MemoryPolicy returns the policy for accesses past the end of memory.
+func (chip *Chip8) MemoryPolicy() MemoryPolicy {
+	return chip.memory_policy
+}
+
+// SetMemoryProtection selects whether writes to the interpreter area, 0x000 to 0x1FF, fail
+// with ErrProtectedMemory. Such writes usually reveal a bug, a stray I overwriting the fontset.
+// Disabled by default, the area being plain memory.
+func (chip *Chip8) SetMemoryProtection(enabled bool) {
+	chip.protect_memory = enabled
+}
+
+// ReadMemory reads the byte at addr like an instruction would, applying the memory policy.
+func (chip *Chip8) ReadMemory(addr int) (byte, error) {
+
+	if err := chip.checkMemory(addr, 1, false); err != nil {
+		return 0, err
+	}
+
+	return chip.peek(addr), nil
+
+}
+
+// WriteMemory writes the byte at addr like an instruction would, applying the memory policy and
+// the protection of the interpreter area.
+func (chip *Chip8) WriteMemory(addr int, value byte) error {
+
+	if err := chip.checkMemory(addr, 1, true); err != nil {
+		return err
+	}
+
+	chip.poke(addr, value)
+
+	return nil
+
+}
+
+// checkMemory checks an access to the n bytes from addr, a write when write is set. Once it
+// passed, peek and poke access those bytes.
+func (chip *Chip8) checkMemory(addr, n int, write bool) error {
+
+	size := chip.memorySize()
+
+	if addr < 0 || (chip.memory_policy != MemoryWrap && addr+n > size) {
+		return ErrMemoryOutOfRange
+	}
+
+	if write && chip.protect_memory {
+		for i := 0; i < n; i++ {
+			if a := (addr + i) % size; a < interpreter_area_end {
+				return fmt.Errorf("%w: 0x%03X", ErrProtectedMemory, a)
+			}
+		}
+	}
+
+	return nil
+
+}
+
+// peek reads the byte at addr, wrapped around to the start of memory.
+func (chip *Chip8) peek(addr int) byte {
+
+	addr %= chip.memorySize()
+	value := chip.mem()[addr]
+
+	if chip.OnRead != nil {
+		value = chip.OnRead(addr, value)
+	}
+	if chip.on_memory != nil {
+		chip.on_memory(addr, false, value, value)
+	}
+
+	return value
+
+}
+
+// poke writes the byte at addr, wrapped around to the start of memory.
+func (chip *Chip8) poke(addr int, value byte) {
+
+	addr %= chip.memorySize()
+	memory := chip.mem()
+
+	if chip.OnWrite != nil {
+		value = chip.OnWrite(addr, memory[addr], value)
+	}
+	if chip.on_memory != nil {
+		chip.on_memory(addr, true, memory[addr], value)
+	}
+
+	memory[addr] = value
+	chip.invalidateDecoded(addr, 1)
+
+}
@@ -0,0 +1,50 @@
+package chip8
+
+// MirrorState is a lightweight copy of the machine state published for live inspection.
+type MirrorState struct {
+	PC         uint16
+	Opcode     uint16
+	Keymask    uint16
+	Frame      uint64
+	DelayTimer uint8
+	SoundTimer uint8
+}
+
+// SetMirror sets the channel PublishState sends to. Pass nil to stop publishing.
+// The channel should be buffered: states are dropped when it is full so the CPU never blocks.
+func (chip *Chip8) SetMirror(ch chan<- MirrorState) {
+	chip.mirror = ch
+}
+
+// PublishState sends the current state to the mirror channel, if any, without blocking.
+// Drivers call it once per frame.
+func (chip *Chip8) PublishState(frame uint64) {
+
+	if chip.mirror == nil {
+		return
+	}
+
+	state := MirrorState{
+		PC:         chip.program_counter,
+		Frame:      frame,
+		DelayTimer: chip.delay_timer,
+		SoundTimer: chip.sound_timer,
+	}
+
+	// Opcode at the program counter, i.e. the next instruction to execute.
+	state.Opcode = chip.opcodeAt(chip.program_counter)
+
+	// One bit per held key, bit 0 being key 0x0.
+	for key, held := range chip.keypad {
+		if held {
+			state.Keymask |= 1 << key
+		}
+	}
+
+	select {
+	case chip.mirror <- state:
+	default:
+		// Drop the state rather than blocking the CPU.
+	}
+
+}
@@ -0,0 +1,68 @@
+package chip8
+
+import "testing"
+
+func TestPublishStateOverFrames(t *testing.T) {
+
+	chip := New()
+	if err := chip.LoadROMBytes([]byte{0x60, 0x05, 0x70, 0x01, 0x12, 0x02}); err != nil {
+		t.Fatal(err)
+	}
+
+	states := make(chan MirrorState, 4)
+	chip.SetMirror(states)
+	chip.KeyDown(0xA)
+
+	for frame := uint64(0); frame < 3; frame++ {
+
+		if err := chip.Cycle(); err != nil {
+			t.Fatal(err)
+		}
+		delay, sound := uint8(10+frame), uint8(20+frame)
+		chip.delay_timer, chip.sound_timer = delay, sound
+		chip.PublishState(frame)
+
+		state := <-states
+		if state.Frame != frame {
+			t.Errorf("frame %d: published frame %d", frame, state.Frame)
+		}
+		if state.PC != chip.program_counter || state.Opcode != chip.opcodeAt(chip.program_counter) {
+			t.Errorf("frame %d: PC %04X opcode %04X, want %04X %04X", frame, state.PC, state.Opcode,
+				chip.program_counter, chip.opcodeAt(chip.program_counter))
+		}
+		if state.Keymask != 1<<0xA {
+			t.Errorf("frame %d: keymask %016b, want key A", frame, state.Keymask)
+		}
+		if state.DelayTimer != delay || state.SoundTimer != sound {
+			t.Errorf("frame %d: timers %d and %d, want %d and %d", frame, state.DelayTimer, state.SoundTimer, delay, sound)
+		}
+	}
+
+}
+
+func TestPublishStateDropsWhenFull(t *testing.T) {
+
+	chip := New()
+
+	states := make(chan MirrorState, 2)
+	chip.SetMirror(states)
+
+	// The third and fourth states find the channel full and are dropped without blocking.
+	for frame := uint64(0); frame < 4; frame++ {
+		chip.PublishState(frame)
+	}
+
+	if len(states) != 2 {
+		t.Fatalf("%d states queued, want 2", len(states))
+	}
+	for _, want := range []uint64{0, 1} {
+		if got := (<-states).Frame; got != want {
+			t.Errorf("got frame %d, want %d", got, want)
+		}
+	}
+
+	// Without a channel nothing is published.
+	chip.SetMirror(nil)
+	chip.PublishState(5)
+
+}
@@ -0,0 +1,458 @@
+package chip8
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Netplay shares a machine between players on different hosts: a NetplayHost runs the program
+// and streams its display to the clients connected over TCP, which send back the keys their
+// player holds. The host presses a key while its own player or any client holds it, so two
+// players can each control their side of the keypad, e.g. 1 and 4 against C and D in Pong.
+//
+// The protocol is a JSON message per line. The host starts with {"netplay": 1}, the version of
+// the protocol, then sends the display each frame it changed, as the telemetry does:
+//
+//	{"frame": 120, "width": 64, "height": 32, "full": false, "rows": {"5": "0001110000..."}, "beep": false}
+//
+// Clients send {"keys": 4096} whenever their keypad changed, the keys held as a mask, bit 0 for
+// key 0. Both sides only send states, never events: the host drops the frames a slow client
+// cannot take, sending it the whole display next, and a late message from either side is made
+// up for by the next one. The keys of a client are released when it disconnects. Megachip
+// displays are not shared.
+
+// Version of the netplay protocol, sent by the host first.
+const netplay_version = 1
+
+// Frames queued per client, a slow client misses the frames sent meanwhile.
+const netplay_queue = 8
+
+// Longest message the host reads from a client, whose messages are only its keys: a client
+// sending longer lines is disconnected rather than buffered.
+const netplay_max_line = 256
+
+// How long a write to a peer may take before it is disconnected.
+const netplay_write_timeout = 5 * time.Second
+
+// ErrNetplayEnded is returned by Run on a netplay client when the host ended the session.
+var ErrNetplayEnded = errors.New("the netplay host ended the session")
+
+// netplayHello is the first message of the host.
+type netplayHello struct {
+	Netplay int `json:"netplay"`
+}
+
+// netplayFrame is the display sent by the host.
+type netplayFrame struct {
+	Frame  uint64 `json:"frame"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+
+	// Full - whether rows holds the whole display, not only the rows that changed
+	Full bool `json:"full"`
+
+	// Rows - the rows of pixels by y, one digit per pixel as in TelemetryFrame
+	Rows map[string]string `json:"rows"`
+
+	// Beep - whether the sound timer runs
+	Beep bool `json:"beep"`
+}
+
+// netplayKeys is the keypad sent by a client.
+type netplayKeys struct {
+	Keys uint16 `json:"keys"`
+}
+
+// NetplayHost shares a running machine with netplay clients, see Netplay above. Publish sends
+// them the display and MergeKeys adds their keys to those of the local player:
+//
+//	host := chip8.NewNetplayHost()
+//	go host.Serve(ctx, listener)
+//	chip.OnFrame = func(chip8.Frame) { host.Publish(chip) }
+//	chip.OnKeys = host.MergeKeys
+type NetplayHost struct {
+	// Guards clients
+	mu      sync.Mutex
+	clients map[*netplayPeer]bool
+
+	// Display and beep of the last frame published, the rows are sent against them, and
+	// frames published
+	frame     Frame
+	beep      bool
+	published uint64
+}
+
+// netplayPeer is a client connected to a host.
+type netplayPeer struct {
+	send chan []byte
+
+	// Set when the next message has to carry the whole display
+	full bool
+
+	// Keys the client holds, as a mask
+	keys uint16
+}
+
+// NewNetplayHost returns a netplay host without clients.
+func NewNetplayHost() *NetplayHost {
+	return &NetplayHost{clients: map[*netplayPeer]bool{}}
+}
+
+// Serve accepts clients on l until ctx is done or l fails.
+func (h *NetplayHost) Serve(ctx context.Context, l net.Listener) error {
+
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go h.ServeConn(ctx, conn)
+	}
+
+}
+
+// ServeConn shares the machine with the client on conn until either side ends the session.
+func (h *NetplayHost) ServeConn(ctx context.Context, conn net.Conn) {
+
+	defer conn.Close()
+
+	hello, _ := json.Marshal(netplayHello{Netplay: netplay_version})
+	if writeNetplay(conn, append(hello, '\n')) != nil {
+		return
+	}
+
+	peer := &netplayPeer{send: make(chan []byte, netplay_queue), full: true}
+
+	h.mu.Lock()
+	h.clients[peer] = true
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, peer)
+		h.mu.Unlock()
+	}()
+
+	// The client is gone once its reads end.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(conn)
+		scanner.Buffer(make([]byte, netplay_max_line), netplay_max_line)
+		for scanner.Scan() {
+			var msg netplayKeys
+			if json.Unmarshal(scanner.Bytes(), &msg) != nil {
+				return
+			}
+			h.mu.Lock()
+			peer.keys = msg.Keys
+			h.mu.Unlock()
+		}
+	}()
+
+	for {
+		select {
+		case msg := <-peer.send:
+			if writeNetplay(conn, msg) != nil {
+				return
+			}
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+
+}
+
+// Clients returns the number of clients connected.
+func (h *NetplayHost) Clients() int {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.clients)
+
+}
+
+// Publish sends the display of chip to the clients, it is called once per frame on the
+// goroutine running the machine, e.g. from OnFrame. Only the frames that changed are sent, and
+// it never blocks: a client whose queue is full misses the frame.
+func (h *NetplayHost) Publish(chip *Chip8) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	frame := chip.Display()
+	if frame.Mega != nil {
+		return
+	}
+
+	previous, previous_beep := h.frame, h.beep
+	h.frame, h.beep = frame, chip.sound_playing
+	h.published++
+
+	changed := frame != previous || h.beep != previous_beep
+
+	msg := netplayFrame{Frame: h.published, Width: frame.Width, Height: frame.Height, Beep: h.beep}
+
+	// Messages are encoded once for all the clients, with and without the whole display.
+	var diff, full []byte
+	encode := func(all bool) []byte {
+		msg.Full, msg.Rows = all, telemetryRows(&frame, &previous, all)
+		data, _ := json.Marshal(msg)
+		return append(data, '\n')
+	}
+
+	for peer := range h.clients {
+
+		var data []byte
+		switch {
+		case peer.full:
+			if full == nil {
+				full = encode(true)
+			}
+			data = full
+		case changed:
+			if diff == nil {
+				diff = encode(false)
+			}
+			data = diff
+		default:
+			continue
+		}
+
+		select {
+		case peer.send <- data:
+			peer.full = false
+		default:
+			peer.full = true
+		}
+	}
+
+}
+
+// MergeKeys returns keys, the keypad of the local player, with the keys the clients hold
+// pressed too, for OnKeys.
+func (h *NetplayHost) MergeKeys(keys [16]bool) [16]bool {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for peer := range h.clients {
+		for k := range keys {
+			keys[k] = keys[k] || peer.keys&(1<<k) != 0
+		}
+	}
+
+	return keys
+
+}
+
+// NetplayClient is the connection of a client to a NetplayHost. A machine following it, see
+// FollowNetplay, shows the display of the host instead of running a program.
+type NetplayClient struct {
+	conn net.Conn
+
+	// Keys last sent to the host, and whether any were
+	keys      uint16
+	keys_sent bool
+
+	// Guards the fields below, written by the goroutine reading the host
+	mu sync.Mutex
+
+	// Display and beep of the host, as of its last message
+	frame Frame
+	beep  bool
+
+	// Why the session ended, nil while it goes on
+	err error
+}
+
+// DialNetplay connects to the netplay host at addr, host:port.
+func DialNetplay(addr string) (*NetplayClient, error) {
+
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := NewNetplayClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+
+}
+
+// NewNetplayClient starts a netplay session on conn, connected to a host.
+func NewNetplayClient(conn net.Conn) (*NetplayClient, error) {
+
+	dec := json.NewDecoder(bufio.NewReader(conn))
+
+	var hello netplayHello
+	if err := dec.Decode(&hello); err != nil {
+		return nil, fmt.Errorf("not a netplay host: %w", err)
+	}
+	if hello.Netplay != netplay_version {
+		return nil, fmt.Errorf("netplay protocol version %d of the host is not supported, want %d", hello.Netplay, netplay_version)
+	}
+
+	c := &NetplayClient{conn: conn, frame: Frame{Width: DisplayWidth, Height: DisplayHeight}}
+	go c.readLoop(dec)
+
+	return c, nil
+
+}
+
+// Close ends the session.
+func (c *NetplayClient) Close() error {
+	return c.conn.Close()
+}
+
+// readLoop applies the frames of the host until the session ends.
+func (c *NetplayClient) readLoop(dec *json.Decoder) {
+
+	for {
+		var msg netplayFrame
+		err := dec.Decode(&msg)
+		if err == nil {
+			err = c.apply(msg)
+		}
+
+		if err != nil {
+			c.mu.Lock()
+			c.err = ErrNetplayEnded
+			if !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.EOF) {
+				c.err = fmt.Errorf("%w: %v", ErrNetplayEnded, err)
+			}
+			c.mu.Unlock()
+			c.conn.Close()
+			return
+		}
+	}
+
+}
+
+// apply updates the display with a frame of the host.
+func (c *NetplayClient) apply(msg netplayFrame) error {
+
+	low := msg.Width == DisplayWidth && msg.Height == DisplayHeight
+	if !low && (msg.Width != HiResWidth || msg.Height != HiResHeight) {
+		return fmt.Errorf("invalid display size %dx%d", msg.Width, msg.Height)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Pixels past a smaller display are off.
+	if msg.Width != c.frame.Width || msg.Height != c.frame.Height {
+		c.frame = Frame{Width: msg.Width, Height: msg.Height}
+	}
+	c.beep = msg.Beep
+
+	for key, row := range msg.Rows {
+		y, err := strconv.Atoi(key)
+		if err != nil || y < 0 || y >= msg.Height || len(row) != msg.Width {
+			return fmt.Errorf("invalid display row %q", key)
+		}
+		for x := range len(row) {
+			pixel := row[x] - '0'
+			if pixel > 3 {
+				return fmt.Errorf("invalid pixel %q in row %d", row[x], y)
+			}
+			setPlanePixel(&c.frame.Planes, x, y, pixel)
+		}
+	}
+
+	return nil
+
+}
+
+// sendKeys sends the keypad to the host if it changed.
+func (c *NetplayClient) sendKeys(keys uint16) error {
+
+	if c.keys_sent && keys == c.keys {
+		return nil
+	}
+	c.keys, c.keys_sent = keys, true
+
+	data, _ := json.Marshal(netplayKeys{Keys: keys})
+
+	return writeNetplay(c.conn, append(data, '\n'))
+
+}
+
+// writeNetplay writes a message to a peer, giving up after netplay_write_timeout.
+func writeNetplay(conn net.Conn, msg []byte) error {
+
+	conn.SetWriteDeadline(time.Now().Add(netplay_write_timeout))
+	_, err := conn.Write(msg)
+
+	return err
+
+}
+
+// FollowNetplay makes Run show the display of the netplay host of c and send it the keypad,
+// instead of running the loaded program, until the session ends and Run returns
+// ErrNetplayEnded. The beep follows the sound timer of the host.
+func (chip *Chip8) FollowNetplay(c *NetplayClient) {
+	chip.netplay = c
+}
+
+// followFrame runs a frame of Run on a netplay client: the display of the host is shown,
+// frame is called and the keys it polled are sent.
+func (chip *Chip8) followFrame(frame func()) error {
+
+	c := chip.netplay
+
+	c.mu.Lock()
+	remote, beep, err := c.frame, c.beep, c.err
+	c.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if width, height := chip.Resolution(); remote.Width != width || remote.Height != height || remote.Planes != chip.display {
+		chip.hires = remote.Width == HiResWidth
+		chip.display = remote.Planes
+		chip.display_dirty = true
+	}
+
+	if beep != chip.sound_playing {
+		chip.sound_playing = beep
+		if chip.OnSound != nil {
+			chip.OnSound(beep)
+		}
+	}
+
+	if frame != nil {
+		frame()
+	}
+
+	if err := c.sendKeys(chip.keyMask()); err != nil {
+		return fmt.Errorf("%w: %v", ErrNetplayEnded, err)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,107 @@
+package chip8
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitFor fails the test if cond does not hold within a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+
+}
+
+func TestNetplayOverPipe(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	host_conn, client_conn := net.Pipe()
+	host := NewNetplayHost()
+	go host.ServeConn(ctx, host_conn)
+
+	client, err := NewNetplayClient(client_conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	waitFor(t, "the client to join", func() bool { return host.Clients() == 1 })
+
+	// The host draws the digit 0 and the client shows it.
+	chip := New()
+	if err := chip.LoadROMBytes([]byte{0x60, 0x00, 0xF0, 0x29, 0xD0, 0x05, 0x12, 0x06}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chip.RunCycles(3); err != nil {
+		t.Fatal(err)
+	}
+	host.Publish(chip)
+
+	want := chip.Display()
+	waitFor(t, "the display", func() bool {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		return client.frame == want
+	})
+
+	// The keys of the client are pressed on the host, with those of the local player.
+	if err := client.sendKeys(1 << 0xC); err != nil {
+		t.Fatal(err)
+	}
+	var local [16]bool
+	local[0x1] = true
+	waitFor(t, "the keys", func() bool {
+		keys := host.MergeKeys(local)
+		return keys[0xC] && keys[0x1]
+	})
+
+	// They are released when it leaves.
+	client.Close()
+	waitFor(t, "the client to leave", func() bool { return host.Clients() == 0 })
+	if keys := host.MergeKeys(local); keys[0xC] {
+		t.Error("the key of the client is held after it left")
+	}
+
+}
+
+func TestNetplayHostDropsLongLines(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	host_conn, client_conn := net.Pipe()
+	defer client_conn.Close()
+
+	host := NewNetplayHost()
+	ended := make(chan struct{})
+	go func() {
+		host.ServeConn(ctx, host_conn)
+		close(ended)
+	}()
+
+	in := bufio.NewReader(client_conn)
+	if _, err := in.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+
+	// A line past netplay_max_line ends the session instead of growing the buffer.
+	go client_conn.Write([]byte(strings.Repeat(" ", 4*netplay_max_line)))
+
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("the host kept reading a line longer than its limit")
+	}
+
+}
@@ -0,0 +1,721 @@
+package chip8
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReadROMFile reads a ROM file, assembling it with AssembleOcto if it is Octo source, a .8o file.
+func ReadROMFile(path string) ([]byte, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil || !IsOctoSource(path) {
+		return data, err
+	}
+
+	rom, err := AssembleOcto(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+
+	return rom, nil
+
+}
+
+// IsOctoSource reports whether a file name is the one of Octo source, ending in .8o.
+func IsOctoSource(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".8o")
+}
+
+// AssembleOcto compiles the subset of the Octo language most games are written in into a ROM
+// to be loaded at DefaultLoadAddress. Tokens are separated by spaces and # starts a comment:
+//
+//	: main
+//		i := sprite
+//		v0 := 10
+//		loop
+//			sprite v0 v1 5
+//			v0 += 8
+//			while v0 != 50
+//		again
+//		if v2 == 0 then v3 := key
+//		tick
+//	: tick  clear ;
+//	: sprite  0xF0 0x90 0xF0 0x90 0xF0
+//
+// It knows labels (: name), calls by name, :const, :alias, :byte, the assignments and
+// arithmetic on vX, i, delay and buzzer, the if ... then and if ... begin ... else ... end
+// conditionals on ==, !=, key and -key, loop ... while ... again, sprite, bcd, save, load,
+// jump, jump0, return or ;, and the SUPER-CHIP and XO-CHIP statements. Numbers and
+// constants alone emit a byte, e.g. sprite data. Execution starts at main when it is
+// defined, through a jump unless main is the first thing in the source.
+func AssembleOcto(src string) ([]byte, error) {
+
+	asm := &octoAssembler{tokens: octoTokens(src), labels: map[string]int{}}
+	jump_main := asm.definesMain() && !asm.mainFirst()
+
+	// The first pass only collects label addresses, the second encodes with all of them known.
+	for pass := 1; pass <= 2; pass++ {
+
+		asm.final = pass == 2
+		asm.rom = asm.rom[:0]
+		asm.pos = 0
+		asm.consts = map[string]int{}
+		asm.aliases = map[string]uint16{}
+		asm.blocks = nil
+
+		if jump_main {
+			asm.jump(0x1000, "main")
+		}
+
+		for asm.pos < len(asm.tokens) {
+			line := asm.tokens[asm.pos].line
+			if err := asm.statement(); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+
+		if len(asm.blocks) > 0 {
+			return nil, fmt.Errorf("%s without its %s", asm.blocks[len(asm.blocks)-1].kind, octo_block_ends[asm.blocks[len(asm.blocks)-1].kind])
+		}
+	}
+
+	return asm.rom, nil
+
+}
+
+// octoToken is a word of Octo source and the line it is on.
+type octoToken struct {
+	text string
+	line int
+}
+
+// octoTokens splits Octo source into tokens, dropping the comments.
+func octoTokens(src string) []octoToken {
+
+	var tokens []octoToken
+
+	for n, line := range strings.Split(src, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		for _, word := range strings.Fields(line) {
+			tokens = append(tokens, octoToken{word, n + 1})
+		}
+	}
+
+	return tokens
+
+}
+
+// octoAssembler holds the state of an AssembleOcto pass.
+type octoAssembler struct {
+	tokens []octoToken
+	pos    int
+
+	labels  map[string]int
+	consts  map[string]int
+	aliases map[string]uint16
+	rom     []byte
+
+	// Set on the second pass, when undefined labels are an error.
+	final bool
+
+	// Blocks open at this point: if ... begin, else and loop
+	blocks []octoBlock
+}
+
+// octoBlock is an open block: its kind, where a loop starts, and the offsets in the ROM of
+// the jumps to the end of the block, patched once the end is known.
+type octoBlock struct {
+	kind    string
+	start   int
+	patches []int
+}
+
+// The statement closing each kind of block.
+var octo_block_ends = map[string]string{"begin": "end", "else": "end", "loop": "again"}
+
+// Opcodes of the statements without operands.
+var octo_no_operands = map[string]uint16{
+	"clear": 0x00E0, "return": 0x00EE, ";": 0x00EE, "scroll-right": 0x00FB, "scroll-left": 0x00FC,
+	"exit": 0x00FD, "lores": 0x00FE, "hires": 0x00FF, "audio": 0xF002,
+}
+
+// Last nibble of the 8XYN instructions, by assignment operator.
+var octo_alu = map[string]uint16{
+	":=": 0x0, "|=": 0x1, "&=": 0x2, "^=": 0x3, "+=": 0x4, "-=": 0x5, ">>=": 0x6, "=-": 0x7, "<<=": 0xE,
+}
+
+// Last byte of the FXNN instructions taking a register, by statement.
+var octo_register_ops = map[string]uint16{
+	"bcd": 0x33, "save": 0x55, "load": 0x65, "saveflags": 0x75, "loadflags": 0x85,
+}
+
+// address is where the next byte is assembled.
+func (asm *octoAssembler) address() int {
+	return DefaultLoadAddress + len(asm.rom)
+}
+
+// definesMain reports whether the source defines the main label.
+func (asm *octoAssembler) definesMain() bool {
+
+	for i := 0; i+1 < len(asm.tokens); i++ {
+		if asm.tokens[i].text == ":" && asm.tokens[i+1].text == "main" {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+// mainFirst reports whether the source starts with the main label.
+func (asm *octoAssembler) mainFirst() bool {
+	return len(asm.tokens) >= 2 && asm.tokens[0].text == ":" && asm.tokens[1].text == "main"
+}
+
+// next returns the next token, failing at the end of the source.
+func (asm *octoAssembler) next() (string, error) {
+
+	if asm.pos >= len(asm.tokens) {
+		return "", fmt.Errorf("unexpected end of source")
+	}
+
+	asm.pos++
+
+	return asm.tokens[asm.pos-1].text, nil
+
+}
+
+// peek returns the next token without consuming it, empty at the end of the source.
+func (asm *octoAssembler) peek() string {
+
+	if asm.pos >= len(asm.tokens) {
+		return ""
+	}
+
+	return asm.tokens[asm.pos].text
+
+}
+
+// emit appends an opcode to the ROM.
+func (asm *octoAssembler) emit(opcode uint16) {
+	asm.rom = append(asm.rom, byte(opcode>>8), byte(opcode))
+}
+
+// jump emits a jump or call to a label or address.
+func (asm *octoAssembler) jump(op uint16, target string) error {
+
+	nnn, err := asm.value(target, 0xFFF)
+	asm.emit(op | nnn)
+
+	return err
+
+}
+
+// placeholder emits a jump whose target is patched later, and returns its offset in the ROM.
+func (asm *octoAssembler) placeholder() int {
+
+	asm.emit(0x1000)
+
+	return len(asm.rom) - 2
+
+}
+
+// patch points the jump at offset to the next address.
+func (asm *octoAssembler) patch(offset int) error {
+
+	addr := asm.address()
+	if addr > 0xFFF {
+		return fmt.Errorf("block ends at 0x%X, past the reach of a jump", addr)
+	}
+
+	asm.rom[offset] = byte(0x10 | addr>>8)
+	asm.rom[offset+1] = byte(addr)
+
+	return nil
+
+}
+
+// statement assembles the statement at the current token.
+func (asm *octoAssembler) statement() error {
+
+	token, err := asm.next()
+	if err != nil {
+		return err
+	}
+
+	if opcode, ok := octo_no_operands[token]; ok {
+		asm.emit(opcode)
+		return nil
+	}
+
+	if nn, ok := octo_register_ops[token]; ok {
+		x, err := asm.registerToken()
+		if err != nil {
+			return err
+		}
+		// XO-CHIP saves and loads a range of registers, save vX - vY.
+		if (token == "save" || token == "load") && asm.peek() == "-" {
+			asm.pos++
+			y, err := asm.registerToken()
+			if err != nil {
+				return err
+			}
+			asm.emit(map[string]uint16{"save": 0x5002, "load": 0x5003}[token] | x<<8 | y<<4)
+			return nil
+		}
+		asm.emit(0xF000 | x<<8 | nn)
+		return nil
+	}
+
+	switch token {
+
+	case ":":
+		return asm.label()
+
+	case ":const":
+		name, err := asm.next()
+		if err != nil {
+			return err
+		}
+		value, err := asm.next()
+		if err != nil {
+			return err
+		}
+		n, err := asm.number(value)
+		if err != nil {
+			return err
+		}
+		asm.consts[name] = n
+		return nil
+
+	case ":alias":
+		name, err := asm.next()
+		if err != nil {
+			return err
+		}
+		x, err := asm.registerToken()
+		if err != nil {
+			return err
+		}
+		asm.aliases[name] = x
+		return nil
+
+	case ":breakpoint", ":monitor":
+		// Directives of the Octo debugger, skipped with their operands.
+		operands := map[string]int{":breakpoint": 1, ":monitor": 2}[token]
+		for range operands {
+			if _, err := asm.next(); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case ":byte":
+		value, err := asm.next()
+		if err != nil {
+			return err
+		}
+		n, err := asm.value(value, 0xFF)
+		asm.rom = append(asm.rom, byte(n))
+		return err
+
+	case "jump", "jump0", ":call", "native":
+		target, err := asm.next()
+		if err != nil {
+			return err
+		}
+		return asm.jump(map[string]uint16{"jump": 0x1000, "jump0": 0xB000, ":call": 0x2000, "native": 0x0000}[token], target)
+
+	case "sprite":
+		x, err := asm.registerToken()
+		if err != nil {
+			return err
+		}
+		y, err := asm.registerToken()
+		if err != nil {
+			return err
+		}
+		n, err := asm.operand(0xF)
+		asm.emit(0xD000 | x<<8 | y<<4 | n)
+		return err
+
+	case "scroll-down", "scroll-up":
+		n, err := asm.operand(0xF)
+		asm.emit(map[string]uint16{"scroll-down": 0x00C0, "scroll-up": 0x00D0}[token] | n)
+		return err
+
+	case "plane":
+		n, err := asm.operand(0xF)
+		asm.emit(0xF001 | n<<8)
+		return err
+
+	case "i":
+		return asm.index()
+
+	case "delay", "buzzer", "pitch":
+		if op, err := asm.next(); err != nil || op != ":=" {
+			return fmt.Errorf("want %s := vX", token)
+		}
+		x, err := asm.registerToken()
+		asm.emit(0xF000 | x<<8 | map[string]uint16{"delay": 0x15, "buzzer": 0x18, "pitch": 0x3A}[token])
+		return err
+
+	case "if":
+		return asm.conditional()
+
+	case "else":
+		if len(asm.blocks) == 0 || asm.blocks[len(asm.blocks)-1].kind != "begin" {
+			return fmt.Errorf("else without if ... begin")
+		}
+		block := &asm.blocks[len(asm.blocks)-1]
+		jump := asm.placeholder()
+		if err := asm.patch(block.patches[0]); err != nil {
+			return err
+		}
+		*block = octoBlock{kind: "else", patches: []int{jump}}
+		return nil
+
+	case "end":
+		if len(asm.blocks) == 0 || asm.blocks[len(asm.blocks)-1].kind == "loop" {
+			return fmt.Errorf("end without if ... begin")
+		}
+		block := asm.blocks[len(asm.blocks)-1]
+		asm.blocks = asm.blocks[:len(asm.blocks)-1]
+		return asm.patch(block.patches[0])
+
+	case "loop":
+		asm.blocks = append(asm.blocks, octoBlock{kind: "loop", start: asm.address()})
+		return nil
+
+	case "while":
+		loop := -1
+		for i := range asm.blocks {
+			if asm.blocks[i].kind == "loop" {
+				loop = i
+			}
+		}
+		if loop < 0 {
+			return fmt.Errorf("while outside a loop")
+		}
+		// The jump out of the loop is skipped while the condition holds.
+		_, skip_if_true, err := asm.condition()
+		if err != nil {
+			return err
+		}
+		asm.emit(skip_if_true)
+		asm.blocks[loop].patches = append(asm.blocks[loop].patches, asm.placeholder())
+		return nil
+
+	case "again":
+		if len(asm.blocks) == 0 || asm.blocks[len(asm.blocks)-1].kind != "loop" {
+			return fmt.Errorf("again without loop")
+		}
+		block := asm.blocks[len(asm.blocks)-1]
+		asm.blocks = asm.blocks[:len(asm.blocks)-1]
+		asm.emit(0x1000 | uint16(block.start))
+		for _, offset := range block.patches {
+			if err := asm.patch(offset); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if x, err := asm.register(token); err == nil {
+		return asm.assignment(x)
+	}
+
+	// A number or a constant alone is a byte of data, a label a call.
+	if n, err := asm.number(token); err == nil {
+		if n < -0x80 || n > 0xFF {
+			return fmt.Errorf("%s is out of range", token)
+		}
+		asm.rom = append(asm.rom, byte(n))
+		return nil
+	}
+	if validLabel(strings.ReplaceAll(token, "-", "_")) {
+		return asm.jump(0x2000, token)
+	}
+
+	return fmt.Errorf("unknown statement %q", token)
+
+}
+
+// label defines the label named by the next token at the current address.
+func (asm *octoAssembler) label() error {
+
+	name, err := asm.next()
+	if err != nil {
+		return err
+	}
+
+	if !asm.final {
+		if _, ok := asm.labels[name]; ok {
+			return fmt.Errorf("label %q defined twice", name)
+		}
+		asm.labels[name] = asm.address()
+	}
+
+	return nil
+
+}
+
+// index assembles the statements on i: i := addr, i := long addr, i := hex vX, i := bighex vX
+// and i += vX.
+func (asm *octoAssembler) index() error {
+
+	op, err := asm.next()
+	if err != nil {
+		return err
+	}
+
+	if op == "+=" {
+		x, err := asm.registerToken()
+		asm.emit(0xF01E | x<<8)
+		return err
+	}
+	if op != ":=" {
+		return fmt.Errorf("unknown operator i %s", op)
+	}
+
+	switch asm.peek() {
+	case "hex", "bighex":
+		kind, _ := asm.next()
+		x, err := asm.registerToken()
+		asm.emit(0xF000 | x<<8 | map[string]uint16{"hex": 0x29, "bighex": 0x30}[kind])
+		return err
+
+	case "long":
+		asm.pos++
+		nnnn, err := asm.operand(0xFFFF)
+		asm.emit(0xF000)
+		asm.emit(nnnn)
+		return err
+	}
+
+	nnn, err := asm.operand(0xFFF)
+	asm.emit(0xA000 | nnn)
+
+	return err
+
+}
+
+// assignment assembles the statements on the register vX: :=, the arithmetic operators, and
+// := random, := delay and := key.
+func (asm *octoAssembler) assignment(x uint16) error {
+
+	op, err := asm.next()
+	if err != nil {
+		return err
+	}
+
+	if op == ":=" {
+		switch asm.peek() {
+		case "random":
+			asm.pos++
+			nn, err := asm.operand(0xFF)
+			asm.emit(0xC000 | x<<8 | nn)
+			return err
+		case "delay", "key":
+			source, _ := asm.next()
+			asm.emit(0xF000 | x<<8 | map[string]uint16{"delay": 0x07, "key": 0x0A}[source])
+			return nil
+		}
+	}
+
+	nibble, ok := octo_alu[op]
+	if !ok {
+		return fmt.Errorf("unknown operator %s", op)
+	}
+
+	operand, err := asm.next()
+	if err != nil {
+		return err
+	}
+
+	if y, err := asm.register(operand); err == nil {
+		asm.emit(0x8000 | x<<8 | y<<4 | nibble)
+		return nil
+	}
+
+	nn, err := asm.value(operand, 0xFF)
+	switch op {
+	case ":=":
+		asm.emit(0x6000 | x<<8 | nn)
+	case "+=":
+		asm.emit(0x7000 | x<<8 | nn)
+	case "-=":
+		asm.emit(0x7000 | x<<8 | -nn&0xFF)
+	default:
+		return fmt.Errorf("%s takes a register", op)
+	}
+
+	return err
+
+}
+
+// conditional assembles if ... then, which makes the next statement conditional, and
+// if ... begin, which opens a block.
+func (asm *octoAssembler) conditional() error {
+
+	skip_if_false, skip_if_true, err := asm.condition()
+	if err != nil {
+		return err
+	}
+
+	switch keyword, err := asm.next(); {
+	case err != nil:
+		return err
+	case keyword == "then":
+		asm.emit(skip_if_false)
+	case keyword == "begin":
+		asm.emit(skip_if_true)
+		asm.blocks = append(asm.blocks, octoBlock{kind: "begin", patches: []int{asm.placeholder()}})
+	default:
+		return fmt.Errorf("want then or begin after the condition, got %q", keyword)
+	}
+
+	return nil
+
+}
+
+// condition parses vX == operand, vX != operand, vX key or vX -key and returns the skip
+// instructions skipping the next instruction when it is false, and when it is true.
+func (asm *octoAssembler) condition() (skip_if_false, skip_if_true uint16, err error) {
+
+	x, err := asm.registerToken()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	op, err := asm.next()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch op {
+	case "key":
+		return 0xE0A1 | x<<8, 0xE09E | x<<8, nil
+	case "-key":
+		return 0xE09E | x<<8, 0xE0A1 | x<<8, nil
+	case "==", "!=":
+	default:
+		return 0, 0, fmt.Errorf("unsupported comparison %s, only ==, !=, key and -key are", op)
+	}
+
+	operand, err := asm.next()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Skips when equal, and when different.
+	var equal, different uint16
+	if y, err := asm.register(operand); err == nil {
+		equal, different = 0x5000|x<<8|y<<4, 0x9000|x<<8|y<<4
+	} else {
+		nn, err := asm.value(operand, 0xFF)
+		if err != nil {
+			return 0, 0, err
+		}
+		equal, different = 0x3000|x<<8|nn, 0x4000|x<<8|nn
+	}
+
+	if op == "==" {
+		return different, equal, nil
+	}
+
+	return equal, different, nil
+
+}
+
+// registerToken parses the next token as a register.
+func (asm *octoAssembler) registerToken() (uint16, error) {
+
+	token, err := asm.next()
+	if err != nil {
+		return 0, err
+	}
+
+	return asm.register(token)
+
+}
+
+// register parses a register, v0 to vF in either case, or an alias of one.
+func (asm *octoAssembler) register(token string) (uint16, error) {
+
+	if x, ok := asm.aliases[token]; ok {
+		return x, nil
+	}
+
+	return register(token)
+
+}
+
+// operand evaluates the next token, see value.
+func (asm *octoAssembler) operand(max int) (uint16, error) {
+
+	token, err := asm.next()
+	if err != nil {
+		return 0, err
+	}
+
+	return asm.value(token, max)
+
+}
+
+// value evaluates a number, constant or label and checks it fits in max. A byte can also be
+// negative, down to -128.
+func (asm *octoAssembler) value(token string, max int) (uint16, error) {
+
+	n, err := asm.number(token)
+	if err != nil {
+		if addr, ok := asm.labels[token]; ok {
+			n, err = addr, nil
+		} else if !asm.final {
+			n, err = 0, nil
+		} else {
+			return 0, fmt.Errorf("undefined label %q", token)
+		}
+	}
+
+	if n > max || (max == 0xFF && n < -0x80) || (max != 0xFF && n < 0) {
+		return 0, fmt.Errorf("%s is out of range", token)
+	}
+
+	return uint16(n) & uint16(max), nil
+
+}
+
+// number evaluates a decimal, 0x hex or 0b binary number, or a constant.
+func (asm *octoAssembler) number(token string) (int, error) {
+
+	if n, ok := asm.consts[token]; ok {
+		return n, nil
+	}
+
+	digits, negative := strings.CutPrefix(token, "-")
+	base := 10
+	switch {
+	case strings.HasPrefix(digits, "0x"), strings.HasPrefix(digits, "0X"):
+		base, digits = 16, digits[2:]
+	case strings.HasPrefix(digits, "0b"), strings.HasPrefix(digits, "0B"):
+		base, digits = 2, digits[2:]
+	}
+
+	n, err := strconv.ParseInt(digits, base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", token)
+	}
+	if negative {
+		n = -n
+	}
+
+	return int(n), nil
+
+}
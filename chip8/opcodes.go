@@ -0,0 +1,810 @@
+package chip8
+
+import "fmt"
+
+// handler executes a decoded instruction and advances the program counter.
+type handler func(chip *Chip8, in Instruction) error
+
+// Handlers indexed by the first nibble of the opcode.
+var dispatch = [16]handler{
+	0x0: (*Chip8).op0NNN,
+	0x1: (*Chip8).op1NNN,
+	0x2: (*Chip8).op2NNN,
+	0x3: (*Chip8).op3XNN,
+	0x4: (*Chip8).op4XNN,
+	0x5: (*Chip8).op5XY0,
+	0x6: (*Chip8).op6XNN,
+	0x7: (*Chip8).op7XNN,
+	0x8: (*Chip8).op8XYN,
+	0x9: (*Chip8).op9XY0,
+	0xA: (*Chip8).opANNN,
+	0xB: (*Chip8).opBNNN,
+	0xC: (*Chip8).opCXNN,
+	0xD: (*Chip8).opDXYN,
+	0xE: (*Chip8).opEXNN,
+	0xF: (*Chip8).opFXNN,
+}
+
+// Handlers for the 8XY_ group, indexed by the last nibble.
+var dispatch_8 = [16]handler{
+	0x0: (*Chip8).op8XY0,
+	0x1: (*Chip8).op8XY1,
+	0x2: (*Chip8).op8XY2,
+	0x3: (*Chip8).op8XY3,
+	0x4: (*Chip8).op8XY4,
+	0x5: (*Chip8).op8XY5,
+	0x6: (*Chip8).op8XY6,
+	0x7: (*Chip8).op8XY7,
+	0xE: (*Chip8).op8XYE,
+}
+
+// Handlers for the EX__ group, indexed by the last byte.
+var dispatch_E = map[int]handler{
+	0x9E: (*Chip8).opEX9E,
+	0xA1: (*Chip8).opEXA1,
+}
+
+// Handlers for the FX__ group, indexed by the last byte.
+var dispatch_F = map[int]handler{
+	0x00: (*Chip8).opF000,
+	0x01: (*Chip8).opFN01,
+	0x02: (*Chip8).opF002,
+	0x07: (*Chip8).opFX07,
+	0x0A: (*Chip8).opFX0A,
+	0x15: (*Chip8).opFX15,
+	0x18: (*Chip8).opFX18,
+	0x1E: (*Chip8).opFX1E,
+	0x29: (*Chip8).opFX29,
+	0x30: (*Chip8).opFX30,
+	0x33: (*Chip8).opFX33,
+	0x3A: (*Chip8).opFX3A,
+	0x55: (*Chip8).opFX55,
+	0x65: (*Chip8).opFX65,
+	0x75: (*Chip8).opFX75,
+	0x85: (*Chip8).opFX85,
+}
+
+func (chip *Chip8) op0NNN(in Instruction) error {
+
+	switch in.Opcode & 0xFFF0 {
+	case 0x00B0:
+		return chip.op00BN(in)
+	case 0x00C0:
+		return chip.op00CN(in)
+	case 0x00D0:
+		return chip.op00DN(in)
+	}
+
+	switch in.Opcode & 0xFF00 {
+	case 0x0100:
+		return chip.op01NN(in)
+	case 0x0200:
+		return chip.op02NN(in)
+	case 0x0300:
+		return chip.op03NN(in)
+	case 0x0400:
+		return chip.op04NN(in)
+	case 0x0500:
+		return chip.op05NN(in)
+	case 0x0600:
+		return chip.op060N(in)
+	case 0x0800:
+		return chip.op080N(in)
+	case 0x0900:
+		return chip.op09NN(in)
+	}
+
+	switch in.Opcode {
+
+	case 0x0010:
+		return chip.op0010(in)
+
+	case 0x0011:
+		return chip.op0011(in)
+
+	case 0x0700:
+		return chip.op0700(in)
+
+	case 0x00E0:
+		return chip.op00E0(in)
+
+	case 0x00EE:
+		return chip.op00EE(in)
+
+	case 0x00FB:
+		return chip.op00FB(in)
+
+	case 0x00FC:
+		return chip.op00FC(in)
+
+	case 0x00FD:
+		return chip.op00FD(in)
+
+	case 0x00FE:
+		return chip.op00FE(in)
+
+	case 0x00FF:
+		return chip.op00FF(in)
+
+	// 0NNN (SYS) called machine code routines on the COSMAC VIP, it can't be emulated.
+	default:
+		return chip.unknownOpcode(in)
+	}
+
+}
+
+// 00E0 - Clear the display, only the selected planes with XO-CHIP. In Megachip mode, show the
+// back buffer and clear it.
+func (chip *Chip8) op00E0(in Instruction) error {
+
+	if chip.MegaMode() {
+		chip.showMega()
+	} else {
+		chip.clearPlanes(chip.planes)
+	}
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 00EE - Return from a subroutine.
+func (chip *Chip8) op00EE(in Instruction) error {
+
+	// Returning with an empty stack would index a slot that was never pushed.
+	if chip.stack_pointer == 0 {
+		return ErrStackUnderflow
+	}
+
+	chip.stack_pointer--
+	chip.program_counter = chip.stack[chip.stack_pointer]
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 1NNN - Jump to location NNN
+func (chip *Chip8) op1NNN(in Instruction) error {
+
+	if chip.detect_halt && uint16(in.NNN) == chip.program_counter {
+		return fmt.Errorf("%w at 0x%03X", ErrHaltLoop, chip.program_counter)
+	}
+
+	chip.program_counter = uint16(in.NNN)
+
+	return nil
+
+}
+
+// 2NNN - Call subroutine at NNN
+func (chip *Chip8) op2NNN(in Instruction) error {
+
+	// Calling with a full stack would write past its last slot.
+	if int(chip.stack_pointer) >= len(chip.stack) {
+		return ErrStackOverflow
+	}
+
+	// Push the address of this instruction, 00EE returns to the one after it.
+	chip.stack[chip.stack_pointer] = chip.program_counter
+	chip.stack_pointer++
+	chip.program_counter = uint16(in.NNN)
+
+	return nil
+
+}
+
+// skipIf advances the program counter past the next instruction if cond holds,
+// or to the next instruction otherwise.
+func (chip *Chip8) skipIf(cond bool) error {
+
+	if cond {
+		// XO-CHIP skips the whole 4-byte F000 NNNN instruction, Megachip 01NN NNNN.
+		next := chip.opcodeAt(chip.program_counter + 2)
+		if chip.xoChip() && next == 0xF000 || chip.megaChip() && next&0xFF00 == 0x0100 {
+			chip.program_counter += 2
+		}
+		chip.program_counter += 2
+	}
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 3XNN - Skip next instruction if V[X] == NN
+func (chip *Chip8) op3XNN(in Instruction) error {
+	return chip.skipIf(chip.registers[in.X] == byte(in.NN))
+}
+
+// 4XNN - Skip next instruction if V[X] != NN
+func (chip *Chip8) op4XNN(in Instruction) error {
+	return chip.skipIf(chip.registers[in.X] != byte(in.NN))
+}
+
+// 5XY0 - Skip next instruction if V[X] == V[Y]
+func (chip *Chip8) op5XY0(in Instruction) error {
+
+	switch in.N {
+	case 0:
+		return chip.skipIf(chip.registers[in.X] == chip.registers[in.Y])
+	case 2:
+		return chip.op5XY2(in)
+	case 3:
+		return chip.op5XY3(in)
+	}
+
+	return chip.unknownOpcode(in)
+
+}
+
+// 6XNN - Set V[X] = NN
+func (chip *Chip8) op6XNN(in Instruction) error {
+
+	chip.registers[in.X] = byte(in.NN)
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 7XNN - Set V[X] = V[X] + NN
+func (chip *Chip8) op7XNN(in Instruction) error {
+
+	// The addition wraps around on overflow (0xFF + 0x02 = 0x01).
+	// Unlike 8XY4, there is no carry: V[F] must be left untouched.
+	chip.registers[in.X] += byte(in.NN)
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 8XY_ - Arithmetic and logic between V[X] and V[Y]
+func (chip *Chip8) op8XYN(in Instruction) error {
+
+	h := dispatch_8[in.N]
+	if h == nil {
+		return chip.unknownOpcode(in)
+	}
+
+	if err := h(chip, in); err != nil {
+		return err
+	}
+
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// In the 8XY_ handlers, V[F] is always written after the result, so the flag wins when X is F.
+
+// 8XY0 - Set V[X] = V[Y]
+func (chip *Chip8) op8XY0(in Instruction) error {
+	chip.registers[in.X] = chip.registers[in.Y]
+	return nil
+}
+
+// 8XY1 - Set V[X] = V[X] OR V[Y]
+func (chip *Chip8) op8XY1(in Instruction) error {
+
+	if err := chip.requireQuirk("vf_reset", in); err != nil {
+		return err
+	}
+
+	chip.registers[in.X] |= chip.registers[in.Y]
+	chip.resetVF()
+
+	return nil
+
+}
+
+// 8XY2 - Set V[X] = V[X] AND V[Y]
+func (chip *Chip8) op8XY2(in Instruction) error {
+
+	if err := chip.requireQuirk("vf_reset", in); err != nil {
+		return err
+	}
+
+	chip.registers[in.X] &= chip.registers[in.Y]
+	chip.resetVF()
+
+	return nil
+
+}
+
+// 8XY3 - Set V[X] = V[X] XOR V[Y]
+func (chip *Chip8) op8XY3(in Instruction) error {
+
+	if err := chip.requireQuirk("vf_reset", in); err != nil {
+		return err
+	}
+
+	chip.registers[in.X] ^= chip.registers[in.Y]
+	chip.resetVF()
+
+	return nil
+
+}
+
+// resetVF clears V[F] after a logic instruction when the VF reset quirk is enabled.
+func (chip *Chip8) resetVF() {
+	if chip.vf_reset_quirk {
+		chip.registers[0xF] = 0
+	}
+}
+
+// 8XY4 - Set V[X] = V[X] + V[Y], set V[F] = carry
+func (chip *Chip8) op8XY4(in Instruction) error {
+
+	vx, vy := chip.registers[in.X], chip.registers[in.Y]
+
+	var flag byte
+	if int(vx)+int(vy) > 0xFF {
+		flag = 1
+	}
+
+	chip.registers[in.X] = vx + vy
+	chip.registers[15] = flag
+
+	return nil
+
+}
+
+// 8XY5 - Set V[X] = V[X] - V[Y], set V[F] = NOT borrow
+func (chip *Chip8) op8XY5(in Instruction) error {
+
+	vx, vy := chip.registers[in.X], chip.registers[in.Y]
+
+	var flag byte
+	if vx >= vy {
+		flag = 1
+	}
+
+	chip.registers[in.X] = vx - vy
+	chip.registers[15] = flag
+
+	return nil
+
+}
+
+// 8XY6 - Set V[X] = V[X] SHR 1, set V[F] = shifted out bit
+func (chip *Chip8) op8XY6(in Instruction) error {
+
+	if err := chip.requireQuirk("shift", in); err != nil {
+		return err
+	}
+
+	vx := chip.registers[in.X]
+	if chip.shift_quirk {
+		vx = chip.registers[in.Y]
+	}
+
+	chip.registers[in.X] = vx >> 1
+	chip.registers[15] = vx & 1
+
+	return nil
+
+}
+
+// 8XY7 - Set V[X] = V[Y] - V[X], set V[F] = NOT borrow
+func (chip *Chip8) op8XY7(in Instruction) error {
+
+	vx, vy := chip.registers[in.X], chip.registers[in.Y]
+
+	var flag byte
+	if vy >= vx {
+		flag = 1
+	}
+
+	chip.registers[in.X] = vy - vx
+	chip.registers[15] = flag
+
+	return nil
+
+}
+
+// 8XYE - Set V[X] = V[X] SHL 1, set V[F] = shifted out bit
+func (chip *Chip8) op8XYE(in Instruction) error {
+
+	if err := chip.requireQuirk("shift", in); err != nil {
+		return err
+	}
+
+	vx := chip.registers[in.X]
+	if chip.shift_quirk {
+		vx = chip.registers[in.Y]
+	}
+
+	chip.registers[in.X] = vx << 1
+	chip.registers[15] = vx >> 7
+
+	return nil
+
+}
+
+// 9XY0 - Skip next instruction if V[X] != V[Y]
+func (chip *Chip8) op9XY0(in Instruction) error {
+
+	if in.N != 0 {
+		return chip.unknownOpcode(in)
+	}
+
+	return chip.skipIf(chip.registers[in.X] != chip.registers[in.Y])
+
+}
+
+// ANNN - Set Index Register  I = NNN
+func (chip *Chip8) opANNN(in Instruction) error {
+
+	chip.index_register = uint32(in.NNN)
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// BNNN - Jump to location NNN + V[0]
+func (chip *Chip8) opBNNN(in Instruction) error {
+
+	if err := chip.requireQuirk("jump", in); err != nil {
+		return err
+	}
+
+	// With the jump quirk, this is BXNN: jump to XNN + V[X].
+	reg := 0
+	if chip.jump_quirk {
+		reg = in.X
+	}
+
+	chip.program_counter = uint16(in.NNN + int(chip.registers[reg]))
+
+	return nil
+
+}
+
+// CXNN - Set V[X] = random byte AND NN
+func (chip *Chip8) opCXNN(in Instruction) error {
+
+	chip.registers[in.X] = byte(chip.rng.Uint32()) & byte(in.NN)
+	chip.rng_draws++
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// DXYN - Display n-byte sprite starting at memory location I at (V[X], V[Y]), set V[F] = collision.
+func (chip *Chip8) opDXYN(in Instruction) (err error) {
+
+	if err := chip.requireQuirk("wrap", in); err != nil {
+		return err
+	}
+	if err := chip.requireQuirk("vertical_wrap", in); err != nil {
+		return err
+	}
+	if err := chip.requireQuirk("display_wait", in); err != nil {
+		return err
+	}
+
+	// With the display wait quirk, the program counter is not advanced until the next frame
+	// begins, so this same instruction runs again on the following cycles, like FX0A.
+	if chip.display_wait {
+		if !chip.vblank {
+			return nil
+		}
+		chip.vblank = false
+	}
+
+	if chip.OnDraw != nil {
+		x, y := int(chip.registers[in.X]), int(chip.registers[in.Y])
+		defer func() {
+			if err == nil {
+				chip.OnDraw(x, y, chip.registers[15] != 0)
+			}
+		}()
+	}
+
+	if chip.MegaMode() {
+		return chip.drawMega(in)
+	}
+
+	//get X and Y coordinates from the registers
+	x := int(chip.registers[in.X])
+	y := int(chip.registers[in.Y])
+
+	//Get the number of bytes
+	n_bytes := in.N
+
+	// The sprite is 8 pixels wide, N rows tall. With SUPER-CHIP, DXY0 draws a 16x16 sprite
+	// made of 2 bytes per row.
+	sprite_width, rows := 8, n_bytes
+	if n_bytes == 0 && chip.platform >= PlatformSuperChip {
+		sprite_width, rows = 16, 16
+		n_bytes = 32
+	}
+
+	// With XO-CHIP, the sprite is drawn on each selected plane in turn,
+	// the data for the next plane following the previous one in memory.
+	planes := make([]int, 0, 2)
+	for p := range chip.display {
+		if chip.planes&(1<<p) != 0 {
+			planes = append(planes, p)
+		}
+	}
+
+	// The whole sprite must be within memory.
+	if err := chip.checkMemory(int(chip.index_register), n_bytes*len(planes), false); err != nil {
+		return fmt.Errorf("%w: sprite at I 0x%03X", err, chip.index_register)
+	}
+
+	width, height := chip.Resolution()
+
+	// The starting position of the sprite will wrap around the screen.
+
+	x = x % width
+	y = y % height
+
+	//V[F] should be set to zero.
+	chip.registers[15] = 0
+
+	bytes_per_row := sprite_width / 8
+
+	for p, plane := range planes {
+
+		sprite := int(chip.index_register) + p*n_bytes
+
+		for i := range rows {
+
+			// Get the row of the sprite, counting from memory address the Index Register,
+			// as a bit pattern with the leftmost pixel in the highest bit.
+			var sprite_row uint64
+			for b := range bytes_per_row {
+				sprite_row = sprite_row<<8 | uint64(chip.peek(sprite+i*bytes_per_row+b))
+			}
+
+			row := y + i
+
+			// Rows past the bottom edge are clipped, unless they wrap around to the top
+			// with the wrap quirk or the vertical wrap.
+			if row >= height {
+				if !chip.wrap_quirk && !chip.vertical_wrap {
+					break
+				}
+				row -= height
+			}
+
+			// Pixels past the right edge are clipped, unless they wrap around to the left with the wrap quirk.
+			line := spriteRow(sprite_row, sprite_width, x, width, chip.wrap_quirk)
+			if line == [plane_words]uint64{} {
+				continue
+			}
+
+			target := &chip.display[plane][row]
+
+			// A pixel of the sprite that is on where the display is on too gets turned off:
+			// set V[F] = 1
+			for w := range target {
+				if target[w]&line[w] != 0 {
+					chip.registers[15] = 1
+				}
+			}
+
+			// The sprite is XORed onto the screen, off bits leave the pixels unchanged.
+			for w := range target {
+				target[w] ^= line[w]
+			}
+			chip.display_dirty = true
+
+		}
+	}
+
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// EX__ - Keypad skips
+func (chip *Chip8) opEXNN(in Instruction) error {
+
+	h := dispatch_E[in.NN]
+	if h == nil {
+		return chip.unknownOpcode(in)
+	}
+
+	return h(chip, in)
+
+}
+
+// EX9E - Skip next instruction if the key with the value of V[X] is pressed.
+func (chip *Chip8) opEX9E(in Instruction) error {
+	return chip.skipIf(chip.keyPressed(chip.registers[in.X] & 0xF))
+}
+
+// EXA1 - Skip next instruction if the key with the value of V[X] is not pressed.
+func (chip *Chip8) opEXA1(in Instruction) error {
+	return chip.skipIf(!chip.keyPressed(chip.registers[in.X] & 0xF))
+}
+
+// FX__ - Timers, keypad wait, index register and memory
+func (chip *Chip8) opFXNN(in Instruction) error {
+
+	h := dispatch_F[in.NN]
+	if h == nil {
+		return chip.unknownOpcode(in)
+	}
+
+	return h(chip, in)
+
+}
+
+// FX07 - Set V[X] = delay timer
+func (chip *Chip8) opFX07(in Instruction) error {
+
+	chip.registers[in.X] = chip.delay_timer
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// FX0A - Wait for a key press and release, store the value of the key in V[X]
+func (chip *Chip8) opFX0A(in Instruction) error {
+
+	// Execution stops until a key is pressed and released: the program counter is not
+	// advanced, so this same instruction runs again on the next cycle. Completing on the
+	// release, like the COSMAC VIP, keeps a held key from satisfying several waits in a row.
+	// The wait is machine state, see RunState, so save states and the debugger see it.
+	if chip.key_waiting {
+		if !chip.keyPressed(chip.key_wait) {
+			chip.registers[in.X] = chip.key_wait
+			chip.key_waiting = false
+			chip.program_counter += 2
+		}
+		return nil
+	}
+
+	for k := range chip.keypad {
+		if chip.keyPressed(byte(k)) {
+			chip.key_wait = byte(k)
+			chip.key_waiting = true
+			chip.awaiting_key = false
+			return nil
+		}
+	}
+
+	if !chip.awaiting_key && chip.OnKeyWait != nil {
+		chip.awaiting_key = true
+		chip.OnKeyWait()
+	}
+	chip.awaiting_key = true
+
+	return nil
+
+}
+
+// FX15 - Set delay timer = V[X]
+func (chip *Chip8) opFX15(in Instruction) error {
+
+	chip.delay_timer = chip.registers[in.X]
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// FX18 - Set sound timer = V[X]
+func (chip *Chip8) opFX18(in Instruction) error {
+
+	chip.sound_timer = chip.registers[in.X]
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// FX1E - Set I = I + V[X]
+func (chip *Chip8) opFX1E(in Instruction) error {
+
+	if err := chip.requireQuirk("index_overflow", in); err != nil {
+		return err
+	}
+
+	chip.addIndex(int(chip.registers[in.X]))
+
+	// Some interpreters (e.g. the Amiga one) set V[F] when I overflows past the addressable range.
+	if chip.index_overflow_quirk {
+		if chip.index_register > 0x0FFF {
+			chip.registers[15] = 1
+		} else {
+			chip.registers[15] = 0
+		}
+	}
+
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// FX29 - Set I = location of the sprite for digit V[X]
+func (chip *Chip8) opFX29(in Instruction) error {
+
+	// The fontset is loaded at address 0, with 5 bytes per digit.
+	chip.index_register = uint32(chip.registers[in.X]&0xF) * 5
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// FX33 - Store the BCD representation of V[X] in memory locations I, I+1 and I+2
+func (chip *Chip8) opFX33(in Instruction) error {
+
+	i := int(chip.index_register)
+
+	if err := chip.checkMemory(i, 3, true); err != nil {
+		return err
+	}
+
+	value := chip.registers[in.X]
+
+	chip.poke(i, value/100)
+	chip.poke(i+1, (value/10)%10)
+	chip.poke(i+2, value%10)
+
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// FX55 - Store registers V[0] through V[X] in memory starting at location I
+func (chip *Chip8) opFX55(in Instruction) error {
+
+	if err := chip.requireQuirk("index_increment", in); err != nil {
+		return err
+	}
+	if err := chip.checkMemory(int(chip.index_register), in.X+1, true); err != nil {
+		return err
+	}
+
+	for i := 0; i <= in.X; i++ {
+		chip.poke(int(chip.index_register)+i, chip.registers[i])
+	}
+
+	if chip.index_increment_quirk {
+		chip.addIndex(in.X + 1)
+	}
+
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// FX65 - Read registers V[0] through V[X] from memory starting at location I
+func (chip *Chip8) opFX65(in Instruction) error {
+
+	if err := chip.requireQuirk("index_increment", in); err != nil {
+		return err
+	}
+	if err := chip.checkMemory(int(chip.index_register), in.X+1, false); err != nil {
+		return err
+	}
+
+	for i := 0; i <= in.X; i++ {
+		chip.registers[i] = chip.peek(int(chip.index_register) + i)
+	}
+
+	if chip.index_increment_quirk {
+		chip.addIndex(in.X + 1)
+	}
+
+	chip.program_counter += 2
+
+	return nil
+
+}
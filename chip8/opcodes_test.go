@@ -0,0 +1,169 @@
+package chip8
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAddImmediateKeepsVF(t *testing.T) {
+
+	for _, vf := range []byte{0x00, 0x01, 0xAB} {
+
+		chip := New()
+		// V0 = 0xFF, VF = vf, V0 += 0x02
+		if err := chip.LoadROMBytes([]byte{0x60, 0xFF, 0x6F, vf, 0x70, 0x02}); err != nil {
+			t.Fatal(err)
+		}
+		for range 3 {
+			if err := chip.Cycle(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if chip.registers[0] != 0x01 {
+			t.Errorf("VF %02X: V0 = %02X, want 01", vf, chip.registers[0])
+		}
+		if chip.registers[0xF] != vf {
+			t.Errorf("VF %02X: VF = %02X after a wrapping 7XNN", vf, chip.registers[0xF])
+		}
+	}
+
+}
+
+func TestAddImmediateToVF(t *testing.T) {
+
+	chip := New()
+	// VF = 0xFF, VF += 0x02
+	if err := chip.LoadROMBytes([]byte{0x6F, 0xFF, 0x7F, 0x02}); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := chip.Cycle(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 7FNN is a plain add to VF, no carry is written over the sum.
+	if chip.registers[0xF] != 0x01 {
+		t.Errorf("VF = %02X, want 01", chip.registers[0xF])
+	}
+
+}
+
+// octoDraw draws a sprite as Octo does with its clip quirk off, the rule of its emulator.js:
+// every pixel of the sprite lands at ((x+b) % width, (y+a) % height) and VF is set when one
+// turns a pixel off.
+func octoDraw(display *[DisplayHeight][DisplayWidth]bool, x, y int, sprite []byte) (vf byte) {
+
+	for a, row := range sprite {
+		for b := range 8 {
+			if row>>(7-b)&1 == 0 {
+				continue
+			}
+			px, py := (x+b)%DisplayWidth, (y+a)%DisplayHeight
+			if display[py][px] {
+				vf = 1
+			}
+			display[py][px] = !display[py][px]
+		}
+	}
+
+	return vf
+
+}
+
+func TestWrapMatchesOcto(t *testing.T) {
+
+	// Two sprites crossing the bottom edge, the second one onto the rows the first wrapped to,
+	// then one crossing both the bottom and the right edges into the corners.
+	sprite := []byte{0xF0, 0x90, 0x90, 0xF0}
+	draws := [][2]int{{10, 30}, {12, 31}, {62, 30}}
+
+	chip := New()
+	chip.SetWrapQuirk(true)
+
+	// I = 0x300, then for each sprite V0 = x, V1 = y and a draw
+	rom := []byte{0xA3, 0x00}
+	for _, at := range draws {
+		rom = append(rom, 0x60, byte(at[0]), 0x61, byte(at[1]), 0xD0, 0x10|byte(len(sprite)))
+	}
+	if err := chip.LoadROMBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	copy(chip.mem()[0x300:], sprite)
+	if err := chip.Cycle(); err != nil {
+		t.Fatal(err)
+	}
+
+	var octo [DisplayHeight][DisplayWidth]bool
+
+	for _, at := range draws {
+
+		for range 3 {
+			if err := chip.Cycle(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if want := octoDraw(&octo, at[0], at[1], sprite); chip.registers[0xF] != want {
+			t.Errorf("sprite at (%d, %d): VF = %d, Octo sets %d", at[0], at[1], chip.registers[0xF], want)
+		}
+	}
+
+	frame := chip.Display()
+	for y := range DisplayHeight {
+		for x := range DisplayWidth {
+			if on := frame.Pixel(x, y) != 0; on != octo[y][x] {
+				t.Fatalf("pixel (%d, %d) is %v, Octo draws %v:\n%s", x, y, on, octo[y][x], frame.Text())
+			}
+		}
+	}
+
+}
+
+func TestReturnWithEmptyStack(t *testing.T) {
+
+	chip := New()
+	// V0 = 1, RET
+	if err := chip.LoadROMBytes([]byte{0x60, 0x01, 0x00, 0xEE}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chip.Cycle(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chip.Cycle(); !errors.Is(err, ErrStackUnderflow) {
+		t.Fatalf("00EE with an empty stack: got %v, want ErrStackUnderflow", err)
+	}
+	if chip.program_counter != 0x202 || chip.stack_pointer != 0 {
+		t.Errorf("PC %03X, SP %d after the failed return, want 202 and 0", chip.program_counter, chip.stack_pointer)
+	}
+
+}
+
+func TestMachineCodeCallKeepsDisplay(t *testing.T) {
+
+	chip := New()
+	// Draw the digit 0, then SYS 0x123
+	if err := chip.LoadROMBytes([]byte{0xD0, 0x05, 0x01, 0x23}); err != nil {
+		t.Fatal(err)
+	}
+
+	var skipped []uint16
+	chip.OnUnknownOpcode = func(opcode, pc uint16) { skipped = append(skipped, opcode) }
+
+	for range 2 {
+		if err := chip.Cycle(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only 00E0 clears the display, any other 0NNN is skipped.
+	if !chip.Pixel(0, 0) {
+		t.Error("0123 cleared the display")
+	}
+	if chip.program_counter != 0x204 || len(skipped) != 1 || skipped[0] != 0x0123 {
+		t.Errorf("PC %03X, skipped %04X, want 204 with 0123 skipped", chip.program_counter, skipped)
+	}
+
+}
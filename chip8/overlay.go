@@ -0,0 +1,236 @@
+package chip8
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"time"
+
+	"chip8-go/chip8/decode"
+)
+
+// Overlay is the debug overlay of the window frontends: it measures the frame rate and the
+// instructions per second of a running machine, and draws them with the registers, the timers
+// and the last instruction on top of the display, in a small bitmap font:
+//
+//	60 FPS  700 IPS
+//	PC 0206  I 022A  SP 0
+//	V0-7 0C 08 00 00 00 00 00 00
+//	V8-F 00 00 00 00 00 00 00 01
+//	DT 00  ST 00
+//	0204 D01F DRW V0, V1, 0xF
+//
+// An overlay is not safe for concurrent use, Update and Draw have to be serialized.
+type Overlay struct {
+	visible bool
+
+	// Frames and instructions counted since start, the rates measured over the last second
+	start  time.Time
+	frames int
+	cycles uint64
+	fps    float64
+	ips    float64
+
+	// Text shown, built by Update while visible
+	lines []string
+}
+
+// How often the rates of an overlay are measured.
+const overlay_rate_period = time.Second
+
+// Size of a glyph of the overlay font, and of the cell it is drawn in with its spacing.
+const (
+	overlay_glyph_width  = 3
+	overlay_glyph_height = 5
+	overlay_cell_width   = overlay_glyph_width + 1
+	overlay_cell_height  = overlay_glyph_height + 1
+)
+
+// Width of the display, in window pixels, per step of the text scale.
+const overlay_scale_width = 320
+
+// The overlay font: 3x5 glyphs, a row per 3 bits from the top one in the high bits. Lowercase
+// letters but x are drawn uppercase, other characters as ?.
+var overlay_font = map[rune]uint16{
+	'0': 0b111_101_101_101_111, '1': 0b010_110_010_010_111, '2': 0b111_001_111_100_111,
+	'3': 0b111_001_111_001_111, '4': 0b101_101_111_001_001, '5': 0b111_100_111_001_111,
+	'6': 0b111_100_111_101_111, '7': 0b111_001_001_010_010, '8': 0b111_101_111_101_111,
+	'9': 0b111_101_111_001_111, 'A': 0b010_101_111_101_101, 'B': 0b110_101_110_101_110,
+	'C': 0b011_100_100_100_011, 'D': 0b110_101_101_101_110, 'E': 0b111_100_110_100_111,
+	'F': 0b111_100_110_100_100, 'G': 0b011_100_101_101_011, 'H': 0b101_101_111_101_101,
+	'I': 0b111_010_010_010_111, 'J': 0b001_001_001_101_010, 'K': 0b101_101_110_101_101,
+	'L': 0b100_100_100_100_111, 'M': 0b101_111_111_101_101, 'N': 0b110_101_101_101_101,
+	'O': 0b010_101_101_101_010, 'P': 0b110_101_110_100_100, 'Q': 0b010_101_101_110_011,
+	'R': 0b110_101_110_101_101, 'S': 0b011_100_010_001_110, 'T': 0b111_010_010_010_010,
+	'U': 0b101_101_101_101_111, 'V': 0b101_101_101_101_010, 'W': 0b101_101_111_111_101,
+	'X': 0b101_101_010_101_101, 'Y': 0b101_101_010_010_010, 'Z': 0b111_001_010_100_111,
+	'x': 0b000_101_010_101_000, ' ': 0, ',': 0b000_000_000_010_100, '.': 0b000_000_000_000_010,
+	':': 0b000_010_000_010_000, '=': 0b000_111_000_111_000, '-': 0b000_000_111_000_000,
+	'+': 0b000_010_111_010_000, '[': 0b110_100_100_100_110, ']': 0b011_001_001_001_011,
+	'(': 0b010_100_100_100_010, ')': 0b010_001_001_001_010, '/': 0b001_001_010_100_100,
+	'#': 0b101_111_101_111_101, '<': 0b001_010_100_010_001, '>': 0b100_010_001_010_100,
+	'?': 0b111_001_010_000_010,
+}
+
+// Colors of the overlay text, and of the box darkening the display behind it.
+var (
+	overlay_text       = color.RGBA{0xFF, 0xFF, 0x55, 0xFF}
+	overlay_background = color.RGBA{0x00, 0x00, 0x00, 0xB0}
+)
+
+// NewOverlay returns a hidden overlay.
+func NewOverlay() *Overlay {
+	return &Overlay{}
+}
+
+// Toggle shows or hides the overlay and returns a message for the user.
+func (o *Overlay) Toggle() string {
+
+	o.visible = !o.visible
+	o.lines = nil
+
+	if o.visible {
+		return "debug overlay on"
+	}
+	return "debug overlay off"
+
+}
+
+// Visible reports whether the overlay is shown.
+func (o *Overlay) Visible() bool {
+	return o.visible
+}
+
+// Update is called once per frame on the goroutine running chip, e.g. from PollKeys: it
+// counts the frame and takes the state shown from chip.
+func (o *Overlay) Update(chip *Chip8) {
+
+	now := chip.timeSource().Now()
+
+	// A reset starts counting the instructions again.
+	if o.start.IsZero() || chip.cycle_count < o.cycles {
+		o.start, o.frames, o.cycles = now, 0, chip.cycle_count
+	}
+
+	o.frames++
+	if elapsed := now.Sub(o.start); elapsed >= overlay_rate_period {
+		o.fps = float64(o.frames) / elapsed.Seconds()
+		o.ips = float64(chip.cycle_count-o.cycles) / elapsed.Seconds()
+		o.start, o.frames, o.cycles = now, 0, chip.cycle_count
+	}
+
+	if !o.visible {
+		return
+	}
+
+	delay, sound := chip.Timers()
+	v := chip.registers
+
+	o.lines = []string{
+		fmt.Sprintf("%.0f FPS  %.0f IPS", o.fps, o.ips),
+		fmt.Sprintf("PC %04X  I %04X  SP %X", chip.program_counter, chip.index_register, chip.stack_pointer),
+		fmt.Sprintf("V0-7 % X", v[:8]),
+		fmt.Sprintf("V8-F % X", v[8:]),
+		fmt.Sprintf("DT %02X  ST %02X", delay, sound),
+	}
+	if pc, opcode, ok := chip.lastInstruction(); ok {
+		o.lines = append(o.lines, fmt.Sprintf("%04X %04X %s", pc, opcode, decode.Decode(opcode)))
+	}
+
+}
+
+// lastInstruction returns the last instruction executed when tracing, the next one otherwise.
+func (chip *Chip8) lastInstruction() (pc, opcode uint16, ok bool) {
+
+	if chip.trace != nil && (chip.trace_next > 0 || chip.trace_full) {
+		e := chip.trace[(chip.trace_next+len(chip.trace)-1)%len(chip.trace)]
+		return e.PC, e.Opcode, true
+	}
+
+	memory := chip.mem()
+	pc = chip.program_counter
+	if int(pc)+1 >= len(memory) {
+		return 0, 0, false
+	}
+
+	return pc, uint16(memory[pc])<<8 | uint16(memory[pc+1]), true
+
+}
+
+// Draw writes the text of a visible overlay over the top left corner of the display drawn in
+// dst at vp, in a size following the size of the display.
+func (o *Overlay) Draw(dst *image.RGBA, vp Viewport) {
+
+	if !o.visible || len(o.lines) == 0 {
+		return
+	}
+
+	scale := max(vp.Width/overlay_scale_width, 1)
+	origin := dst.Bounds().Min.Add(image.Pt(vp.X, vp.Y))
+
+	columns := 0
+	for _, line := range o.lines {
+		columns = max(columns, len(line))
+	}
+
+	// The box leaves a cell of margin around the text.
+	box := image.Rect(0, 0, (columns+1)*overlay_cell_width*scale, (len(o.lines)+1)*overlay_cell_height*scale)
+	box = box.Add(origin).Intersect(dst.Bounds())
+
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		for x := box.Min.X; x < box.Max.X; x++ {
+			dst.SetRGBA(x, y, blendRGBA(dst.RGBAAt(x, y), overlay_background))
+		}
+	}
+
+	margin := image.Pt(overlay_cell_width*scale/2, overlay_cell_height*scale/2)
+
+	for row, line := range o.lines {
+		for column, r := range line {
+			at := origin.Add(margin).Add(image.Pt(column*overlay_cell_width*scale, row*overlay_cell_height*scale))
+			drawGlyph(dst, at, r, scale)
+		}
+	}
+
+}
+
+// drawGlyph draws r of the overlay font with its top left corner at at.
+func drawGlyph(dst *image.RGBA, at image.Point, r rune, scale int) {
+
+	glyph, ok := overlay_font[r]
+	if !ok {
+		if glyph, ok = overlay_font[[]rune(strings.ToUpper(string(r)))[0]]; !ok {
+			glyph = overlay_font['?']
+		}
+	}
+
+	for gy := 0; gy < overlay_glyph_height; gy++ {
+		for gx := 0; gx < overlay_glyph_width; gx++ {
+
+			bit := (overlay_glyph_height-1-gy)*overlay_glyph_width + overlay_glyph_width - 1 - gx
+			if glyph>>bit&1 == 0 {
+				continue
+			}
+
+			pixel := image.Rect(0, 0, scale, scale).Add(at.Add(image.Pt(gx*scale, gy*scale))).Intersect(dst.Bounds())
+			for y := pixel.Min.Y; y < pixel.Max.Y; y++ {
+				for x := pixel.Min.X; x < pixel.Max.X; x++ {
+					dst.SetRGBA(x, y, overlay_text)
+				}
+			}
+		}
+	}
+
+}
+
+// blendRGBA draws the translucent color c over the opaque color under.
+func blendRGBA(under, c color.RGBA) color.RGBA {
+
+	mix := func(a, b uint8) uint8 {
+		return uint8((int(a)*(255-int(c.A)) + int(b)*int(c.A)) / 255)
+	}
+
+	return color.RGBA{mix(under.R, c.R), mix(under.G, c.G), mix(under.B, c.B), 0xFF}
+
+}
@@ -0,0 +1,76 @@
+package chip8
+
+import "chip8-go/chip8/decode"
+
+// decodedOp is an entry of the decode cache: an instruction and the handler that executes it.
+type decodedOp struct {
+	in      Instruction
+	handler handler
+	valid   bool
+}
+
+// resolve returns the handler of an instruction, looking it up in the table of its group when
+// the group handler would do nothing else.
+func resolve(in Instruction) handler {
+
+	switch in.Op {
+	case 0xE:
+		if h := dispatch_E[in.NN]; h != nil {
+			return h
+		}
+	case 0xF:
+		if h := dispatch_F[in.NN]; h != nil {
+			return h
+		}
+	}
+
+	return dispatch[in.Op]
+
+}
+
+// decodeAt returns the instruction at addr, which must be within memory, decoding it on first
+// use. The cache covers the addresses the 16-bit program counter reaches and is dropped when
+// the memory size changes, see SetPlatform.
+func (chip *Chip8) decodeAt(addr int) decodedOp {
+
+	size := min(chip.memorySize(), xo_memory_size)
+
+	if len(chip.decoded) != size {
+		chip.decoded = make([]decodedOp, size)
+	}
+
+	op := &chip.decoded[addr]
+
+	if !op.valid {
+		memory := chip.mem()
+		in := decode.Decode(uint16(memory[addr])<<8 | uint16(memory[(addr+1)%chip.memorySize()]))
+		*op = decodedOp{in: in, handler: resolve(in), valid: true}
+	}
+
+	return *op
+
+}
+
+// invalidateDecoded drops the cached instructions overlapping the n bytes written from addr:
+// those starting there and the one starting on the byte before.
+func (chip *Chip8) invalidateDecoded(addr, n int) {
+
+	if len(chip.decoded) == 0 {
+		return
+	}
+
+	for a := max(addr-1, 0); a < addr+n && a < len(chip.decoded); a++ {
+		chip.decoded[a].valid = false
+	}
+
+	// A write to the first byte changes the instruction at the end of memory when it wraps.
+	if addr == 0 {
+		chip.decoded[len(chip.decoded)-1].valid = false
+	}
+
+}
+
+// invalidateAllDecoded drops the whole decode cache, e.g. when a ROM is loaded.
+func (chip *Chip8) invalidateAllDecoded() {
+	clear(chip.decoded)
+}
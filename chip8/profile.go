@@ -0,0 +1,142 @@
+package chip8
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"chip8-go/chip8/decode"
+)
+
+// profile counts the instructions executed per address and per opcode.
+type profile struct {
+	addresses [xo_memory_size]uint64
+	opcodes   [1 << 16]uint64
+	total     uint64
+}
+
+// SetProfiling starts counting the instructions executed per address and per opcode, from
+// zero, or stops it. Profile reports the counts. Profiling is off by default.
+func (chip *Chip8) SetProfiling(enabled bool) {
+
+	chip.profile = nil
+	if enabled {
+		chip.profile = &profile{}
+	}
+
+}
+
+// Profile is a report of the instructions executed since profiling started, see SetProfiling.
+type Profile struct {
+	Instructions uint64 `json:"instructions"`
+
+	// Addresses executed, the hottest first.
+	Addresses []ProfileAddress `json:"addresses"`
+
+	// Instructions executed per opcode class, such as 8XY4, the most frequent first.
+	Opcodes []ProfileOpcode `json:"opcodes"`
+}
+
+// ProfileAddress is the number of times the instruction at an address was executed. The
+// instruction is the one in memory when the report was made.
+type ProfileAddress struct {
+	Addr        uint16 `json:"addr"`
+	Count       uint64 `json:"count"`
+	Instruction string `json:"instruction"`
+}
+
+// ProfileOpcode is the number of instructions executed of an opcode class, "invalid" for the
+// opcodes that are not instructions, skipped with OnUnknownOpcode.
+type ProfileOpcode struct {
+	Opcode string `json:"opcode"`
+	Count  uint64 `json:"count"`
+}
+
+// Profile returns the counts of the instructions executed since SetProfiling, nil if
+// profiling is off.
+func (chip *Chip8) Profile() *Profile {
+
+	p := chip.profile
+	if p == nil {
+		return nil
+	}
+
+	report := &Profile{Instructions: p.total, Addresses: []ProfileAddress{}, Opcodes: []ProfileOpcode{}}
+
+	for addr, count := range p.addresses {
+		if count > 0 {
+			report.Addresses = append(report.Addresses, ProfileAddress{
+				Addr:        uint16(addr),
+				Count:       count,
+				Instruction: decode.Decode(chip.opcodeAt(uint16(addr))).String(),
+			})
+		}
+	}
+
+	classes := map[string]uint64{}
+	for opcode, count := range p.opcodes {
+		if count > 0 {
+			classes[opcodeClass(uint16(opcode))] += count
+		}
+	}
+	for class, count := range classes {
+		report.Opcodes = append(report.Opcodes, ProfileOpcode{Opcode: class, Count: count})
+	}
+
+	// Ties are listed by address and opcode, keeping reports of a run comparable.
+	slices.SortStableFunc(report.Addresses, func(a, b ProfileAddress) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	slices.SortFunc(report.Opcodes, func(a, b ProfileOpcode) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Opcode, b.Opcode))
+	})
+
+	return report
+
+}
+
+// opcodeClass returns the instruction pattern an opcode matches, such as 8XY4.
+func opcodeClass(opcode uint16) string {
+
+	for _, pattern := range instruction_patterns {
+		if matchPattern(pattern, opcode) {
+			return pattern
+		}
+	}
+
+	return "invalid"
+
+}
+
+// WriteText writes the report as text: the top hottest addresses, all of them if top is 0,
+// then the instruction mix.
+func (p *Profile) WriteText(w io.Writer, top int) error {
+
+	percent := func(count uint64) float64 {
+		return float64(count) * 100 / float64(max(p.Instructions, 1))
+	}
+
+	addresses := p.Addresses
+	if top > 0 && len(addresses) > top {
+		addresses = addresses[:top]
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%d instructions executed at %d addresses\n\nHot addresses:\n", p.Instructions, len(p.Addresses))
+	for _, a := range addresses {
+		fmt.Fprintf(&b, "  %04X  %12d  %5.1f%%  %s\n", a.Addr, a.Count, percent(a.Count), a.Instruction)
+	}
+
+	b.WriteString("\nInstruction mix:\n")
+	for _, o := range p.Opcodes {
+		fmt.Fprintf(&b, "  %-7s  %12d  %5.1f%%\n", o.Opcode, o.Count, percent(o.Count))
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+
+}
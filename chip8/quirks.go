@@ -0,0 +1,112 @@
+package chip8
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Quirks bundles the behavior toggles that differ between CHIP-8 interpreters.
+type Quirks struct {
+
+	// Shift - 8XY6/8XYE shift V[Y] into V[X] instead of shifting V[X] in place
+	Shift bool
+
+	// IndexIncrement - FX55/FX65 increment I by X+1
+	IndexIncrement bool
+
+	// IndexOverflow - FX1E sets V[F] when I goes past 0x0FFF
+	IndexOverflow bool
+
+	// VFReset - 8XY1/8XY2/8XY3 reset V[F] to 0
+	VFReset bool
+
+	// Jump - BNNN is read as BXNN, jumping to XNN + V[X]
+	Jump bool
+
+	// Wrap - DXYN pixels past the edges wrap around instead of being clipped
+	Wrap bool
+
+	// VerticalWrap - DXYN rows past the bottom edge wrap to the top, columns are still clipped
+	VerticalWrap bool
+
+	// DisplayWait - DXYN waits for the start of the next 60Hz frame before drawing
+	DisplayWait bool
+}
+
+// QuirksCOSMAC returns the behavior of the original COSMAC VIP interpreter.
+func QuirksCOSMAC() Quirks {
+	return Quirks{
+		Shift:          true,
+		IndexIncrement: true,
+		VFReset:        true,
+		DisplayWait:    true,
+	}
+}
+
+// QuirksSuperChip returns the behavior of CHIP-48 and SUPER-CHIP 1.1.
+func QuirksSuperChip() Quirks {
+	return Quirks{
+		Jump: true,
+	}
+}
+
+// QuirksModern returns the defaults of New: shifts in place as most modern ROMs expect,
+// while keeping the original I increment, BNNN jump and edge clipping.
+func QuirksModern() Quirks {
+	return Quirks{
+		IndexIncrement: true,
+	}
+}
+
+// QuirksPreset returns the quirks of a preset by name: "vip" (or "cosmac"), "schip"
+// (or "superchip") or "modern".
+func QuirksPreset(name string) (Quirks, error) {
+
+	switch strings.ToLower(name) {
+	case "vip", "cosmac":
+		return QuirksCOSMAC(), nil
+	case "schip", "superchip":
+		return QuirksSuperChip(), nil
+	case "modern":
+		return QuirksModern(), nil
+	}
+
+	return Quirks{}, fmt.Errorf("unknown quirks preset %q, want vip, schip or modern", name)
+
+}
+
+// NewWithQuirks returns a new machine using the given quirks.
+func NewWithQuirks(q Quirks) *Chip8 {
+
+	chip := New()
+	chip.SetQuirks(q)
+
+	return chip
+
+}
+
+// SetQuirks sets every quirk at once. They all count as configured for strict mode.
+func (chip *Chip8) SetQuirks(q Quirks) {
+	chip.SetShiftQuirk(q.Shift)
+	chip.SetIndexIncrementQuirk(q.IndexIncrement)
+	chip.SetIndexOverflowQuirk(q.IndexOverflow)
+	chip.SetVFResetQuirk(q.VFReset)
+	chip.SetJumpQuirk(q.Jump)
+	chip.SetWrapQuirk(q.Wrap)
+	chip.SetVerticalWrap(q.VerticalWrap)
+	chip.SetDisplayWaitQuirk(q.DisplayWait)
+}
+
+// Quirks returns the quirks currently in use.
+func (chip *Chip8) Quirks() Quirks {
+	return Quirks{
+		Shift:          chip.shift_quirk,
+		IndexIncrement: chip.index_increment_quirk,
+		IndexOverflow:  chip.index_overflow_quirk,
+		VFReset:        chip.vf_reset_quirk,
+		Jump:           chip.jump_quirk,
+		Wrap:           chip.wrap_quirk,
+		VerticalWrap:   chip.vertical_wrap,
+		DisplayWait:    chip.display_wait,
+	}
+}
@@ -0,0 +1,42 @@
+package chip8
+
+import "testing"
+
+func TestQuirksPresets(t *testing.T) {
+
+	tests := []struct {
+		names []string
+		want  Quirks
+	}{
+		{[]string{"vip", "cosmac", "VIP"}, Quirks{Shift: true, IndexIncrement: true, VFReset: true, DisplayWait: true}},
+		{[]string{"schip", "superchip"}, Quirks{Jump: true}},
+		{[]string{"modern"}, Quirks{IndexIncrement: true}},
+	}
+
+	for _, test := range tests {
+		for _, name := range test.names {
+
+			q, err := QuirksPreset(name)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if q != test.want {
+				t.Errorf("%s: %+v, want %+v", name, q, test.want)
+			}
+
+			// A machine made with the preset uses every flag of it.
+			if got := NewWithQuirks(q).Quirks(); got != test.want {
+				t.Errorf("%s: machine uses %+v, want %+v", name, got, test.want)
+			}
+		}
+	}
+
+	if QuirksModern() != New().Quirks() {
+		t.Errorf("QuirksModern %+v differs from the defaults of New %+v", QuirksModern(), New().Quirks())
+	}
+
+	if _, err := QuirksPreset("octo"); err == nil {
+		t.Error("unknown preset accepted")
+	}
+
+}
@@ -0,0 +1,104 @@
+package chip8
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+)
+
+// Image renders the frame with the palette, each pixel a scale x scale block. The color index
+// of a pixel is its Pixel value, so the image has the background, foreground, Plane2 and
+// Overlap colors in that order. A scale below 1 is treated as 1.
+func (f *Frame) Image(palette Palette, scale int) *image.Paletted {
+
+	scale = max(scale, 1)
+
+	colors := color.Palette{palette.Background, palette.Foreground, palette.Plane2, palette.Overlap}
+	img := image.NewPaletted(image.Rect(0, 0, f.Width*scale, f.Height*scale), colors)
+
+	for y := 0; y < f.Height*scale; y++ {
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < f.Width*scale; x++ {
+			row[x] = f.Pixel(x/scale, y/scale)
+		}
+	}
+
+	return img
+
+}
+
+// Recorder records the frames of a run into an animated GIF. Frames are counted at 60Hz and
+// captured at a lower rate to keep the file small, a frame that did not change extending the
+// previous one instead of being stored again.
+type Recorder struct {
+	palette  Palette
+	scale    int
+	interval int
+
+	// Frames counted so far, the frame the delay of the last image runs up to and the
+	// last frame captured.
+	frames   int
+	captured int
+	last     Frame
+
+	images []*image.Paletted
+	delays []int
+}
+
+// NewRecorder returns a recorder rendering frames with the palette at the given scale and
+// capturing fps of the 60 frames of each second.
+func NewRecorder(palette Palette, scale int, fps int) *Recorder {
+	return &Recorder{
+		palette:  palette,
+		scale:    max(scale, 1),
+		interval: max(60/max(fps, 1), 1),
+	}
+}
+
+// Frame counts a 60Hz frame, capturing it every 60/fps frames. Drivers call it once per frame
+// with the display.
+func (r *Recorder) Frame(frame Frame) {
+
+	r.frames++
+	if (r.frames-1)%r.interval != 0 {
+		return
+	}
+
+	// The previous image lasts until this frame starts.
+	r.extend(r.frames - 1)
+
+	if len(r.images) > 0 && frame == r.last {
+		return
+	}
+
+	r.images = append(r.images, frame.Image(r.palette, r.scale))
+	r.delays = append(r.delays, 0)
+	r.last = frame
+
+}
+
+// extend makes the last image last until the given frame. GIF delays are in hundredths of
+// a second, the rounding is spread over the whole animation.
+func (r *Recorder) extend(frame int) {
+
+	if len(r.delays) > 0 {
+		r.delays[len(r.delays)-1] += frame*100/60 - r.captured*100/60
+	}
+	r.captured = frame
+
+}
+
+// Frames returns the number of 60Hz frames recorded.
+func (r *Recorder) Frames() int {
+	return r.frames
+}
+
+// WriteGIF encodes the recording as an animated GIF looping forever.
+func (r *Recorder) WriteGIF(w io.Writer) error {
+
+	r.extend(r.frames)
+
+	return gif.EncodeAll(w, &gif.GIF{Image: r.images, Delay: r.delays})
+
+}
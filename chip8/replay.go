@@ -0,0 +1,208 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// errReplayEnded is returned by playFrame once every frame was played.
+var errReplayEnded = errors.New("replay ended")
+
+// ErrReplayDiverged is returned when a replay no longer matches its recording, e.g. because
+// it was recorded with another version of the interpreter.
+var ErrReplayDiverged = errors.New("replay diverged from the recording")
+
+// Replay is a recorded run: the machine state it started from, which includes the ROM, the
+// settings and the random seed, and what happened in each 60Hz frame. Playing it back
+// executes exactly the same instructions with the same keys held.
+type Replay struct {
+	// ROM is the name of the ROM file, for information.
+	ROM string `json:"rom"`
+
+	// State is the save state the run started from, see SaveState.
+	State []byte `json:"state"`
+
+	Frames []ReplayFrame `json:"frames"`
+}
+
+// ReplayFrame is a frame of a replay: the instructions executed before its timer tick,
+// whether the timers stood still, paused or in slow motion, the keys held after it, one bit per key with bit 0 for key
+// 0x0, and the program counter at its end, to detect a diverging replay.
+type ReplayFrame struct {
+	Cycles int    `json:"c"`
+	Paused bool   `json:"p,omitempty"`
+	Keys   uint16 `json:"k"`
+	PC     uint16 `json:"pc"`
+}
+
+// ReadReplay decodes a replay written by Replay.Write.
+func ReadReplay(r io.Reader) (*Replay, error) {
+
+	var replay Replay
+
+	if err := json.NewDecoder(r).Decode(&replay); err != nil {
+		return nil, fmt.Errorf("could not read replay: %w", err)
+	}
+
+	return &replay, nil
+
+}
+
+// Write encodes the replay as JSON.
+func (replay *Replay) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(replay)
+}
+
+// replayRecorder records the frames of a run driven by Run.
+type replayRecorder struct {
+	replay Replay
+	cycles int
+}
+
+// replayPlayer plays a replay back, next being the frame to play.
+type replayPlayer struct {
+	replay *Replay
+	next   int
+}
+
+// RecordReplay starts recording the run from the current state: Run then records every frame
+// until StopRecording. Pausing is recorded, but resetting, rewinding or loading a state during
+// a recording makes its replay diverge.
+func (chip *Chip8) RecordReplay() error {
+
+	var state bytes.Buffer
+	if err := chip.SaveState(&state); err != nil {
+		return err
+	}
+
+	chip.recording = &replayRecorder{replay: Replay{ROM: chip.rom_path, State: state.Bytes()}}
+
+	return nil
+
+}
+
+// StopRecording ends the recording started by RecordReplay and returns it, nil if there was none.
+func (chip *Chip8) StopRecording() *Replay {
+
+	if chip.recording == nil {
+		return nil
+	}
+
+	replay := &chip.recording.replay
+	chip.recording = nil
+
+	return replay
+
+}
+
+// recordFrame records the frame Run just ran, once the keypad was read.
+func (chip *Chip8) recordFrame(stopped bool) {
+
+	r := chip.recording
+
+	r.replay.Frames = append(r.replay.Frames, ReplayFrame{
+		Cycles: r.cycles,
+		Paused: stopped,
+		Keys:   chip.keyMask(),
+		PC:     chip.program_counter,
+	})
+	r.cycles = 0
+
+}
+
+// StartReplay restores the state a replay starts from. Run then plays its frames instead of
+// running at the clock rate, ignoring the keypad, until they run out. A replay that diverges
+// from its recording stops with ErrReplayDiverged.
+func (chip *Chip8) StartReplay(replay *Replay) error {
+
+	if err := chip.LoadState(bytes.NewReader(replay.State)); err != nil {
+		return err
+	}
+
+	chip.replay = &replayPlayer{replay: replay}
+
+	return nil
+
+}
+
+// PlayReplay plays the whole replay started by StartReplay at once, without a frontend.
+// It returns nil once every frame was played.
+func (chip *Chip8) PlayReplay() error {
+
+	for chip.replay != nil {
+		if err := chip.playFrame(); err != nil {
+			if errors.Is(err, errReplayEnded) || errors.Is(err, ErrExited) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// playFrame executes the next frame of the replay: the keys held after the previous frame,
+// its instructions and its timer tick.
+func (chip *Chip8) playFrame() error {
+
+	p := chip.replay
+
+	if p.next >= len(p.replay.Frames) {
+		chip.replay = nil
+		chip.log(slog.LevelInfo, "replay ended", "frames", p.next)
+		return errReplayEnded
+	}
+
+	frame := p.replay.Frames[p.next]
+
+	if p.next > 0 {
+		chip.setKeyMask(p.replay.Frames[p.next-1].Keys)
+	}
+
+	for i := 0; i < frame.Cycles; i++ {
+		if err := chip.Cycle(); err != nil {
+			chip.replay = nil
+			return fmt.Errorf("replay frame %d: %w", p.next, err)
+		}
+	}
+
+	if !frame.Paused {
+		chip.DecrementTimers()
+	}
+
+	if chip.program_counter != frame.PC {
+		chip.replay = nil
+		return fmt.Errorf("%w at frame %d: PC 0x%03X, recorded 0x%03X", ErrReplayDiverged, p.next, chip.program_counter, frame.PC)
+	}
+
+	p.next++
+
+	return nil
+
+}
+
+// keyMask returns the keys held, one bit per key with bit 0 for key 0x0.
+func (chip *Chip8) keyMask() uint16 {
+
+	var mask uint16
+	for key, held := range chip.keypad {
+		if held {
+			mask |= 1 << key
+		}
+	}
+
+	return mask
+
+}
+
+// setKeyMask holds the keys of a mask returned by keyMask.
+func (chip *Chip8) setKeyMask(mask uint16) {
+	for key := range chip.keypad {
+		chip.keypad[key] = mask&(1<<key) != 0
+	}
+}
@@ -0,0 +1,47 @@
+package chip8
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// StateReport returns a human-readable dump of the machine state: the V registers,
+// I, PC, SP, both timers, the stack and how many display pixels are on.
+func (chip *Chip8) StateReport() string {
+
+	var b strings.Builder
+
+	for i, value := range chip.registers {
+		fmt.Fprintf(&b, "V%X=%02X", i, value)
+		if i%8 == 7 {
+			b.WriteString("\n")
+		} else {
+			b.WriteString(" ")
+		}
+	}
+
+	fmt.Fprintf(&b, "PC=%04X I=%04X SP=%02X\n", chip.program_counter, chip.index_register, chip.stack_pointer)
+	fmt.Fprintf(&b, "DT=%02X ST=%02X\n", chip.delay_timer, chip.sound_timer)
+
+	b.WriteString("Stack:")
+	if chip.stack_pointer == 0 {
+		b.WriteString(" (empty)")
+	}
+	for i := 0; i < int(chip.stack_pointer) && i < len(chip.stack); i++ {
+		fmt.Fprintf(&b, " %04X", chip.stack[i])
+	}
+	b.WriteString("\n")
+
+	pixels := 0
+	for y := range HiResHeight {
+		for w := range plane_words {
+			pixels += bits.OnesCount64(chip.display[0][y][w] | chip.display[1][y][w])
+		}
+	}
+	width, height := chip.Resolution()
+	fmt.Fprintf(&b, "Display: %d/%d pixels on\n", pixels, width*height)
+
+	return b.String()
+
+}
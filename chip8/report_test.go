@@ -0,0 +1,48 @@
+package chip8
+
+import "testing"
+
+func TestStateReport(t *testing.T) {
+
+	chip := New()
+	// V0 = 0x0C, VE = 0xAB, call 0x208, which draws the 0 of the font at (0x0C, 0)
+	rom := []byte{0x60, 0x0C, 0x6E, 0xAB, 0x22, 0x08, 0x00, 0x00, 0xF1, 0x29, 0xD0, 0x15}
+	if err := chip.LoadROMBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	for range 5 {
+		if err := chip.Cycle(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chip.delay_timer, chip.sound_timer = 0x3C, 0x05
+
+	want := "V0=0C V1=00 V2=00 V3=00 V4=00 V5=00 V6=00 V7=00\n" +
+		"V8=00 V9=00 VA=00 VB=00 VC=00 VD=00 VE=AB VF=00\n" +
+		"PC=020C I=0000 SP=01\n" +
+		"DT=3C ST=05\n" +
+		"Stack: 0204\n" +
+		"Display: 14/2048 pixels on\n"
+
+	if got := chip.StateReport(); got != want {
+		t.Errorf("report:\n%s\nwant:\n%s", got, want)
+	}
+
+}
+
+func TestStateReportEmptyStack(t *testing.T) {
+
+	chip := New()
+
+	want := "V0=00 V1=00 V2=00 V3=00 V4=00 V5=00 V6=00 V7=00\n" +
+		"V8=00 V9=00 VA=00 VB=00 VC=00 VD=00 VE=00 VF=00\n" +
+		"PC=0200 I=0000 SP=00\n" +
+		"DT=00 ST=00\n" +
+		"Stack: (empty)\n" +
+		"Display: 0/2048 pixels on\n"
+
+	if got := chip.StateReport(); got != want {
+		t.Errorf("report:\n%s\nwant:\n%s", got, want)
+	}
+
+}
@@ -0,0 +1,208 @@
+package chip8
+
+import "time"
+
+// Memory is snapshotted in pages of this many bytes. Pages that did not change since the
+// previous snapshot are shared with it, and so is its page table when none did, so a snapshot
+// of a program that only touches a few variables costs little more than its registers.
+const rewind_page = 256
+
+type rewindPage [rewind_page]byte
+
+// rewindSnapshot is the part of the machine state a program changes while running. The
+// configuration is left out, it does not change during a rewind.
+type rewindSnapshot struct {
+	registers       [16]byte
+	program_counter uint16
+	index_register  uint32
+	stack           [16]uint16
+	stack_pointer   uint8
+	delay_timer     uint8
+	sound_timer     uint8
+	timer_elapsed   time.Duration
+	hires           bool
+	rpl             [16]byte
+	planes          uint8
+	pattern         [16]byte
+	pitch           byte
+	key_wait        byte
+	key_waiting     bool
+	awaiting_key    bool
+	vblank          bool
+	cycle_count     uint64
+	rng_draws       uint64
+
+	pages   []*rewindPage
+	display [2]Plane
+	mega    *megaChip
+}
+
+// Rewind keeps a ring buffer of recent machine snapshots to step gameplay back in time.
+type Rewind struct {
+	chip *Chip8
+
+	// Frames between snapshots, and frames counted since the last one.
+	interval int
+	frames   int
+
+	// Ring buffer of snapshots, the newest at (next-1) and count of them valid.
+	snapshots []*rewindSnapshot
+	next      int
+	count     int
+}
+
+// NewRewind returns a rewind buffer for chip holding up to capacity snapshots, taken every
+// interval frames. At 60 frames per second, NewRewind(chip, 100, 6) keeps the last 10 seconds.
+func NewRewind(chip *Chip8, capacity int, interval int) *Rewind {
+	return &Rewind{
+		chip:      chip,
+		interval:  max(interval, 1),
+		snapshots: make([]*rewindSnapshot, max(capacity, 1)),
+	}
+}
+
+// Frame counts a frame and takes a snapshot every interval frames. Drivers call it once
+// per frame while the program runs forward.
+func (r *Rewind) Frame() {
+
+	r.frames++
+
+	if r.frames >= r.interval {
+		r.frames = 0
+		r.Capture()
+	}
+
+}
+
+// Capture takes a snapshot now, dropping the oldest one when the buffer is full.
+func (r *Rewind) Capture() {
+
+	chip := r.chip
+
+	var last *rewindSnapshot
+	if r.count > 0 {
+		last = r.snapshots[(r.next+len(r.snapshots)-1)%len(r.snapshots)]
+	}
+
+	s := &rewindSnapshot{
+		registers:       chip.registers,
+		program_counter: chip.program_counter,
+		index_register:  chip.index_register,
+		stack:           chip.stack,
+		stack_pointer:   chip.stack_pointer,
+		delay_timer:     chip.delay_timer,
+		sound_timer:     chip.sound_timer,
+		timer_elapsed:   chip.timer_elapsed,
+		hires:           chip.hires,
+		rpl:             chip.rpl,
+		planes:          chip.planes,
+		pattern:         chip.pattern,
+		pitch:           chip.pitch,
+		key_wait:        chip.key_wait,
+		key_waiting:     chip.key_waiting,
+		awaiting_key:    chip.awaiting_key,
+		vblank:          chip.vblank,
+		cycle_count:     chip.cycle_count,
+		rng_draws:       chip.rng_draws,
+		display:         chip.display,
+		mega:            chip.mega.clone(),
+	}
+
+	// The table of the last snapshot is shared until a page changed, snapshots are never
+	// modified once taken.
+	memory := chip.mem()
+	count := len(memory) / rewind_page
+
+	var previous []*rewindPage
+	if last != nil && len(last.pages) == count {
+		previous = last.pages
+	}
+	s.pages = previous
+	own := previous == nil
+	if own {
+		s.pages = make([]*rewindPage, count)
+	}
+
+	for i := range s.pages {
+		page := (*rewindPage)(memory[i*rewind_page : (i+1)*rewind_page])
+		if previous != nil && *previous[i] == *page {
+			continue
+		}
+		if !own {
+			s.pages = append([]*rewindPage(nil), previous...)
+			own = true
+		}
+		copied := *page
+		s.pages[i] = &copied
+	}
+
+	r.snapshots[r.next] = s
+	r.next = (r.next + 1) % len(r.snapshots)
+	r.count = min(r.count+1, len(r.snapshots))
+
+}
+
+// Len returns the number of snapshots available to step back to.
+func (r *Rewind) Len() int {
+	return r.count
+}
+
+// StepBack restores the newest snapshot and removes it, so repeated calls go further back.
+// It returns false when there is nothing left to rewind to.
+func (r *Rewind) StepBack() bool {
+
+	if r.count == 0 {
+		return false
+	}
+
+	r.next = (r.next + len(r.snapshots) - 1) % len(r.snapshots)
+	r.count--
+
+	s := r.snapshots[r.next]
+	r.snapshots[r.next] = nil
+	r.frames = 0
+
+	chip := r.chip
+
+	chip.registers = s.registers
+	chip.program_counter = s.program_counter
+	chip.index_register = s.index_register
+	chip.stack = s.stack
+	chip.stack_pointer = s.stack_pointer
+	chip.delay_timer = s.delay_timer
+	chip.sound_timer = s.sound_timer
+	chip.timer_elapsed = s.timer_elapsed
+	chip.hires = s.hires
+	chip.rpl = s.rpl
+	chip.planes = s.planes
+	chip.pattern = s.pattern
+	chip.pitch = s.pitch
+	chip.key_wait = s.key_wait
+	chip.key_waiting = s.key_waiting
+	chip.awaiting_key = s.awaiting_key
+	chip.vblank = s.vblank
+	chip.cycle_count = s.cycle_count
+	chip.has_fetched = false
+
+	memory := chip.mem()
+	for i, page := range s.pages {
+		copy(memory[i*rewind_page:], page[:])
+	}
+	chip.invalidateAllDecoded()
+	chip.display = s.display
+	if s.mega != nil && chip.mega != nil {
+		chip.mega = s.mega.clone()
+	}
+	chip.display_dirty = true
+
+	// The default random source goes back to the same position.
+	if chip.rng_default && chip.rng_draws != s.rng_draws {
+		chip.SetSeed(chip.rng_seed)
+		for ; chip.rng_draws < s.rng_draws; chip.rng_draws++ {
+			chip.rng.Uint32()
+		}
+	}
+
+	return true
+
+}
@@ -0,0 +1,89 @@
+package chip8
+
+import "testing"
+
+func TestRewindSharesUnchangedMemory(t *testing.T) {
+
+	for _, platform := range []Platform{PlatformChip8, PlatformXOChip, PlatformMegaChip} {
+
+		chip := New()
+		chip.SetPlatform(platform)
+		if err := chip.LoadROMBytes([]byte{0x12, 0x00}); err != nil {
+			t.Fatal(err)
+		}
+
+		r := NewRewind(chip, 4, 1)
+		r.Capture()
+		first := r.snapshots[0]
+
+		// Without a write, a snapshot shares the page table of the previous one and costs
+		// only itself, and the copy of the Megachip state.
+		want := 1.0
+		if platform == PlatformMegaChip {
+			want = 2
+		}
+		if allocs := testing.AllocsPerRun(10, r.Capture); allocs > want {
+			t.Errorf("%v: %.0f allocations per snapshot of unchanged memory, want %.0f", platform, allocs, want)
+		}
+		last := r.snapshots[(r.next+len(r.snapshots)-1)%len(r.snapshots)]
+		if &last.pages[0] != &first.pages[0] {
+			t.Errorf("%v: the page table of unchanged memory was copied", platform)
+		}
+
+		// A write copies the table and the page written, the other pages stay shared.
+		chip.poke(0x300, 0xAB)
+		r.Capture()
+		changed := r.snapshots[(r.next+len(r.snapshots)-1)%len(r.snapshots)]
+		if &changed.pages[0] == &first.pages[0] {
+			t.Fatalf("%v: a page changed but the table is shared", platform)
+		}
+		for i := range changed.pages {
+			if shared := changed.pages[i] == first.pages[i]; shared != (i != 0x300/rewind_page) {
+				t.Errorf("%v: page %d shared %v", platform, i, shared)
+			}
+		}
+		if first.pages[0x300/rewind_page][0] != 0 {
+			t.Errorf("%v: the write changed an older snapshot", platform)
+		}
+	}
+
+}
+
+func TestRewindStepBack(t *testing.T) {
+
+	chip := New()
+	chip.SetIndexIncrementQuirk(false)
+	// V0 += 1, save V0 at I = 0x300, loop
+	if err := chip.LoadROMBytes([]byte{0xA3, 0x00, 0x70, 0x01, 0xF0, 0x55, 0x12, 0x02}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chip.Cycle(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRewind(chip, 8, 1)
+	var saved []byte
+	for range 5 {
+		for range 3 {
+			if err := chip.Cycle(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r.Frame()
+		saved = append(saved, chip.memory[0x300])
+	}
+
+	for i := len(saved) - 1; i >= 0; i-- {
+		if !r.StepBack() {
+			t.Fatalf("nothing to step back to with %d snapshots left", i+1)
+		}
+		if chip.memory[0x300] != saved[i] || chip.registers[0] != saved[i] {
+			t.Errorf("step back to snapshot %d: memory %d, V0 %d, want %d", i, chip.memory[0x300], chip.registers[0], saved[i])
+		}
+	}
+
+	if r.StepBack() {
+		t.Error("stepped back past the oldest snapshot")
+	}
+
+}
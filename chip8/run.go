@@ -0,0 +1,304 @@
+package chip8
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrCycleLimit is returned by RunUntilHalt when the program did not halt within the allowed cycles.
+var ErrCycleLimit = errors.New("cycle limit reached before the program halted")
+
+// Number of instructions executed per 60Hz frame when running headlessly (~600Hz).
+const cycles_per_frame = 10
+
+// RunROM loads the ROM at path into a new machine, runs it headlessly for the given
+// number of frames and returns the final display.
+func RunROM(path string, frames int) (Frame, error) {
+
+	chip := New()
+
+	if err := chip.LoadROM(path); err != nil {
+		return Frame{}, err
+	}
+
+	for frame := 0; frame < frames; frame++ {
+		for i := 0; i < cycles_per_frame; i++ {
+			if err := chip.Cycle(); err != nil {
+				return chip.Display(), fmt.Errorf("frame %d: %w", frame, err)
+			}
+		}
+		chip.DecrementTimers()
+	}
+
+	return chip.Display(), nil
+
+}
+
+// DefaultFrameRate is the rate Run calls its frame callback at unless SetFrameRate changes it,
+// the refresh rate of most displays and the rate of the timers.
+const DefaultFrameRate = 60
+
+// DefaultFrameSkip is how many 60Hz frames in a row Run may skip drawing to keep up with a
+// slow host unless SetFrameSkip changes it.
+const DefaultFrameSkip = 5
+
+// SetFrameRate sets how many times per second Run calls its frame callback, e.g. 144 to draw
+// and read the keys at the refresh rate of a 144Hz display. The timers tick at 60Hz whatever
+// the frame rate. A rate of 0 selects DefaultFrameRate.
+func (chip *Chip8) SetFrameRate(fps int) {
+	chip.frame_rate = max(fps, 0)
+}
+
+// FrameRate returns how many times per second Run calls its frame callback.
+func (chip *Chip8) FrameRate() int {
+
+	if chip.frame_rate == 0 {
+		return DefaultFrameRate
+	}
+
+	return chip.frame_rate
+
+}
+
+// SetFrameSkip sets how many 60Hz frames in a row Run may run without calling its frame
+// callback when the host cannot keep up, see Run. With 0 the machine slows down instead.
+func (chip *Chip8) SetFrameSkip(frames int) {
+	chip.frame_skip = max(frames, 0)
+}
+
+// Run executes instructions at ClockHz instructions per second and decrements the timers at
+// 60Hz, calling frame FrameRate times per second, see SetFrameRate. It returns when ctx is done
+// or an instruction fails.
+//
+// Each frame runs the batch of instructions and timer ticks due since the previous one, in the
+// order they fall due, then calls frame. What is due is counted from the start of the run, so
+// the pace does not drift with the sleeps between frames. When the host cannot keep up, e.g. a
+// frame callback is slow, the next frame catches up on the frames missed, which are not drawn,
+// up to the frame skip of SetFrameSkip: past it the time missed is dropped and the machine
+// slows down, so a stall (a suspended laptop, a debugger) does not turn into a burst.
+//
+// A batcher, see SetBatcher, sizes the instructions run per timer tick to what the host keeps
+// up with.
+//
+// It follows SetClockHz, SetSpeed and SetFrameRate while running. While paused, see Pause,
+// only the frame callback runs.
+//
+// While a replay plays, see StartReplay, the instruction rate is ignored: each timer tick
+// plays a frame of the replay instead, and the run goes on live once it ended. A netplay
+// client, see FollowNetplay, runs nothing and shows the display of the host.
+func (chip *Chip8) Run(ctx context.Context, frame func()) error {
+
+	hz := chip.instructionRate()
+	fps := chip.FrameRate()
+
+	chip.log(slog.LevelDebug, "running", "clock_hz", hz, "fps", fps, "platform", chip.platform.String())
+
+	clock := chip.timeSource()
+
+	refresh := clock.NewTicker(time.Second / time.Duration(fps))
+	defer refresh.Stop()
+
+	// Instructions executed since cpu_start, and when the timers were last advanced: they carry
+	// the time short of a tick over, as with TickTimers.
+	cpu_start := clock.Now()
+	timer_at := cpu_start
+	var executed uint64
+
+	// Timer ticks counted in slow motion, only one in slow_motion_factor decrements the timers.
+	var slow_ticks int
+
+	// runDue executes the instructions due at now.
+	runDue := func(now time.Time) error {
+
+		// After a change of rate, the instructions are counted from the new one.
+		if rate := chip.instructionRate(); rate != hz {
+			hz, cpu_start, executed = rate, now, 0
+			chip.log(slog.LevelDebug, "clock rate changed", "clock_hz", hz)
+		}
+
+		// Rounded to the nearest instruction.
+		due := (uint64(now.Sub(cpu_start))*uint64(hz) + uint64(time.Second)/2) / uint64(time.Second)
+
+		// Paused time is skipped, not caught up on, and a replay runs its own instructions.
+		// Turbo runs flat out once the frame caught up, see runFrame.
+		if chip.paused || chip.replay != nil || chip.speed == SpeedTurbo {
+			executed = max(executed, due)
+			return nil
+		}
+
+		// With a batcher, at most a batch runs and the rest is dropped.
+		if chip.batcher != nil && executed < due {
+			count := min(due-executed, uint64(chip.batcher.Size()))
+			executed = due
+			return chip.runBatch(chip.batcher, int(count))
+		}
+
+		for ; executed < due; executed++ {
+			if err := chip.Cycle(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	}
+
+	// tickTimers decrements the timers, or plays a frame of the replay.
+	tickTimers := func() error {
+
+		tick := !chip.paused
+		if tick && chip.speed == SpeedSlow {
+			slow_ticks++
+			tick = slow_ticks%slow_motion_factor == 0
+		}
+		switch {
+		case !tick:
+		case chip.replay != nil:
+			if err := chip.playFrame(); err != nil && !errors.Is(err, errReplayEnded) {
+				return err
+			}
+		default:
+			chip.DecrementTimers()
+		}
+		if chip.recording != nil {
+			chip.recordFrame(!tick)
+		}
+
+		return nil
+
+	}
+
+	// runFrame runs the batch of a frame ending at now and calls frame.
+	runFrame := func(now time.Time) error {
+
+		if rate := chip.FrameRate(); rate != fps {
+			fps = rate
+			refresh.Reset(time.Second / time.Duration(fps))
+			chip.log(slog.LevelDebug, "frame rate changed", "fps", fps)
+		}
+
+		// A netplay client shows the display of the host instead of running the program.
+		if chip.netplay != nil {
+			return chip.followFrame(frame)
+		}
+
+		// Past a frame and the frame skip, the time missed is dropped from both counts.
+		elapsed := now.Sub(timer_at)
+		timer_at = now
+		lag := chip.timer_elapsed + elapsed
+		if allowed := time.Second/time.Duration(fps) + time.Duration(chip.frame_skip)*timer_period; lag > allowed {
+			cpu_start = cpu_start.Add(lag - allowed)
+			elapsed -= lag - allowed
+		}
+
+		// The instructions due before each timer tick run before it, the ticks falling a
+		// period apart from the time the timers carried over.
+		tick_at := now.Add(-chip.timer_elapsed - elapsed)
+		for range chip.elapseTimers(elapsed) {
+			tick_at = tick_at.Add(timer_period)
+			if err := runDue(tick_at); err != nil {
+				return err
+			}
+			if err := tickTimers(); err != nil {
+				return err
+			}
+		}
+		// A batcher runs the instructions by tick, those due since the last one wait for the next.
+		if chip.batcher == nil {
+			if err := runDue(now); err != nil {
+				return err
+			}
+		}
+
+		if chip.speed == SpeedTurbo && !chip.paused && chip.replay == nil {
+			if err := chip.runTurbo(time.Second / time.Duration(fps) * turbo_share / 100); err != nil {
+				return err
+			}
+		}
+
+		if frame != nil {
+			frame()
+		}
+
+		return nil
+
+	}
+
+	for {
+		select {
+
+		case <-ctx.Done():
+			return nil
+
+		case now := <-refresh.C():
+			err := runFrame(now)
+			tickHandled(refresh)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+}
+
+// Percentage of each frame turbo runs instructions flat out, the rest is left to the frame
+// callback, and how many it runs between looks at the clock.
+const (
+	turbo_share = 75
+	turbo_batch = 256
+)
+
+// runTurbo executes instructions as fast as possible for slice. The slice is wall clock time
+// whatever the clock of the machine, it measures how fast the host runs.
+func (chip *Chip8) runTurbo(slice time.Duration) error {
+
+	start := time.Now()
+
+	for time.Since(start) < slice {
+		for i := 0; i < turbo_batch; i++ {
+			if err := chip.Cycle(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+
+}
+
+// RunUntilHalt executes instructions until the program halts or maxCycles instructions have run.
+// A program is considered halted when it jumps to its own address (1NNN with NNN == PC), the
+// infinite loop test ROMs end with, or exits with the SUPER-CHIP 00FD. It returns nil on halt,
+// ErrCycleLimit if the limit was reached first, or the error of a failing instruction.
+func (chip *Chip8) RunUntilHalt(maxCycles int) error {
+
+	for i := 0; i < maxCycles; i++ {
+
+		in, err := chip.Fetch()
+		if err != nil {
+			return err
+		}
+
+		if isHaltLoop(in, chip.program_counter) {
+			return nil
+		}
+
+		if err := chip.Execute(); err != nil {
+			if errors.Is(err, ErrExited) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return ErrCycleLimit
+
+}
+
+// isHaltLoop reports whether the instruction at pc jumps to itself.
+func isHaltLoop(in Instruction, pc uint16) bool {
+	return in.Op == 0x1 && uint16(in.NNN) == pc
+}
@@ -0,0 +1,84 @@
+package chip8
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRunROM(t *testing.T) {
+
+	frame, err := RunROM("testdata/ibm_logo.ch8", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if frame.Width != DisplayWidth || frame.Height != DisplayHeight {
+		t.Fatalf("display is %dx%d, want %dx%d", frame.Width, frame.Height, DisplayWidth, DisplayHeight)
+	}
+
+	lit := 0
+	for y := 0; y < frame.Height; y++ {
+		for x := 0; x < frame.Width; x++ {
+			if frame.Pixel(x, y) != 0 {
+				lit++
+			}
+		}
+	}
+	if lit == 0 {
+		t.Fatal("the display is blank after running the IBM logo")
+	}
+
+	// The top left corner of the I.
+	if frame.Pixel(12, 8) == 0 {
+		t.Errorf("pixel (12, 8) is off, the logo is not where it should be:\n%s", frame.Text())
+	}
+
+}
+
+func TestRunROMMissingFile(t *testing.T) {
+
+	if _, err := RunROM("testdata/missing.ch8", 1); err == nil {
+		t.Error("RunROM of a missing file succeeded")
+	}
+
+}
+
+func TestRunUntilHalt(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		platform Platform
+		rom      []byte
+		err      error
+		pc       uint16
+	}{
+		// LD V0, 1; JP self
+		{"jump to itself", PlatformChip8, []byte{0x60, 0x01, 0x12, 0x02}, nil, 0x202},
+		// LD V0, 1; EXIT
+		{"exit", PlatformSuperChip, []byte{0x60, 0x01, 0x00, 0xFD}, nil, 0x202},
+		// LD V0, 1; JP 0x200, a loop that never halts
+		{"loop", PlatformChip8, []byte{0x60, 0x01, 0x12, 0x00}, ErrCycleLimit, 0},
+		// LD V0, 1; RET with an empty stack
+		{"failing instruction", PlatformChip8, []byte{0x60, 0x01, 0x00, 0xEE}, ErrStackUnderflow, 0x202},
+	}
+
+	for _, test := range tests {
+
+		chip := New()
+		chip.SetPlatform(test.platform)
+		if err := chip.LoadROMBytes(test.rom); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := chip.RunUntilHalt(100); !errors.Is(err, test.err) {
+			t.Errorf("%s: %v, want %v", test.name, err, test.err)
+		}
+		if chip.registers[0] != 1 {
+			t.Errorf("%s: V0 %d, the first instruction did not run", test.name, chip.registers[0])
+		}
+		if test.err != ErrCycleLimit && chip.program_counter != test.pc {
+			t.Errorf("%s: PC %03X, want %03X", test.name, chip.program_counter, test.pc)
+		}
+	}
+
+}
@@ -0,0 +1,48 @@
+package chip8
+
+// RunState is what the machine does on its next cycle: run the next instruction, or go on
+// waiting in FX0A. Waiting is machine state rather than a blocking read, so frontends keep
+// running, and save states, rewinds and the debugger capture it.
+type RunState int
+
+const (
+	StateRunning RunState = iota
+
+	// StateWaitingForKey - FX0A waits for a key to be pressed
+	StateWaitingForKey
+
+	// StateWaitingForRelease - FX0A saw a key pressed and waits for its release
+	StateWaitingForRelease
+)
+
+func (s RunState) String() string {
+
+	switch s {
+	case StateWaitingForKey:
+		return "waiting for key"
+	case StateWaitingForRelease:
+		return "waiting for key release"
+	}
+
+	return "running"
+
+}
+
+// RunState returns what the machine does on its next cycle. A wait only lasts while the
+// program counter is on the FX0A, moving it elsewhere, e.g. from the debugger, ends it.
+func (chip *Chip8) RunState() RunState {
+
+	if chip.opcodeAt(chip.program_counter)&0xF0FF != 0xF00A {
+		return StateRunning
+	}
+
+	switch {
+	case chip.key_waiting:
+		return StateWaitingForRelease
+	case chip.awaiting_key:
+		return StateWaitingForKey
+	}
+
+	return StateRunning
+
+}
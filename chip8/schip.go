@@ -0,0 +1,232 @@
+package chip8
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// ErrExited is returned when a SUPER-CHIP program exits the interpreter with 00FD.
+var ErrExited = errors.New("program exited")
+
+// Address of the SUPER-CHIP large font in memory, after the small font.
+const big_font_address = 0x50
+
+// Big Fontset - 8x10 digit sprites used by FX30. SUPER-CHIP 1.1 only defines 0 to 9,
+// A to F follow Octo.
+var big_fontset = [160]byte{
+	0xFF, 0xFF, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, // 0
+	0x18, 0x78, 0x78, 0x18, 0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF, // 1
+	0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // 2
+	0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 3
+	0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0x03, 0x03, // 4
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 5
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 6
+	0xFF, 0xFF, 0x03, 0x03, 0x06, 0x0C, 0x18, 0x18, 0x18, 0x18, // 7
+	0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 8
+	0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 9
+	0x7E, 0xFF, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xC3, // A
+	0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, // B
+	0x3C, 0xFF, 0xC3, 0xC0, 0xC0, 0xC0, 0xC0, 0xC3, 0xFF, 0x3C, // C
+	0xFC, 0xFE, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFE, 0xFC, // D
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // E
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xC0, 0xC0, // F
+}
+
+// HiRes reports whether the SUPER-CHIP 128x64 mode is on.
+func (chip *Chip8) HiRes() bool {
+	return chip.hires
+}
+
+// RPLFlags returns the SUPER-CHIP user flags written by FX75, e.g. to persist them between runs.
+func (chip *Chip8) RPLFlags() [16]byte {
+	return chip.rpl
+}
+
+// SetRPLFlags sets the user flags read by FX85.
+func (chip *Chip8) SetRPLFlags(flags [16]byte) {
+	chip.rpl = flags
+}
+
+// superChip reports whether SUPER-CHIP instructions are enabled. When they are not, they
+// are unknown opcodes.
+func (chip *Chip8) superChip() bool {
+	return chip.platform >= PlatformSuperChip
+}
+
+// 00CN - Scroll the display down N pixels
+func (chip *Chip8) op00CN(in Instruction) error {
+
+	if !chip.superChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.scroll(0, in.N)
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 00FB - Scroll the display right 4 pixels
+func (chip *Chip8) op00FB(in Instruction) error {
+
+	if !chip.superChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.scroll(4, 0)
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 00FC - Scroll the display left 4 pixels
+func (chip *Chip8) op00FC(in Instruction) error {
+
+	if !chip.superChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.scroll(-4, 0)
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 00FD - Exit the interpreter
+func (chip *Chip8) op00FD(in Instruction) error {
+
+	if !chip.superChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	// The program counter stays on 00FD, so running on exits again.
+	chip.log(slog.LevelInfo, "program exited", hexAttr("pc", chip.program_counter))
+
+	return ErrExited
+
+}
+
+// 00FE - Switch to low resolution (64x32) and clear the display
+func (chip *Chip8) op00FE(in Instruction) error {
+
+	if !chip.superChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.hires = false
+	chip.Clear()
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 00FF - Switch to high resolution (128x64) and clear the display
+func (chip *Chip8) op00FF(in Instruction) error {
+
+	if !chip.superChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.hires = true
+	chip.Clear()
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// FX30 - Point I at the large font sprite for the digit in V[X]
+func (chip *Chip8) opFX30(in Instruction) error {
+
+	if !chip.superChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.index_register = big_font_address + uint32(chip.registers[in.X]&0xF)*10
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// FX75 - Store V[0] to V[X] in the RPL user flags
+func (chip *Chip8) opFX75(in Instruction) error {
+
+	if !chip.superChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	copy(chip.rpl[:in.X+1], chip.registers[:in.X+1])
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// FX85 - Load V[0] to V[X] from the RPL user flags
+func (chip *Chip8) opFX85(in Instruction) error {
+
+	if !chip.superChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	copy(chip.registers[:in.X+1], chip.rpl[:in.X+1])
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// scroll moves the selected planes of the display by dx pixels right and dy pixels down in
+// the current resolution. Pixels scrolled off the edge are lost and the uncovered area is cleared.
+func (chip *Chip8) scroll(dx, dy int) {
+
+	// Megachip scrolls the back buffer, which is shown by the next 00E0.
+	if chip.MegaMode() {
+		chip.mega.scroll(dx, dy)
+		chip.display_dirty = true
+		return
+	}
+
+	width, height := chip.Resolution()
+
+	for p := range chip.display {
+
+		// Unselected planes stay in place.
+		if chip.planes&(1<<p) == 0 {
+			continue
+		}
+
+		var scrolled Plane
+
+		for y := 0; y < height; y++ {
+			sy := y - dy
+			if sy < 0 || sy >= height {
+				continue
+			}
+
+			hi, lo := chip.display[p][sy][0], chip.display[p][sy][1]
+			if dx >= 0 {
+				hi, lo = shiftRight128(hi, lo, dx)
+			} else {
+				hi, lo = shiftLeft128(hi, lo, -dx)
+			}
+
+			// In low resolution, the second word is past the right edge.
+			if width == DisplayWidth {
+				lo = 0
+			}
+
+			scrolled[y] = [plane_words]uint64{hi, lo}
+		}
+
+		chip.display[p] = scrolled
+	}
+
+	chip.display_dirty = true
+
+}
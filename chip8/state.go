@@ -0,0 +1,319 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// A save state starts with this header, followed by the gob encoding of a machineState.
+var state_magic = []byte("C8STATE")
+
+// Version 2 added the SUPER-CHIP state and the 128x64 display,
+// version 3 the XO-CHIP planes, audio pattern and 64kB memory,
+// version 4 the seed and position of the random source,
+// version 5 the display wait quirk and the vertical blank,
+// version 6 the memory policy and protection,
+// version 7 the display packed as bitplanes,
+// version 8 the Megachip state, its 16MB memory and a 24-bit I,
+// version 9 the FX0A wait for a key press,
+// version 10 the entry point of the ROM.
+// Bundles record the version of their state too, see bundle_version.
+const state_version = 10
+
+// Most draws of the default random source a state may be at. Restoring replays the source up to
+// the state, which takes seconds at this count, reached by drawing at every instruction for
+// over two weeks at 700Hz, so a corrupted count cannot stall loading.
+const max_rng_draws = 1 << 30
+
+// ErrInvalidState is returned when loading data that is not a supported save state.
+var ErrInvalidState = errors.New("invalid save state")
+
+// machineState is a serializable copy of the complete machine, including its configuration.
+type machineState struct {
+	Registers    [16]byte
+	PC           uint16
+	I            uint32
+	Stack        [16]uint16
+	SP           uint8
+	DelayTimer   uint8
+	SoundTimer   uint8
+	TimerElapsed time.Duration
+	Memory       [xo_memory_size]byte
+	MegaMemory   []byte
+	Mega         *megaChip
+	LoadAddress  uint16
+	EntryPoint   uint16
+	Display      [2]Plane
+	HiRes        bool
+	RPL          [16]byte
+	Planes       uint8
+	Pattern      [16]byte
+	Pitch        byte
+	Keypad       [16]bool
+	KeyWait      byte
+	KeyWaiting   bool
+	AwaitingKey  bool
+	VBlank       bool
+	CycleCount   uint64
+	RNGSeed      int64
+	RNGDraws     uint64
+	RNGDefault   bool
+
+	// Configuration
+	Platform      Platform
+	SingleKey     bool
+	ShiftQuirk    bool
+	IndexQuirk    bool
+	OverflowQuirk bool
+	VFResetQuirk  bool
+	JumpQuirk     bool
+	WrapQuirk     bool
+	VerticalWrap  bool
+	DisplayWait   bool
+	MemoryPolicy  MemoryPolicy
+	ProtectMemory bool
+	Strict        bool
+	QuirksSet     map[string]bool
+	ClockHz       int
+	ClockOverride bool
+}
+
+// snapshot copies the machine state.
+func (chip *Chip8) snapshot() machineState {
+
+	state := machineState{
+		Registers:    chip.registers,
+		PC:           chip.program_counter,
+		I:            chip.index_register,
+		Stack:        chip.stack,
+		SP:           chip.stack_pointer,
+		DelayTimer:   chip.delay_timer,
+		SoundTimer:   chip.sound_timer,
+		TimerElapsed: chip.timer_elapsed,
+		Memory:       chip.memory,
+		Mega:         chip.mega.clone(),
+		LoadAddress:  chip.load_address,
+		EntryPoint:   chip.entry_point,
+		Display:      chip.display,
+		HiRes:        chip.hires,
+		RPL:          chip.rpl,
+		Planes:       chip.planes,
+		Pattern:      chip.pattern,
+		Pitch:        chip.pitch,
+		Keypad:       chip.keypad,
+		KeyWait:      chip.key_wait,
+		KeyWaiting:   chip.key_waiting,
+		AwaitingKey:  chip.awaiting_key,
+		VBlank:       chip.vblank,
+		CycleCount:   chip.cycle_count,
+		RNGSeed:      chip.rng_seed,
+		RNGDraws:     chip.rng_draws,
+		RNGDefault:   chip.rng_default,
+
+		Platform:      chip.platform,
+		SingleKey:     chip.single_key,
+		ShiftQuirk:    chip.shift_quirk,
+		IndexQuirk:    chip.index_increment_quirk,
+		OverflowQuirk: chip.index_overflow_quirk,
+		VFResetQuirk:  chip.vf_reset_quirk,
+		JumpQuirk:     chip.jump_quirk,
+		WrapQuirk:     chip.wrap_quirk,
+		VerticalWrap:  chip.vertical_wrap,
+		DisplayWait:   chip.display_wait,
+		MemoryPolicy:  chip.memory_policy,
+		ProtectMemory: chip.protect_memory,
+		Strict:        chip.strict,
+		QuirksSet:     map[string]bool{},
+		ClockHz:       chip.clock_hz,
+		ClockOverride: chip.clock_override,
+	}
+
+	for quirk, set := range chip.quirks_set {
+		state.QuirksSet[quirk] = set
+	}
+	if chip.mega_memory != nil {
+		state.MegaMemory = append([]byte(nil), chip.mega_memory...)
+	}
+
+	return state
+
+}
+
+// check returns an error wrapping ErrInvalidState if the state could not be that of a machine,
+// e.g. a corrupted save state with the stack pointer past the stack.
+func (state *machineState) check() error {
+
+	if state.Platform < PlatformChip8 || state.Platform > PlatformMegaChip {
+		return fmt.Errorf("%w: unknown platform %d", ErrInvalidState, state.Platform)
+	}
+
+	if int(state.SP) > len(state.Stack) {
+		return fmt.Errorf("%w: stack pointer %d, the stack holds %d addresses", ErrInvalidState, state.SP, len(state.Stack))
+	}
+
+	size := state.Platform.memorySize()
+	addresses := []struct {
+		name  string
+		value uint32
+	}{
+		{"PC", uint32(state.PC)},
+		{"I", state.I},
+		{"load address", uint32(state.LoadAddress)},
+		{"entry point", uint32(state.EntryPoint)},
+	}
+	for _, a := range addresses {
+		if int(a.value) >= size {
+			return fmt.Errorf("%w: %s 0x%X past the %d bytes of memory of %v", ErrInvalidState, a.name, a.value, size, state.Platform)
+		}
+	}
+
+	if state.Planes > 3 {
+		return fmt.Errorf("%w: planes %d, there are 2", ErrInvalidState, state.Planes)
+	}
+
+	if int(state.KeyWait) >= len(state.Keypad) {
+		return fmt.Errorf("%w: waiting for key %d", ErrInvalidState, state.KeyWait)
+	}
+
+	if state.MemoryPolicy != MemoryStrict && state.MemoryPolicy != MemoryWrap {
+		return fmt.Errorf("%w: unknown memory policy %d", ErrInvalidState, state.MemoryPolicy)
+	}
+
+	if state.RNGDefault && state.RNGDraws > max_rng_draws {
+		return fmt.Errorf("%w: %d random draws, at most %d are replayed", ErrInvalidState, state.RNGDraws, max_rng_draws)
+	}
+
+	return nil
+
+}
+
+// restore overwrites the machine with a previously taken snapshot, once checked: an invalid
+// state is rejected and the machine left unchanged. Any fetched but not executed instruction
+// is discarded.
+func (chip *Chip8) restore(state machineState) error {
+
+	if err := state.check(); err != nil {
+		return err
+	}
+
+	chip.registers = state.Registers
+	chip.program_counter = state.PC
+	chip.index_register = state.I
+	chip.stack = state.Stack
+	chip.stack_pointer = state.SP
+	chip.delay_timer = state.DelayTimer
+	chip.sound_timer = state.SoundTimer
+	chip.timer_elapsed = state.TimerElapsed
+	chip.setPlatform(state.Platform)
+	chip.memory = state.Memory
+	chip.invalidateAllDecoded()
+	if chip.mega_memory != nil && state.MegaMemory != nil {
+		copy(chip.mega_memory, state.MegaMemory)
+	}
+	if chip.mega != nil && state.Mega != nil {
+		chip.mega = state.Mega.clone()
+	}
+	chip.load_address = state.LoadAddress
+	chip.entry_point = state.EntryPoint
+	chip.display = state.Display
+	chip.display_dirty = true
+	chip.hires = state.HiRes
+	chip.rpl = state.RPL
+	chip.planes = state.Planes
+	chip.pattern = state.Pattern
+	chip.pitch = state.Pitch
+	chip.keypad = state.Keypad
+	chip.key_wait = state.KeyWait
+	chip.key_waiting = state.KeyWaiting
+	chip.awaiting_key = state.AwaitingKey
+	chip.vblank = state.VBlank
+	chip.cycle_count = state.CycleCount
+
+	// The default random source continues where it was, a custom one is left alone.
+	if state.RNGDefault {
+		chip.SetSeed(state.RNGSeed)
+		for ; chip.rng_draws < state.RNGDraws; chip.rng_draws++ {
+			chip.rng.Uint32()
+		}
+	}
+
+	chip.single_key = state.SingleKey
+	chip.shift_quirk = state.ShiftQuirk
+	chip.index_increment_quirk = state.IndexQuirk
+	chip.index_overflow_quirk = state.OverflowQuirk
+	chip.vf_reset_quirk = state.VFResetQuirk
+	chip.jump_quirk = state.JumpQuirk
+	chip.wrap_quirk = state.WrapQuirk
+	chip.vertical_wrap = state.VerticalWrap
+	chip.display_wait = state.DisplayWait
+	chip.memory_policy = state.MemoryPolicy
+	chip.protect_memory = state.ProtectMemory
+	chip.strict = state.Strict
+	chip.quirks_set = map[string]bool{}
+	for quirk, set := range state.QuirksSet {
+		chip.quirks_set[quirk] = set
+	}
+	chip.clock_hz = state.ClockHz
+	chip.clock_override = state.ClockOverride
+
+	chip.has_fetched = false
+
+	return nil
+
+}
+
+// SaveState writes the complete machine state to w in a versioned format that LoadState can
+// restore: memory, registers, stack, timers, display, keypad, random source and configuration.
+func (chip *Chip8) SaveState(w io.Writer) error {
+
+	header := append(append([]byte(nil), state_magic...), state_version)
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(w).Encode(chip.snapshot()); err != nil {
+		return fmt.Errorf("could not encode state: %w", err)
+	}
+
+	chip.log(slog.LevelInfo, "state saved", hexAttr("pc", chip.program_counter))
+
+	return nil
+
+}
+
+// LoadState restores a machine state written by SaveState. Data with another version, that is
+// truncated or that holds an impossible state, e.g. PC past the end of memory, is rejected with
+// an error wrapping ErrInvalidState, in which case the machine is left unchanged.
+func (chip *Chip8) LoadState(r io.Reader) error {
+
+	header := make([]byte, len(state_magic)+1)
+
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header[:len(state_magic)], state_magic) {
+		return fmt.Errorf("%w: bad header", ErrInvalidState)
+	}
+
+	if version := header[len(state_magic)]; version != state_version {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidState, version)
+	}
+
+	var state machineState
+
+	if err := gob.NewDecoder(r).Decode(&state); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidState, err)
+	}
+
+	if err := chip.restore(state); err != nil {
+		return err
+	}
+
+	chip.log(slog.LevelInfo, "state loaded", hexAttr("pc", chip.program_counter))
+
+	return nil
+
+}
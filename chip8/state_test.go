@@ -0,0 +1,206 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"testing"
+)
+
+// state_test_rom draws random sprites with random keys held, using every part of the state that
+// the next instructions depend on: V registers, I, the stack, timers and the random source.
+var state_test_rom = []byte{
+	0x22, 0x06, // 200: call 206
+	0x12, 0x00, // 202: jump 200
+	0x00, 0x00,
+	0xC0, 0x3F, // 206: V0 = random & 3F
+	0xC1, 0x1F, // 208: V1 = random & 1F
+	0xF2, 0x07, // 20A: V2 = DT
+	0x32, 0x00, // 20C: skip unless V2 == 0
+	0x60, 0x00, // 20E: V0 = 0
+	0xF0, 0x15, // 210: DT = V0
+	0xF0, 0x29, // 212: I = font of V0
+	0xD0, 0x15, // 214: draw
+	0x00, 0xEE, // 216: return
+}
+
+// runFrames runs frames of 10 instructions and a timer tick, returning the display after each.
+func runFrames(t *testing.T, chip *Chip8, frames int) []Frame {
+
+	t.Helper()
+
+	var displays []Frame
+	for range frames {
+		for range 10 {
+			if err := chip.Cycle(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		chip.DecrementTimers()
+		displays = append(displays, chip.Display())
+	}
+
+	return displays
+
+}
+
+func TestSaveLoadState(t *testing.T) {
+
+	chip := New()
+	chip.SetSeed(42)
+	if err := chip.LoadROMBytes(state_test_rom); err != nil {
+		t.Fatal(err)
+	}
+	runFrames(t, chip, 20)
+
+	var saved bytes.Buffer
+	if err := chip.SaveState(&saved); err != nil {
+		t.Fatal(err)
+	}
+	want := runFrames(t, chip, 30)
+
+	// Loaded into a new machine, the state runs exactly as the original did.
+	restored := New()
+	if err := restored.LoadState(bytes.NewReader(saved.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	got := runFrames(t, restored, 30)
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("frame %d after loading differs:\n%s\nwant:\n%s", i, got[i].Text(), want[i].Text())
+		}
+	}
+	if restored.State() != chip.State() {
+		t.Errorf("CPU after loading %+v, want %+v", restored.State(), chip.State())
+	}
+
+}
+
+func TestLoadStateRejectsTruncatedData(t *testing.T) {
+
+	chip := New()
+	var saved bytes.Buffer
+	if err := chip.SaveState(&saved); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{0, 5, len(state_magic) + 1, saved.Len() / 2} {
+		err := New().LoadState(bytes.NewReader(saved.Bytes()[:n]))
+		if !errors.Is(err, ErrInvalidState) {
+			t.Errorf("%d bytes of %d: got %v, want ErrInvalidState", n, saved.Len(), err)
+		}
+	}
+
+	// Another version is rejected as well.
+	data := bytes.Clone(saved.Bytes())
+	data[len(state_magic)]++
+	if err := New().LoadState(bytes.NewReader(data)); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("version %d: got %v, want ErrInvalidState", data[len(state_magic)], err)
+	}
+
+}
+
+func TestRestoreChecksState(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		corrupt func(state *machineState)
+	}{
+		{"stack pointer past the stack", func(state *machineState) { state.SP = 17 }},
+		{"PC past memory", func(state *machineState) { state.PC = memory_size }},
+		{"I past memory", func(state *machineState) { state.I = memory_size + 0x10 }},
+		{"I past XO-CHIP memory", func(state *machineState) {
+			state.Platform = PlatformXOChip
+			state.I = xo_memory_size
+		}},
+		{"entry point past memory", func(state *machineState) { state.EntryPoint = 0xFFFF }},
+		{"planes past the 2 of XO-CHIP", func(state *machineState) { state.Planes = 4 }},
+		{"unknown platform", func(state *machineState) { state.Platform = PlatformMegaChip + 1 }},
+		{"negative platform", func(state *machineState) { state.Platform = -1 }},
+		{"waiting for key 16", func(state *machineState) { state.KeyWait = 16 }},
+		{"random draws past the limit", func(state *machineState) {
+			state.RNGDefault, state.RNGDraws = true, max_rng_draws+1
+		}},
+	}
+
+	for _, test := range tests {
+
+		chip := New()
+		if err := chip.LoadROMBytes(state_test_rom); err != nil {
+			t.Fatal(err)
+		}
+		runFrames(t, chip, 3)
+		before := chip.snapshot()
+
+		state := chip.snapshot()
+		test.corrupt(&state)
+
+		if err := chip.restore(state); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("%s: got %v, want ErrInvalidState", test.name, err)
+		}
+		if after := chip.snapshot(); after.PC != before.PC || after.SP != before.SP || after.I != before.I ||
+			after.Platform != before.Platform || after.Planes != before.Planes {
+			t.Errorf("%s: the rejected state changed the machine", test.name)
+		}
+	}
+
+	// The limits themselves are valid: a full stack, the last address of memory, both planes.
+	chip := New()
+	state := chip.snapshot()
+	state.SP, state.PC, state.I, state.Planes = 16, memory_size-1, memory_size-1, 3
+	if err := chip.restore(state); err != nil {
+		t.Errorf("valid state at the limits rejected: %v", err)
+	}
+
+}
+
+// encodeState writes state as SaveState would, whether it is valid or not.
+func encodeState(t *testing.T, state machineState) []byte {
+
+	t.Helper()
+
+	var b bytes.Buffer
+	b.Write(state_magic)
+	b.WriteByte(state_version)
+	if err := gob.NewEncoder(&b).Encode(state); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+
+}
+
+func TestLoadStateRejectsCorruptedState(t *testing.T) {
+
+	chip := New()
+	if err := chip.LoadROMBytes(state_test_rom); err != nil {
+		t.Fatal(err)
+	}
+	runFrames(t, chip, 3)
+	before := chip.State()
+
+	corrupted := chip.snapshot()
+	corrupted.PC = 0x2000
+	corrupted.SP = 0xFF
+	corrupted.Registers[0] = 0x99
+
+	err := chip.LoadState(bytes.NewReader(encodeState(t, corrupted)))
+	if !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("got %v, want ErrInvalidState", err)
+	}
+	if chip.State() != before {
+		t.Errorf("the corrupted state changed the machine: %+v, was %+v", chip.State(), before)
+	}
+
+	// The machine still runs.
+	runFrames(t, chip, 3)
+
+	// A huge count of random draws is rejected rather than replayed.
+	corrupted = chip.snapshot()
+	corrupted.RNGDefault, corrupted.RNGDraws = true, 1<<62
+	if err := chip.LoadState(bytes.NewReader(encodeState(t, corrupted))); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("%d random draws: got %v, want ErrInvalidState", corrupted.RNGDraws, err)
+	}
+
+}
@@ -0,0 +1,52 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrQuirkUnset is returned in strict mode when a quirk-dependent opcode is executed
+// without the corresponding quirk having been configured explicitly.
+var ErrQuirkUnset = errors.New("quirk not configured")
+
+// QuirkError reports which quirk an opcode depended on in strict mode.
+type QuirkError struct {
+	Opcode uint16
+	Quirk  string
+}
+
+func (e *QuirkError) Error() string {
+	return fmt.Sprintf("opcode 0x%04X depends on the %s quirk: %v", e.Opcode, e.Quirk, ErrQuirkUnset)
+}
+
+func (e *QuirkError) Unwrap() error {
+	return ErrQuirkUnset
+}
+
+// SetStrict enables or disables strict mode. In strict mode, executing an opcode whose behavior
+// depends on an unconfigured quirk returns a QuirkError instead of silently using the default.
+func (chip *Chip8) SetStrict(enabled bool) {
+	chip.strict = enabled
+}
+
+// configureQuirk records that a quirk was chosen explicitly. Quirk setters must call it.
+func (chip *Chip8) configureQuirk(quirk string) {
+
+	if chip.quirks_set == nil {
+		chip.quirks_set = map[string]bool{}
+	}
+
+	chip.quirks_set[quirk] = true
+
+}
+
+// requireQuirk returns a QuirkError in strict mode if the quirk was never configured.
+func (chip *Chip8) requireQuirk(quirk string, in Instruction) error {
+
+	if chip.strict && !chip.quirks_set[quirk] {
+		return &QuirkError{Opcode: in.Opcode, Quirk: quirk}
+	}
+
+	return nil
+
+}
@@ -0,0 +1,250 @@
+package chip8
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// TelemetryServer streams the state of a running machine over WebSocket, one JSON message per
+// frame, for dashboards and teaching tools to show its internals live, e.g. in a browser.
+// Publish sends the state of each frame to every client:
+//
+//	{"frame": 120, "cycle": 8000, "width": 64, "height": 32, "full": false,
+//	 "rows": {"5": "0001110000..."}, "registers": {"pc": 520, "i": 554, "v": [...], ...}}
+//
+// The display is sent as the rows that changed since the previous frame, one digit per pixel:
+// 0 off, 1 on in the first plane, 2 in the second XO-CHIP plane and 3 in both. A client gets
+// every row, with "full" set, in its first message and after dropped messages. Registers are
+// as in DebugRegisters. A GET without the WebSocket upgrade serves a page drawing the stream.
+type TelemetryServer struct {
+	// Guards clients
+	mu      sync.Mutex
+	clients map[*telemetryClient]bool
+
+	// Display of the last frame published, the rows are sent against it, and frames published
+	frame     Frame
+	published uint64
+}
+
+// telemetryClient is a connected client, and the messages queued for it.
+type telemetryClient struct {
+	send chan []byte
+
+	// Set when the next message has to carry the whole display
+	full bool
+}
+
+// Messages queued per client, a slow client misses the frames sent meanwhile.
+const telemetry_queue = 8
+
+// TelemetryFrame is a message of a TelemetryServer.
+type TelemetryFrame struct {
+	Frame  uint64 `json:"frame"`
+	Cycle  uint64 `json:"cycle"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+
+	// Full - whether rows holds the whole display, not only the rows that changed
+	Full bool `json:"full"`
+
+	// Rows - the rows of pixels by y, one digit per pixel
+	Rows map[string]string `json:"rows"`
+
+	Registers DebugRegisters `json:"registers"`
+}
+
+// NewTelemetryServer returns a telemetry server without clients.
+func NewTelemetryServer() *TelemetryServer {
+	return &TelemetryServer{clients: map[*telemetryClient]bool{}}
+}
+
+// ServeHTTP connects a WebSocket client to the stream, or serves the telemetry page.
+func (s *TelemetryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	conn, err := acceptWebSocket(w, r)
+	if err == errNotWebSocket {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(telemetry_page))
+		return
+	}
+	if err == errCrossOrigin {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer conn.Close()
+
+	client := &telemetryClient{send: make(chan []byte, telemetry_queue), full: true}
+
+	s.mu.Lock()
+	s.clients[client] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, client)
+		s.mu.Unlock()
+	}()
+
+	// The client is gone once its reads end.
+	done := make(chan struct{})
+	go func() {
+		conn.readLoop()
+		close(done)
+	}()
+
+	for {
+		select {
+		case msg := <-client.send:
+			if err := conn.WriteText(msg); err != nil {
+				return
+			}
+		case <-done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+}
+
+// Publish sends the state of chip to the clients, it is called once per frame on the goroutine
+// running the machine, e.g. from OnFrame. It does nothing without clients, and never blocks:
+// a client whose queue is full misses the frame.
+func (s *TelemetryServer) Publish(chip *Chip8) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	frame := chip.Display()
+	previous := s.frame
+	s.frame = frame
+	s.published++
+
+	if len(s.clients) == 0 {
+		return
+	}
+
+	msg := TelemetryFrame{
+		Frame:     s.published,
+		Cycle:     chip.cycle_count,
+		Width:     frame.Width,
+		Height:    frame.Height,
+		Registers: chip.debugRegisters(),
+	}
+
+	// Messages are encoded once for all the clients, with and without the whole display.
+	var diff, full []byte
+	encode := func(all bool) []byte {
+		msg.Full, msg.Rows = all, telemetryRows(&frame, &previous, all)
+		data, err := json.Marshal(msg)
+		if err != nil {
+			chip.log(slog.LevelError, "could not encode telemetry", "err", err)
+		}
+		return data
+	}
+
+	for client := range s.clients {
+
+		var data []byte
+		if client.full {
+			if full == nil {
+				full = encode(true)
+			}
+			data = full
+		} else {
+			if diff == nil {
+				diff = encode(false)
+			}
+			data = diff
+		}
+
+		select {
+		case client.send <- data:
+			client.full = false
+		default:
+			client.full = true
+		}
+	}
+
+}
+
+// telemetryRows returns the rows of frame that differ from previous, all of them if all is set
+// or the size changed.
+func telemetryRows(frame, previous *Frame, all bool) map[string]string {
+
+	all = all || frame.Width != previous.Width || frame.Height != previous.Height
+
+	rows := map[string]string{}
+	line := make([]byte, frame.Width)
+
+	for y := range frame.Height {
+
+		changed := all
+		for x := range frame.Width {
+			pixel := frame.Pixel(x, y)
+			changed = changed || pixel != previous.Pixel(x, y)
+			line[x] = '0' + pixel
+		}
+
+		if changed {
+			rows[strconv.Itoa(y)] = string(line)
+		}
+	}
+
+	return rows
+
+}
+
+// telemetry_page draws the stream of the server it is served from.
+const telemetry_page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>CHIP-8 telemetry</title>
+<style>
+body { background: #111; color: #ddd; font: 14px monospace; }
+canvas { image-rendering: pixelated; width: 640px; border: 1px solid #444; }
+</style>
+</head>
+<body>
+<canvas id="display" width="64" height="32"></canvas>
+<pre id="registers">connecting...</pre>
+<script>
+const canvas = document.getElementById("display");
+const ctx = canvas.getContext("2d");
+const registers = document.getElementById("registers");
+const colors = ["#000000", "#ffffff", "#ff5555", "#ffff55"];
+const hex = (n, digits) => n.toString(16).toUpperCase().padStart(digits, "0");
+
+const ws = new WebSocket("ws://" + location.host + location.pathname);
+ws.onclose = () => registers.textContent = "disconnected";
+ws.onmessage = (event) => {
+	const msg = JSON.parse(event.data);
+	if (canvas.width != msg.width || canvas.height != msg.height) {
+		canvas.width = msg.width;
+		canvas.height = msg.height;
+	}
+	for (const [y, row] of Object.entries(msg.rows)) {
+		for (let x = 0; x < row.length; x++) {
+			ctx.fillStyle = colors[row.charCodeAt(x) - 48];
+			ctx.fillRect(x, Number(y), 1, 1);
+		}
+	}
+	const r = msg.registers;
+	registers.textContent =
+		"frame " + msg.frame + "  cycle " + msg.cycle + "  " + r.state + "\n" +
+		"PC=" + hex(r.pc, 4) + " I=" + hex(r.i, 4) + " SP=" + r.sp + " DT=" + r.dt + " ST=" + r.st + "\n" +
+		r.v.map((v, i) => "V" + hex(i, 1) + "=" + hex(v, 2)).join(" ") + "\n" +
+		"stack: " + r.stack.slice(0, r.sp).map((a) => hex(a, 4)).join(" ");
+};
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,80 @@
+package chip8
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+	"strings"
+)
+
+// Themes are named palettes. The two colors of the XO-CHIP planes are picked to stay
+// distinguishable from the foreground and from each other.
+var Themes = map[string]Palette{
+	"default": DefaultPalette,
+
+	// Green phosphor of a monochrome monitor.
+	"green": {
+		Foreground: rgb(0x33, 0xFF, 0x66),
+		Background: rgb(0x00, 0x11, 0x00),
+		Border:     rgb(0x00, 0x11, 0x00),
+		Plane2:     rgb(0x22, 0x99, 0x44),
+		Overlap:    rgb(0xAA, 0xFF, 0xBB),
+	},
+
+	// Amber phosphor.
+	"amber": {
+		Foreground: rgb(0xFF, 0xB0, 0x00),
+		Background: rgb(0x1A, 0x0F, 0x00),
+		Border:     rgb(0x1A, 0x0F, 0x00),
+		Plane2:     rgb(0xAA, 0x66, 0x00),
+		Overlap:    rgb(0xFF, 0xE0, 0x99),
+	},
+
+	// Dark pixels on the green gray of a handheld LCD.
+	"lcd": {
+		Foreground: rgb(0x0F, 0x38, 0x0F),
+		Background: rgb(0x9B, 0xBC, 0x0F),
+		Border:     rgb(0x8B, 0xAC, 0x0F),
+		Plane2:     rgb(0x6B, 0x8C, 0x0F),
+		Overlap:    rgb(0x30, 0x62, 0x30),
+	},
+
+	// The colors of the Octo IDE, where most XO-CHIP programs are written.
+	"octo": {
+		Foreground: rgb(0xFF, 0xCC, 0x00),
+		Background: rgb(0x99, 0x66, 0x00),
+		Border:     rgb(0x99, 0x66, 0x00),
+		Plane2:     rgb(0xFF, 0x66, 0x00),
+		Overlap:    rgb(0x66, 0x22, 0x00),
+	},
+}
+
+// Theme returns the palette of a theme, by its case-insensitive name.
+func Theme(name string) (Palette, error) {
+
+	palette, ok := Themes[strings.ToLower(name)]
+	if !ok {
+		return Palette{}, fmt.Errorf("unknown theme %q, want one of %s", name, strings.Join(ThemeNames(), ", "))
+	}
+
+	return palette, nil
+
+}
+
+// ThemeNames returns the names of the themes, sorted.
+func ThemeNames() []string {
+
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+
+}
+
+// rgb returns an opaque color.
+func rgb(r, g, b uint8) color.RGBA {
+	return color.RGBA{r, g, b, 0xFF}
+}
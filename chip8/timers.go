@@ -0,0 +1,65 @@
+package chip8
+
+import "time"
+
+// Period of the 60Hz delay and sound timers.
+const timer_period = time.Second / 60
+
+// DecrementTimers decrements each non-zero timer by one. It is meant to be called at 60Hz;
+// timers saturate at zero so calling it too often never wraps them around.
+// OnSound is called when the beep starts or stops.
+func (chip *Chip8) DecrementTimers() {
+
+	chip.tickSample()
+	chip.updateSound()
+
+	if chip.delay_timer > 0 {
+		chip.delay_timer--
+	}
+
+	if chip.sound_timer > 0 {
+		chip.sound_timer--
+	}
+
+	// A new frame begins, a DXYN waiting for the vertical blank can draw.
+	chip.vblank = true
+
+}
+
+// updateSound reports the beep starting or stopping to OnSound. It sounds for every tick the
+// sound timer starts non-zero, and while a Megachip digitised sound plays.
+func (chip *Chip8) updateSound() {
+
+	playing := chip.sound_timer > 0 || chip.mega != nil && chip.mega.Sample.Playing
+
+	if playing != chip.sound_playing {
+		chip.sound_playing = playing
+		if chip.OnSound != nil {
+			chip.OnSound(playing)
+		}
+	}
+
+}
+
+// TickTimers advances the timers by the given elapsed time, decrementing them once per
+// elapsed 60Hz period. Time shorter than a period is carried over to the next call,
+// so calling it faster than 60Hz is harmless.
+func (chip *Chip8) TickTimers(elapsed time.Duration) {
+
+	for range chip.elapseTimers(elapsed) {
+		chip.DecrementTimers()
+	}
+
+}
+
+// elapseTimers adds elapsed to the time carried over since the last timer tick and returns how
+// many 60Hz ticks are due, keeping the rest for the next call.
+func (chip *Chip8) elapseTimers(elapsed time.Duration) int {
+
+	chip.timer_elapsed += elapsed
+	ticks := int(chip.timer_elapsed / timer_period)
+	chip.timer_elapsed -= time.Duration(ticks) * timer_period
+
+	return ticks
+
+}
@@ -0,0 +1,141 @@
+package chip8
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTimerMillis(t *testing.T) {
+
+	tests := []struct {
+		value uint8
+		want  int
+	}{
+		{0, 0},
+		{1, 16},
+		{3, 50},
+		{60, 1000},
+		{255, 4250},
+	}
+
+	for _, test := range tests {
+
+		chip := New()
+		chip.delay_timer, chip.sound_timer = test.value, test.value
+
+		if got := chip.DelayTimerMillis(); got != test.want {
+			t.Errorf("delay timer %d: %d ms, want %d", test.value, got, test.want)
+		}
+		if got := chip.SoundTimerMillis(); got != test.want {
+			t.Errorf("sound timer %d: %d ms, want %d", test.value, got, test.want)
+		}
+	}
+
+}
+
+func TestDecrementTimersSaturates(t *testing.T) {
+
+	chip := New()
+	chip.delay_timer, chip.sound_timer = 3, 2
+
+	for range 1000 {
+		chip.DecrementTimers()
+	}
+
+	if delay, sound := chip.Timers(); delay != 0 || sound != 0 {
+		t.Errorf("timers at %d and %d after decrementing past 0, want 0", delay, sound)
+	}
+
+}
+
+func TestTickTimersFollowsElapsedTime(t *testing.T) {
+
+	chip := New()
+	chip.delay_timer, chip.sound_timer = 100, 5
+
+	// A second in steps of a millisecond, far faster than 60Hz, is 60 ticks.
+	for range 1000 {
+		chip.TickTimers(time.Millisecond)
+	}
+
+	if delay, sound := chip.Timers(); delay != 40 || sound != 0 {
+		t.Errorf("timers at %d and %d after a second, want 40 and 0", delay, sound)
+	}
+
+}
+
+func TestRunTicksTimersAt60Hz(t *testing.T) {
+
+	chip := New()
+	if err := chip.LoadROMBytes([]byte{0x12, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	chip.delay_timer = 100
+	chip.SetFrameRate(120)
+
+	clock := NewManualClock(time.Time{})
+	chip.SetClock(clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- chip.Run(ctx, nil) }()
+
+	clock.WaitTickers(1)
+	clock.Advance(time.Second)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// 120 frames of a second tick the timers 60 times.
+	if delay, _ := chip.Timers(); delay != 40 {
+		t.Errorf("delay timer at %d after a second, want 40", delay)
+	}
+
+}
+
+func TestOnSound(t *testing.T) {
+
+	// LD V0, 3; LD ST, V0; JP self
+	chip := New()
+	if err := chip.LoadROMBytes([]byte{0x60, 0x03, 0xF0, 0x18, 0x12, 0x04}); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []bool
+	chip.OnSound = func(playing bool) { events = append(events, playing) }
+
+	if err := chip.RunCycles(2); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("OnSound called %v before a timer tick", events)
+	}
+
+	// The beep starts on the first tick and sounds for the 3 ticks the timer is non-zero.
+	for tick := 1; tick <= 3; tick++ {
+		chip.DecrementTimers()
+		if !slices.Equal(events, []bool{true}) {
+			t.Fatalf("tick %d: OnSound called %v, want [true]", tick, events)
+		}
+	}
+
+	chip.DecrementTimers()
+	if !slices.Equal(events, []bool{true, false}) {
+		t.Fatalf("OnSound called %v once the timer ran out, want [true false]", events)
+	}
+
+	// A reset stops a beep that plays.
+	chip.Reset()
+	if err := chip.RunCycles(2); err != nil {
+		t.Fatal(err)
+	}
+	chip.DecrementTimers()
+	chip.Reset()
+	if !slices.Equal(events, []bool{true, false, true, false}) {
+		t.Errorf("OnSound called %v, want the beep stopped by the reset", events)
+	}
+
+}
@@ -0,0 +1,229 @@
+package chip8
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the time source of Run: it paces the instructions and the 60Hz timers. SystemClock
+// is the wall clock, a ManualClock only moves when told to, for tests and tools stepping
+// through time without sleeping.
+type Clock interface {
+	Now() time.Time
+
+	// NewTicker returns a ticker sending the time on its channel every period.
+	NewTicker(period time.Duration) Ticker
+}
+
+// Ticker delivers the ticks of a Clock, like time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Reset(period time.Duration)
+	Stop()
+}
+
+// SystemClock is the wall clock, the clock of a new machine.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(period time.Duration) Ticker {
+	return systemTicker{time.NewTicker(period)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// SetClock sets the clock Run paces the machine with, SystemClock if nil.
+func (chip *Chip8) SetClock(clock Clock) {
+	chip.clock = clock
+}
+
+// timeSource returns the clock of the machine.
+func (chip *Chip8) timeSource() Clock {
+
+	if chip.clock == nil {
+		return SystemClock
+	}
+
+	return chip.clock
+
+}
+
+// tickHandled tells a ManualClock that Run is done with a tick of ticker.
+func tickHandled(ticker Ticker) {
+	if t, ok := ticker.(*manualTicker); ok {
+		t.handled <- struct{}{}
+	}
+}
+
+// ManualClock is a Clock that only moves with Advance. Its ticks are handed to Run one at a
+// time, in order, and Advance returns once Run handled them all, so after Advance the machine
+// is exactly where it would be after that much real time:
+//
+//	clock := chip8.NewManualClock(time.Time{})
+//	chip.SetClock(clock)
+//	go chip.Run(ctx, nil)
+//	clock.WaitTickers(1)
+//	clock.Advance(time.Second / 60) // one timer tick and its instructions
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*manualTicker
+
+	// Signalled when a ticker is created, for WaitTickers.
+	changed chan struct{}
+}
+
+// NewManualClock returns a manual clock reading start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start, changed: make(chan struct{}, 1)}
+}
+
+// manualTicker is a ticker of a ManualClock.
+type manualTicker struct {
+	clock  *ManualClock
+	period time.Duration
+	next   time.Time
+
+	c       chan time.Time
+	handled chan struct{}
+	stopped chan struct{}
+	stop    sync.Once
+}
+
+func (c *ManualClock) Now() time.Time {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+
+}
+
+func (c *ManualClock) NewTicker(period time.Duration) Ticker {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &manualTicker{
+		clock:   c,
+		period:  period,
+		next:    c.now.Add(period),
+		c:       make(chan time.Time),
+		handled: make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	c.tickers = append(c.tickers, t)
+
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+
+	return t
+
+}
+
+// WaitTickers blocks until n tickers are running, e.g. until Run started.
+func (c *ManualClock) WaitTickers(n int) {
+
+	for {
+		c.mu.Lock()
+		running := len(c.tickers)
+		c.mu.Unlock()
+
+		if running >= n {
+			return
+		}
+		<-c.changed
+	}
+
+}
+
+// Advance moves the clock forward by d, delivering every tick falling due on the way in
+// chronological order. Each delivery waits until the tick is received and handled, or its
+// ticker stopped.
+func (c *ManualClock) Advance(d time.Duration) {
+
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+
+		var due *manualTicker
+		for _, t := range c.tickers {
+			if !t.next.After(end) && (due == nil || t.next.Before(due.next)) {
+				due = t
+			}
+		}
+
+		if due == nil {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+
+		c.now = due.next
+		due.next = due.next.Add(due.period)
+		now := c.now
+
+		c.mu.Unlock()
+
+		select {
+		case due.c <- now:
+			select {
+			case <-due.handled:
+			case <-due.stopped:
+			}
+		case <-due.stopped:
+		}
+	}
+
+}
+
+func (t *manualTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTicker) Reset(period time.Duration) {
+
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.period = period
+	t.next = t.clock.now.Add(period)
+
+}
+
+// Stop removes the ticker from its clock, a pending delivery is dropped.
+func (t *manualTicker) Stop() {
+
+	t.stop.Do(func() {
+
+		close(t.stopped)
+
+		t.clock.mu.Lock()
+		defer t.clock.mu.Unlock()
+
+		for i, other := range t.clock.tickers {
+			if other == t {
+				t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+				break
+			}
+		}
+
+	})
+
+}
@@ -0,0 +1,123 @@
+package chip8
+
+import "math"
+
+// DefaultToneHz is the pitch of the beep when none is configured.
+const DefaultToneHz = 440
+
+// Tone generates the square wave frontends play while the sound timer is non-zero.
+type Tone struct {
+	// Frequency of the wave in Hz.
+	Frequency float64
+
+	// Volume from 0 (silent) to 1 (full scale).
+	Volume float64
+
+	// Position in the current period, from 0 to 1.
+	phase float64
+
+	// XO-CHIP audio pattern played instead of the square wave, at rate samples per second.
+	pattern     [16]byte
+	rate        float64
+	has_pattern bool
+
+	// Position in the pattern, from 0 to 128 samples.
+	position float64
+
+	// Megachip digitised sound played instead of the others, 8-bit unsigned samples at
+	// sample_rate samples per second, from sample_position.
+	samples         []byte
+	sample_rate     float64
+	sample_loop     bool
+	sample_position float64
+}
+
+// SetSamples makes the tone play a Megachip digitised sound, as returned by
+// Chip8.DigitisedSound, from its start. Without samples the tone goes back to the square wave
+// or audio pattern.
+func (t *Tone) SetSamples(samples []byte, rate float64, loop bool) {
+	t.samples = samples
+	t.sample_rate = rate
+	t.sample_loop = loop
+	t.sample_position = 0
+}
+
+// SetPattern makes the tone play an XO-CHIP audio pattern, as returned by Chip8.AudioPattern,
+// instead of the square wave.
+func (t *Tone) SetPattern(pattern [16]byte, rate float64) {
+	t.pattern = pattern
+	t.rate = rate
+	t.has_pattern = true
+}
+
+// NewTone returns a tone at the given pitch and volume. A pitch of 0 means DefaultToneHz,
+// the volume is clamped to [0, 1].
+func NewTone(frequency, volume float64) *Tone {
+
+	if frequency <= 0 {
+		frequency = DefaultToneHz
+	}
+
+	return &Tone{Frequency: frequency, Volume: math.Max(0, math.Min(volume, 1))}
+
+}
+
+// Samples fills buf with signed 16-bit mono samples at sampleRate samples per second.
+// Consecutive calls continue the wave where the previous one stopped, so buffers can be
+// queued back to back without clicks.
+func (t *Tone) Samples(buf []int16, sampleRate int) {
+
+	amplitude := int16(t.Volume * math.MaxInt16)
+
+	// A digitised sound that does not loop is silent past its end.
+	if len(t.samples) > 0 {
+		step := t.sample_rate / float64(sampleRate)
+
+		for i := range buf {
+			s := int(t.sample_position)
+			if s >= len(t.samples) {
+				buf[i] = 0
+				continue
+			}
+			buf[i] = int16((int(t.samples[s]) - 0x80) * int(amplitude) / 0x80)
+
+			t.sample_position += step
+			if t.sample_loop {
+				t.sample_position = math.Mod(t.sample_position, float64(len(t.samples)))
+			}
+		}
+
+		return
+	}
+
+	if t.has_pattern {
+		step := t.rate / float64(sampleRate)
+
+		for i := range buf {
+			bit := int(t.position)
+			if t.pattern[bit/8]&(0x80>>(bit%8)) != 0 {
+				buf[i] = amplitude
+			} else {
+				buf[i] = -amplitude
+			}
+
+			t.position = math.Mod(t.position+step, 128)
+		}
+
+		return
+	}
+
+	step := t.Frequency / float64(sampleRate)
+
+	for i := range buf {
+		if t.phase < 0.5 {
+			buf[i] = amplitude
+		} else {
+			buf[i] = -amplitude
+		}
+
+		t.phase += step
+		t.phase -= math.Floor(t.phase)
+	}
+
+}
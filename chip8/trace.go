@@ -0,0 +1,191 @@
+package chip8
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"chip8-go/chip8/decode"
+)
+
+// TraceEntry records an executed instruction and the registers it changed.
+type TraceEntry struct {
+	PC     uint16
+	Opcode uint16
+
+	// Registers before and after the instruction.
+	Before TraceRegisters
+	After  TraceRegisters
+}
+
+// TraceRegisters are the registers an instruction may change, as recorded in a TraceEntry.
+type TraceRegisters struct {
+	V  [16]byte
+	I  uint32
+	SP uint8
+	DT uint8
+	ST uint8
+}
+
+// String formats the entry as the address, opcode and mnemonic followed by the registers
+// that changed: "0204  7105  ADD V1, 0x05       V1=03->08".
+func (e TraceEntry) String() string {
+
+	var changes []string
+
+	for i := range e.Before.V {
+		if e.Before.V[i] != e.After.V[i] {
+			changes = append(changes, fmt.Sprintf("V%X=%02X->%02X", i, e.Before.V[i], e.After.V[i]))
+		}
+	}
+	if e.Before.I != e.After.I {
+		changes = append(changes, fmt.Sprintf("I=%04X->%04X", e.Before.I, e.After.I))
+	}
+	if e.Before.SP != e.After.SP {
+		changes = append(changes, fmt.Sprintf("SP=%02X->%02X", e.Before.SP, e.After.SP))
+	}
+	if e.Before.DT != e.After.DT {
+		changes = append(changes, fmt.Sprintf("DT=%02X->%02X", e.Before.DT, e.After.DT))
+	}
+	if e.Before.ST != e.After.ST {
+		changes = append(changes, fmt.Sprintf("ST=%02X->%02X", e.Before.ST, e.After.ST))
+	}
+
+	text := fmt.Sprintf("%04X  %04X  %-18s", e.PC, e.Opcode, decode.Decode(e.Opcode))
+
+	return strings.TrimRight(text+" "+strings.Join(changes, " "), " ")
+
+}
+
+// TraceFilter selects the trace entries worth logging.
+type TraceFilter struct {
+	// Classes are opcode classes: a single hex digit matches the first digit of the opcode
+	// ("D" for every DXYN), anything else the mnemonic ("DRW", "LD"). Empty matches every opcode.
+	Classes []string
+
+	// Addresses from From to To, inclusive. A To of 0 matches up to the end of memory.
+	From uint16
+	To   uint16
+}
+
+// ParseTraceFilter builds a filter from a comma-separated list of opcode classes, e.g. "D,CALL",
+// and an address range written "200-2FF" in hexadecimal. Either may be empty to match everything.
+func ParseTraceFilter(classes string, addresses string) (TraceFilter, error) {
+
+	var f TraceFilter
+
+	for _, class := range strings.Split(classes, ",") {
+		if class = strings.ToUpper(strings.TrimSpace(class)); class != "" {
+			f.Classes = append(f.Classes, class)
+		}
+	}
+
+	if addresses != "" {
+		from, to, ok := strings.Cut(addresses, "-")
+		a, err_from := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(from), "0x"), 16, 16)
+		b, err_to := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(to), "0x"), 16, 16)
+		if !ok || err_from != nil || err_to != nil || b < a {
+			return TraceFilter{}, fmt.Errorf("invalid address range %q, want e.g. 200-2FF", addresses)
+		}
+		f.From, f.To = uint16(a), uint16(b)
+	}
+
+	return f, nil
+
+}
+
+// Match reports whether the filter selects the entry.
+func (f TraceFilter) Match(e TraceEntry) bool {
+
+	if e.PC < f.From || (f.To != 0 && e.PC > f.To) {
+		return false
+	}
+
+	if len(f.Classes) == 0 {
+		return true
+	}
+
+	mnemonic := decode.Decode(e.Opcode).Mnemonic
+	prefix := fmt.Sprintf("%X", e.Opcode>>12)
+
+	for _, class := range f.Classes {
+		if class == prefix || class == mnemonic {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+// CycleCount returns the number of instructions executed since the machine was created or reset.
+func (chip *Chip8) CycleCount() uint64 {
+	return chip.cycle_count
+}
+
+// SetTrace enables recording the last size executed instructions, or disables tracing
+// if size is 0. Tracing is off by default. Changing the size discards the recorded trace.
+func (chip *Chip8) SetTrace(size int) {
+
+	chip.trace = nil
+	chip.trace_next = 0
+	chip.trace_full = false
+
+	if size > 0 {
+		chip.trace = make([]TraceEntry, size)
+	}
+
+}
+
+// Trace returns the recorded instructions, oldest first.
+func (chip *Chip8) Trace() []TraceEntry {
+
+	if !chip.trace_full {
+		return append([]TraceEntry(nil), chip.trace[:chip.trace_next]...)
+	}
+
+	entries := make([]TraceEntry, 0, len(chip.trace))
+	entries = append(entries, chip.trace[chip.trace_next:]...)
+	entries = append(entries, chip.trace[:chip.trace_next]...)
+
+	return entries
+
+}
+
+// tracing reports whether executed instructions are recorded or reported.
+func (chip *Chip8) tracing() bool {
+	return chip.trace != nil || chip.OnTrace != nil
+}
+
+// traceRegisters copies the registers recorded in a TraceEntry.
+func (chip *Chip8) traceRegisters() TraceRegisters {
+	return TraceRegisters{
+		V:  chip.registers,
+		I:  chip.index_register,
+		SP: chip.stack_pointer,
+		DT: chip.delay_timer,
+		ST: chip.sound_timer,
+	}
+}
+
+// recordTrace appends an executed instruction to the trace, overwriting the oldest when full,
+// and reports it to OnTrace.
+func (chip *Chip8) recordTrace(entry TraceEntry) {
+
+	if chip.OnTrace != nil {
+		chip.OnTrace(entry)
+	}
+
+	if chip.trace == nil {
+		return
+	}
+
+	chip.trace[chip.trace_next] = entry
+	chip.trace_next++
+
+	if chip.trace_next == len(chip.trace) {
+		chip.trace_next = 0
+		chip.trace_full = true
+	}
+
+}
@@ -0,0 +1,97 @@
+package chip8
+
+import "testing"
+
+// trace_test_rom sets V1 and I, calls a subroutine at 0x208 and loops there.
+var trace_test_rom = []byte{0x61, 0x03, 0x71, 0x05, 0xA3, 0x00, 0x22, 0x08, 0x12, 0x08}
+
+func TestTrace(t *testing.T) {
+
+	chip := New()
+	if err := chip.LoadROMBytes(trace_test_rom); err != nil {
+		t.Fatal(err)
+	}
+	chip.SetTrace(3)
+
+	if err := chip.RunCycles(2); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := chip.Trace()
+	if len(entries) != 2 {
+		t.Fatalf("%d entries after 2 instructions, want 2", len(entries))
+	}
+	if want := "0200  6103  LD V1, 0x03        V1=00->03"; entries[0].String() != want {
+		t.Errorf("first entry %q, want %q", entries[0], want)
+	}
+	if want := "0202  7105  ADD V1, 0x05       V1=03->08"; entries[1].String() != want {
+		t.Errorf("second entry %q, want %q", entries[1], want)
+	}
+
+	// Once full, the oldest entries are overwritten.
+	if err := chip.RunCycles(3); err != nil {
+		t.Fatal(err)
+	}
+
+	entries = chip.Trace()
+	want := []uint16{0x204, 0x206, 0x208}
+	if len(entries) != len(want) {
+		t.Fatalf("%d entries, want %d", len(entries), len(want))
+	}
+	for i, e := range entries {
+		if e.PC != want[i] {
+			t.Errorf("entry %d at %04X, want %04X", i, e.PC, want[i])
+		}
+	}
+	if call := entries[1]; call.Opcode != 0x2208 || call.Before.SP != 0 || call.After.SP != 1 {
+		t.Errorf("CALL entry %+v, want opcode 2208 moving SP from 0 to 1", call)
+	}
+	if chip.CycleCount() != 5 {
+		t.Errorf("cycle count %d, want 5", chip.CycleCount())
+	}
+
+	// Disabling the trace discards it, a reset restarts the count.
+	chip.SetTrace(0)
+	if err := chip.RunCycles(1); err != nil {
+		t.Fatal(err)
+	}
+	if entries := chip.Trace(); len(entries) != 0 {
+		t.Errorf("%d entries with tracing off", len(entries))
+	}
+	chip.Reset()
+	if chip.CycleCount() != 0 {
+		t.Errorf("cycle count %d after a reset", chip.CycleCount())
+	}
+
+}
+
+func TestTraceFilter(t *testing.T) {
+
+	f, err := ParseTraceFilter("d, call", "204-206")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		entry TraceEntry
+		want  bool
+	}{
+		{TraceEntry{PC: 0x204, Opcode: 0xD015}, true},
+		{TraceEntry{PC: 0x206, Opcode: 0x2208}, true},
+		{TraceEntry{PC: 0x204, Opcode: 0x7105}, false},
+		{TraceEntry{PC: 0x208, Opcode: 0xD015}, false},
+	}
+
+	for _, test := range tests {
+		if got := f.Match(test.entry); got != test.want {
+			t.Errorf("%s: match %v, want %v", test.entry, got, test.want)
+		}
+	}
+
+	for _, addresses := range []string{"300-200", "200", "x-2FF"} {
+		if _, err := ParseTraceFilter("", addresses); err == nil {
+			t.Errorf("address range %q accepted", addresses)
+		}
+	}
+
+}
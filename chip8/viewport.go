@@ -0,0 +1,94 @@
+package chip8
+
+import (
+	"image"
+	"image/color"
+)
+
+// Viewport is the area of a window the display is drawn into
+// after integer scaling and letterboxing.
+type Viewport struct {
+	Scale  int
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// Palette holds the colors used by the renderer. Border fills the letterbox around the display.
+// Foreground is used for pixels on in the first plane, Plane2 for pixels on in the second
+// XO-CHIP plane only and Overlap for pixels on in both.
+type Palette struct {
+	Foreground color.RGBA
+	Background color.RGBA
+	Border     color.RGBA
+	Plane2     color.RGBA
+	Overlap    color.RGBA
+}
+
+// DefaultPalette is white pixels on black with a black border, the second plane in gray.
+var DefaultPalette = Palette{
+	Foreground: color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+	Background: color.RGBA{0x00, 0x00, 0x00, 0xFF},
+	Border:     color.RGBA{0x00, 0x00, 0x00, 0xFF},
+	Plane2:     color.RGBA{0xAA, 0xAA, 0xAA, 0xFF},
+	Overlap:    color.RGBA{0x55, 0x55, 0x55, 0xFF},
+}
+
+// FitViewport returns the largest integer scale of the 64x32 display that fits in a window of
+// the given size, centered in it. The scale is never less than 1, even if the window is smaller.
+func FitViewport(window_width, window_height int) Viewport {
+	return FitFrameViewport(window_width, window_height, DisplayWidth, DisplayHeight)
+}
+
+// FitFrameViewport is FitViewport for a display of any size, e.g. the 128x64 SUPER-CHIP mode.
+func FitFrameViewport(window_width, window_height, width, height int) Viewport {
+
+	scale := min(window_width/width, window_height/height)
+	scale = max(scale, 1)
+
+	vp := Viewport{
+		Scale:  scale,
+		Width:  width * scale,
+		Height: height * scale,
+	}
+
+	vp.X = (window_width - vp.Width) / 2
+	vp.Y = (window_height - vp.Height) / 2
+
+	return vp
+
+}
+
+// DrawLetterboxed renders the chip's display into dst with nearest-neighbor integer scaling,
+// filling the rest of dst with the border color. It returns the viewport used.
+func DrawLetterboxed(dst *image.RGBA, chip *Chip8, palette Palette) Viewport {
+	return DrawFrameLetterboxed(dst, chip.Display(), palette)
+}
+
+// DrawFrameLetterboxed is DrawLetterboxed for a frame, as passed to Display.Draw.
+func DrawFrameLetterboxed(dst *image.RGBA, frame Frame, palette Palette) Viewport {
+
+	bounds := dst.Bounds()
+	vp := FitFrameViewport(bounds.Dx(), bounds.Dy(), frame.Width, frame.Height)
+
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+
+			// Position relative to the viewport.
+			x := px - bounds.Min.X - vp.X
+			y := py - bounds.Min.Y - vp.Y
+
+			c := palette.Border
+
+			if x >= 0 && y >= 0 && x < vp.Width && y < vp.Height {
+				c = frame.Color(x/vp.Scale, y/vp.Scale, palette)
+			}
+
+			dst.SetRGBA(px, py, c)
+		}
+	}
+
+	return vp
+
+}
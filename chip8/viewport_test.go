@@ -0,0 +1,65 @@
+package chip8
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFitFrameViewport(t *testing.T) {
+
+	tests := []struct {
+		name                        string
+		window_width, window_height int
+		width, height               int
+		want                        Viewport
+	}{
+		{"exact fit", 640, 320, 64, 32, Viewport{Scale: 10, X: 0, Y: 0, Width: 640, Height: 320}},
+		{"letterbox top and bottom", 800, 600, 64, 32, Viewport{Scale: 12, X: 16, Y: 108, Width: 768, Height: 384}},
+		{"pillarbox left and right", 1000, 320, 64, 32, Viewport{Scale: 10, X: 180, Y: 0, Width: 640, Height: 320}},
+		{"integer scale rounds down", 639, 319, 64, 32, Viewport{Scale: 9, X: 31, Y: 15, Width: 576, Height: 288}},
+		{"hires", 1280, 720, 128, 64, Viewport{Scale: 10, X: 0, Y: 40, Width: 1280, Height: 640}},
+		{"window smaller than the display", 50, 20, 64, 32, Viewport{Scale: 1, X: -7, Y: -6, Width: 64, Height: 32}},
+	}
+
+	for _, test := range tests {
+		got := FitFrameViewport(test.window_width, test.window_height, test.width, test.height)
+		if got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+
+}
+
+func TestDrawFrameLetterboxed(t *testing.T) {
+
+	palette := DefaultPalette
+	palette.Border.R = 0x80
+
+	frame := Frame{Width: DisplayWidth, Height: DisplayHeight}
+	setPlanePixel(&frame.Planes, 0, 0, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	vp := DrawFrameLetterboxed(dst, frame, palette)
+
+	if want := (Viewport{Scale: 3, X: 4, Y: 2, Width: 192, Height: 96}); vp != want {
+		t.Fatalf("viewport %+v, want %+v", vp, want)
+	}
+
+	checks := []struct {
+		x, y int
+		want string
+	}{
+		{0, 0, "border"}, {3, 50, "border"}, {196, 50, "border"}, {100, 1, "border"}, {100, 98, "border"},
+		{4, 2, "pixel"}, {6, 4, "pixel"}, {7, 2, "background"}, {4, 5, "background"},
+	}
+
+	colors := map[string]color.RGBA{"border": palette.Border, "pixel": palette.Foreground, "background": palette.Background}
+
+	for _, check := range checks {
+		if got := dst.RGBAAt(check.x, check.y); got != colors[check.want] {
+			t.Errorf("(%d, %d) is %v, want the %s color", check.x, check.y, got, check.want)
+		}
+	}
+
+}
@@ -0,0 +1,264 @@
+package chip8
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WatchAccess selects the accesses a watchpoint stops on.
+type WatchAccess uint8
+
+const (
+	WatchRead WatchAccess = 1 << iota
+	WatchWrite
+
+	WatchReadWrite = WatchRead | WatchWrite
+)
+
+// String returns "read", "write" or "read/write".
+func (a WatchAccess) String() string {
+
+	switch a {
+	case WatchRead:
+		return "read"
+	case WatchWrite:
+		return "write"
+	case WatchReadWrite:
+		return "read/write"
+	}
+
+	return fmt.Sprintf("WatchAccess(%d)", uint8(a))
+
+}
+
+// ParseWatchAccess parses "r", "w" or "rw", or the names returned by WatchAccess.String.
+func ParseWatchAccess(s string) (WatchAccess, error) {
+
+	switch strings.ToLower(s) {
+	case "r", "read":
+		return WatchRead, nil
+	case "w", "write":
+		return WatchWrite, nil
+	case "rw", "read/write":
+		return WatchReadWrite, nil
+	}
+
+	return 0, fmt.Errorf("invalid watch access %q, want r, w or rw", s)
+
+}
+
+// Watchpoint makes the Debugger stop after an instruction accessing a memory range or a
+// register. Instruction fetches are not accesses, nor are the accesses of the debugger itself.
+//
+// A register is written by an instruction that changes it, like a GDB watch, and read by an
+// instruction that uses its value as an operand, including the registers FX55 stores.
+type Watchpoint struct {
+	// Register watched, V0 to VF or I, empty for a memory range.
+	Register string
+
+	// Start and End are the first and last address of the memory range watched.
+	Start uint16
+	End   uint16
+
+	Access WatchAccess
+}
+
+// index of the watched register in registerSet, -1 for a memory range.
+func (w Watchpoint) index() int {
+
+	switch {
+	case w.Register == "":
+		return -1
+	case w.Register == "I":
+		return register_i
+	}
+
+	// A register not parsed by ParseWatchpoint may not exist, it is never accessed.
+	v, err := strconv.ParseUint(w.Register[1:], 16, 8)
+	if err != nil || len(w.Register) != 2 {
+		return -1
+	}
+
+	return int(v)
+
+}
+
+// String describes the watchpoint, e.g. "V3 write" or "0x300-0x30F read/write".
+func (w Watchpoint) String() string {
+
+	switch {
+	case w.Register != "":
+		return w.Register + " " + w.Access.String()
+	case w.Start == w.End:
+		return fmt.Sprintf("0x%03X %s", w.Start, w.Access)
+	}
+
+	return fmt.Sprintf("0x%03X-0x%03X %s", w.Start, w.End, w.Access)
+
+}
+
+// ParseWatchpoint parses a watchpoint target: a register, V0 to VF or I, an address or an
+// address range such as 300-30F, in hexadecimal, and its access.
+func ParseWatchpoint(target string, access WatchAccess) (Watchpoint, error) {
+
+	w := Watchpoint{Access: access}
+	name := strings.ToUpper(target)
+
+	if name == "I" {
+		w.Register = name
+		return w, nil
+	}
+	if len(name) == 2 && name[0] == 'V' && strings.ContainsRune("0123456789ABCDEF", rune(name[1])) {
+		w.Register = name
+		return w, nil
+	}
+
+	start, end, is_range := strings.Cut(target, "-")
+	if !is_range {
+		end = start
+	}
+
+	for _, a := range []struct {
+		s   string
+		dst *uint16
+	}{{start, &w.Start}, {end, &w.End}} {
+		addr, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(a.s), "0x"), 16, 16)
+		if err != nil {
+			return Watchpoint{}, fmt.Errorf("invalid watchpoint %q, want a register, an address or a range like 300-30F", target)
+		}
+		*a.dst = uint16(addr)
+	}
+
+	if w.End < w.Start {
+		return Watchpoint{}, fmt.Errorf("invalid watchpoint %q, the range ends before it starts", target)
+	}
+
+	return w, nil
+
+}
+
+// WatchHit is an access that triggered a watchpoint.
+type WatchHit struct {
+	Watch Watchpoint
+
+	// PC of the instruction that made the access.
+	PC uint16
+
+	// Addr is the address accessed, for a memory watchpoint.
+	Addr uint16
+
+	// Write tells a write from a read. Old and New are the value before and after the
+	// access, the same for a read.
+	Write bool
+	Old   uint32
+	New   uint32
+}
+
+// String describes the hit, e.g. "0x204: V3 written, 0x00 -> 0x01".
+func (h WatchHit) String() string {
+
+	what := h.Watch.Register
+	if what == "" {
+		what = fmt.Sprintf("0x%03X", h.Addr)
+	}
+
+	if !h.Write {
+		return fmt.Sprintf("0x%03X: %s read, 0x%02X", h.PC, what, h.Old)
+	}
+
+	return fmt.Sprintf("0x%03X: %s written, 0x%02X -> 0x%02X", h.PC, what, h.Old, h.New)
+
+}
+
+// Index of I in a registerSet, after V0 to VF.
+const register_i = 16
+
+// registerSet has a bit per register, V0 to VF then I.
+type registerSet uint32
+
+// registersIn returns the set of registers min(a, b) to max(a, b).
+func registersIn(a, b int) registerSet {
+
+	lo, hi := min(a, b), max(a, b)
+
+	return registerSet((1<<(hi+1) - 1) &^ (1<<lo - 1))
+
+}
+
+// registersRead returns the registers an instruction uses as operands, under the current quirks.
+func (chip *Chip8) registersRead(in Instruction) registerSet {
+
+	x, y := registerSet(1)<<in.X, registerSet(1)<<in.Y
+	i := registerSet(1) << register_i
+
+	switch in.Op {
+
+	case 0x3, 0x4, 0x7, 0xE:
+		return x
+
+	case 0x5:
+		switch in.N {
+		case 2:
+			return registersIn(in.X, in.Y) | i
+		case 3:
+			return i
+		}
+		return x | y
+
+	case 0x8:
+		switch in.N {
+		case 0x0:
+			return y
+		case 0x6, 0xE:
+			if chip.shift_quirk {
+				return x
+			}
+			return y
+		}
+		return x | y
+
+	case 0x9:
+		return x | y
+
+	case 0xB:
+		if chip.jump_quirk {
+			return x
+		}
+		return 1
+
+	case 0xD:
+		return x | y | i
+
+	case 0xF:
+		switch in.NN {
+		case 0x02, 0x65:
+			return i
+		case 0x15, 0x18, 0x29, 0x30, 0x3A:
+			return x
+		case 0x1E, 0x33:
+			return x | i
+		case 0x55:
+			return registersIn(0, in.X) | i
+		case 0x75:
+			return registersIn(0, in.X)
+		}
+
+	}
+
+	return 0
+
+}
+
+// registerValues returns V0 to VF then I, indexed like a registerSet.
+func (chip *Chip8) registerValues() [register_i + 1]uint32 {
+
+	var values [register_i + 1]uint32
+	for r, v := range chip.registers {
+		values[r] = uint32(v)
+	}
+	values[register_i] = chip.index_register
+
+	return values
+
+}
@@ -0,0 +1,211 @@
+package chip8
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// The minimal WebSocket server side of RFC 6455 the telemetry needs: the handshake, text
+// messages to the client, and answering the pings and close of the client. Messages of the
+// client are read and dropped.
+
+// Appended to the key of the client to compute the accept header of the handshake.
+const websocket_guid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes.
+const (
+	ws_text  = 0x1
+	ws_close = 0x8
+	ws_ping  = 0x9
+	ws_pong  = 0xA
+)
+
+// Largest payload accepted from a client, its messages are only control frames.
+const ws_max_payload = 1 << 16
+
+var errNotWebSocket = errors.New("not a WebSocket handshake")
+
+// errCrossOrigin is returned for the handshake of a page served by another host.
+var errCrossOrigin = errors.New("the WebSocket origin is not this host")
+
+// wsConn is a WebSocket connection accepted by acceptWebSocket.
+type wsConn struct {
+	conn net.Conn
+	in   *bufio.Reader
+
+	// Messages and control frames can be written at the same time.
+	write_mu sync.Mutex
+}
+
+// acceptWebSocket completes the handshake of a WebSocket request and takes over its connection.
+// Browsers let any page open a WebSocket, so a handshake whose Origin is another host is
+// rejected; clients that are not browsers send no Origin and are accepted.
+func acceptWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket") || key == "" {
+		return nil, errNotWebSocket
+	}
+	if !sameOrigin(r) {
+		return nil, errCrossOrigin
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("the connection cannot be taken over")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + websocket_guid))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &wsConn{conn: conn, in: rw.Reader}, nil
+
+}
+
+// sameOrigin reports whether the Origin of r, if any, is the host r was sent to.
+func sameOrigin(r *http.Request) bool {
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+
+}
+
+// headerHas reports whether a comma separated header lists value, ignoring case.
+func headerHas(h http.Header, name, value string) bool {
+
+	for _, line := range h.Values(name) {
+		for _, token := range strings.Split(line, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return true
+			}
+		}
+	}
+
+	return false
+
+}
+
+// WriteText sends a text message.
+func (c *wsConn) WriteText(data []byte) error {
+	return c.writeFrame(ws_text, data)
+}
+
+// writeFrame sends a single unmasked frame, as servers do.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.write_mu.Lock()
+	defer c.write_mu.Unlock()
+
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+
+	return err
+
+}
+
+// readLoop reads the frames of the client until it closes the connection or it fails,
+// answering pings.
+func (c *wsConn) readLoop() error {
+
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(c.in, header[:]); err != nil {
+			return err
+		}
+
+		opcode := header[0] & 0x0F
+		masked := header[1]&0x80 != 0
+		n := uint64(header[1] & 0x7F)
+
+		switch n {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(c.in, ext[:]); err != nil {
+				return err
+			}
+			n = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(c.in, ext[:]); err != nil {
+				return err
+			}
+			n = binary.BigEndian.Uint64(ext[:])
+		}
+
+		if n > ws_max_payload {
+			return errors.New("WebSocket message too large")
+		}
+
+		var mask [4]byte
+		if masked {
+			if _, err := io.ReadFull(c.in, mask[:]); err != nil {
+				return err
+			}
+		}
+
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.in, payload); err != nil {
+			return err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch opcode {
+		case ws_close:
+			c.writeFrame(ws_close, payload[:min(len(payload), 2)])
+			return nil
+		case ws_ping:
+			if err := c.writeFrame(ws_pong, payload); err != nil {
+				return err
+			}
+		}
+	}
+
+}
+
+// Close closes the connection.
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
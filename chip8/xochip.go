@@ -0,0 +1,230 @@
+package chip8
+
+import (
+	"fmt"
+	"math"
+)
+
+// Memory sizes: CHIP-8 and SUPER-CHIP address 4kB, XO-CHIP 64kB. Megachip has 16MB, see mem.
+const (
+	memory_size    = 0x1000
+	xo_memory_size = 0x10000
+)
+
+// Pitch of the audio pattern after a reset, playing it at 4000 samples per second.
+const default_pitch = 64
+
+// memorySize returns the amount of memory the platform can address.
+func (chip *Chip8) memorySize() int {
+	return chip.platform.memorySize()
+}
+
+// memorySize returns the amount of memory a machine of the platform addresses.
+func (p Platform) memorySize() int {
+
+	switch p {
+	case PlatformXOChip:
+		return xo_memory_size
+	case PlatformMegaChip:
+		return mega_memory_size
+	}
+
+	return memory_size
+
+}
+
+// opcodeAt returns the opcode stored at addr, or 0 past the end of memory.
+func (chip *Chip8) opcodeAt(addr uint16) uint16 {
+
+	if int(addr)+1 >= chip.memorySize() {
+		return 0
+	}
+
+	memory := chip.mem()
+
+	return uint16(memory[addr])<<8 | uint16(memory[addr+1])
+
+}
+
+// Planes returns the XO-CHIP display planes drawn to, 1 for the first plane and 2 for the second.
+func (chip *Chip8) Planes() uint8 {
+	return chip.planes
+}
+
+// AudioPattern returns the XO-CHIP audio pattern, 128 1-bit samples played from the highest bit
+// of the first byte, and the rate to play them at in samples per second. ok is false unless
+// XO-CHIP is enabled, in which case frontends play a plain tone.
+func (chip *Chip8) AudioPattern() (pattern [16]byte, rate float64, ok bool) {
+
+	if !chip.xoChip() {
+		return pattern, 0, false
+	}
+
+	return chip.pattern, PatternRate(chip.pitch), true
+
+}
+
+// PatternRate returns the playback rate of the XO-CHIP audio pattern for a pitch set by FX3A,
+// in samples per second: 4000 * 2^((pitch-64)/48).
+func PatternRate(pitch byte) float64 {
+	return 4000 * math.Pow(2, (float64(pitch)-64)/48)
+}
+
+// xoChip reports whether XO-CHIP instructions are enabled. When they are not, they are
+// unknown opcodes.
+func (chip *Chip8) xoChip() bool {
+	return chip.platform == PlatformXOChip
+}
+
+// 00DN - Scroll the display up N pixels
+func (chip *Chip8) op00DN(in Instruction) error {
+
+	if !chip.xoChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.scroll(0, -in.N)
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// registerRange returns the registers from V[X] to V[Y], in descending order if X > Y.
+func registerRange(in Instruction) []int {
+
+	step := 1
+	if in.X > in.Y {
+		step = -1
+	}
+
+	regs := []int{in.X}
+	for r := in.X; r != in.Y; {
+		r += step
+		regs = append(regs, r)
+	}
+
+	return regs
+
+}
+
+// 5XY2 - Store registers V[X] through V[Y] in memory starting at location I, I is unchanged
+func (chip *Chip8) op5XY2(in Instruction) error {
+
+	if !chip.xoChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	regs := registerRange(in)
+
+	if err := chip.checkMemory(int(chip.index_register), len(regs), true); err != nil {
+		return err
+	}
+
+	for i, r := range regs {
+		chip.poke(int(chip.index_register)+i, chip.registers[r])
+	}
+
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// 5XY3 - Read registers V[X] through V[Y] from memory starting at location I, I is unchanged
+func (chip *Chip8) op5XY3(in Instruction) error {
+
+	if !chip.xoChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	regs := registerRange(in)
+
+	if err := chip.checkMemory(int(chip.index_register), len(regs), false); err != nil {
+		return err
+	}
+
+	for i, r := range regs {
+		chip.registers[r] = chip.peek(int(chip.index_register) + i)
+	}
+
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// F000 NNNN - Set I = NNNN, the 16-bit address in the next 2 bytes
+func (chip *Chip8) opF000(in Instruction) error {
+
+	if in.X != 0 {
+		return chip.unknownOpcode(in)
+	}
+	if !chip.xoChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	addr := int(chip.program_counter) + 2
+
+	if err := chip.checkMemory(addr, 2, false); err != nil {
+		return fmt.Errorf("%w: fetch at PC 0x%04X", err, addr)
+	}
+
+	chip.index_register = uint32(chip.peek(addr))<<8 | uint32(chip.peek(addr+1))
+	chip.program_counter += 4
+
+	return nil
+
+}
+
+// FN01 - Select the display planes drawn to by 00E0, 00CN, 00DN, 00FB, 00FC and DXYN
+func (chip *Chip8) opFN01(in Instruction) error {
+
+	if !chip.xoChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	// The instruction is FN01: N is in the X position.
+	chip.planes = uint8(in.X) & 3
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// F002 - Load the 16-byte audio pattern from memory starting at location I
+func (chip *Chip8) opF002(in Instruction) error {
+
+	if in.X != 0 {
+		return chip.unknownOpcode(in)
+	}
+	if !chip.xoChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	if err := chip.checkMemory(int(chip.index_register), len(chip.pattern), false); err != nil {
+		return err
+	}
+
+	for i := range chip.pattern {
+		chip.pattern[i] = chip.peek(int(chip.index_register) + i)
+	}
+	chip.program_counter += 2
+
+	return nil
+
+}
+
+// FX3A - Set the audio pattern pitch = V[X]
+func (chip *Chip8) opFX3A(in Instruction) error {
+
+	if !chip.xoChip() {
+		return chip.unknownOpcode(in)
+	}
+
+	chip.pitch = chip.registers[in.X]
+	chip.program_counter += 2
+
+	return nil
+
+}
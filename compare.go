@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"chip8-go/chip8"
+)
+
+// compareFrontend shows two machines running in lockstep until the user quits.
+type compareFrontend func(lockstep *chip8.Lockstep, opts options) error
+
+// compare_frontends holds the frontends that can show a comparison, by name. The text one
+// runs headlessly and reports the first divergence.
+var compare_frontends = map[string]compareFrontend{}
+
+// compareSettings configures one side of a comparison.
+type compareSettings struct {
+	rom      string
+	platform string
+	quirks   string
+}
+
+// runCompare runs two ROMs, or one ROM with two settings, in lockstep:
+// chip8 compare [flags] romA [romB]
+func runCompare(args []string) {
+
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: chip8 compare [flags] romA.ch8 [romB.ch8]")
+		fmt.Fprintln(os.Stderr, "Without romB, romA runs on both machines, e.g. with other -quirks-b.")
+		fs.PrintDefaults()
+	}
+
+	speed := fs.Int("speed", 0, "instructions per second of both machines, 0 for the recommended speed of romA")
+	platform := fs.String("platform", "auto", "instruction set of machine A: chip8, schip, xochip, megachip, or auto to detect it from the ROM")
+	platform_b := fs.String("platform-b", "", "instruction set of machine B, the one of A by default")
+	quirks := fs.String("quirks", "", "quirks preset of machine A: vip, schip or modern (the default)")
+	quirks_b := fs.String("quirks-b", "", "quirks preset of machine B, the one of A by default")
+	seed := fs.Int64("seed", 1, "seed of the CXNN random source, the same for both machines")
+	frames := fs.Int("frames", 600, "with the text frontend, 60Hz frames to run before reporting")
+	name := fs.String("frontend", "text", "frontend to show the machines side by side: text reports the first divergence, tui draws both (Linux)")
+	rom_cache := fs.String("rom-cache", "", "keep ROMs downloaded from URLs in this directory, reading them from there the next time")
+	keys := fs.String("keys", "", "keyboard keys for the keypad 0 to F, e.g. x123qweasdzc4rfv (the default)")
+
+	positional := parseArgs(fs, args)
+	if len(positional) < 1 || len(positional) > 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	a := compareSettings{rom: positional[0], platform: *platform, quirks: *quirks}
+	b := compareSettings{rom: positional[len(positional)-1], platform: *platform_b, quirks: *quirks_b}
+	if b.platform == "" {
+		b.platform = a.platform
+	}
+	if b.quirks == "" {
+		b.quirks = a.quirks
+	}
+
+	keymap := chip8.DefaultKeymap()
+	if *keys != "" {
+		var err error
+		if keymap, err = chip8.ParseKeymap(*keys); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	chip_a, err := newCompareChip(a, *speed, *seed, *rom_cache)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	chip_b, err := newCompareChip(b, *speed, *seed, *rom_cache)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Both machines run at the rate of A, instruction for instruction.
+	chip_b.SetClockHz(chip_a.ClockHz())
+
+	lockstep := chip8.NewLockstep(chip_a, chip_b)
+
+	if *name == "text" {
+		if err := compareHeadless(lockstep, *frames); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	run, ok := compare_frontends[*name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "frontend %q cannot show a comparison\n", *name)
+		os.Exit(2)
+	}
+
+	if err := run(lockstep, options{Keymap: keymap, Palette: chip8.DefaultPalette, Mute: true}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if lockstep.First != nil {
+		fmt.Printf("first divergence at %s\n", lockstep.First)
+	}
+
+}
+
+// newCompareChip returns a machine with the ROM and settings of one side of a comparison.
+func newCompareChip(settings compareSettings, speed int, seed int64, rom_cache string) (*chip8.Chip8, error) {
+
+	chip := chip8.New()
+	chip.SetSeed(seed)
+
+	if settings.quirks != "" {
+		q, err := chip8.QuirksPreset(settings.quirks)
+		if err != nil {
+			return nil, err
+		}
+		chip.SetQuirks(q)
+	}
+
+	data, _, err := openROM(settings.rom, rom_cache)
+	if err != nil {
+		return nil, fmt.Errorf("could not read ROM: %w", err)
+	}
+
+	if settings.platform == "auto" {
+		chip.SetPlatform(chip8.DetectROMPlatform(data))
+	} else {
+		p, err := chip8.ParsePlatform(settings.platform)
+		if err != nil {
+			return nil, err
+		}
+		chip.SetPlatform(p)
+	}
+
+	if err := chip.LoadROMBytes(data); err != nil {
+		return nil, err
+	}
+
+	if speed > 0 {
+		chip.SetClockHz(speed)
+	} else {
+		chip.SetClockHz(chip8.RecommendedClockHz(data, romName(settings.rom)))
+	}
+
+	return chip, nil
+
+}
+
+// compareHeadless runs the lockstep for the given number of frames, without keys, and returns
+// an error describing the first divergence, if any. A program exiting with 00FD ends the run.
+func compareHeadless(lockstep *chip8.Lockstep, frames int) error {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for frame := 0; frame < frames && ctx.Err() == nil; frame++ {
+		if _, err := lockstep.Frame(); err != nil {
+			if errors.Is(err, chip8.ErrExited) {
+				break
+			}
+			return fmt.Errorf("frame %d: %w", frame, err)
+		}
+		if lockstep.First != nil {
+			return fmt.Errorf("machines diverged at frame %d, %s%s", frame, lockstep.First, divergedPixels(*lockstep.First))
+		}
+	}
+
+	fmt.Printf("no divergence in %d cycles\n", lockstep.A.CycleCount())
+
+	return nil
+
+}
+
+// divergedPixels lists the first pixels of a divergence, for the report.
+func divergedPixels(d chip8.Divergence) string {
+
+	const shown = 8
+
+	if len(d.Pixels) == 0 {
+		return ""
+	}
+
+	var points []string
+	for _, p := range d.Pixels[:min(len(d.Pixels), shown)] {
+		points = append(points, fmt.Sprintf("%d,%d", p.X, p.Y))
+	}
+	if len(d.Pixels) > shown {
+		points = append(points, "...")
+	}
+
+	return "\npixels differ at " + strings.Join(points, " ")
+
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"chip8-go/chip8"
+)
+
+// The configuration file is a small subset of TOML: [section] headers, key = value lines
+// and # comments. Every key is the name of a chip8 run flag, whose default it replaces, except
+// in [gamepad] where keys name games.
+const default_config = `# chip8go configuration. Every setting is the default of the chip8 run flag of the same
+# name, flags given on the command line still take precedence.
+
+[display]
+# Window size as a multiple of the 64x32 display (sdl builds).
+scale = 10
+# Colors of the display: default, amber, green, lcd or octo.
+theme = "default"
+# Colors of the pixels that are on and off, as #RRGGBB, instead of the theme's. The second
+# XO-CHIP plane has its own colors, for its pixels alone and for those on in both planes.
+# fg = "#FFFFFF"
+# bg = "#000000"
+# plane2 = "#AAAAAA"
+# overlap = "#555555"
+# CRT effects of the window frontends: the share of its brightness a pixel turned off keeps
+# each frame, from 0 to below 1, scanlines and a curved screen.
+crt-decay = 0
+scanlines = false
+curvature = false
+# Frontend to run: text, tui, debug, sdl or ebiten (builds with that tag).
+# frontend = "tui"
+
+[cpu]
+# Instructions per second, 0 for the ROM's recommended speed.
+speed = 0
+# Instruction set: chip8, schip, xochip, megachip, or auto to detect it from the ROM.
+platform = "auto"
+# Quirks preset: vip, schip or modern.
+quirks = "modern"
+# An access past the end of memory stops with an error (strict) or wraps around to 0 (wrap).
+memory = "strict"
+# Stop with an error when the program writes below 0x200, where the fonts are.
+protect-memory = false
+
+[audio]
+mute = false
+# Pitch of the beep in Hz and its volume from 0 to 1.
+tone = 440
+volume = 0.25
+
+[input]
+# Keyboard keys for the keypad keys 0 to F.
+keys = "x123qweasdzc4rfv"
+
+[gamepad]
+# Gamepad buttons for the keypad keys of every game, as button=key pairs. The buttons are up,
+# down, left, right (the d-pad or the left stick), a, b, x, y, lb, rb, back and start.
+default = "up=5,down=8,left=7,right=9,a=6,b=4,x=1,y=C"
+# The buttons of a game, by the file name of its ROM without the extension, e.g. for pong.ch8:
+# pong = "up=1,down=4"
+`
+
+// Keys allowed in each section of the configuration file.
+var config_sections = map[string][]string{
+	"display": {"scale", "theme", "fg", "bg", "plane2", "overlap", "crt-decay", "scanlines", "curvature", "frontend"},
+	"cpu":     {"speed", "platform", "quirks", "seed", "memory", "protect-memory"},
+	"audio":   {"mute", "tone", "volume"},
+	"input":   {"keys"},
+	"gamepad": nil,
+}
+
+// Section of the configuration file holding gamepad maps by game instead of flags.
+const config_gamepad_section = "gamepad"
+
+// configPath returns where the configuration file is kept, ~/.config/chip8go/config.toml on Linux.
+func configPath() (string, error) {
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "chip8go", "config.toml"), nil
+
+}
+
+// applyConfig reads the configuration file at path into the defaults of flags, and returns
+// the gamepad maps of its [gamepad] section by game, "default" for every game. A missing
+// file is not an error.
+func applyConfig(flags *flag.FlagSet, path string) (map[string]chip8.GamepadMap, error) {
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gamepads := map[string]chip8.GamepadMap{}
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+
+	for n := 1; scanner.Scan(); n++ {
+
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if _, ok := config_sections[section]; !ok {
+				return nil, fmt.Errorf("%s:%d: unknown section [%s]", path, n, section)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: want key = value", path, n)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		if strings.HasPrefix(value, `"`) {
+			if value, err = strconv.Unquote(value); err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid string for %s", path, n, key)
+			}
+		}
+
+		if section == config_gamepad_section {
+			m, err := chip8.ParseGamepadMap(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+			gamepads[strings.ToLower(key)] = m
+			continue
+		}
+
+		known := false
+		for _, k := range config_sections[section] {
+			known = known || k == key
+		}
+		if !known {
+			return nil, fmt.Errorf("%s:%d: unknown setting %q in [%s]", path, n, key, section)
+		}
+
+		if err := flags.Set(key, value); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+
+		// The file changes the defaults, shown by -h.
+		flags.Lookup(key).DefValue = value
+	}
+
+	return gamepads, scanner.Err()
+
+}
+
+// stripComment removes a # comment that is not inside a quoted string.
+func stripComment(line string) string {
+
+	quoted := false
+
+	for i, r := range line {
+		switch {
+		case r == '"' && (i == 0 || line[i-1] != '\\'):
+			quoted = !quoted
+		case r == '#' && !quoted:
+			return line[:i]
+		}
+	}
+
+	return line
+
+}
+
+// runConfig manages the configuration file: chip8 config init|path
+func runConfig(args []string) {
+
+	flags := flag.NewFlagSet("config", flag.ExitOnError)
+	force := flags.Bool("force", false, "with init, overwrite an existing configuration file")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: chip8 config init [-force] | chip8 config path")
+		flags.PrintDefaults()
+	}
+
+	positional := parseArgs(flags, args)
+	if len(positional) != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	path, err := configPath()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	switch positional[0] {
+
+	case "path":
+		fmt.Println(path)
+
+	case "init":
+		if _, err := os.Stat(path); err == nil && !*force {
+			fmt.Printf("%s already exists, use -force to overwrite it\n", path)
+			os.Exit(1)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(path, []byte(default_config), 0o644); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("wrote", path)
+
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+
+	"chip8-go/chip8"
+)
+
+// options configures the frontends from the command line.
+type options struct {
+	// Window size as a multiple of the display, for frontends that open a window.
+	Scale int
+
+	// Keymap translates keyboard keys to the keypad.
+	Keymap chip8.Keymap
+
+	// Gamepad translates gamepad buttons to the keypad, for frontends reading gamepads.
+	Gamepad chip8.GamepadMap
+
+	// Mute turns the beep off.
+	Mute bool
+
+	// Pitch in Hz and volume from 0 to 1 of the beep, for frontends with audio output.
+	ToneHz float64
+	Volume float64
+
+	// Palette colors the display, for frontends that support colors.
+	Palette chip8.Palette
+
+	// CRT are the effects the window frontends draw the display with.
+	CRT chip8.CRTEffects
+
+	// StateFile is where the save state hotkeys save and restore the machine.
+	StateFile string
+
+	// Capture takes the screenshots and recordings of the F8 and F9 hotkeys.
+	Capture *capture
+}
+
+// newCRT returns the CRT drawing the display of a window, nil when no effect is on.
+func newCRT(opts options) *chip8.CRT {
+
+	if !opts.CRT.Enabled() {
+		return nil
+	}
+
+	return chip8.NewCRT(opts.CRT, opts.Palette)
+
+}
+
+// frontend drives a chip until the user quits.
+type frontend func(chip *chip8.Chip8, opts options) error
+
+// frontends holds every frontend compiled in, by name. Frontends behind build tags
+// register themselves from init.
+var frontends = map[string]frontend{
+	"text": runText,
+}
+
+// defaultFrontend is used when -frontend is not given.
+func defaultFrontend() string {
+	for _, name := range []string{"wasm", "sdl", "ebiten"} {
+		if _, ok := frontends[name]; ok {
+			return name
+		}
+	}
+	return "text"
+}
+
+func runFrontend(name string, chip *chip8.Chip8, opts options) error {
+
+	run, ok := frontends[name]
+	if !ok {
+		names := make([]string, 0, len(frontends))
+		for n := range frontends {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown frontend %q, available: %v", name, names)
+	}
+
+	return run(chip, opts)
+
+}
+
+// Rewind snapshots are taken 10 times a second for the last 10 seconds.
+const (
+	rewind_interval = 6
+	rewind_capacity = 100
+)
+
+// rewinder drives a chip8.Rewind from a frontend: snapshots are taken while the program runs,
+// and while the rewind key is held the chip is paused and steps back a snapshot per frame.
+type rewinder struct {
+	chip   *chip8.Chip8
+	rewind *chip8.Rewind
+
+	// Set while the rewind key is held, and whether the chip was paused before.
+	active     bool
+	was_paused bool
+}
+
+func newRewinder(chip *chip8.Chip8) *rewinder {
+	return &rewinder{chip: chip, rewind: chip8.NewRewind(chip, rewind_capacity, rewind_interval)}
+}
+
+// frame is called once per frame with the state of the rewind key.
+func (rw *rewinder) frame(held bool) {
+
+	if held {
+		if !rw.active {
+			rw.active = true
+			rw.was_paused = rw.chip.Paused()
+			rw.chip.Pause()
+		}
+		rw.rewind.StepBack()
+		return
+	}
+
+	if rw.active {
+		rw.active = false
+		if !rw.was_paused {
+			rw.chip.Resume()
+		}
+	}
+
+	if !rw.chip.Paused() {
+		rw.rewind.Frame()
+	}
+
+}
+
+// togglePause pauses or resumes the chip and returns a message for the user.
+func togglePause(chip *chip8.Chip8) string {
+
+	if chip.Paused() {
+		chip.Resume()
+		return "resumed"
+	}
+
+	chip.Pause()
+	return "paused"
+
+}
+
+// Bounds of the clock rate set with the speed hotkeys, and the factor of each step.
+const (
+	min_clock_hz  = 60
+	max_clock_hz  = 100_000
+	clock_step_hz = 1.25
+)
+
+// changeSpeed makes the chip run faster or slower by a step and returns a message for the user.
+func changeSpeed(chip *chip8.Chip8, faster bool) string {
+
+	hz := float64(chip.ClockHz())
+	if faster {
+		hz *= clock_step_hz
+	} else {
+		hz /= clock_step_hz
+	}
+	chip.SetClockHz(min(max(int(hz+0.5), min_clock_hz), max_clock_hz))
+
+	return fmt.Sprintf("%d instructions/s", chip.ClockHz())
+
+}
+
+// toggleSpeed switches the chip between speed and the normal one and returns a message for
+// the user.
+func toggleSpeed(chip *chip8.Chip8, speed chip8.Speed) string {
+
+	if chip.Speed() == speed {
+		speed = chip8.SpeedNormal
+	}
+	chip.SetSpeed(speed)
+
+	return speed.String() + " speed"
+
+}
+
+// resetChip restarts the program from power-on and returns a message for the user.
+func resetChip(chip *chip8.Chip8) string {
+	chip.Reset()
+	return "reset"
+}
+
+// reloadROM reads the ROM file again, restarts it and returns a message for the user.
+func reloadROM(chip *chip8.Chip8) string {
+
+	if err := chip.ReloadROM(); err != nil {
+		return err.Error()
+	}
+
+	return "ROM reloaded"
+
+}
+
+// saveState writes the machine state to path and returns a message for the user.
+func saveState(chip *chip8.Chip8, path string) string {
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err.Error()
+	}
+
+	err = chip.SaveState(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err.Error()
+	}
+
+	return "state saved to " + path
+
+}
+
+// loadState restores the machine state saved at path and returns a message for the user.
+func loadState(chip *chip8.Chip8, path string) string {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err.Error()
+	}
+	defer f.Close()
+
+	if err := chip.LoadState(bufio.NewReader(f)); err != nil {
+		return err.Error()
+	}
+
+	return "state loaded from " + path
+
+}
+
+// statusLog is a log handler showing each message on a status line, for the frontends that own
+// the terminal. The level filter of the replaced handler still applies.
+type statusLog struct {
+	next slog.Handler
+	show func(msg string)
+}
+
+func (h statusLog) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h statusLog) Handle(ctx context.Context, r slog.Record) error {
+	h.show(fmt.Sprintf("%s: %s", r.Level, r.Message))
+	return nil
+}
+
+func (h statusLog) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return statusLog{next: h.next.WithAttrs(attrs), show: h.show}
+}
+
+func (h statusLog) WithGroup(name string) slog.Handler {
+	return statusLog{next: h.next.WithGroup(name), show: h.show}
+}
+
+// logToStatus sends the log messages of the chip to show until the returned function restores
+// its logger.
+func logToStatus(chip *chip8.Chip8, show func(msg string)) func() {
+
+	logger := chip.Logger()
+	if logger == nil {
+		return func() {}
+	}
+
+	chip.SetLogger(slog.New(statusLog{next: logger.Handler(), show: show}))
+
+	return func() { chip.SetLogger(logger) }
+
+}
+
+// textDisplay prints the display to stdout as lines of 0 and 1 each time it changes.
+// With XO-CHIP, 2 is a pixel on in the second plane only and 3 in both.
+type textDisplay struct{}
+
+func (textDisplay) Draw(frame chip8.Frame) error {
+
+	for y := 0; y < frame.Height; y++ {
+		for x := 0; x < frame.Width; x++ {
+			fmt.Print(frame.Pixel(x, y))
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+
+	return nil
+
+}
+
+func (textDisplay) Beep(on bool) {}
+
+// runText runs the chip with the text display and no keypad input, until Ctrl-C is pressed.
+func runText(chip *chip8.Chip8, opts options) error {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return chip.RunWith(ctx, textDisplay{}, nil)
+
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"chip8-go/chip8"
+	"chip8-go/chip8/decode"
+)
+
+func init() {
+	frontends["debug"] = runDebug
+}
+
+const debug_help = `Commands:
+  break ADDR      stop before the instruction at ADDR (b)
+  delete ADDR     remove the breakpoint at ADDR (d)
+  breaks          list the breakpoints
+  watch W [ACC]   stop after an access to W, a register (V0-VF, I), an address or a range
+                  like 300-30F, ACC being r, w (the default) or rw (w)
+  unwatch W [ACC] remove a watchpoint
+  watches         list the watchpoints
+  step [N]        execute N instructions, 1 by default (s)
+  continue        run until a breakpoint, Ctrl-C pauses (c)
+  print           show the registers, timers and next instruction (p)
+  mem ADDR [LEN]  dump LEN bytes of memory from ADDR, 64 by default (x)
+  panel [W] [N]   show N rows of memory, 4 by default, with each status, following W: pc, i
+                  (the default) or an address; * marks the bytes the last command wrote;
+                  "panel off" hides it
+  screen          print the display
+  reset           restart the program from power-on
+  reload          read the ROM file again and restart it
+  quit            leave the debugger (q)
+Addresses are hexadecimal. An empty line repeats the last command.`
+
+// runDebug runs the chip under a command line debugger reading from stdin. The program
+// starts paused with no keypad input.
+func runDebug(chip *chip8.Chip8, opts options) error {
+
+	d := chip8.NewDebugger(chip)
+	panel := &memoryPanel{}
+	in := bufio.NewScanner(os.Stdin)
+
+	fmt.Println(`CHIP-8 debugger, "help" lists the commands.`)
+	fmt.Print(d.Status())
+
+	last := ""
+
+	for {
+		fmt.Print("> ")
+		if !in.Scan() {
+			return in.Err()
+		}
+
+		line := strings.TrimSpace(in.Text())
+		if line == "" {
+			line = last
+		}
+		last = line
+
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+
+		quit, err := debugCommand(d, chip, panel, args)
+		if err != nil {
+			fmt.Println(err)
+		}
+		if quit {
+			return nil
+		}
+	}
+
+}
+
+// debugCommand runs a single debugger command and reports whether the debugger should quit.
+func debugCommand(d *chip8.Debugger, chip *chip8.Chip8, panel *memoryPanel, args []string) (bool, error) {
+
+	switch args[0] {
+
+	case "help", "h", "?":
+		fmt.Println(debug_help)
+
+	case "quit", "q":
+		return true, nil
+
+	case "break", "b", "delete", "d":
+		if len(args) != 2 {
+			return false, fmt.Errorf("usage: %s ADDR", args[0])
+		}
+		addr, err := parseHex(args[1])
+		if err != nil {
+			return false, err
+		}
+		if args[0][0] == 'b' {
+			d.SetBreakpoint(addr)
+		} else {
+			d.ClearBreakpoint(addr)
+		}
+
+	case "breaks":
+		for _, addr := range d.Breakpoints() {
+			fmt.Printf("%04X %s\n", addr, decode.Decode(opcodeAt(chip, addr)))
+		}
+
+	case "step", "s":
+		d.ClearWrites()
+		n := 1
+		if len(args) > 1 {
+			var err error
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return false, fmt.Errorf("invalid count %q", args[1])
+			}
+		}
+		for i := 0; i < n; i++ {
+			if err := d.Step(); err != nil {
+				return false, err
+			}
+			printWatchHits(d)
+		}
+		fmt.Print(d.Status())
+		panel.show(d, chip)
+
+	case "continue", "c":
+		d.ClearWrites()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		hit, err := d.Continue(ctx)
+		stop()
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case len(d.WatchHits()) > 0:
+			fmt.Println("Watchpoint")
+			printWatchHits(d)
+		case hit:
+			fmt.Println("Breakpoint")
+		default:
+			fmt.Println("Paused")
+		}
+		fmt.Print(d.Status())
+		panel.show(d, chip)
+
+	case "watch", "w", "unwatch":
+		if len(args) < 2 || len(args) > 3 {
+			return false, fmt.Errorf("usage: %s TARGET [r|w|rw]", args[0])
+		}
+		access := chip8.WatchWrite
+		if len(args) > 2 {
+			var err error
+			if access, err = chip8.ParseWatchAccess(args[2]); err != nil {
+				return false, err
+			}
+		}
+		w, err := chip8.ParseWatchpoint(args[1], access)
+		if err != nil {
+			return false, err
+		}
+		if args[0] == "unwatch" {
+			d.RemoveWatchpoint(w)
+		} else {
+			d.AddWatchpoint(w)
+		}
+
+	case "watches":
+		for _, w := range d.Watchpoints() {
+			fmt.Println(w)
+		}
+
+	case "print", "p":
+		fmt.Print(d.Status())
+		panel.show(d, chip)
+
+	case "mem", "x":
+		if len(args) < 2 {
+			return false, fmt.Errorf("usage: %s ADDR [LEN]", args[0])
+		}
+		addr, err := parseHex(args[1])
+		if err != nil {
+			return false, err
+		}
+		n := 64
+		if len(args) > 2 {
+			if n, err = strconv.Atoi(args[2]); err != nil || n < 1 {
+				return false, fmt.Errorf("invalid length %q", args[2])
+			}
+		}
+		data := chip.Memory(addr, n)
+		for i := 0; i < len(data); i += 16 {
+			fmt.Printf("%04X  % X\n", int(addr)+i, data[i:min(i+16, len(data))])
+		}
+
+	case "panel":
+		if err := panel.set(args[1:]); err != nil {
+			return false, err
+		}
+		panel.show(d, chip)
+
+	case "screen":
+		textDisplay{}.Draw(chip.Display())
+
+	case "reset":
+		fmt.Println(resetChip(chip))
+		fmt.Print(d.Status())
+		panel.show(d, chip)
+
+	case "reload":
+		fmt.Println(reloadROM(chip))
+		fmt.Print(d.Status())
+		panel.show(d, chip)
+
+	default:
+		return false, fmt.Errorf("unknown command %q, try help", args[0])
+	}
+
+	return false, nil
+
+}
+
+// Rows of memory shown by the panel unless given.
+const panel_rows = 4
+
+// memoryPanel is the memory shown with each status of the debugger, following the program
+// counter, I or a fixed address.
+type memoryPanel struct {
+	on     bool
+	follow string
+	addr   uint16
+	rows   int
+}
+
+// set configures the panel from the arguments of the panel command.
+func (p *memoryPanel) set(args []string) error {
+
+	if len(args) > 0 && args[0] == "off" {
+		p.on = false
+		return nil
+	}
+
+	p.on, p.follow, p.rows = true, "i", panel_rows
+
+	if len(args) > 0 {
+		switch strings.ToLower(args[0]) {
+		case "pc", "i":
+			p.follow = strings.ToLower(args[0])
+		default:
+			addr, err := parseHex(args[0])
+			if err != nil {
+				return err
+			}
+			p.follow, p.addr = "", addr
+		}
+	}
+
+	if len(args) > 1 {
+		rows, err := strconv.Atoi(args[1])
+		if err != nil || rows < 1 {
+			return fmt.Errorf("invalid row count %q", args[1])
+		}
+		p.rows = rows
+	}
+
+	if len(args) > 2 {
+		return errors.New("usage: panel [pc|i|ADDR] [ROWS], or panel off")
+	}
+
+	return nil
+
+}
+
+// show prints the panel, if on: the rows from the one of the followed address, or from the row
+// before it when there are more than two.
+func (p *memoryPanel) show(d *chip8.Debugger, chip *chip8.Chip8) {
+
+	if !p.on {
+		return
+	}
+
+	state := chip.State()
+
+	addr, name := int(p.addr), fmt.Sprintf("%04X", p.addr)
+	switch p.follow {
+	case "pc":
+		addr, name = int(state.PC), fmt.Sprintf("PC=%04X", state.PC)
+	case "i":
+		addr, name = int(state.I), fmt.Sprintf("I=%04X", state.I)
+	}
+
+	start := addr &^ 0xF
+	if p.rows > 2 {
+		start = max(start-0x10, 0)
+	}
+
+	fmt.Printf("Memory at %s:\n", name)
+	lines := chip.HexDump(start, p.rows*16, chip8.HexDumpOptions{
+		Marked: func(addr int) bool { return d.Written(uint16(addr)) },
+	})
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+
+}
+
+// printWatchHits prints the accesses of the last instruction that triggered a watchpoint.
+func printWatchHits(d *chip8.Debugger) {
+	for _, hit := range d.WatchHits() {
+		fmt.Println(hit)
+	}
+}
+
+// serveDebug serves the debugger protocol of chip8.DebugServer on a local port until Ctrl-C,
+// for editors and other tools to drive the chip.
+func serveDebug(chip *chip8.Chip8, port int) error {
+
+	l, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "debug server listening on %s, Ctrl-C stops it\n", l.Addr())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return chip8.NewDebugServer(chip).Serve(ctx, l)
+
+}
+
+// parseHex parses an address, with or without a 0x prefix.
+func parseHex(s string) (uint16, error) {
+
+	addr, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", s)
+	}
+
+	return uint16(addr), nil
+
+}
+
+// opcodeAt reads the opcode at addr from the chip memory.
+func opcodeAt(chip *chip8.Chip8, addr uint16) uint16 {
+
+	data := chip.Memory(addr, 2)
+	if len(data) < 2 {
+		return 0
+	}
+
+	return uint16(data[0])<<8 | uint16(data[1])
+
+}
@@ -0,0 +1,409 @@
+//go:build ebiten
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"image"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"chip8-go/chip8"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Sample rate of the beep, and how much of it the player buffers ahead.
+const (
+	ebiten_sample_rate = 44100
+	ebiten_audio_ahead = 2 * time.Second / 60
+)
+
+func init() {
+	frontends["ebiten"] = runEbiten
+}
+
+// ebitenFrontend connects the chip, running on its own goroutine, to an ebiten window. Ebiten
+// calls the game from the main thread, so the two sides only share the fields under mu.
+// It implements chip8.Display and chip8.Input, ebitenGame implements ebiten.Game.
+type ebitenFrontend struct {
+	chip    *chip8.Chip8
+	keymap  chip8.Keymap
+	gamepad chip8.GamepadMap
+	palette chip8.Palette
+
+	// F5 saves the machine state to this file, F7 restores it.
+	state_file string
+
+	// F8 takes a screenshot, F9 starts and stops a recording.
+	capture *capture
+
+	// Hotkeys are run by PollKeys on the chip goroutine, their message is shown in the title.
+	actions chan func() string
+
+	// Backspace rewinds while held.
+	rewind *rewinder
+
+	mu        sync.Mutex
+	frame     chip8.Frame
+	crt       *chip8.CRT
+	overlay   *chip8.Overlay
+	keys      [16]bool
+	rewinding bool
+	title     string
+
+	// The beep, read by the audio player.
+	tone *ebitenTone
+}
+
+// ebitenGame is the ebiten side of the frontend. Its Draw would clash with chip8.Display.
+type ebitenGame struct {
+	fe *ebitenFrontend
+
+	// Frame buffer written to the screen, sized to the window.
+	pixels *image.RGBA
+	title  string
+
+	// Receives the result of the chip goroutine.
+	done chan error
+}
+
+// runEbiten opens a window of scale times the display size and runs the chip until the
+// window is closed or Escape is pressed.
+func runEbiten(chip *chip8.Chip8, opts options) error {
+
+	scale := max(opts.Scale, 1)
+
+	ebiten.SetWindowSize(chip8.DisplayWidth*scale, chip8.DisplayHeight*scale)
+	ebiten.SetWindowTitle("CHIP-8")
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+
+	fe := &ebitenFrontend{
+		chip:       chip,
+		keymap:     opts.Keymap,
+		gamepad:    opts.Gamepad,
+		palette:    opts.Palette,
+		state_file: opts.StateFile,
+		capture:    opts.Capture,
+		actions:    make(chan func() string, 8),
+		rewind:     newRewinder(chip),
+		crt:        newCRT(opts),
+		overlay:    chip8.NewOverlay(),
+	}
+
+	if !opts.Mute {
+		fe.tone = &ebitenTone{tone: chip8.NewTone(opts.ToneHz, opts.Volume)}
+		player, err := audio.NewContext(ebiten_sample_rate).NewPlayer(fe.tone)
+		if err == nil {
+			player.SetBufferSize(ebiten_audio_ahead)
+			player.Play()
+			defer player.Close()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	game := &ebitenGame{fe: fe, done: make(chan error, 1)}
+	go func() {
+		game.done <- chip.RunWith(ctx, fe, fe)
+	}()
+
+	if err := ebiten.RunGame(game); err != nil {
+		return err
+	}
+
+	// The window was closed, stop the chip and wait for it.
+	cancel()
+
+	return <-game.done
+
+}
+
+// Update reads the keyboard. Escape quits, F1 shows the debug overlay, F2 pauses and resumes, F3 resets, F4 reloads the
+// ROM from disk, F5 and F7 save and load the state, F6 toggles turbo, F8 takes a screenshot, F9
+// starts and stops a recording, F10 and F11 slow down and speed up, F12 toggles slow motion, and
+// holding Backspace rewinds.
+func (g *ebitenGame) Update() error {
+
+	select {
+	case err := <-g.done:
+		// The chip stopped by itself, e.g. on 00FD or an invalid opcode.
+		g.done <- err
+		return ebiten.Termination
+	default:
+	}
+
+	if ebiten.IsKeyPressed(ebiten.KeyEscape) {
+		return ebiten.Termination
+	}
+
+	fe := g.fe
+
+	for key, action := range map[ebiten.Key]func() string{
+		ebiten.KeyF1:  fe.toggleOverlay,
+		ebiten.KeyF2:  func() string { return togglePause(fe.chip) },
+		ebiten.KeyF3:  func() string { return resetChip(fe.chip) },
+		ebiten.KeyF4:  func() string { return reloadROM(fe.chip) },
+		ebiten.KeyF5:  func() string { return saveState(fe.chip, fe.state_file) },
+		ebiten.KeyF6:  func() string { return toggleSpeed(fe.chip, chip8.SpeedTurbo) },
+		ebiten.KeyF7:  func() string { return loadState(fe.chip, fe.state_file) },
+		ebiten.KeyF8:  func() string { return fe.capture.screenshot() },
+		ebiten.KeyF9:  func() string { return fe.capture.toggleRecording() },
+		ebiten.KeyF10: func() string { return changeSpeed(fe.chip, false) },
+		ebiten.KeyF11: func() string { return changeSpeed(fe.chip, true) },
+		ebiten.KeyF12: func() string { return toggleSpeed(fe.chip, chip8.SpeedSlow) },
+	} {
+		if inpututil.IsKeyJustPressed(key) {
+			select {
+			case fe.actions <- action:
+			default:
+			}
+		}
+	}
+
+	var keys [16]bool
+	for _, k := range inpututil.AppendPressedKeys(nil) {
+		if r, ok := ebitenKeyRune(k); ok {
+			if key, ok := fe.keymap.Lookup(r); ok {
+				keys[key] = true
+			}
+		}
+	}
+	for _, id := range ebiten.AppendGamepadIDs(nil) {
+		if ebiten.IsStandardGamepadLayoutAvailable(id) {
+			fe.gamepad.Press(&keys, ebitenGamepadButtons(id))
+		}
+	}
+
+	fe.mu.Lock()
+	fe.keys = keys
+	fe.rewinding = ebiten.IsKeyPressed(ebiten.KeyBackspace)
+	title := fe.title
+	fe.mu.Unlock()
+
+	if title != g.title {
+		g.title = title
+		ebiten.SetWindowTitle("CHIP-8 - " + title)
+	}
+
+	return nil
+
+}
+
+// Draw renders the last frame of the chip letterboxed in the window.
+func (g *ebitenGame) Draw(screen *ebiten.Image) {
+
+	bounds := screen.Bounds()
+	if g.pixels == nil || g.pixels.Rect.Size() != bounds.Size() {
+		g.pixels = image.NewRGBA(image.Rectangle{Max: bounds.Size()})
+	}
+
+	g.fe.mu.Lock()
+	frame := g.fe.frame
+	var vp chip8.Viewport
+	if g.fe.crt != nil && frame.Width != 0 {
+		vp = g.fe.crt.Draw(g.pixels)
+	}
+	g.fe.mu.Unlock()
+
+	// Nothing to show before the chip drew its first frame.
+	if frame.Width == 0 {
+		return
+	}
+
+	if g.fe.crt == nil {
+		vp = chip8.DrawFrameLetterboxed(g.pixels, frame, g.fe.palette)
+	}
+
+	// The overlay is updated on the chip goroutine.
+	g.fe.mu.Lock()
+	g.fe.overlay.Draw(g.pixels, vp)
+	g.fe.mu.Unlock()
+
+	screen.WritePixels(g.pixels.Pix)
+
+}
+
+// Layout uses the window size as is, the frame is scaled by DrawFrameLetterboxed.
+func (g *ebitenGame) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return outsideWidth, outsideHeight
+}
+
+// PollKeys runs the hotkeys pressed since the last frame and returns the keypad.
+func (fe *ebitenFrontend) PollKeys() [16]bool {
+
+drain:
+	for {
+		select {
+		case action := <-fe.actions:
+			msg := action()
+			fe.mu.Lock()
+			fe.title = msg
+			fe.mu.Unlock()
+		default:
+			break drain
+		}
+	}
+
+	fe.mu.Lock()
+	keys, rewinding := fe.keys, fe.rewinding
+	fe.overlay.Update(fe.chip)
+	fe.mu.Unlock()
+
+	fe.rewind.frame(rewinding)
+
+	return keys
+
+}
+
+// toggleOverlay shows or hides the debug overlay, which the window draws, and returns a message
+// for the user.
+func (fe *ebitenFrontend) toggleOverlay() string {
+
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	return fe.overlay.Toggle()
+
+}
+
+// Draw hands the frame to the window, which shows it on its next refresh.
+func (fe *ebitenFrontend) Draw(frame chip8.Frame) error {
+
+	fe.mu.Lock()
+	fe.frame = frame
+	if fe.crt != nil {
+		fe.crt.Update(frame)
+	}
+	fe.mu.Unlock()
+
+	return nil
+
+}
+
+// Animating keeps the frames coming while the CRT fades pixels out.
+func (fe *ebitenFrontend) Animating() bool {
+
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	return fe.crt != nil && fe.crt.Fading()
+
+}
+
+// Beep starts or stops the tone. XO-CHIP programs play their own audio pattern, Megachip
+// ones their digitised sounds.
+func (fe *ebitenFrontend) Beep(on bool) {
+
+	if fe.tone == nil {
+		return
+	}
+
+	pattern, rate, has_pattern := fe.chip.AudioPattern()
+	samples, sample_rate, loop, _ := fe.chip.DigitisedSound()
+
+	fe.tone.mu.Lock()
+	fe.tone.playing = on
+	if has_pattern {
+		fe.tone.tone.SetPattern(pattern, rate)
+	}
+	fe.tone.tone.SetSamples(samples, float64(sample_rate), loop)
+	fe.tone.mu.Unlock()
+
+}
+
+// ebitenTone streams the beep to an audio player as 16-bit little endian stereo, the format
+// ebiten plays, and silence while the tone is off.
+type ebitenTone struct {
+	mu      sync.Mutex
+	tone    *chip8.Tone
+	playing bool
+	samples []int16
+}
+
+func (t *ebitenTone) Read(p []byte) (int, error) {
+
+	n := len(p) / 4
+
+	if cap(t.samples) < n {
+		t.samples = make([]int16, n)
+	}
+	samples := t.samples[:n]
+
+	t.mu.Lock()
+	if t.playing {
+		t.tone.Samples(samples, ebiten_sample_rate)
+	} else {
+		clear(samples)
+	}
+	t.mu.Unlock()
+
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(p[4*i:], uint16(s))
+		binary.LittleEndian.PutUint16(p[4*i+2:], uint16(s))
+	}
+
+	return 4 * n, nil
+
+}
+
+// Names of the punctuation keys, ebiten names letters by themselves and digits "Digit0" to "Digit9".
+var ebiten_key_runes = map[string]rune{
+	"Comma": ',', "Period": '.', "Slash": '/', "Semicolon": ';', "Quote": '\'',
+	"Minus": '-', "Equal": '=', "BracketLeft": '[', "BracketRight": ']',
+	"Backslash": '\\', "Backquote": '`', "Space": ' ',
+}
+
+// Gamepad buttons of ebiten's standard layout, indexed by chip8.GamepadButton.
+var ebiten_gamepad_buttons = [chip8.GamepadButtons]ebiten.StandardGamepadButton{
+	chip8.GamepadUp:            ebiten.StandardGamepadButtonLeftTop,
+	chip8.GamepadDown:          ebiten.StandardGamepadButtonLeftBottom,
+	chip8.GamepadLeft:          ebiten.StandardGamepadButtonLeftLeft,
+	chip8.GamepadRight:         ebiten.StandardGamepadButtonLeftRight,
+	chip8.GamepadA:             ebiten.StandardGamepadButtonRightBottom,
+	chip8.GamepadB:             ebiten.StandardGamepadButtonRightRight,
+	chip8.GamepadX:             ebiten.StandardGamepadButtonRightLeft,
+	chip8.GamepadY:             ebiten.StandardGamepadButtonRightTop,
+	chip8.GamepadLeftShoulder:  ebiten.StandardGamepadButtonFrontTopLeft,
+	chip8.GamepadRightShoulder: ebiten.StandardGamepadButtonFrontTopRight,
+	chip8.GamepadBack:          ebiten.StandardGamepadButtonCenterLeft,
+	chip8.GamepadStart:         ebiten.StandardGamepadButtonCenterRight,
+}
+
+// ebitenGamepadButtons returns the buttons held down on a gamepad, the left stick pressing the d-pad.
+func ebitenGamepadButtons(id ebiten.GamepadID) chip8.GamepadState {
+
+	var held chip8.GamepadState
+	for b, button := range ebiten_gamepad_buttons {
+		held[b] = ebiten.IsStandardGamepadButtonPressed(id, button)
+	}
+
+	held.PushStick(
+		ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickHorizontal),
+		ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickVertical))
+
+	return held
+
+}
+
+// ebitenKeyRune returns the character a key types without modifiers, for the keymap.
+func ebitenKeyRune(k ebiten.Key) (rune, bool) {
+
+	name := strings.TrimPrefix(k.String(), "Digit")
+
+	if r, ok := ebiten_key_runes[name]; ok {
+		return r, true
+	}
+	if runes := []rune(name); len(runes) == 1 {
+		return unicode.ToLower(runes[0]), true
+	}
+
+	return 0, false
+
+}
@@ -0,0 +1,379 @@
+//go:build sdl
+
+package main
+
+import (
+	"context"
+	"image"
+	"runtime"
+	"time"
+	"unsafe"
+
+	"chip8-go/chip8"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Sample rate of the beep, and how much of it is kept queued ahead of playback.
+const (
+	sdl_sample_rate = 44100
+	sdl_audio_ahead = 2 * time.Second / 60
+)
+
+func init() {
+	// SDL must be driven from the main OS thread.
+	runtime.LockOSThread()
+
+	frontends["sdl"] = runSDL
+}
+
+// sdlFrontend draws the display into a resizable window and feeds the keyboard to the keypad.
+// It implements chip8.Display and chip8.Input.
+type sdlFrontend struct {
+	window   *sdl.Window
+	renderer *sdl.Renderer
+	texture  *sdl.Texture
+	chip     *chip8.Chip8
+	keymap   chip8.Keymap
+	palette  chip8.Palette
+	quit     func()
+
+	// Draws the display with CRT effects, nil without.
+	crt *chip8.CRT
+
+	// F1 shows and hides the debug overlay.
+	overlay *chip8.Overlay
+
+	// F5 saves the machine state to this file, F7 restores it.
+	state_file string
+
+	// F8 takes a screenshot, F9 starts and stops a recording.
+	capture *capture
+
+	// Audio device, 0 when muted or unavailable.
+	audio   sdl.AudioDeviceID
+	tone    *chip8.Tone
+	playing bool
+
+	// Frame buffer uploaded to the texture, sized to the renderer output.
+	frame *image.RGBA
+
+	// Backspace rewinds while held.
+	rewind    *rewinder
+	rewinding bool
+
+	// Gamepads plugged in, and the keys their buttons press.
+	controllers []*sdl.GameController
+	gamepad     chip8.GamepadMap
+
+	keys [16]bool
+	last chip8.Frame
+}
+
+// Gamepad buttons of SDL, indexed by chip8.GamepadButton. The d-pad also follows the left stick.
+var sdl_gamepad_buttons = [chip8.GamepadButtons]sdl.GameControllerButton{
+	chip8.GamepadUp:            sdl.CONTROLLER_BUTTON_DPAD_UP,
+	chip8.GamepadDown:          sdl.CONTROLLER_BUTTON_DPAD_DOWN,
+	chip8.GamepadLeft:          sdl.CONTROLLER_BUTTON_DPAD_LEFT,
+	chip8.GamepadRight:         sdl.CONTROLLER_BUTTON_DPAD_RIGHT,
+	chip8.GamepadA:             sdl.CONTROLLER_BUTTON_A,
+	chip8.GamepadB:             sdl.CONTROLLER_BUTTON_B,
+	chip8.GamepadX:             sdl.CONTROLLER_BUTTON_X,
+	chip8.GamepadY:             sdl.CONTROLLER_BUTTON_Y,
+	chip8.GamepadLeftShoulder:  sdl.CONTROLLER_BUTTON_LEFTSHOULDER,
+	chip8.GamepadRightShoulder: sdl.CONTROLLER_BUTTON_RIGHTSHOULDER,
+	chip8.GamepadBack:          sdl.CONTROLLER_BUTTON_BACK,
+	chip8.GamepadStart:         sdl.CONTROLLER_BUTTON_START,
+}
+
+// runSDL opens a window of scale times the display size and runs the chip until the
+// window is closed or Escape is pressed.
+func runSDL(chip *chip8.Chip8, opts options) error {
+
+	if err := sdl.Init(sdl.INIT_VIDEO | sdl.INIT_AUDIO | sdl.INIT_GAMECONTROLLER); err != nil {
+		return err
+	}
+	defer sdl.Quit()
+
+	scale := max(opts.Scale, 1)
+
+	window, err := sdl.CreateWindow("CHIP-8", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
+		int32(chip8.DisplayWidth*scale), int32(chip8.DisplayHeight*scale), sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE)
+	if err != nil {
+		return err
+	}
+	defer window.Destroy()
+
+	renderer, err := sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED|sdl.RENDERER_PRESENTVSYNC)
+	if err != nil {
+		return err
+	}
+	defer renderer.Destroy()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fe := &sdlFrontend{window: window, renderer: renderer, chip: chip, keymap: opts.Keymap, palette: opts.Palette, quit: cancel, state_file: opts.StateFile, capture: opts.Capture}
+	fe.crt = newCRT(opts)
+	fe.overlay = chip8.NewOverlay()
+	fe.rewind = newRewinder(chip)
+	defer fe.destroyTexture()
+
+	fe.gamepad = opts.Gamepad
+	fe.openControllers()
+	defer fe.closeControllers()
+
+	if !opts.Mute {
+		fe.openAudio(opts)
+		defer fe.closeAudio()
+	}
+
+	return chip.RunWith(ctx, fe, fe)
+
+}
+
+// PollKeys drains the SDL event queue, tracking mapped keys. Closing the window or
+// pressing Escape quits, resizing it repaints the display. F1 shows the debug overlay, F2 pauses and resumes, F3 resets, F4 reloads
+// the ROM from disk, F5 and F7 save and load the state, F6 toggles turbo, F8 takes a screenshot, F9
+// starts and stops a recording, F10 and F11 slow down and speed up, F12 toggles slow motion, and
+// holding Backspace rewinds. Gamepad buttons press the keys they are mapped to.
+func (fe *sdlFrontend) PollKeys() [16]bool {
+
+	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+		switch e := event.(type) {
+
+		case *sdl.QuitEvent:
+			fe.quit()
+
+		case *sdl.ControllerDeviceEvent:
+			if e.Type == sdl.CONTROLLERDEVICEADDED || e.Type == sdl.CONTROLLERDEVICEREMOVED {
+				fe.openControllers()
+			}
+
+		case *sdl.WindowEvent:
+			// Nothing to repaint before the first frame was drawn.
+			if fe.last.Width == 0 {
+				continue
+			}
+			if e.Event == sdl.WINDOWEVENT_SIZE_CHANGED || e.Event == sdl.WINDOWEVENT_EXPOSED {
+				fe.Draw(fe.last)
+			}
+
+		case *sdl.KeyboardEvent:
+			switch {
+			case e.Keysym.Sym == sdl.K_ESCAPE:
+				fe.quit()
+			case e.Keysym.Sym == sdl.K_BACKSPACE:
+				fe.rewinding = e.Type == sdl.KEYDOWN
+			case e.Keysym.Sym == sdl.K_F1 && e.Type == sdl.KEYDOWN:
+				fe.window.SetTitle("CHIP-8 - " + fe.overlay.Toggle())
+			case e.Keysym.Sym == sdl.K_F2 && e.Type == sdl.KEYDOWN:
+				fe.window.SetTitle("CHIP-8 - " + togglePause(fe.chip))
+			case e.Keysym.Sym == sdl.K_F3 && e.Type == sdl.KEYDOWN:
+				fe.window.SetTitle("CHIP-8 - " + resetChip(fe.chip))
+			case e.Keysym.Sym == sdl.K_F4 && e.Type == sdl.KEYDOWN:
+				fe.window.SetTitle("CHIP-8 - " + reloadROM(fe.chip))
+			case e.Keysym.Sym == sdl.K_F5 && e.Type == sdl.KEYDOWN:
+				fe.window.SetTitle("CHIP-8 - " + saveState(fe.chip, fe.state_file))
+			case e.Keysym.Sym == sdl.K_F6 && e.Type == sdl.KEYDOWN:
+				fe.window.SetTitle("CHIP-8 - " + toggleSpeed(fe.chip, chip8.SpeedTurbo))
+			case e.Keysym.Sym == sdl.K_F7 && e.Type == sdl.KEYDOWN:
+				fe.window.SetTitle("CHIP-8 - " + loadState(fe.chip, fe.state_file))
+			case e.Keysym.Sym == sdl.K_F8 && e.Type == sdl.KEYDOWN:
+				fe.window.SetTitle("CHIP-8 - " + fe.capture.screenshot())
+			case e.Keysym.Sym == sdl.K_F9 && e.Type == sdl.KEYDOWN:
+				fe.window.SetTitle("CHIP-8 - " + fe.capture.toggleRecording())
+			case e.Keysym.Sym == sdl.K_F10 && e.Type == sdl.KEYDOWN:
+				fe.window.SetTitle("CHIP-8 - " + changeSpeed(fe.chip, false))
+			case e.Keysym.Sym == sdl.K_F11 && e.Type == sdl.KEYDOWN:
+				fe.window.SetTitle("CHIP-8 - " + changeSpeed(fe.chip, true))
+			case e.Keysym.Sym == sdl.K_F12 && e.Type == sdl.KEYDOWN:
+				fe.window.SetTitle("CHIP-8 - " + toggleSpeed(fe.chip, chip8.SpeedSlow))
+			}
+			// Keycodes of letters and digits are their ASCII values.
+			if key, ok := fe.keymap.Lookup(rune(e.Keysym.Sym)); ok {
+				fe.keys[key] = e.Type == sdl.KEYDOWN
+			}
+		}
+	}
+
+	fe.rewind.frame(fe.rewinding)
+	fe.overlay.Update(fe.chip)
+	fe.queueAudio()
+
+	keys := fe.keys
+	for _, c := range fe.controllers {
+		fe.gamepad.Press(&keys, controllerButtons(c))
+	}
+
+	return keys
+
+}
+
+// openControllers opens every gamepad plugged in, closing those opened before. Joysticks
+// unknown to SDL as gamepads are left alone, their buttons have no standard layout.
+func (fe *sdlFrontend) openControllers() {
+
+	fe.closeControllers()
+
+	for i := 0; i < sdl.NumJoysticks(); i++ {
+		if !sdl.IsGameController(i) {
+			continue
+		}
+		if c := sdl.GameControllerOpen(i); c != nil {
+			fe.controllers = append(fe.controllers, c)
+		}
+	}
+
+}
+
+func (fe *sdlFrontend) closeControllers() {
+	for _, c := range fe.controllers {
+		c.Close()
+	}
+	fe.controllers = nil
+}
+
+// controllerButtons returns the buttons held down on a gamepad, the left stick pressing the d-pad.
+func controllerButtons(c *sdl.GameController) chip8.GamepadState {
+
+	var held chip8.GamepadState
+	for b, button := range sdl_gamepad_buttons {
+		held[b] = c.Button(button) == sdl.PRESSED
+	}
+
+	// Axes go from -32768 to 32767.
+	held.PushStick(float64(c.Axis(sdl.CONTROLLER_AXIS_LEFTX))/32768, float64(c.Axis(sdl.CONTROLLER_AXIS_LEFTY))/32768)
+
+	return held
+
+}
+
+// openAudio opens the default audio device for the beep. Without one the emulator runs silent.
+func (fe *sdlFrontend) openAudio(opts options) {
+
+	spec := sdl.AudioSpec{
+		Freq:     sdl_sample_rate,
+		Format:   sdl.AUDIO_S16SYS,
+		Channels: 1,
+		Samples:  512,
+	}
+
+	dev, err := sdl.OpenAudioDevice("", false, &spec, nil, 0)
+	if err != nil {
+		return
+	}
+
+	fe.audio = dev
+	fe.tone = chip8.NewTone(opts.ToneHz, opts.Volume)
+
+	sdl.PauseAudioDevice(dev, false)
+
+}
+
+func (fe *sdlFrontend) closeAudio() {
+	if fe.audio != 0 {
+		sdl.CloseAudioDevice(fe.audio)
+		fe.audio = 0
+	}
+}
+
+// Beep starts or stops the square wave. Stopping drops what is still queued so the tone
+// ends with the sound timer, so does a Megachip digitised sound starting.
+func (fe *sdlFrontend) Beep(on bool) {
+
+	fe.playing = on
+
+	if fe.audio == 0 {
+		return
+	}
+
+	samples, rate, loop, digitised := fe.chip.DigitisedSound()
+	fe.tone.SetSamples(samples, float64(rate), loop)
+
+	if !on || digitised {
+		sdl.ClearQueuedAudio(fe.audio)
+	}
+
+}
+
+// queueAudio tops the audio queue up to sdl_audio_ahead of samples while the tone plays.
+func (fe *sdlFrontend) queueAudio() {
+
+	if !fe.playing || fe.audio == 0 {
+		return
+	}
+
+	const ahead = int(sdl_sample_rate * sdl_audio_ahead / time.Second)
+
+	queued := int(sdl.GetQueuedAudioSize(fe.audio)) / 2
+	if queued >= ahead {
+		return
+	}
+
+	// XO-CHIP programs play their own audio pattern.
+	if pattern, rate, ok := fe.chip.AudioPattern(); ok {
+		fe.tone.SetPattern(pattern, rate)
+	}
+
+	samples := make([]int16, ahead-queued)
+	fe.tone.Samples(samples, sdl_sample_rate)
+
+	sdl.QueueAudio(fe.audio, unsafe.Slice((*byte)(unsafe.Pointer(&samples[0])), 2*len(samples)))
+
+}
+
+// Draw renders the display letterboxed in the window.
+func (fe *sdlFrontend) Draw(frame chip8.Frame) error {
+
+	w, h, err := fe.renderer.GetOutputSize()
+	if err != nil {
+		return err
+	}
+
+	if fe.frame == nil || fe.frame.Rect.Dx() != int(w) || fe.frame.Rect.Dy() != int(h) {
+		fe.destroyTexture()
+
+		// RGBA32 is the R, G, B, A byte order of image.RGBA whatever the endianness.
+		fe.texture, err = fe.renderer.CreateTexture(uint32(sdl.PIXELFORMAT_RGBA32), sdl.TEXTUREACCESS_STREAMING, w, h)
+		if err != nil {
+			return err
+		}
+		fe.frame = image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
+	}
+
+	var vp chip8.Viewport
+	if fe.crt != nil {
+		fe.crt.Update(frame)
+		vp = fe.crt.Draw(fe.frame)
+	} else {
+		vp = chip8.DrawFrameLetterboxed(fe.frame, frame, fe.palette)
+	}
+	fe.overlay.Draw(fe.frame, vp)
+
+	if err := fe.texture.Update(nil, unsafe.Pointer(&fe.frame.Pix[0]), fe.frame.Stride); err != nil {
+		return err
+	}
+	if err := fe.renderer.Copy(fe.texture, nil, nil); err != nil {
+		return err
+	}
+	fe.renderer.Present()
+
+	fe.last = frame
+
+	return nil
+
+}
+
+// Animating keeps the display drawn while the CRT fades pixels out, and while the overlay shows
+// the registers changing.
+func (fe *sdlFrontend) Animating() bool {
+	return fe.crt != nil && fe.crt.Fading() || fe.overlay.Visible()
+}
+
+func (fe *sdlFrontend) destroyTexture() {
+	if fe.texture != nil {
+		fe.texture.Destroy()
+		fe.texture = nil
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"chip8-go/chip8"
+)
+
+func TestLoadStateHotkeyRejectsCorruptedFile(t *testing.T) {
+
+	chip := chip8.New()
+	if err := chip.LoadROMBytes([]byte{0x60, 0x01, 0x70, 0x01, 0x12, 0x02}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chip.RunCycles(5); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "game.state")
+	if msg := saveState(chip, path); msg != "state saved to "+path {
+		t.Fatal(msg)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chip.RunCycles(5); err != nil {
+		t.Fatal(err)
+	}
+	before := chip.State()
+
+	// Cut short, the state is rejected and the game goes on where it was.
+	if err := os.WriteFile(path, data[:len(data)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if msg := loadState(chip, path); !strings.Contains(msg, "invalid save state") {
+		t.Errorf("loading a truncated state: %q", msg)
+	}
+	if chip.State() != before {
+		t.Errorf("the truncated state changed the machine: %+v, was %+v", chip.State(), before)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if msg := loadState(chip, path); msg != "state loaded from "+path {
+		t.Errorf("loading the saved state: %q", msg)
+	}
+
+}
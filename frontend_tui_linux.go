@@ -0,0 +1,537 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"chip8-go/chip8"
+)
+
+func init() {
+	frontends["tui"] = runTUI
+	compare_frontends["tui"] = runCompareTUI
+}
+
+// Terminals only report key presses, so a key counts as held for this many frames after its
+// last press. Auto-repeat keeps a held key down.
+const tui_key_hold = 6
+
+// Each terminal cell shows two display rows, top and bottom, with half block characters.
+var tui_blocks = [4]string{" ", "▀", "▄", "█"}
+
+// tuiFrontend draws the display with block characters and reads the keypad from stdin.
+// It implements chip8.Display and chip8.Input.
+type tuiFrontend struct {
+	out    *bufio.Writer
+	keymap chip8.Keymap
+	input  <-chan []byte
+	winch  <-chan os.Signal
+	quit   func()
+	mute   bool
+
+	// Controlled by the function keys. F5 saves the machine state to state_file, F7 restores it,
+	// F8 and F9 take screenshots and recordings.
+	chip       *chip8.Chip8
+	state_file string
+	capture    *capture
+
+	// Shown on the last line until the next hotkey.
+	status string
+
+	// Escape sequence setting the palette colors, empty for the terminal's own colors.
+	colors string
+
+	// Colors of the XO-CHIP planes, which need a color per pixel.
+	palette chip8.Palette
+
+	width, height int
+
+	// Set after a resize so the next Draw clears the old picture.
+	clear bool
+
+	// Frames left before each CHIP-8 key is released.
+	held [16]int
+
+	// Backspace rewinds, released like the keypad keys.
+	rewind    *rewinder
+	rewinding int
+
+	last chip8.Frame
+}
+
+// runTUI runs the chip in the terminal until Escape or Ctrl-C is pressed.
+func runTUI(chip *chip8.Chip8, opts options) error {
+
+	fe, ctx, restore, err := startTUI(opts)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	fe.chip = chip
+	fe.state_file = opts.StateFile
+	fe.capture = opts.Capture
+	fe.rewind = newRewinder(chip)
+
+	// Log messages written to the terminal would garble the screen.
+	defer logToStatus(chip, func(msg string) { fe.status = msg })()
+
+	return chip.RunWith(ctx, fe, fe)
+
+}
+
+// startTUI takes over the terminal: raw input read in the background and the alternate screen.
+// The context is done when the user quits, restore gives the terminal back.
+func startTUI(opts options) (*tuiFrontend, context.Context, func(), error) {
+
+	fd := int(os.Stdin.Fd())
+
+	saved, err := makeRaw(fd)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("tui needs a terminal: %w", err)
+	}
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+
+	input := make(chan []byte, 16)
+	go readInput(os.Stdin, input)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	fe := &tuiFrontend{
+		out:    bufio.NewWriter(os.Stdout),
+		keymap: opts.Keymap,
+		input:  input,
+		winch:  winch,
+		quit:   cancel,
+		mute:   opts.Mute,
+
+		palette: opts.Palette,
+	}
+
+	// Pixels that are on are drawn in the foreground color, so the palette only needs
+	// setting when it is not the default white on black.
+	if p := opts.Palette; p.Foreground != chip8.DefaultPalette.Foreground || p.Background != chip8.DefaultPalette.Background {
+		fe.colors = fmt.Sprintf("\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm",
+			p.Foreground.R, p.Foreground.G, p.Foreground.B, p.Background.R, p.Background.G, p.Background.B)
+	}
+
+	// Alternate screen, hidden cursor.
+	fe.out.WriteString("\x1b[?1049h\x1b[?25l")
+
+	fe.resize()
+
+	restore := func() {
+		fe.out.WriteString("\x1b[?25h\x1b[?1049l")
+		fe.out.Flush()
+		cancel()
+		signal.Stop(winch)
+		setTermios(fd, saved)
+	}
+
+	return fe, ctx, restore, nil
+
+}
+
+// Color of the pixels that differ between the machines of a comparison.
+var tui_diverged = color.RGBA{0xFF, 0x30, 0x30, 0xFF}
+
+// runCompareTUI draws the two machines of a lockstep side by side, the pixels that differ in
+// red, until Escape or Ctrl-C is pressed. The status line shows the first divergence. The
+// hotkeys are off, they would only act on one machine.
+func runCompareTUI(lockstep *chip8.Lockstep, opts options) error {
+
+	fe, ctx, restore, err := startTUI(opts)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	ticker := time.NewTicker(time.Second / 60)
+	defer ticker.Stop()
+
+	for {
+		select {
+
+		case <-ctx.Done():
+			return nil
+
+		case <-ticker.C:
+			lockstep.SetKeys(fe.PollKeys())
+
+			d, err := lockstep.Frame()
+			if err != nil {
+				return err
+			}
+
+			fe.status = "in lockstep"
+			if lockstep.First != nil {
+				fe.status = "first divergence at " + lockstep.First.String()
+			}
+
+			if err := fe.drawCompare(lockstep.A.Display(), lockstep.B.Display(), d); err != nil {
+				return err
+			}
+		}
+	}
+
+}
+
+// readInput forwards everything read from r to ch until r fails.
+func readInput(r *os.File, ch chan<- []byte) {
+	for {
+		buf := make([]byte, 16)
+		n, err := r.Read(buf)
+		if err != nil {
+			close(ch)
+			return
+		}
+		ch <- buf[:n]
+	}
+}
+
+// PollKeys applies pending key presses and releases keys not pressed recently.
+// Escape, Ctrl-C or the end of input quit. A terminal resize repaints the display.
+// Function keys control the emulator, see hotkey, and holding Backspace rewinds.
+func (fe *tuiFrontend) PollKeys() [16]bool {
+
+	select {
+	case <-fe.winch:
+		fe.resize()
+		fe.Draw(fe.last)
+	default:
+	}
+
+	for k := range fe.held {
+		if fe.held[k] > 0 {
+			fe.held[k]--
+		}
+	}
+	if fe.rewinding > 0 {
+		fe.rewinding--
+	}
+
+	for done := false; !done; {
+		select {
+
+		case buf, ok := <-fe.input:
+			if !ok {
+				fe.quit()
+				done = true
+				continue
+			}
+
+			// A lone Escape quits; longer reads starting with Escape are arrow keys and the like.
+			if len(buf) == 1 && buf[0] == 0x1B {
+				fe.quit()
+			}
+
+			for len(buf) > 0 {
+				// Escape sequences are not keypad keys, whatever characters they contain.
+				if buf[0] == 0x1B && len(buf) > 1 {
+					var seq string
+					seq, buf = escapeSequence(buf)
+					fe.hotkey(seq)
+					continue
+				}
+
+				switch buf[0] {
+				case 0x03:
+					fe.quit()
+				case 0x7F, 0x08:
+					fe.rewinding = tui_key_hold
+				}
+				if key, ok := fe.keymap.Lookup(rune(buf[0])); ok {
+					fe.held[key] = tui_key_hold
+				}
+				buf = buf[1:]
+			}
+
+		default:
+			done = true
+		}
+	}
+
+	if fe.rewind != nil {
+		fe.rewind.frame(fe.rewinding > 0)
+	}
+
+	var keys [16]bool
+	for k := range fe.held {
+		keys[k] = fe.held[k] > 0
+	}
+	return keys
+
+}
+
+// Escape sequences xterm and most terminals send for the hotkeys.
+const (
+	tui_f2  = "\x1bOQ"
+	tui_f3  = "\x1bOR"
+	tui_f4  = "\x1bOS"
+	tui_f5  = "\x1b[15~"
+	tui_f6  = "\x1b[17~"
+	tui_f7  = "\x1b[18~"
+	tui_f8  = "\x1b[19~"
+	tui_f9  = "\x1b[20~"
+	tui_f10 = "\x1b[21~"
+	tui_f11 = "\x1b[23~"
+	tui_f12 = "\x1b[24~"
+)
+
+// escapeSequence splits the escape sequence at the start of buf from the rest: Escape, then
+// for CSI sequences [ up to a final byte from @ to ~, O and a byte for SS3 sequences,
+// otherwise a single byte.
+func escapeSequence(buf []byte) (string, []byte) {
+
+	end := 2
+	if buf[1] == 'O' {
+		end = min(3, len(buf))
+	}
+	if buf[1] == '[' {
+		for end < len(buf) && (buf[end] < 0x40 || buf[end] > 0x7E) {
+			end++
+		}
+		end = min(end+1, len(buf))
+	}
+
+	return string(buf[:end]), buf[end:]
+
+}
+
+// hotkey handles F2 to pause and resume, F3 to reset, F4 to reload the ROM, F5 to save the
+// machine state, F6 to toggle turbo, F7 to restore the state, F8 to take a screenshot, F9 to
+// start and stop a recording, F10 and F11 to slow down and speed up and F12 to toggle slow motion.
+func (fe *tuiFrontend) hotkey(seq string) {
+
+	if fe.chip == nil {
+		return
+	}
+
+	switch seq {
+	case tui_f2:
+		fe.status = togglePause(fe.chip)
+	case tui_f3:
+		fe.status = resetChip(fe.chip)
+	case tui_f4:
+		fe.status = reloadROM(fe.chip)
+	case tui_f5:
+		fe.status = saveState(fe.chip, fe.state_file)
+	case tui_f6:
+		fe.status = toggleSpeed(fe.chip, chip8.SpeedTurbo)
+	case tui_f7:
+		fe.status = loadState(fe.chip, fe.state_file)
+	case tui_f8:
+		fe.status = fe.capture.screenshot()
+	case tui_f9:
+		fe.status = fe.capture.toggleRecording()
+	case tui_f10:
+		fe.status = changeSpeed(fe.chip, false)
+	case tui_f11:
+		fe.status = changeSpeed(fe.chip, true)
+	case tui_f12:
+		fe.status = toggleSpeed(fe.chip, chip8.SpeedSlow)
+	default:
+		return
+	}
+
+	fe.clear = true
+	fe.Draw(fe.chip.Display())
+
+}
+
+// Beep rings the terminal bell when the tone starts, unless muted.
+func (fe *tuiFrontend) Beep(on bool) {
+	if on && !fe.mute {
+		fe.out.WriteString("\a")
+		fe.out.Flush()
+	}
+}
+
+// resize reads the terminal size.
+func (fe *tuiFrontend) resize() {
+
+	var ws struct{ Row, Col, Xpixel, Ypixel uint16 }
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdout.Fd(), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 || ws.Col == 0 || ws.Row == 0 {
+		ws.Col, ws.Row = 80, 24
+	}
+
+	fe.width, fe.height = int(ws.Col), int(ws.Row)
+	fe.clear = true
+
+}
+
+// Draw repaints the display centered in the terminal.
+func (fe *tuiFrontend) Draw(frame chip8.Frame) error {
+
+	// A change of resolution leaves the old picture around the new one.
+	if frame.Width != fe.last.Width {
+		fe.clear = true
+	}
+
+	rows := frame.Height / 2
+
+	// Pixels of the second XO-CHIP plane and of Megachip mode are colored each, the others
+	// only need the foreground color.
+	two_planes := frame.Planes[1] != chip8.Plane{} || frame.Mega != nil
+
+	if fe.clear {
+		fe.out.WriteString("\x1b[2J")
+		fe.clear = false
+	}
+
+	if fe.width < frame.Width || fe.height < rows {
+		fmt.Fprintf(fe.out, "\x1b[1;1Hterminal too small, need %dx%d", frame.Width, rows)
+	} else {
+
+		left := (fe.width-frame.Width)/2 + 1
+		top := (fe.height-rows)/2 + 1
+
+		for row := 0; row < rows; row++ {
+			fmt.Fprintf(fe.out, "\x1b[%d;%dH%s", top+row, left, fe.colors)
+
+			if two_planes {
+				fe.drawColorRow(frame.Width, row, func(x, y int) color.RGBA { return frame.Color(x, y, fe.palette) })
+				continue
+			}
+
+			for x := 0; x < frame.Width; x++ {
+				cell := 0
+				if frame.At(x, 2*row) {
+					cell |= 1
+				}
+				if frame.At(x, 2*row+1) {
+					cell |= 2
+				}
+				fe.out.WriteString(tui_blocks[cell])
+			}
+		}
+		if fe.colors != "" || two_planes {
+			fe.out.WriteString("\x1b[0m")
+		}
+	}
+
+	if fe.status != "" {
+		fmt.Fprintf(fe.out, "\x1b[%d;1H%s", fe.height, fe.status)
+	}
+
+	fe.last = frame
+
+	return fe.out.Flush()
+
+}
+
+// drawColorRow draws a row of the terminal, two rows of pixels, in the colors of at: the upper
+// half block in the color of the upper pixel over the color of the lower one.
+func (fe *tuiFrontend) drawColorRow(width, row int, at func(x, y int) color.RGBA) {
+
+	// Colors are only set when they change from the previous cell.
+	var last_top, last_bottom color.RGBA
+
+	for x := 0; x < width; x++ {
+		top, bottom := at(x, 2*row), at(x, 2*row+1)
+
+		if x == 0 || top != last_top {
+			fmt.Fprintf(fe.out, "\x1b[38;2;%d;%d;%dm", top.R, top.G, top.B)
+		}
+		if x == 0 || bottom != last_bottom {
+			fmt.Fprintf(fe.out, "\x1b[48;2;%d;%d;%dm", bottom.R, bottom.G, bottom.B)
+		}
+		last_top, last_bottom = top, bottom
+
+		fe.out.WriteString(tui_blocks[1])
+	}
+
+}
+
+// drawCompare repaints the displays of a comparison side by side, A on the left, with the
+// pixels of the divergence in red.
+func (fe *tuiFrontend) drawCompare(a, b chip8.Frame, d chip8.Divergence) error {
+
+	const gap = 2
+
+	width := a.Width + gap + b.Width
+	rows := max(a.Height, b.Height) / 2
+
+	// A change of resolution leaves the old picture around the new one.
+	if a.Width != fe.last.Width {
+		fe.clear = true
+	}
+	fe.last = a
+
+	diverged := map[image.Point]bool{}
+	for _, p := range d.Pixels {
+		diverged[p] = true
+	}
+
+	if fe.clear {
+		fe.out.WriteString("\x1b[2J")
+		fe.clear = false
+	}
+
+	if fe.width < width || fe.height < rows+1 {
+		fmt.Fprintf(fe.out, "\x1b[1;1Hterminal too small, need %dx%d", width, rows+1)
+	} else {
+
+		left := (fe.width-width)/2 + 1
+		top := (fe.height-rows)/2 + 1
+
+		for i, frame := range []chip8.Frame{a, b} {
+			x0 := left + i*(a.Width+gap)
+			at := func(x, y int) color.RGBA {
+				if diverged[image.Point{x, y}] {
+					return tui_diverged
+				}
+				return frame.Color(x, y, fe.palette)
+			}
+			for row := 0; row < frame.Height/2; row++ {
+				fmt.Fprintf(fe.out, "\x1b[%d;%dH", top+row, x0)
+				fe.drawColorRow(frame.Width, row, at)
+			}
+		}
+		fe.out.WriteString("\x1b[0m")
+	}
+
+	fmt.Fprintf(fe.out, "\x1b[%d;1H\x1b[2K%s", fe.height, fe.status)
+
+	return fe.out.Flush()
+
+}
+
+// makeRaw turns off line buffering, echo and signal keys on the terminal and returns the
+// previous settings.
+func makeRaw(fd int) (syscall.Termios, error) {
+
+	var saved syscall.Termios
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCGETS, uintptr(unsafe.Pointer(&saved))); errno != 0 {
+		return saved, errno
+	}
+
+	raw := saved
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO | syscall.ISIG | syscall.IEXTEN
+	raw.Iflag &^= syscall.IXON | syscall.ICRNL
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	return saved, setTermios(fd, raw)
+
+}
+
+func setTermios(fd int, t syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(&t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"syscall/js"
+
+	"chip8-go/chip8"
+)
+
+func init() {
+	frontends["wasm"] = runWasm
+	readROM = fetchROM
+}
+
+// wasmFrontend draws the display into the <canvas id="screen"> element of the page and feeds
+// the keyboard to the keypad. It implements chip8.Display and chip8.Input.
+type wasmFrontend struct {
+	chip    *chip8.Chip8
+	keymap  chip8.Keymap
+	palette chip8.Palette
+
+	// Draws the display with CRT effects, nil without.
+	crt *chip8.CRT
+
+	canvas js.Value
+	ctx2d  js.Value
+	status js.Value
+
+	// Frame buffer copied to the canvas, sized to it, and the JS array it is copied through.
+	frame  *image.RGBA
+	pixels js.Value
+
+	// Key events are queued by the JS listeners and applied by PollKeys.
+	events chan wasmKey
+
+	// WebAudio nodes of the beep, created on the first key press as browsers only allow
+	// audio to start from a user gesture. The gain is 0 while the tone is silent.
+	audio_ctx js.Value
+	gain      js.Value
+	tone_hz   float64
+	volume    float64
+	mute      bool
+	playing   bool
+
+	// Backspace rewinds while held.
+	rewind    *rewinder
+	rewinding bool
+
+	keys [16]bool
+}
+
+// wasmKey is a keyboard event, the KeyboardEvent.key of the key and whether it went down.
+type wasmKey struct {
+	key  string
+	down bool
+}
+
+// runWasm runs the chip in the page until the tab is closed. F2 pauses and resumes, F3 resets
+// and holding Backspace rewinds.
+func runWasm(chip *chip8.Chip8, opts options) error {
+
+	document := js.Global().Get("document")
+
+	canvas := document.Call("getElementById", "screen")
+	if canvas.IsNull() {
+		return errors.New(`the page has no <canvas id="screen"> to draw on`)
+	}
+
+	fe := &wasmFrontend{
+		chip:    chip,
+		keymap:  opts.Keymap,
+		palette: opts.Palette,
+		crt:     newCRT(opts),
+		canvas:  canvas,
+		ctx2d:   canvas.Call("getContext", "2d"),
+		events:  make(chan wasmKey, 64),
+		tone_hz: opts.ToneHz,
+		volume:  opts.Volume,
+		mute:    opts.Mute,
+		status:  document.Call("getElementById", "status"),
+		rewind:  newRewinder(chip),
+	}
+
+	for _, event := range []string{"keydown", "keyup"} {
+		down := event == "keydown"
+		listener := js.FuncOf(func(this js.Value, args []js.Value) any {
+			e := args[0]
+			if down {
+				fe.startAudio()
+			}
+			// Keep keys like Backspace and the function keys from acting on the page.
+			if !e.Get("ctrlKey").Bool() && !e.Get("metaKey").Bool() {
+				e.Call("preventDefault")
+			}
+			select {
+			case fe.events <- wasmKey{key: e.Get("key").String(), down: down}:
+			default:
+			}
+			return nil
+		})
+		defer listener.Release()
+		document.Call("addEventListener", event, listener)
+		defer document.Call("removeEventListener", event, listener)
+	}
+
+	fe.setStatus("running, F2 pauses, F3 resets, hold Backspace to rewind")
+
+	return chip.RunWith(context.Background(), fe, fe)
+
+}
+
+// PollKeys applies the key events queued since the last frame.
+func (fe *wasmFrontend) PollKeys() [16]bool {
+
+	for {
+		select {
+		case e := <-fe.events:
+			fe.keyEvent(e)
+		default:
+			fe.rewind.frame(fe.rewinding)
+			return fe.keys
+		}
+	}
+
+}
+
+func (fe *wasmFrontend) keyEvent(e wasmKey) {
+
+	switch {
+	case e.key == "Backspace":
+		fe.rewinding = e.down
+	case e.key == "F2" && e.down:
+		fe.setStatus(togglePause(fe.chip))
+	case e.key == "F3" && e.down:
+		fe.setStatus(resetChip(fe.chip))
+	}
+
+	// Printable keys are a single character, named keys like "Shift" are longer.
+	if runes := []rune(e.key); len(runes) == 1 {
+		if key, ok := fe.keymap.Lookup(runes[0]); ok {
+			fe.keys[key] = e.down
+		}
+	}
+
+}
+
+// setStatus shows a message in the <div id="status"> element of the page, if it has one.
+func (fe *wasmFrontend) setStatus(msg string) {
+	if !fe.status.IsNull() {
+		fe.status.Set("textContent", msg)
+	}
+}
+
+// startAudio creates the WebAudio graph of the beep: a square wave oscillator, always running,
+// through a gain node that Beep turns up and down.
+func (fe *wasmFrontend) startAudio() {
+
+	if fe.mute || !fe.audio_ctx.IsUndefined() {
+		return
+	}
+
+	constructor := js.Global().Get("AudioContext")
+	if constructor.IsUndefined() {
+		constructor = js.Global().Get("webkitAudioContext")
+	}
+	if constructor.IsUndefined() {
+		fe.mute = true
+		return
+	}
+
+	fe.audio_ctx = constructor.New()
+
+	fe.gain = fe.audio_ctx.Call("createGain")
+	fe.gain.Get("gain").Set("value", 0)
+	fe.gain.Call("connect", fe.audio_ctx.Get("destination"))
+
+	osc := fe.audio_ctx.Call("createOscillator")
+	osc.Set("type", "square")
+	osc.Get("frequency").Set("value", fe.tone_hz)
+	osc.Call("connect", fe.gain)
+	osc.Call("start")
+
+	fe.Beep(fe.playing)
+
+}
+
+// Beep turns the tone up or down. XO-CHIP audio patterns and Megachip digitised sounds are
+// played as the plain tone.
+func (fe *wasmFrontend) Beep(on bool) {
+
+	fe.playing = on
+
+	if fe.gain.IsUndefined() {
+		return
+	}
+
+	volume := 0.0
+	if on {
+		volume = fe.volume
+	}
+	fe.gain.Get("gain").Call("setValueAtTime", volume, fe.audio_ctx.Get("currentTime"))
+
+}
+
+// Draw renders the display letterboxed in the canvas.
+func (fe *wasmFrontend) Draw(frame chip8.Frame) error {
+
+	w, h := fe.canvas.Get("width").Int(), fe.canvas.Get("height").Int()
+	if w == 0 || h == 0 {
+		return nil
+	}
+
+	if fe.frame == nil || fe.frame.Rect.Dx() != w || fe.frame.Rect.Dy() != h {
+		fe.frame = image.NewRGBA(image.Rect(0, 0, w, h))
+		fe.pixels = js.Global().Get("Uint8ClampedArray").New(len(fe.frame.Pix))
+	}
+
+	if fe.crt != nil {
+		fe.crt.Update(frame)
+		fe.crt.Draw(fe.frame)
+	} else {
+		chip8.DrawFrameLetterboxed(fe.frame, frame, fe.palette)
+	}
+
+	js.CopyBytesToJS(fe.pixels, fe.frame.Pix)
+	fe.ctx2d.Call("putImageData", js.Global().Get("ImageData").New(fe.pixels, w, h), 0, 0)
+
+	return nil
+
+}
+
+// Animating keeps the display drawn while the CRT fades pixels out.
+func (fe *wasmFrontend) Animating() bool {
+	return fe.crt != nil && fe.crt.Fading()
+}
+
+// fetchROM downloads the ROM at url, relative to the page.
+func fetchROM(url string) ([]byte, error) {
+
+	resp, err := await(js.Global().Call("fetch", url))
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Get("ok").Bool() {
+		return nil, fmt.Errorf("%s: %d %s", url, resp.Get("status").Int(), resp.Get("statusText").String())
+	}
+
+	buf, err := await(resp.Call("arrayBuffer"))
+	if err != nil {
+		return nil, err
+	}
+
+	array := js.Global().Get("Uint8Array").New(buf)
+	data := make([]byte, array.Get("length").Int())
+	js.CopyBytesToGo(data, array)
+
+	return data, nil
+
+}
+
+// await blocks until promise settles and returns its value, or its rejection as an error.
+// Other goroutines and the browser keep running meanwhile.
+func await(promise js.Value) (js.Value, error) {
+
+	type result struct {
+		value js.Value
+		err   error
+	}
+	done := make(chan result, 1)
+
+	resolve := js.FuncOf(func(this js.Value, args []js.Value) any {
+		done <- result{value: args[0]}
+		return nil
+	})
+	defer resolve.Release()
+
+	reject := js.FuncOf(func(this js.Value, args []js.Value) any {
+		done <- result{err: errors.New(args[0].Call("toString").String())}
+		return nil
+	})
+	defer reject.Release()
+
+	promise.Call("then", resolve, reject)
+
+	r := <-done
+	return r.value, r.err
+
+}
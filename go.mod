@@ -2,4 +2,17 @@ module chip8-go
 
 go 1.22.5
 
-require github.com/veandco/go-sdl2 v0.4.40 // indirect
+require (
+	github.com/hajimehoshi/ebiten/v2 v2.8.8
+	github.com/veandco/go-sdl2 v0.4.40
+)
+
+require (
+	github.com/ebitengine/gomobile v0.0.0-20240911145611-4856209ac325 // indirect
+	github.com/ebitengine/hideconsole v1.0.0 // indirect
+	github.com/ebitengine/oto/v3 v3.3.3 // indirect
+	github.com/ebitengine/purego v0.8.0 // indirect
+	github.com/jezek/xgb v1.1.1 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+	golang.org/x/sys v0.25.0 // indirect
+)
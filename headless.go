@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"chip8-go/chip8"
+)
+
+// headlessOptions configures a headless run from the command line.
+type headlessOptions struct {
+	// Cycles is the number of instructions to execute.
+	Cycles int
+
+	// Replay plays the whole replay started on the chip instead of executing Cycles instructions.
+	Replay bool
+
+	// Dump is where the final display is written: a text snapshot, a PNG when the name
+	// ends in .png, or stdout for "-". Empty writes nothing.
+	Dump string
+
+	// Expect is a text snapshot the final display must match.
+	Expect string
+
+	// CrashFile is where the crash report is written if the program fails, see reportCrash.
+	CrashFile string
+}
+
+// runHeadless runs the chip without a frontend, then dumps the display and compares it
+// against the expected snapshot. A mismatch is returned as an error.
+func runHeadless(chip *chip8.Chip8, opts headlessOptions) error {
+
+	run := func() error { return chip.RunCycles(opts.Cycles) }
+	if opts.Replay {
+		run = chip.PlayReplay
+	}
+
+	if err := run(); err != nil {
+		reportCrash(chip, err, opts.CrashFile)
+		return err
+	}
+
+	frame := chip.Display()
+	text := frame.Text()
+
+	switch {
+	case opts.Dump == "-":
+		fmt.Print(text)
+
+	case strings.EqualFold(filepath.Ext(opts.Dump), ".png"):
+		var b bytes.Buffer
+		if err := chip.WritePNG(&b, 1); err != nil {
+			return err
+		}
+		if err := os.WriteFile(opts.Dump, b.Bytes(), 0o644); err != nil {
+			return err
+		}
+
+	case opts.Dump != "":
+		if err := os.WriteFile(opts.Dump, []byte(text), 0o644); err != nil {
+			return err
+		}
+	}
+
+	if opts.Expect == "" {
+		return nil
+	}
+
+	want, err := os.ReadFile(opts.Expect)
+	if err != nil {
+		return err
+	}
+
+	if diff := chip8.DiffFrameText(string(want), text); diff != nil {
+		return fmt.Errorf("display does not match %s:\n%s", opts.Expect, strings.Join(diff, "\n"))
+	}
+
+	return nil
+
+}
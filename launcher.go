@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"chip8-go/chip8"
+)
+
+// runLauncher lists the ROMs of a directory and runs the one picked with the settings of its
+// metadata: chip8 launch [dir] [run flags]. The flags apply to every ROM and take precedence
+// over the metadata. Quitting a ROM returns to the list.
+func runLauncher(args []string) {
+
+	dir := "roms"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		dir, args = args[0], args[1:]
+	}
+
+	roms, err := chip8.ScanROMs(dir)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if len(roms) == 0 {
+		fmt.Printf("no ROMs in %s\n", dir)
+		os.Exit(1)
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	shown := roms
+
+	for {
+		printROMs(shown)
+		fmt.Print("Number to run, text to search, empty to quit: ")
+
+		if !in.Scan() {
+			return
+		}
+
+		line := strings.TrimSpace(in.Text())
+		if line == "" {
+			return
+		}
+
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			shown = searchROMs(roms, line)
+			if len(shown) == 0 {
+				fmt.Printf("nothing matches %q\n", line)
+				shown = roms
+			}
+			continue
+		}
+		if n < 1 || n > len(shown) {
+			fmt.Printf("no ROM number %d\n", n)
+			continue
+		}
+
+		runCommand(launchArgs(shown[n-1], args))
+	}
+
+}
+
+// printROMs prints the numbered list of ROMs.
+func printROMs(roms []chip8.ROMInfo) {
+
+	fmt.Println()
+
+	for i, rom := range roms {
+		line := fmt.Sprintf("%3d  %s", i+1, rom.Title)
+		if rom.Author != "" {
+			line += " by " + rom.Author
+		}
+		if rom.Platform != "" {
+			line += " [" + rom.Platform + "]"
+		}
+		fmt.Println(line)
+		if rom.Description != "" {
+			fmt.Println("     " + rom.Description)
+		}
+	}
+
+	fmt.Println()
+
+}
+
+// searchROMs returns the ROMs whose title, author or file name contain text, ignoring case.
+func searchROMs(roms []chip8.ROMInfo, text string) []chip8.ROMInfo {
+
+	text = strings.ToLower(text)
+
+	var found []chip8.ROMInfo
+	for _, rom := range roms {
+		if strings.Contains(strings.ToLower(rom.Title+"\n"+rom.Author+"\n"+rom.Path), text) {
+			found = append(found, rom)
+		}
+	}
+
+	return found
+
+}
+
+// launchArgs builds the run command arguments of a ROM: its settings, then the launcher
+// flags so they override them, then the ROM.
+func launchArgs(rom chip8.ROMInfo, flags []string) []string {
+
+	args := []string{"-speed", strconv.Itoa(rom.Speed)}
+
+	if rom.Platform != "" {
+		args = append(args, "-platform", rom.Platform)
+	}
+	if rom.Quirks != "" {
+		args = append(args, "-quirks", rom.Quirks)
+	}
+	if rom.Keys != "" {
+		args = append(args, "-keys", rom.Keys)
+	}
+	if rom.LoadAddress != "" {
+		args = append(args, "-load-addr", rom.LoadAddress)
+	}
+
+	args = append(args, flags...)
+
+	return append(args, rom.Path)
+
+}
@@ -1,7 +1,17 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"image/color"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"chip8-go/chip8"
 )
 
 // b byte
@@ -14,27 +24,697 @@ import (
 //Set bit to 0
 //b = b & (^mask)
 
-func PrintDisplay(display [32][64]int) {
-	for _, j := range display {
-		fmt.Print(j, "\t")
-		fmt.Println()
+// parseLogLevel parses a log level name: debug, info, warn or error.
+func parseLogLevel(name string) (slog.Level, error) {
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, want debug, info, warn or error", name)
 	}
-	fmt.Println()
+
+	return level, nil
 
 }
 
-func main() {
+// newLogger returns a logger writing the messages of level and above to stderr, without the
+// time which only clutters a terminal.
+func newLogger(level slog.Level) *slog.Logger {
+
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+}
+
+// readROM reads the ROM given to the run command. The WebAssembly build fetches it from the
+// web server instead, a browser has no file system.
+var readROM = os.ReadFile
+
+// runDisasm prints an annotated listing of a ROM: chip8 disasm [-follow] [-load-addr 0x200] rom.ch8
+func runDisasm(args []string) {
+
+	fs := flag.NewFlagSet("disasm", flag.ExitOnError)
+	follow := fs.Bool("follow", false, "follow jumps and calls from the entry point, listing unreached bytes as data")
+	load_addr := fs.String("load-addr", "0x200", "address the ROM is loaded at, e.g. 0x600 for ETI-660 programs")
+	entry := fs.String("entry", "", "address execution starts at, the load address by default")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("usage: chip8 disasm [-follow] [-load-addr 0x200] [-entry ADDR] rom.ch8")
+		os.Exit(2)
+	}
+
+	addr, start, err := parseLoadAddress(*load_addr, *entry)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
 
-	chip8 := NewChip()
-	chip8.LoadROM("./roms/IBM Logo.ch8")
+	rom, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	for _, line := range chip8.DisassembleROMAt(rom, addr, start, *follow) {
+		fmt.Println(line)
+	}
 
-	// //fmt.Printf("%d\n", chip8.program_counter)
+}
+
+// runHexdump prints the memory of a machine with a ROM loaded, with the font and program regions
+// annotated: chip8 hexdump [-platform auto] [-load-addr 0x200] [-all] rom.ch8
+func runHexdump(args []string) {
+
+	fs := flag.NewFlagSet("hexdump", flag.ExitOnError)
+	platform := fs.String("platform", "auto", "instruction set, which sets the memory layout: chip8, schip, xochip, megachip, or auto to detect it from the ROM")
+	load_addr := fs.String("load-addr", "0x200", "address the ROM is loaded at, e.g. 0x600 for ETI-660 programs")
+	all := fs.Bool("all", false, "dump the whole address space, not only up to the end of the program")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("usage: chip8 hexdump [-platform auto] [-load-addr 0x200] [-all] rom.ch8")
+		os.Exit(2)
+	}
+
+	addr, _, err := parseLoadAddress(*load_addr, "")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	rom, _, err := openROM(fs.Arg(0), "")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	chip := chip8.New()
+	if *platform == "auto" {
+		chip.SetPlatform(chip8.DetectROMPlatform(rom))
+	} else {
+		p, err := chip8.ParsePlatform(*platform)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		chip.SetPlatform(p)
+	}
+
+	if err := chip.SetLoadAddress(addr, addr); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := chip.LoadROMBytes(rom); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	regions := chip.MemoryRegions()
+	end := regions[len(regions)-1].End
+	if !*all {
+		for _, region := range regions {
+			if region.Name != "free" {
+				end = region.End
+			}
+		}
+	}
+
+	for _, line := range chip.HexDump(0, end, chip8.HexDumpOptions{Squeeze: true}) {
+		fmt.Println(line)
+	}
+
+}
+
+// runAsm assembles a source file into a ROM: chip8 asm [-o rom.ch8] source.asm, or Octo
+// source given as a .8o file.
+func runAsm(args []string) {
+
+	fs := flag.NewFlagSet("asm", flag.ExitOnError)
+	out := fs.String("o", "", "ROM file to write, the source file name with a .ch8 extension by default")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("usage: chip8 asm [-o rom.ch8] source.asm")
+		os.Exit(2)
+	}
+
+	src, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	assemble := chip8.Assemble
+	if chip8.IsOctoSource(fs.Arg(0)) {
+		assemble = chip8.AssembleOcto
+	}
+
+	rom, err := assemble(string(src))
+	if err != nil {
+		fmt.Printf("%s: %v\n", fs.Arg(0), err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		*out = strings.TrimSuffix(fs.Arg(0), filepath.Ext(fs.Arg(0))) + ".ch8"
+	}
+
+	if err := os.WriteFile(*out, rom, 0o644); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+}
+
+// usage lists the commands.
+func usage() {
+	fmt.Fprint(os.Stderr, `usage: chip8 <command> [arguments]
+
+Commands:
+  run [flags] rom.ch8          run a ROM, see chip8 run -h for the flags
+  launch [dir] [run flags]     pick a ROM from a directory, roms by default, and run it
+  asm [-o rom.ch8] source.asm  assemble a source file, or Octo source.8o, into a ROM
+  disasm [-follow] rom.ch8     print an annotated listing of a ROM
+  hexdump [-all] rom.ch8       dump the memory with a ROM loaded, font and program annotated
+  config init|path             write a default configuration file, or show where it is
+  compare [flags] romA [romB]  run two ROMs, or one with two settings, in lockstep and show where they diverge
+  testroms [-update] [dir]     run the test ROMs of a directory against their golden displays
+`)
+}
+
+// parseArgs parses flags placed before, between or after the positional arguments, so both
+// chip8 run -scale 12 rom.ch8 and chip8 run rom.ch8 -scale 12 work, and returns the latter.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+
+	var positional []string
 
 	for {
-		//time.Sleep(time.Second)
-		chip8.Cycle()
-		PrintDisplay(chip8.display)
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+}
+
+// parseLoadAddress parses the -load-addr and -entry flags, addresses written in hex with 0x or
+// in decimal, the entry point being the load address when entry is empty.
+func parseLoadAddress(load, entry string) (addr, start uint16, err error) {
+
+	value, err := strconv.ParseUint(load, 0, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid load address %q, want e.g. 0x600", load)
+	}
+	addr, start = uint16(value), uint16(value)
+
+	if entry != "" {
+		value, err := strconv.ParseUint(entry, 0, 16)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid entry point %q, want e.g. 0x600", entry)
+		}
+		start = uint16(value)
+	}
+
+	return addr, start, nil
+
+}
+
+// parseColor parses a color written #RRGGBB or #RGB, the # being optional.
+func parseColor(s string) (color.RGBA, error) {
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, want #RRGGBB", s)
+	}
+
+	return color.RGBA{byte(value >> 16), byte(value >> 8), byte(value), 0xFF}, nil
+
+}
+
+// gamepadMap returns the gamepad map of a run: the one given with -gamepad, the one of the ROM
+// in the configuration file, by its file name without the extension, the configuration's
+// default one, or DefaultGamepadMap.
+func gamepadMap(flag_value string, gamepads map[string]chip8.GamepadMap, rom string) (chip8.GamepadMap, error) {
+
+	if flag_value != "" {
+		return chip8.ParseGamepadMap(flag_value)
+	}
+
+	game := strings.ToLower(strings.TrimSuffix(filepath.Base(rom), filepath.Ext(rom)))
+	for _, name := range []string{game, "default"} {
+		if m, ok := gamepads[name]; ok {
+			return m, nil
+		}
+	}
+
+	return chip8.DefaultGamepadMap(), nil
+
+}
+
+// runCommand runs a ROM: chip8 run [flags] rom.ch8
+func runCommand(args []string) {
+
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: chip8 run [flags] rom.ch8|https://host/rom.ch8|archive.zip#rom.ch8")
+		fs.PrintDefaults()
+	}
+
+	speed := fs.Int("speed", 0, "instructions per second, 0 for the ROM's recommended speed")
+	fps := fs.Int("fps", chip8.DefaultFrameRate, "frames drawn per second, the refresh rate of the display; the timers still run at 60Hz")
+	frame_skip := fs.Int("frame-skip", chip8.DefaultFrameSkip, "frames in a row that may go undrawn to keep the game speed when the host is too slow, 0 to slow down instead")
+	scale := fs.Int("scale", 10, "window size as a multiple of the 64x32 display (sdl builds)")
+	theme := fs.String("theme", "default", "colors of the display: "+strings.Join(chip8.ThemeNames(), ", ")+"; -fg, -bg, -plane2 and -overlap override them")
+	fg := fs.String("fg", "", "color of the pixels that are on, as #RRGGBB, instead of the theme's")
+	bg := fs.String("bg", "", "color of the pixels that are off and of the border, as #RRGGBB, instead of the theme's")
+	plane2 := fs.String("plane2", "", "color of the pixels on in the second XO-CHIP plane only, as #RRGGBB, instead of the theme's")
+	overlap := fs.String("overlap", "", "color of the pixels on in both XO-CHIP planes, as #RRGGBB, instead of the theme's")
+	crt_decay := fs.Float64("crt-decay", 0, "share of its brightness a pixel turned off keeps each frame, from 0 to below 1, fading it out like a CRT phosphor (window frontends)")
+	scanlines := fs.Bool("scanlines", false, "darken the bottom of every row of pixels like a CRT (window frontends)")
+	curvature := fs.Bool("curvature", false, "bulge the display like the glass of a CRT (window frontends)")
+	name := fs.String("frontend", defaultFrontend(), "frontend to run: text, tui, debug, sdl or ebiten (builds with that tag) or wasm (browser builds)")
+	mute := fs.Bool("mute", false, "turn the beep off")
+	tone := fs.Float64("tone", chip8.DefaultToneHz, "pitch of the beep in Hz")
+	volume := fs.Float64("volume", 0.25, "volume of the beep, from 0 to 1")
+	platform := fs.String("platform", "auto", "instruction set: chip8, schip, xochip, megachip, or auto to detect it from the ROM")
+	load_addr := fs.String("load-addr", "0x200", "address the ROM is loaded at, e.g. 0x600 for ETI-660 programs")
+	entry := fs.String("entry", "", "address execution starts at, the load address by default")
+	quirks := fs.String("quirks", "", "quirks preset: vip, schip or modern (the default)")
+	seed := fs.Int64("seed", 0, "seed of the CXNN random source for reproducible runs, 0 for a random one")
+	memory := fs.String("memory", "strict", "what an access past the end of memory does: strict stops with an error, wrap wraps around to 0")
+	protect_memory := fs.Bool("protect-memory", false, "stop with an error when the program writes to the interpreter area, 0x000 to 0x1FF")
+	cheats := fs.String("cheat", "", "freeze bytes of memory as ADDR=VALUE pairs in hex, e.g. 2F0=03 to keep a lives counter at 3")
+	halt_on_loop := fs.Bool("halt-on-loop", false, "stop with a crash report when the program jumps to itself, a loop nothing gets it out of")
+	crash_file := fs.String("crash-file", "", "when the program fails, write the crash report with the last 1000 instructions to this file")
+	skip_invalid := fs.Bool("skip-invalid", false, "report invalid opcodes and skip them instead of stopping")
+	headless := fs.Bool("headless", false, "run without a frontend for -cycles instructions, then dump or check the display")
+	cycles := fs.Int("cycles", 1000, "instructions to execute with -headless")
+	dump := fs.String("dump", "", "with -headless, write the display to this file as text, or as PNG for a .png name, - for stdout")
+	expect := fs.String("expect", "", "with -headless, exit with an error unless the display matches this text snapshot")
+	log_level := fs.String("log-level", "warn", "least severe messages logged to stderr: debug, info, warn or error")
+	trace := fs.String("trace", "", "log every executed instruction and the registers it changed to this file, - for stderr")
+	trace_ops := fs.String("trace-ops", "", "with -trace, only log these opcode classes: first hex digits or mnemonics, e.g. D,CALL")
+	trace_range := fs.String("trace-range", "", "with -trace, only log instructions in this address range, e.g. 200-2FF")
+	profile := fs.String("profile", "", "count the instructions executed per address and opcode, and write a report to this file at exit: JSON for a .json name, text otherwise, - for stderr")
+	profile_top := fs.Int("profile-top", 20, "hottest addresses listed by a text -profile report, 0 for all")
+	rom_cache := fs.String("rom-cache", "", "keep ROMs downloaded from URLs in this directory, reading them from there the next time")
+	keys := fs.String("keys", "", "keyboard keys for the keypad 0 to F, e.g. x123qweasdzc4rfv (the default)")
+	gamepad := fs.String("gamepad", "", "gamepad buttons for the keypad as button=key pairs, e.g. up=1,down=4,a=6, instead of the game's map in the configuration file (sdl and ebiten builds)")
+	record := fs.String("record", "", "record the run to this animated GIF, F9 starts and stops a recording too")
+	record_seconds := fs.Float64("record-seconds", 0, "with -record, stop recording after this many seconds, 0 for the whole run")
+	record_fps := fs.Int("record-fps", 30, "frames per second captured by recordings, from 1 to 60")
+	capture_scale := fs.Int("capture-scale", 4, "size of screenshots (F8) and recordings as a multiple of the display")
+	record_replay := fs.String("record-replay", "", "record the keys pressed during the run to this replay file")
+	replay := fs.String("replay", "", "play back a replay file recorded with -record-replay, with -headless as fast as possible")
+	netplay_host := fs.String("netplay-host", "", "experimental: share the game with a player running -netplay-join, listening on this address, e.g. :7777 on every interface; anyone reaching it can join and press keys")
+	netplay_join := fs.String("netplay-join", "", "experimental: play the game of a -netplay-host at this address, e.g. 192.168.1.10:7777, instead of a ROM")
+	telemetry_port := fs.Int("telemetry-port", 0, "stream the display and registers of every frame over WebSocket on this local port, with a page showing them")
+	debug_port := fs.Int("debug-port", 0, "instead of running a frontend, serve the JSON-RPC debugger protocol on this local TCP port")
+
+	// The configuration file provides the defaults, flags override them.
+	var gamepads map[string]chip8.GamepadMap
+	if path, err := configPath(); err == nil {
+		if gamepads, err = applyConfig(fs, path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	positional := parseArgs(fs, args)
+
+	switch {
+	case len(positional) == 0 && *netplay_join == "":
+		fmt.Fprintln(os.Stderr, "missing the ROM to run")
+		fs.Usage()
+		os.Exit(2)
+	case len(positional) > 0 && *netplay_join != "":
+		fmt.Fprintln(os.Stderr, "-netplay-join plays the ROM of the host, give no ROM")
+		os.Exit(2)
+	case len(positional) > 1:
+		fmt.Fprintf(os.Stderr, "too many arguments: %q\n", positional[1:])
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// A netplay client has no ROM, its screenshots and recordings are named after the session.
+	rom := "netplay.ch8"
+	if len(positional) == 1 {
+		rom = positional[0]
+	}
+
+	if *netplay_join != "" && (*netplay_host != "" || *headless || *debug_port != 0 || *replay != "" || *record_replay != "") {
+		fmt.Fprintln(os.Stderr, "-netplay-join needs a frontend and cannot be combined with -netplay-host, -headless, -debug-port or replays")
+		os.Exit(2)
+	}
+
+	if *speed < 0 {
+		fmt.Fprintln(os.Stderr, "-speed must not be negative")
+		os.Exit(2)
+	}
+	if *scale < 1 {
+		fmt.Fprintln(os.Stderr, "-scale must be at least 1")
+		os.Exit(2)
+	}
+	if *fps < 1 || *fps > 1000 {
+		fmt.Fprintln(os.Stderr, "-fps must be from 1 to 1000")
+		os.Exit(2)
+	}
+	if *frame_skip < 0 {
+		fmt.Fprintln(os.Stderr, "-frame-skip must not be negative")
+		os.Exit(2)
+	}
+	if *record_fps < 1 || *record_fps > 60 {
+		fmt.Fprintln(os.Stderr, "-record-fps must be from 1 to 60")
+		os.Exit(2)
+	}
+	if *capture_scale < 1 {
+		fmt.Fprintln(os.Stderr, "-capture-scale must be at least 1")
+		os.Exit(2)
+	}
+	if *crt_decay < 0 || *crt_decay >= 1 {
+		fmt.Fprintln(os.Stderr, "-crt-decay must be from 0 to below 1")
+		os.Exit(2)
+	}
+	if *record_replay != "" && (*replay != "" || *headless) {
+		fmt.Fprintln(os.Stderr, "-record-replay needs a frontend and cannot be combined with -replay")
+		os.Exit(2)
+	}
+
+	palette, err := chip8.Theme(*theme)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for _, c := range []struct {
+		value string
+		dst   []*color.RGBA
+	}{
+		{*fg, []*color.RGBA{&palette.Foreground}},
+		{*bg, []*color.RGBA{&palette.Background, &palette.Border}},
+		{*plane2, []*color.RGBA{&palette.Plane2}},
+		{*overlap, []*color.RGBA{&palette.Overlap}},
+	} {
+		if c.value == "" {
+			continue
+		}
+		rgba, err := parseColor(c.value)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		for _, dst := range c.dst {
+			*dst = rgba
+		}
+	}
+
+	level, err := parseLogLevel(*log_level)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger := newLogger(level)
+
+	// fatal logs an error that ends the run.
+	fatal := func(err error) {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	chip := chip8.New()
+	chip.SetLogger(logger)
+	if *speed > 0 {
+		chip.SetClockHz(*speed)
+	}
+	chip.SetFrameRate(*fps)
+	chip.SetFrameSkip(*frame_skip)
+	if *quirks != "" {
+		q, err := chip8.QuirksPreset(*quirks)
+		if err != nil {
+			fatal(err)
+		}
+		chip.SetQuirks(q)
+	}
+	if *seed != 0 {
+		chip.SetSeed(*seed)
+	}
+	policy, err := chip8.ParseMemoryPolicy(*memory)
+	if err != nil {
+		fatal(err)
+	}
+	chip.SetMemoryPolicy(policy)
+	chip.SetMemoryProtection(*protect_memory)
+	chip.SetHaltDetection(*halt_on_loop)
+	if *cheats != "" {
+		c, err := chip8.ParseCheats(*cheats)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		chip.ApplyCheats(c)
+	}
+	if *skip_invalid {
+		// The chip logs each skipped opcode as a warning.
+		chip.OnUnknownOpcode = func(opcode uint16, pc uint16) {}
+	}
+
+	var data []byte
+	var local string
+	if *netplay_join == "" {
+		if data, local, err = openROM(rom, *rom_cache); err != nil {
+			fatal(fmt.Errorf("could not read ROM: %w", err))
+		}
+	}
+
+	// The platform is selected before loading, XO-CHIP ROMs may need more than 4kB.
+	if *platform == "auto" {
+		chip.SetPlatform(chip8.DetectROMPlatform(data))
+	} else {
+		p, err := chip8.ParsePlatform(*platform)
+		if err != nil {
+			fatal(err)
+		}
+		chip.SetPlatform(p)
+	}
+
+	// The load address is checked against the memory of the platform.
+	addr, start, err := parseLoadAddress(*load_addr, *entry)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := chip.SetLoadAddress(addr, start); err != nil {
+		fatal(err)
+	}
+
+	// Files of a ROM from a URL or an archive, e.g. its .state, are named after its file name.
+	rom_name := romName(rom)
+
+	if local != "" {
+		err = chip.LoadNamedROM(local, data)
+	} else {
+		err = chip.LoadROMBytes(data)
+		if err == nil && *speed == 0 {
+			chip.SetClockHz(chip8.RecommendedClockHz(data, rom_name))
+		}
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	// With a trace or a crash file, the last instructions are reported if the program fails, a
+	// crash included. Recording them copies the registers on every instruction.
+	if *trace != "" || *crash_file != "" {
+		chip.SetTrace(crash_trace_size)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			reportCrash(chip, fmt.Errorf("panic: %v", r), *crash_file)
+			panic(r)
+		}
+	}()
+
+	stop_trace := func() error { return nil }
+	if *trace != "" {
+		filter, err := chip8.ParseTraceFilter(*trace_ops, *trace_range)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if stop_trace, err = startTrace(chip, *trace, filter); err != nil {
+			fatal(err)
+		}
+	}
+	defer stop_trace()
+
+	stop_profile := func() {}
+	if *profile != "" {
+		chip.SetProfiling(true)
+		stop_profile = func() {
+			if err := writeProfile(chip, *profile, *profile_top); err != nil {
+				logger.Error("could not write profile: " + err.Error())
+			}
+		}
+	}
+	defer stop_profile()
+
+	// fail ends a run that went wrong, the trace log and the profile are still written.
+	fail := func(err error) {
+		stop_trace()
+		stop_profile()
+		fatal(err)
+	}
+
+	keymap := chip8.DefaultKeymap()
+	if *keys != "" {
+		var err error
+		if keymap, err = chip8.ParseKeymap(*keys); err != nil {
+			fatal(err)
+		}
+	}
+	chip.SetKeymap(keymap)
+
+	gamepad_map, err := gamepadMap(*gamepad, gamepads, rom_name)
+	if err != nil {
+		fatal(err)
+	}
+
+	if *headless {
+		// A fixed seed keeps CXNN, and so the snapshot, reproducible.
+		if *seed == 0 {
+			chip.SetSeed(1)
+		}
+	}
+
+	// The replay restores the machine it was recorded on, seed and settings included.
+	if *replay != "" {
+		if err := startReplay(chip, *replay); err != nil {
+			fatal(err)
+		}
+	}
+
+	if *headless {
+		if err := runHeadless(chip, headlessOptions{Cycles: *cycles, Replay: *replay != "", Dump: *dump, Expect: *expect, CrashFile: *crash_file}); err != nil {
+			fail(err)
+		}
+		return
+	}
+
+	if *debug_port != 0 {
+		if err := serveDebug(chip, *debug_port); err != nil {
+			fail(err)
+		}
+		return
+	}
+
+	// Instructions run at the clock rate while the timers and the display
+	// are updated at 60Hz, see Chip8.Run.
+	opts := options{
+		Scale:     *scale,
+		Keymap:    keymap,
+		Gamepad:   gamepad_map,
+		Mute:      *mute,
+		ToneHz:    *tone,
+		Volume:    *volume,
+		Palette:   palette,
+		CRT:       chip8.CRTEffects{Decay: *crt_decay, Scanlines: *scanlines, Curvature: *curvature},
+		StateFile: strings.TrimSuffix(rom_name, filepath.Ext(rom_name)) + ".state",
+		Capture:   newCapture(chip, rom_name, palette, *capture_scale, *record_fps),
+	}
+	if *record != "" {
+		opts.Capture.record(*record, *record_seconds)
+	}
+	if *telemetry_port != 0 {
+		stop_telemetry, err := startTelemetry(chip, *telemetry_port)
+		if err != nil {
+			fatal(err)
+		}
+		defer stop_telemetry()
+	}
+	if *netplay_host != "" {
+		stop_netplay, err := startNetplayHost(chip, *netplay_host)
+		if err != nil {
+			fatal(err)
+		}
+		defer stop_netplay()
+	}
+	if *netplay_join != "" {
+		leave, err := joinNetplay(chip, *netplay_join)
+		if err != nil {
+			fatal(err)
+		}
+		defer leave()
+	}
+	if *record_replay != "" {
+		if err := chip.RecordReplay(); err != nil {
+			fatal(err)
+		}
+	}
+
+	// A SUPER-CHIP program may end with 00FD, exiting the interpreter.
+	err = runFrontend(*name, chip, opts)
+	opts.Capture.finish()
+	if *record_replay != "" {
+		if err := writeReplay(chip.StopRecording(), *record_replay); err != nil {
+			logger.Error(err.Error())
+		} else {
+			logger.Info("replay saved to " + *record_replay)
+		}
+	}
+	if errors.Is(err, chip8.ErrNetplayEnded) {
+		logger.Warn(err.Error())
+		return
+	}
+	if err != nil && !errors.Is(err, chip8.ErrExited) {
+		reportCrash(chip, err, *crash_file)
+		fail(err)
+	}
+
+}
+
+func main() {
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
 
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "run":
+		runCommand(args)
+	case "launch":
+		runLauncher(args)
+	case "asm":
+		runAsm(args)
+	case "disasm":
+		runDisasm(args)
+	case "hexdump":
+		runHexdump(args)
+	case "config":
+		runConfig(args)
+	case "compare":
+		runCompare(args)
+	case "testroms":
+		runTestROMs(args)
+	case "help", "-h", "-help", "--help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", command)
+		usage()
+		os.Exit(2)
 	}
 
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"chip8-go/chip8"
+)
+
+// startNetplayHost shares chip with the netplay clients connecting to addr, see
+// chip8.NetplayHost: the display is sent every frame after the OnFrame callback already set,
+// and the keys of the clients are pressed with those of the local player. It returns a function
+// stopping the host.
+func startNetplayHost(chip *chip8.Chip8, addr string) (func(), error) {
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	host := chip8.NewNetplayHost()
+	go host.Serve(ctx, l)
+
+	fmt.Fprintf(os.Stderr, "netplay host on %s, join with: chip8 run -netplay-join HOST:%d\n", l.Addr(), l.Addr().(*net.TCPAddr).Port)
+
+	on_frame := chip.OnFrame
+	chip.OnFrame = func(frame chip8.Frame) {
+		if on_frame != nil {
+			on_frame(frame)
+		}
+		host.Publish(chip)
+	}
+
+	on_keys := chip.OnKeys
+	chip.OnKeys = func(keys [16]bool) [16]bool {
+		if on_keys != nil {
+			keys = on_keys(keys)
+		}
+		return host.MergeKeys(keys)
+	}
+
+	return cancel, nil
+
+}
+
+// joinNetplay connects chip to the netplay host at addr, so that running it shows the display
+// of the host. It returns a function ending the session.
+func joinNetplay(chip *chip8.Chip8, addr string) (func(), error) {
+
+	client, err := chip8.DialNetplay(addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not join the netplay host: %w", err)
+	}
+
+	chip.FollowNetplay(client)
+	fmt.Fprintf(os.Stderr, "joined the netplay host %s\n", addr)
+
+	return func() { client.Close() }, nil
+
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"chip8-go/chip8"
+)
+
+// writeProfile writes the profile of the run to path: as JSON for a .json name, as text with
+// the top hottest addresses otherwise, or to stderr for "-".
+func writeProfile(chip *chip8.Chip8, path string, top int) error {
+
+	profile := chip.Profile()
+	if profile == nil {
+		return nil
+	}
+
+	var b bytes.Buffer
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		enc := json.NewEncoder(&b)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(profile); err != nil {
+			return err
+		}
+	} else if err := profile.WriteText(&b, top); err != nil {
+		return err
+	}
+
+	if path == "-" {
+		_, err := os.Stderr.Write(b.Bytes())
+		return err
+	}
+
+	return os.WriteFile(path, b.Bytes(), 0o644)
+
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"os"
+
+	"chip8-go/chip8"
+)
+
+// startReplay reads the replay file at path and starts playing it on the chip.
+func startReplay(chip *chip8.Chip8, path string) error {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	replay, err := chip8.ReadReplay(f)
+	if err != nil {
+		return err
+	}
+
+	return chip.StartReplay(replay)
+
+}
+
+// writeReplay writes a recorded replay to path.
+func writeReplay(replay *chip8.Replay, path string) error {
+
+	var b bytes.Buffer
+	if err := replay.Write(&b); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b.Bytes(), 0o644)
+
+}
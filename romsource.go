@@ -0,0 +1,195 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"chip8-go/chip8"
+)
+
+// Largest ROM accepted from a URL or an archive: Megachip memory above the load address.
+const max_rom_size = 0x1000000 - chip8.DefaultLoadAddress
+
+// Time allowed to download a ROM.
+const rom_download_timeout = 30 * time.Second
+
+// openROM reads the ROM given to the run command: a file, an http(s) URL, or a file in a zip
+// archive given as archive.zip#path/in/archive.ch8. Downloads are kept in cache, if not empty,
+// and read from there the next time. It also returns the file the ROM can be read from again,
+// for ReloadROM, empty for a ROM from an uncached URL or an archive. Octo source, a .8o file,
+// is assembled.
+func openROM(source, cache string) ([]byte, string, error) {
+
+	data, local, err := readROMSource(source, cache)
+	if err != nil || !chip8.IsOctoSource(romName(source)) {
+		return data, local, err
+	}
+
+	rom, err := chip8.AssembleOcto(string(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", filepath.Base(romName(source)), err)
+	}
+
+	return rom, local, nil
+
+}
+
+// readROMSource reads the file of a ROM source for openROM.
+func readROMSource(source, cache string) ([]byte, string, error) {
+
+	if isURL(source) {
+		return downloadROM(source, cache)
+	}
+
+	if archive, member, ok := strings.Cut(source, "#"); ok && strings.EqualFold(filepath.Ext(archive), ".zip") {
+		data, err := readZippedROM(archive, member)
+		return data, "", err
+	}
+
+	if strings.EqualFold(filepath.Ext(source), ".zip") {
+		return nil, "", zipMembersError(source)
+	}
+
+	data, err := readROM(source)
+
+	return data, source, err
+
+}
+
+// romName returns the path the files of a ROM are named after, such as its .state file: the
+// ROM file itself, the file name of a URL in the current directory, or the file name of an
+// archived ROM next to the archive.
+func romName(source string) string {
+
+	if isURL(source) {
+		if u, err := url.Parse(source); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+			return path.Base(u.Path)
+		}
+		return "rom.ch8"
+	}
+
+	if archive, member, ok := strings.Cut(source, "#"); ok && strings.EqualFold(filepath.Ext(archive), ".zip") {
+		return filepath.Join(filepath.Dir(archive), path.Base(member))
+	}
+
+	return source
+
+}
+
+// isURL reports whether a ROM is given as an http or https URL.
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// downloadROM downloads the ROM at a URL, or reads it from the cache directory if an earlier
+// run downloaded it. Cached files are named after a hash of the URL.
+func downloadROM(source, cache string) ([]byte, string, error) {
+
+	var cached string
+	if cache != "" {
+		sum := sha256.Sum256([]byte(source))
+		cached = filepath.Join(cache, hex.EncodeToString(sum[:8])+"-"+romName(source))
+		if data, err := os.ReadFile(cached); err == nil {
+			return data, cached, nil
+		}
+	}
+
+	client := http.Client{Timeout: rom_download_timeout}
+
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("%s: %s", source, resp.Status)
+	}
+
+	data, err := readLimitedROM(resp.Body, source)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if cached == "" {
+		return data, "", nil
+	}
+
+	if err := os.MkdirAll(cache, 0o755); err != nil {
+		return nil, "", err
+	}
+	if err := os.WriteFile(cached, data, 0o644); err != nil {
+		return nil, "", err
+	}
+
+	return data, cached, nil
+
+}
+
+// readZippedROM reads the file at member, a slash separated path, from a zip archive.
+func readZippedROM(archive, member string) ([]byte, error) {
+
+	z, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+
+	f, err := z.Open(strings.TrimPrefix(member, "/"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s has no file %s", archive, member)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readLimitedROM(f, archive+"#"+member)
+
+}
+
+// zipMembersError returns the error for an archive given without the file to run, listing its files.
+func zipMembersError(archive string) error {
+
+	z, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+
+	var names []string
+	for _, f := range z.File {
+		if !f.FileInfo().IsDir() {
+			names = append(names, f.Name)
+		}
+	}
+
+	return fmt.Errorf("give the file of %s to run as %s#file, it holds: %s", archive, archive, strings.Join(names, ", "))
+
+}
+
+// readLimitedROM reads a ROM, failing without reading it all when it is too large for memory.
+func readLimitedROM(r io.Reader, name string) ([]byte, error) {
+
+	data, err := io.ReadAll(io.LimitReader(r, max_rom_size+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(data) > max_rom_size {
+		return nil, fmt.Errorf("%s: %w: more than %d bytes", name, chip8.ErrROMTooLarge, max_rom_size)
+	}
+
+	return data, nil
+
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"chip8-go/chip8"
+)
+
+// startTelemetry serves the state of chip over WebSocket on a local port, see
+// chip8.TelemetryServer, publishing it every frame after the OnFrame callback already set.
+// It returns a function stopping the server.
+func startTelemetry(chip *chip8.Chip8, port int) (func(), error) {
+
+	l, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	telemetry := chip8.NewTelemetryServer()
+	server := &http.Server{Handler: telemetry}
+	go server.Serve(l)
+
+	fmt.Fprintf(os.Stderr, "telemetry on ws://%s/, open http://%s/ to watch it\n", l.Addr(), l.Addr())
+
+	on_frame := chip.OnFrame
+	chip.OnFrame = func(frame chip8.Frame) {
+		if on_frame != nil {
+			on_frame(frame)
+		}
+		telemetry.Publish(chip)
+	}
+
+	return func() { server.Shutdown(context.Background()) }, nil
+
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"chip8-go/chip8"
+)
+
+// Name of the manifest of a test ROM directory. The golden displays are kept next to it,
+// in golden/<rom>.<config>.txt.
+const test_rom_manifest = "testroms.json"
+
+// testROMManifest lists the test ROMs of a directory and the configurations they are run in.
+type testROMManifest struct {
+	Configs map[string]testROMConfig `json:"configs"`
+	ROMs    []testROM                `json:"roms"`
+}
+
+// testROMConfig is a configuration a test ROM runs in: a quirks preset and a platform, as
+// accepted by QuirksPreset and ParsePlatform, and bytes poked into memory after loading.
+// The Timendus suite reads the platform to test from 0x1FF instead of asking for it.
+type testROMConfig struct {
+	Quirks   string           `json:"quirks"`
+	Platform string           `json:"platform"`
+	Memory   map[string]uint8 `json:"memory"`
+}
+
+// testROM is a ROM run for a fixed number of cycles, in every configuration of the manifest
+// unless Configs lists some of them. Its Memory is poked after the one of the configuration.
+type testROM struct {
+	ROM     string           `json:"rom"`
+	Cycles  int              `json:"cycles"`
+	Configs []string         `json:"configs"`
+	Memory  map[string]uint8 `json:"memory"`
+}
+
+// Results of a test ROM run, as shown in the matrix.
+const (
+	test_pass       = "pass"
+	test_fail       = "FAIL"
+	test_no_golden  = "no golden"
+	test_updated    = "updated"
+	test_missing    = "missing"
+	test_not_run    = "-"
+	test_run_failed = "error"
+)
+
+// runTestROMs runs the test ROMs of a directory headlessly and compares their final display
+// against the golden ones: chip8 testroms [-update] [dir]. It prints a matrix of the results
+// per configuration and fails unless every run passed.
+func runTestROMs(args []string) {
+
+	flags := flag.NewFlagSet("testroms", flag.ExitOnError)
+	update := flags.Bool("update", false, "write the final displays as the golden ones instead of comparing them")
+	flags.Parse(args)
+
+	dir := "testroms"
+	switch flags.NArg() {
+	case 0:
+	case 1:
+		dir = flags.Arg(0)
+	default:
+		fmt.Println("usage: chip8 testroms [-update] [dir]")
+		os.Exit(2)
+	}
+
+	manifest, configs, err := readTestROMManifest(dir)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var failures []string
+	results := make([][]string, len(manifest.ROMs))
+
+	for i, rom := range manifest.ROMs {
+		for _, name := range configs {
+			result, detail := runTestROM(dir, rom, name, manifest.Configs[name], *update)
+			results[i] = append(results[i], result)
+			switch {
+			case result == test_missing:
+				// The same in every configuration, reported once.
+				if !slices.Contains(results[i][:len(results[i])-1], test_missing) {
+					failures = append(failures, fmt.Sprintf("%s: %s", rom.ROM, detail))
+				}
+			case detail != "":
+				failures = append(failures, fmt.Sprintf("%s, %s: %s", rom.ROM, name, detail))
+			}
+		}
+	}
+
+	printTestMatrix(manifest.ROMs, configs, results)
+
+	for _, failure := range failures {
+		fmt.Println()
+		fmt.Println(failure)
+	}
+
+	if len(failures) > 0 {
+		os.Exit(1)
+	}
+
+}
+
+// readTestROMManifest reads the manifest of a test ROM directory and returns it with the names
+// of its configurations, sorted.
+func readTestROMManifest(dir string) (testROMManifest, []string, error) {
+
+	var manifest testROMManifest
+	data, err := os.ReadFile(filepath.Join(dir, test_rom_manifest))
+	if err == nil {
+		err = json.Unmarshal(data, &manifest)
+	}
+	if err != nil {
+		return testROMManifest{}, nil, err
+	}
+
+	configs := make([]string, 0, len(manifest.Configs))
+	for name := range manifest.Configs {
+		configs = append(configs, name)
+	}
+	slices.Sort(configs)
+
+	return manifest, configs, nil
+
+}
+
+// runTestROM runs a test ROM in a configuration and returns its result, with a description
+// of what went wrong when it did not pass.
+func runTestROM(dir string, rom testROM, name string, config testROMConfig, update bool) (string, string) {
+
+	if len(rom.Configs) > 0 && !slices.Contains(rom.Configs, name) {
+		return test_not_run, ""
+	}
+
+	path := filepath.Join(dir, rom.ROM)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return test_missing, "ROM not found, see the README for where to get it"
+	}
+	if err != nil {
+		return test_run_failed, err.Error()
+	}
+
+	chip, err := newTestChip(config)
+	if err != nil {
+		return test_run_failed, err.Error()
+	}
+	if err := chip.LoadNamedROM(path, data); err != nil {
+		return test_run_failed, err.Error()
+	}
+	for _, memory := range []map[string]uint8{config.Memory, rom.Memory} {
+		if err := pokeMemory(chip, memory); err != nil {
+			return test_run_failed, err.Error()
+		}
+	}
+
+	if err := chip.RunCycles(rom.Cycles); err != nil {
+		return test_run_failed, err.Error()
+	}
+
+	frame := chip.Display()
+	got := frame.Text()
+	golden := filepath.Join(dir, "golden", strings.TrimSuffix(rom.ROM, filepath.Ext(rom.ROM))+"."+name+".txt")
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			return test_run_failed, err.Error()
+		}
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			return test_run_failed, err.Error()
+		}
+		return test_updated, ""
+	}
+
+	want, err := os.ReadFile(golden)
+	if errors.Is(err, fs.ErrNotExist) {
+		return test_no_golden, "no golden display, check the display with run -headless -dump - and write it with -update"
+	}
+	if err != nil {
+		return test_run_failed, err.Error()
+	}
+
+	if diff := chip8.DiffFrameText(string(want), got); diff != nil {
+		return test_fail, "display does not match " + golden + ":\n" + strings.Join(diff, "\n")
+	}
+
+	return test_pass, ""
+
+}
+
+// newTestChip returns a machine set up for a configuration, with a fixed seed so the runs are
+// reproducible.
+func newTestChip(config testROMConfig) (*chip8.Chip8, error) {
+
+	chip := chip8.New()
+	chip.SetSeed(1)
+
+	if config.Quirks != "" {
+		q, err := chip8.QuirksPreset(config.Quirks)
+		if err != nil {
+			return nil, err
+		}
+		chip.SetQuirks(q)
+	}
+
+	if config.Platform != "" {
+		p, err := chip8.ParsePlatform(config.Platform)
+		if err != nil {
+			return nil, err
+		}
+		chip.SetPlatform(p)
+	}
+
+	return chip, nil
+
+}
+
+// pokeMemory writes bytes keyed by address, "0x1FF" or "511", into memory.
+func pokeMemory(chip *chip8.Chip8, memory map[string]uint8) error {
+
+	for addr, value := range memory {
+		a, err := strconv.ParseInt(addr, 0, 32)
+		if err != nil {
+			return fmt.Errorf("invalid address %q", addr)
+		}
+		if err := chip.WriteMemory(int(a), value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// printTestMatrix prints the results as a table, a row per ROM and a column per configuration.
+func printTestMatrix(roms []testROM, configs []string, results [][]string) {
+
+	width := len("ROM")
+	for _, rom := range roms {
+		width = max(width, len(rom.ROM))
+	}
+
+	row := func(first string, cells []string) {
+		line := fmt.Sprintf("%-*s", width, first)
+		for _, cell := range cells {
+			line += fmt.Sprintf("  %-10s", cell)
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+
+	row("ROM", configs)
+	for i, rom := range roms {
+		row(rom.ROM, results[i])
+	}
+
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+// TestROMs runs the matrix of testroms/testroms.json like the testroms command. The ROMs are not
+// part of the repository, the runs of those missing from testroms/ are skipped.
+func TestROMs(t *testing.T) {
+
+	manifest, configs, err := readTestROMManifest("testroms")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rom := range manifest.ROMs {
+		for _, name := range configs {
+			t.Run(rom.ROM+"/"+name, func(t *testing.T) {
+
+				result, detail := runTestROM("testroms", rom, name, manifest.Configs[name], false)
+				switch result {
+				case test_pass:
+				case test_not_run:
+					t.Skip("not run in this configuration")
+				case test_missing:
+					t.Skip(detail)
+				default:
+					t.Errorf("%s: %s", result, detail)
+				}
+
+			})
+		}
+	}
+
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"chip8-go/chip8"
+)
+
+// Instructions kept for the crash report when the program fails, and how many of them are
+// printed, the crash file getting them all.
+const (
+	crash_trace_size  = 1000
+	crash_trace_shown = 20
+)
+
+// startTrace logs every executed instruction matched by filter to path, or to stderr for "-".
+// The returned function flushes and closes the log.
+func startTrace(chip *chip8.Chip8, path string, filter chip8.TraceFilter) (func() error, error) {
+
+	var w io.Writer = os.Stderr
+	closer := func() error { return nil }
+
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		w, closer = f, f.Close
+	}
+
+	buf := bufio.NewWriter(w)
+
+	chip.OnTrace = func(entry chip8.TraceEntry) {
+		if filter.Match(entry) {
+			fmt.Fprintln(buf, entry)
+		}
+	}
+
+	return func() error {
+		chip.OnTrace = nil
+		err := buf.Flush()
+		if cerr := closer(); err == nil {
+			err = cerr
+		}
+		return err
+	}, nil
+
+}
+
+// reportCrash prints the post-mortem of a program that failed with err to stderr: the
+// faulting instruction, the registers, the stack and the last instructions executed. With a
+// crash file, the whole report is written there too, every traced instruction included.
+func reportCrash(chip *chip8.Chip8, err error, crash_file string) {
+
+	report := chip.NewCrashReport(err)
+	fmt.Fprint(os.Stderr, report.Format(crash_trace_shown))
+
+	if crash_file == "" {
+		return
+	}
+	if err := os.WriteFile(crash_file, []byte(report.String()), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "could not write the crash report:", err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, "crash report written to", crash_file)
+
+}